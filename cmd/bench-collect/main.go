@@ -0,0 +1,193 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grant/sn42/pkg/collect"
+	"github.com/grant/sn42/pkg/pipeline"
+	"github.com/grant/sn42/pkg/sample"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// defaultStages is benchmarked when no processing flags are given: every
+// stage that runs locally
+var defaultStages = []string{
+	"-normalize", "nfkc,strip-zero-width,fold-confusables",
+	"-clean", "strip-urls,collapse-whitespace,remove-control,emoji-aliases",
+	"-dedup", "text",
+}
+
+func main() {
+	n := flag.Int("n", 100000, "Number of synthetic tweets to push through the pipeline")
+	batchSize := flag.Int("batch", collect.APIMaxResults, "Tweets per synthetic batch")
+	dupRate := flag.Float64("dup-rate", 0.1, "Fraction of tweets that repeat an earlier text, to exercise dedup")
+	seed := sample.RegisterSeedFlag(flag.CommandLine)
+	pipeFlags := pipeline.RegisterFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: bench-collect [-n tweets] [-batch size] [-dup-rate fraction] [processing flags]\n\n")
+		fmt.Fprintf(os.Stderr, "Without processing flags, benchmarks: %s\n\n", strings.Join(defaultStages, " "))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *n <= 0 || *batchSize <= 0 || *dupRate < 0 || *dupRate >= 1 {
+		log.Fatal("-n and -batch must be positive and -dup-rate in [0, 1)")
+	}
+	if !pipelineFlagSet() {
+		if err := flag.CommandLine.Parse(append(defaultStages, os.Args[1:]...)); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	pipe, err := pipeFlags.Build()
+	if err != nil {
+		log.Fatalf("Failed to configure processing: %v", err)
+	}
+	defer pipe.Close()
+
+	// Wrap every stage so its time and record counts are measured
+	timed := make([]*timedStage, len(pipe))
+	for i, stage := range pipe {
+		timed[i] = &timedStage{Stage: stage}
+		pipe[i] = timed[i]
+	}
+
+	v := seed.Value()
+	source := &mockSource{rng: sample.NewRand(v), dupRate: *dupRate, nextID: 1 << 40}
+	fmt.Printf("Benchmarking %d synthetic tweets in batches of %d (seed %d, dup rate %.2f)\n", *n, *batchSize, v, *dupRate)
+	fmt.Printf("Processing: %s\n\n", strings.Join(pipe.Names(), " -> "))
+
+	var fetchTime time.Duration
+	kept := 0
+	start := time.Now()
+	for fetched := 0; fetched < *n; {
+		t := time.Now()
+		batch := source.batch(min(*batchSize, *n-fetched))
+		fetchTime += time.Since(t)
+		fetched += len(batch)
+
+		out, err := pipe.Process(batch)
+		if err != nil {
+			log.Fatalf("Processing failed: %v", err)
+		}
+		kept += len(out)
+	}
+	total := time.Since(start)
+
+	width := len("fetch (mock)")
+	for _, s := range timed {
+		width = max(width, len(s.Name()))
+	}
+	fmt.Printf("%-*s %9s %9s %12s %12s\n", width, "stage", "in", "out", "time", "tweets/sec")
+	fmt.Printf("%-*s %9s %9d %12s %12s\n", width, "fetch (mock)", "-", *n, round(fetchTime), rate(*n, fetchTime))
+	for _, s := range timed {
+		fmt.Printf("%-*s %9d %9d %12s %12s\n", width, s.Name(), s.in, s.out, round(s.elapsed), rate(s.in, s.elapsed))
+	}
+	fmt.Printf("%-*s %9d %9d %12s %12s\n", width, "total", *n, kept, round(total), rate(*n, total))
+}
+
+// pipelineFlagSet reports whether any processing flag was given
+func pipelineFlagSet() bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "normalize", "clean", "dedup", "moderate":
+			set = true
+		}
+	})
+	return set
+}
+
+// timedStage measures the time spent in a stage and the records through it
+type timedStage struct {
+	pipeline.Stage
+	in, out int
+	elapsed time.Duration
+}
+
+func (s *timedStage) Process(docs []types.Document) ([]types.Document, error) {
+	start := time.Now()
+	out, err := s.Stage.Process(docs)
+	s.elapsed += time.Since(start)
+	s.in += len(docs)
+	s.out += len(out)
+	return out, err
+}
+
+func rate(n int, d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+	return strconv.FormatFloat(float64(n)/d.Seconds(), 'f', 0, 64)
+}
+
+func round(d time.Duration) string {
+	return d.Round(time.Microsecond).String()
+}
+
+// mockSource generates tweet-like documents with the features the stages act
+// on: URLs, emoji, hashtags, irregular whitespace and repeated texts
+type mockSource struct {
+	rng     *rand.Rand
+	dupRate float64
+	nextID  int64
+	texts   []string // Earlier texts, reused for duplicates
+}
+
+var (
+	words   = strings.Fields("bitcoin price market crypto rally today new high bullish bearish chart looks strong weak trading volume halving ETF inflow whales buy sell hold")
+	extras  = []string{" https://t.co/abc123", " 🚀🚀", " #BTC", " @satoshi", "  \t ", " Ｂｉｔｃｏｉｎ", "\u200b", " 😂"}
+	langs   = []string{"en", "en", "en", "es", "pt", "ja"}
+	created = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func (m *mockSource) batch(size int) []types.Document {
+	docs := make([]types.Document, size)
+	for i := range docs {
+		text := m.text()
+		m.nextID--
+		docs[i] = types.Document{
+			Id:      strconv.FormatInt(m.nextID, 10),
+			Source:  "twitter",
+			Content: text,
+			Metadata: map[string]any{
+				"tweet_id":   m.nextID,
+				"username":   fmt.Sprintf("user%d", m.rng.IntN(5000)),
+				"lang":       langs[m.rng.IntN(len(langs))],
+				"created_at": created.Add(time.Duration(m.rng.IntN(30*24)) * time.Hour).Format(time.RFC3339),
+				"likes":      m.rng.IntN(5000),
+				"retweets":   m.rng.IntN(500),
+				"replies":    m.rng.IntN(100),
+			},
+		}
+	}
+	return docs
+}
+
+func (m *mockSource) text() string {
+	if len(m.texts) > 0 && m.rng.Float64() < m.dupRate {
+		return m.texts[m.rng.IntN(len(m.texts))]
+	}
+	var b strings.Builder
+	for range 8 + m.rng.IntN(25) {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(words[m.rng.IntN(len(words))])
+		if m.rng.IntN(6) == 0 {
+			b.WriteString(extras[m.rng.IntN(len(extras))])
+		}
+	}
+	text := b.String()
+	if len(m.texts) < 10000 {
+		m.texts = append(m.texts, text)
+	}
+	return text
+}
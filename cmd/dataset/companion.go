@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/grant/sn42/pkg/crypt"
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/manifest"
+	"github.com/grant/sn42/pkg/sample"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// topTweets returns the tweets of the dataset at path with at least
+// minEngagement likes, retweets and replies together, most engaged first,
+// keeping at most limit of them (0 for all), and the dataset's header
+func topTweets(path string, minEngagement float64, limit int) ([]types.Document, *dataset.File, error) {
+	var selected []types.Document
+	header, err := dataset.Scan(path, func(doc types.Document) error {
+		if sample.Engagement(doc) >= minEngagement {
+			selected = append(selected, doc)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	n := len(selected)
+	if limit > 0 {
+		n = min(n, limit)
+	}
+	return sample.Top(selected, n), header, nil
+}
+
+// companionPath returns the default path of a dataset collected from input:
+// input with its extension replaced by suffix, and encrypted if input is
+func companionPath(input, suffix string) string {
+	base := strings.TrimSuffix(input, crypt.Extension)
+	path := strings.TrimSuffix(base, filepath.Ext(base)) + suffix
+	if strings.HasSuffix(input, crypt.Extension) {
+		path += crypt.Extension
+	}
+	return path
+}
+
+// saveCompanion writes f to path, encrypted with ENCRYPTION_KEY if path ends
+// in crypt.Extension, and m as its manifest with the record count filled in
+func saveCompanion(path string, f *dataset.File, m *manifest.Manifest) error {
+	var key []byte
+	if strings.HasSuffix(path, crypt.Extension) {
+		var err error
+		if key, err = crypt.KeyFromEnv(); err != nil {
+			return fmt.Errorf("failed to load encryption key: %w", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := f.Save(path, key); err != nil {
+		return err
+	}
+	m.Records = f.TotalTweets
+	if _, err := manifest.Write(path, m); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
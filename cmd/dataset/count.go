@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/manifest"
+)
+
+func runCount(args []string) {
+	flags := flag.NewFlagSet("count", flag.ExitOnError)
+	verify := flags.Bool("verify", false, "Check each count against the records in the file's manifest, failing on a mismatch")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dataset count [-verify] <dataset or dir>...\n\nPrints the number of records per file, per directory and in total, without decoding the records.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	var total int64
+	files, mismatched := 0, 0
+	for _, arg := range flags.Args() {
+		paths, err := dataset.Files(arg)
+		if err != nil {
+			log.Fatalf("Failed to list datasets: %v", err)
+		}
+		var sum int64
+		for _, path := range paths {
+			n, format, err := dataset.Count(path)
+			if err != nil {
+				log.Fatalf("Failed to count records: %v", err)
+			}
+			sum += n
+			if !*verify {
+				fmt.Printf("%12d  %-8s %s\n", n, format, path)
+				continue
+			}
+			m, err := manifest.Load(path)
+			switch {
+			case errors.Is(err, fs.ErrNotExist):
+				fmt.Printf("%12d  %-8s %s (no manifest)\n", n, format, path)
+			case err != nil:
+				log.Fatalf("Failed to load manifest for %s: %v", path, err)
+			case int64(m.Records) == n:
+				fmt.Printf("%12d  %-8s %s ✅\n", n, format, path)
+			default:
+				fmt.Printf("%12d  %-8s %s ❌ manifest says %d\n", n, format, path, m.Records)
+				mismatched++
+			}
+		}
+		// Directories get a subtotal over their files
+		if info, err := os.Stat(arg); err == nil && info.IsDir() {
+			fmt.Printf("%12d  %-8s %s (%d files)\n", sum, "total", arg, len(paths))
+		}
+		total += sum
+		files += len(paths)
+	}
+	fmt.Printf("✅ %d records in %d files\n", total, files)
+	if mismatched > 0 {
+		fmt.Fprintf(os.Stderr, "❌ %d files do not match their manifest\n", mismatched)
+		os.Exit(1)
+	}
+}
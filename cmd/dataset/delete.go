@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/signing"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// auditEntry is one line of a deletion audit log. It names the deleted
+// tweet but none of its content.
+type auditEntry struct {
+	DeletedAt string `json:"deleted_at"`
+	Request   string `json:"request,omitempty"`
+	Dataset   string `json:"dataset"`
+	TweetID   string `json:"tweet_id"`
+	User      string `json:"user,omitempty"`   // The -users entry that matched
+	Reason    string `json:"reason,omitempty"` // Why the tweet was removed otherwise
+}
+
+// users matches records by author ID or handle
+type users struct {
+	ids     map[string]string // user_id/author_id -> entry
+	handles map[string]string // Lowercase username -> entry
+}
+
+// parseUsers reads entries such as "@handle", "handle" or "12345". A bare
+// number matches an author ID or a handle.
+func parseUsers(entries []string) users {
+	u := users{ids: map[string]string{}, handles: map[string]string{}}
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		if e == "" || strings.HasPrefix(e, "#") {
+			continue
+		}
+		if handle, ok := strings.CutPrefix(e, "@"); ok {
+			u.handles[strings.ToLower(handle)] = e
+			continue
+		}
+		u.handles[strings.ToLower(e)] = e
+		if strings.Trim(e, "0123456789") == "" {
+			u.ids[e] = e
+		}
+	}
+	return u
+}
+
+func (u users) len() int {
+	return len(u.ids) + len(u.handles)
+}
+
+// match returns the entry matching the record's author, if any
+func (u users) match(doc types.Document) (string, bool) {
+	for _, key := range []string{"user_id", "author_id"} {
+		if v, ok := doc.Metadata[key]; ok && v != nil {
+			if e, ok := u.ids[fmt.Sprint(v)]; ok {
+				return e, true
+			}
+		}
+	}
+	if name, ok := doc.Metadata["username"].(string); ok {
+		e, ok := u.handles[strings.ToLower(strings.TrimPrefix(name, "@"))]
+		return e, ok
+	}
+	return "", false
+}
+
+func runDeleteUsers(args []string) {
+	flags := flag.NewFlagSet("delete-users", flag.ExitOnError)
+	list := flags.String("users", "", "Comma-separated author IDs or @handles to delete")
+	listFile := flags.String("users-file", "", "File with one author ID or @handle per line")
+	auditPath := flags.String("audit", "data/deletions.audit.jsonl", "Append a line per deleted tweet to this audit log")
+	request := flags.String("request", "", "Reference of the deletion request, recorded in the audit log")
+	dryRun := flags.Bool("dry-run", false, "Report what would be deleted without changing any file")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dataset delete-users (-users @a,123 | -users-file users.txt) [-request ID] [-dry-run] <dataset or dir>...\n\n")
+		fmt.Fprintf(os.Stderr, "Removes every tweet by the given authors from the datasets in place, updates their\nmanifests and appends the deletions to an audit log.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 || (*list == "" && *listFile == "") {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	entries := strings.Split(*list, ",")
+	if *listFile != "" {
+		data, err := os.ReadFile(*listFile)
+		if err != nil {
+			log.Fatalf("Failed to read users file: %v", err)
+		}
+		entries = append(entries, strings.Split(string(data), "\n")...)
+	}
+	u := parseUsers(entries)
+	if u.len() == 0 {
+		log.Fatal("No users to delete")
+	}
+
+	files, err := dataset.Files(flags.Args()...)
+	if err != nil {
+		log.Fatalf("Failed to list datasets: %v", err)
+	}
+
+	var audit *json.Encoder
+	if !*dryRun {
+		if err := os.MkdirAll(filepath.Dir(*auditPath), 0755); err != nil {
+			log.Fatalf("Failed to create audit log directory: %v", err)
+		}
+		f, err := os.OpenFile(*auditPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Fatalf("Failed to open audit log: %v", err)
+		}
+		defer f.Close()
+		audit = json.NewEncoder(f)
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	removed, changed, stale := 0, 0, 0
+	for _, path := range files {
+		var deleted []auditEntry
+		drop := func(doc *types.Document) (bool, bool) {
+			e, ok := u.match(*doc)
+			if ok {
+				deleted = append(deleted, auditEntry{DeletedAt: now, Request: *request, Dataset: path, TweetID: doc.Id, User: e})
+			}
+			return !ok, false
+		}
+
+		var kept int
+		var err error
+		rewritable := canRewrite(path)
+		if *dryRun || !rewritable {
+			kept, err = countKept(path, drop)
+		} else {
+			kept, _, err = rewrite(path, drop)
+		}
+		if err != nil {
+			log.Fatalf("Failed to delete from %s: %v", path, err)
+		}
+		if len(deleted) == 0 {
+			continue
+		}
+
+		switch {
+		case !rewritable:
+			// Exports and compressed files are regenerated from their source instead
+			fmt.Printf("⚠️  %s: %d matching tweets, but this format cannot be rewritten; regenerate it from the cleaned dataset\n", path, len(deleted))
+			stale++
+			continue
+		case *dryRun:
+			fmt.Printf("🔎 %s: would delete %d tweets (%d left)\n", path, len(deleted), kept)
+		default:
+			for _, e := range deleted {
+				if err := audit.Encode(e); err != nil {
+					log.Fatalf("Failed to write audit log: %v", err)
+				}
+			}
+			if err := updateManifest(path, kept, fmt.Sprintf("delete_users(removed=%d)", len(deleted))); err != nil {
+				log.Fatalf("Failed to update manifest for %s: %v", path, err)
+			}
+			fmt.Printf("🗑️  %s: deleted %d tweets (%d left)\n", path, len(deleted), kept)
+			if _, err := os.Stat(path + signing.Extension); err == nil {
+				fmt.Printf("⚠️  %s%s no longer matches the file; sign it again\n", path, signing.Extension)
+			}
+		}
+		removed += len(deleted)
+		changed++
+	}
+
+	if *dryRun {
+		fmt.Printf("✅ Would delete %d tweets from %d of %d files\n", removed, changed, len(files))
+	} else {
+		fmt.Printf("✅ Deleted %d tweets from %d of %d files (audit log: %s)\n", removed, changed, len(files), *auditPath)
+	}
+	if stale > 0 {
+		fmt.Fprintf(os.Stderr, "❌ %d files still contain tweets by these users\n", stale)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/grant/sn42/pkg/expr"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// selectFields marshals the given fields of a record as a JSON object in the
+// order listed, with null for missing ones
+func selectFields(doc types.Document, fields []string) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, name := range fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		v, _ := expr.Field(doc, name)
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// parseFields splits a comma-separated -fields value
+func parseFields(s string) []string {
+	var fields []string
+	for f := range strings.SplitSeq(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+func runGrep(args []string) {
+	fs := flag.NewFlagSet("grep", flag.ExitOnError)
+	ignoreCase := fs.Bool("i", false, "Match case-insensitively")
+	fixed := fs.Bool("F", false, "Match the pattern as a plain substring instead of a regular expression")
+	countOnly := fs.Bool("c", false, "Print only the number of matching tweets per file")
+	fields := fs.String("fields", "", "Comma-separated fields to print instead of whole records")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dataset grep [-i] [-F] [-c] [-fields id,content,...] <pattern> <dataset or dir>...\n\n")
+		fmt.Fprintf(os.Stderr, "Prints the tweets whose text matches the pattern as JSON lines, with counts per file.\nDirectories are searched for every dataset file in them.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	pattern := fs.Arg(0)
+	if *fixed {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if *ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		log.Fatalf("Invalid pattern: %v", err)
+	}
+	files, err := dataset.Files(fs.Args()[1:]...)
+	if err != nil {
+		log.Fatalf("Failed to list datasets: %v", err)
+	}
+	if len(files) == 0 {
+		log.Fatal("No dataset files found")
+	}
+
+	// Records go to stdout and counts to stderr, unless only counts are wanted
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	counts := bufio.NewWriter(os.Stderr)
+	if *countOnly {
+		counts = w
+	}
+	defer counts.Flush()
+
+	matched, searched := 0, 0
+	for _, path := range files {
+		n, total := 0, 0
+		it, err := dataset.OpenDataset(path, dataset.Filter{Match: func(doc types.Document) bool {
+			total++
+			return re.MatchString(doc.Content)
+		}})
+		if err != nil {
+			w.Flush()
+			log.Fatalf("Failed to read dataset: %v", err)
+		}
+		for doc, err := range it.All() {
+			if err == nil && !*countOnly {
+				err = writeRecord(w, doc, parseFields(*fields))
+			}
+			if err != nil {
+				w.Flush()
+				log.Fatalf("Failed to search %s: %v", path, err)
+			}
+			n++
+		}
+		it.Close()
+		fmt.Fprintf(counts, "%s: %d of %d\n", path, n, total)
+		matched += n
+		searched += total
+	}
+	fmt.Fprintf(counts, "✅ %d of %d tweets in %d files match\n", matched, searched, len(files))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/grant/sn42/pkg/dataset"
+)
+
+func runHead(args []string) {
+	fs := flag.NewFlagSet("head", flag.ExitOnError)
+	n := fs.Int("n", 10, "Number of records to print per file")
+	fields := fs.String("fields", "", "Comma-separated fields to print, e.g. id,content,metadata.likes (default: whole records)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dataset head [-n 10] [-fields id,content,...] <dataset>...\n\nPrints the first records of each file as JSON lines.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	for i, path := range fs.Args() {
+		// Name each file when there are several, as head(1) does
+		if fs.NArg() > 1 {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "==> %s <==\n", path)
+		}
+		if err := printRecords(w, path, *n, parseFields(*fields)); err != nil {
+			w.Flush()
+			log.Fatalf("Failed to read dataset: %v", err)
+		}
+	}
+}
+
+func runCat(args []string) {
+	fs := flag.NewFlagSet("cat", flag.ExitOnError)
+	fields := fs.String("fields", "", "Comma-separated fields to print, e.g. id,content,metadata.likes (default: whole records)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dataset cat [-fields id,content,...] <dataset>...\n\nPrints every record of the files as JSON lines, e.g. to convert a dataset to JSONL.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	for _, path := range fs.Args() {
+		if err := printRecords(w, path, -1, parseFields(*fields)); err != nil {
+			w.Flush()
+			log.Fatalf("Failed to read dataset: %v", err)
+		}
+	}
+}
+
+// printRecords writes up to limit records of a file (all if negative) to w,
+// one JSON object per line
+func printRecords(w io.Writer, path string, limit int, fields []string) error {
+	it, err := dataset.OpenDataset(path)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+	printed := 0
+	for doc, err := range it.All() {
+		if limit >= 0 && printed >= limit {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if err := writeRecord(w, doc, fields); err != nil {
+			return err
+		}
+		printed++
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/linking"
+	"github.com/grant/sn42/pkg/manifest"
+	"github.com/grant/sn42/pkg/sink"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+func runLink(args []string) {
+	fs := flag.NewFlagSet("link", flag.ExitOnError)
+	output := fs.String("o", "", "Write the tagged records to this dataset file, with a manifest (required)")
+	minSources := fs.Int("min-sources", 2, "Link entities found in at least this many sources")
+	entitiesPath := fs.String("entities", "", "Also write the linked entities, with their record counts per source, to this JSON file")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dataset link -o output.json [-min-sources 2] [-entities entities.json] <dataset or dir>...\n\n")
+		fmt.Fprintf(os.Stderr, "Tags records with the IDs of the links, hashtags and topics they share with records from other sources.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 || *output == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	inputs, err := dataset.Files(fs.Args()...)
+	if err != nil {
+		log.Fatalf("Failed to list datasets: %v", err)
+	}
+
+	// The first pass finds the entities shared across sources, the second tags
+	// the records containing them
+	index := linking.NewIndex()
+	if err := eachRecord(inputs, func(doc types.Document) error {
+		index.Add(doc)
+		return nil
+	}); err != nil {
+		log.Fatalf("Failed to read dataset: %v", err)
+	}
+	linked := index.Linked(*minSources)
+	fmt.Printf("🔗 %d entities appear in %d or more sources\n", len(linked), *minSources)
+
+	writer, err := dataset.NewWriter(*output, dataset.File{})
+	if err != nil {
+		log.Fatalf("Failed to create output: %v", err)
+	}
+	tagger := linking.NewTagger(linked)
+	tagged := 0
+	err = eachRecord(inputs, func(doc types.Document) error {
+		if tagger.Tag(&doc) {
+			tagged++
+		}
+		return writer.Write(context.Background(), sink.Batch{Docs: []types.Document{doc}})
+	})
+	if err != nil {
+		writer.Discard()
+		log.Fatalf("Failed to tag records: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		log.Fatalf("Failed to save dataset: %v", err)
+	}
+	if _, err := manifest.Write(*output, &manifest.Manifest{
+		Tool:       "dataset link",
+		Records:    writer.Count(),
+		Pipeline:   []string{fmt.Sprintf("link_entities(min_sources=%d,linked=%d)", *minSources, len(linked))},
+		Provenance: manifest.ProvenanceFromEnv(),
+	}); err != nil {
+		log.Fatalf("Failed to write manifest: %v", err)
+	}
+
+	if *entitiesPath != "" {
+		data, err := json.MarshalIndent(linked, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to marshal entities: %v", err)
+		}
+		if err := os.WriteFile(*entitiesPath, append(data, '\n'), 0644); err != nil {
+			log.Fatalf("Failed to write entities: %v", err)
+		}
+	}
+	fmt.Printf("✅ Tagged %d of %d records from %d datasets to %s\n", tagged, writer.Count(), len(inputs), *output)
+}
+
+// eachRecord calls fn with every record of the files in order
+func eachRecord(files []string, fn func(types.Document) error) error {
+	for _, path := range files {
+		it, err := dataset.OpenDataset(path)
+		if err != nil {
+			return err
+		}
+		for doc, err := range it.All() {
+			if err == nil {
+				err = fn(doc)
+			}
+			if err != nil {
+				it.Close()
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		it.Close()
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+// Command dataset inspects dataset files in any format dataset.OpenDataset
+// reads: collector JSON, JSONL, Arrow and Parquet, compressed or encrypted.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/grant/sn42/pkg/dataset"
+)
+
+// command is a dataset subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(args []string)
+}
+
+var commands = []command{
+	{"head", "Print the first records of each file", runHead},
+	{"cat", "Print every record of the files", runCat},
+	{"query", "Stream the records matching an expression", runQuery},
+	{"grep", "Search tweet text across files and directories", runGrep},
+	{"count", "Count the records per file without decoding them", runCount},
+	{"schema", "Infer the fields and types of the records", runSchema},
+	{"unify", "Write records from any source in the unified multi-platform schema", runUnify},
+	{"link", "Tag records with the links, hashtags and topics shared across sources", runLink},
+	{"top", "List the most frequent hashtags, mentions, authors and link domains", runTop},
+	{"delete-users", "Delete every tweet by the given authors, with an audit log", runDeleteUsers},
+	{"quotes", "Collect the quote tweets of the most engaged tweets into a companion dataset", runQuotes},
+	{"retweeters", "Collect the accounts retweeting the most engaged tweets into an audience dataset", runRetweeters},
+	{"users", "Look up the current profiles of the authors into a users table", runUsers},
+	{"recheck", "Remove or flag tweets deleted or protected since collection", runRecheck},
+	{"sort", "Write the records to a new file ordered by time, tweet ID or engagement", runSort},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	name := os.Args[1]
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		dataset.LoadEnv()
+		cmd.run(os.Args[2:])
+		return
+	}
+	if name != "-h" && name != "-help" && name != "help" {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+	}
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: dataset <command> [flags] <dataset>...\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun \"dataset <command> -h\" for the flags of a command.\n")
+}
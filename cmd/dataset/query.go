@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/expr"
+	"github.com/grant/sn42/pkg/manifest"
+	"github.com/grant/sn42/pkg/sink"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+func runQuery(args []string) {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	output := fs.String("o", "", "Write matching records to this dataset file, with a manifest (default: print them as JSON lines)")
+	fields := fs.String("fields", "", "Comma-separated fields to print instead of whole records (without -o)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dataset query [-o output.json] [-fields id,content,...] '<expression>' <dataset or dir>...\n\n")
+		fmt.Fprintf(os.Stderr, "Streams the records matching the expression, e.g. 'likes > 500 && lang == \"en\"'.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *output != "" && *fields != "" {
+		log.Fatal("-fields cannot be used with -o")
+	}
+	source := fs.Arg(0)
+	e, err := expr.Parse(source)
+	if err != nil {
+		log.Fatalf("Invalid expression: %v", err)
+	}
+	filter := e.Filter()
+
+	// Matches go to a dataset file, or to stdout with the summary on stderr
+	var emit func(types.Document) error
+	var writer *dataset.Writer
+	status := os.Stdout
+	if *output != "" {
+		if writer, err = dataset.NewWriter(*output, dataset.File{}); err != nil {
+			log.Fatalf("Failed to create output: %v", err)
+		}
+		emit = func(doc types.Document) error {
+			return writer.Write(context.Background(), sink.Batch{Docs: []types.Document{doc}})
+		}
+	} else {
+		w := bufio.NewWriter(os.Stdout)
+		defer w.Flush()
+		emit = func(doc types.Document) error { return writeRecord(w, doc, parseFields(*fields)) }
+		status = os.Stderr
+	}
+
+	fail := func(format string, args ...any) {
+		if writer != nil {
+			writer.Discard()
+		}
+		log.Fatalf(format, args...)
+	}
+
+	inputs, err := dataset.Files(fs.Args()[1:]...)
+	if err != nil {
+		fail("%v", err)
+	}
+	matched := 0
+	var first *dataset.File
+	collectedAt := ""
+	for _, path := range inputs {
+		it, err := dataset.OpenDataset(path, filter)
+		if err != nil {
+			fail("Failed to read dataset: %v", err)
+		}
+		for doc, err := range it.All() {
+			if err == nil {
+				err = emit(doc)
+			}
+			if err != nil {
+				fail("Failed to query %s: %v", path, err)
+			}
+			matched++
+		}
+		if header := it.Header(); header != nil {
+			if first == nil {
+				first = header
+			}
+			collectedAt = max(collectedAt, header.CollectedAt)
+		}
+		it.Close()
+	}
+
+	if writer != nil {
+		// Keep the inputs' collected_at so reruns are byte-identical
+		header := writer.Header()
+		header.CollectedAt = collectedAt
+		if len(inputs) == 1 && first != nil {
+			header.Trend, header.Query, header.SavedQuery = first.Trend, first.Query, first.SavedQuery
+		}
+		if err := writer.Close(); err != nil {
+			log.Fatalf("Failed to save dataset: %v", err)
+		}
+		if _, err := manifest.Write(*output, &manifest.Manifest{
+			Tool:       "dataset query",
+			Query:      header.Query,
+			Trend:      header.Trend,
+			Records:    writer.Count(),
+			Pipeline:   []string{"query(" + source + ")"},
+			Provenance: manifest.ProvenanceFromEnv(),
+		}); err != nil {
+			log.Fatalf("Failed to write manifest: %v", err)
+		}
+		fmt.Fprintf(status, "✅ Matched %d records from %d datasets to %s\n", matched, len(inputs), *output)
+		return
+	}
+	fmt.Fprintf(status, "✅ Matched %d records from %d datasets\n", matched, len(inputs))
+}
+
+// writeRecord writes a record, or the given fields of it, as a JSON line
+func writeRecord(w io.Writer, doc types.Document, fields []string) error {
+	var line []byte
+	var err error
+	if len(fields) > 0 {
+		line, err = selectFields(doc, fields)
+	} else {
+		line, err = json.Marshal(doc)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", line)
+	return err
+}
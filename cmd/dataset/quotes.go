@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/grant/sn42/pkg/apiclient"
+	"github.com/grant/sn42/pkg/collect"
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/manifest"
+	"github.com/grant/sn42/pkg/sample"
+	"github.com/grant/sn42/pkg/twitterquery"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+func runQuotes(args []string) {
+	flags := flag.NewFlagSet("quotes", flag.ExitOnError)
+	output := flags.String("o", "", "Write the quote tweets to this dataset (default: <dataset>.quotes.json, or .quotes.json.enc for an encrypted dataset)")
+	minEngagement := flags.Float64("min-engagement", 100, "Only collect quotes of tweets with at least this many likes, retweets and replies together")
+	limit := flags.Int("limit", 100, "Collect quotes of at most this many tweets, the most engaged first (0 = all above -min-engagement)")
+	perTweet := flags.Int("per-tweet", 100, "Collect at most this many quotes of each tweet")
+	retries := flags.Int("retries", 2, "Retry a failed request this many times")
+	retryDelay := flags.Duration("retry-delay", 5*time.Second, "Wait before the first retry, doubling each time")
+	apiFlags := apiclient.RegisterFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dataset quotes [-min-engagement 100] [-limit 100] [-per-tweet 100] [-o quotes.json] <dataset>\n\n")
+		fmt.Fprintf(os.Stderr, "Collects the quote tweets of the most engaged tweets in the dataset into a companion\ndataset, recording the quoted tweet's ID as quoted_id in each record's metadata.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *limit < 0 || *perTweet <= 0 {
+		log.Fatal("-limit must not be negative and -per-tweet must be positive")
+	}
+	input := flags.Arg(0)
+	outPath := *output
+	if outPath == "" {
+		outPath = companionPath(input, ".quotes.json")
+	}
+
+	// Select the tweets whose quotes are worth collecting
+	selected, header, err := topTweets(input, *minEngagement, *limit)
+	if err != nil {
+		log.Fatalf("Failed to read dataset: %v", err)
+	}
+	if len(selected) == 0 {
+		log.Fatalf("No tweets in %s have an engagement of at least %g", input, *minEngagement)
+	}
+
+	c, err := apiFlags.New()
+	if err != nil {
+		log.Fatalf("Failed to create client from config: %v\nMake sure GOPHER_CLIENT_TOKEN is set in your .env file", err)
+	}
+	if c.Token == "" {
+		log.Fatal("GOPHER_CLIENT_TOKEN is not set. Please set it in your .env file")
+	}
+	collector := &collect.Collector{Client: c, Retries: *retries, RetryDelay: *retryDelay}
+
+	fmt.Printf("🔁 Collecting quotes of %d tweets from %s...\n", len(selected), input)
+	var quotes []types.Document
+	seen := map[string]bool{}
+	failed := 0
+	for i, src := range selected {
+		id, err := collect.TweetID(src)
+		if err != nil {
+			fmt.Printf("⚠️  Skipping a tweet without an ID: %v\n", err)
+			continue
+		}
+		query, err := twitterquery.New().QuotedTweet(id).Build()
+		if err != nil {
+			log.Fatal(err)
+		}
+		found, err := collector.Collect(context.Background(), query, *perTweet)
+		if err != nil {
+			fmt.Printf("⚠️  %d/%d: failed to collect quotes of %d: %v\n", i+1, len(selected), id, err)
+			failed++
+		}
+		added := 0
+		for _, q := range found {
+			// A quote is kept once, linked to the first tweet it was found for
+			if q.Id == "" || seen[q.Id] || q.Id == src.Id {
+				continue
+			}
+			seen[q.Id] = true
+			if q.Metadata == nil {
+				q.Metadata = map[string]any{}
+			}
+			q.Metadata["quoted_id"] = src.Id
+			quotes = append(quotes, q)
+			added++
+		}
+		fmt.Printf("  %d/%d: %d quotes of %d (engagement %g)\n", i+1, len(selected), added, id, sample.Engagement(src))
+	}
+
+	out := &dataset.File{Query: "quoted_tweet_id:<id>", Trend: header.Trend, Tweets: quotes}
+	err = saveCompanion(outPath, out, &manifest.Manifest{
+		Tool:       "dataset quotes",
+		Query:      out.Query,
+		Trend:      header.Trend,
+		Pipeline:   []string{fmt.Sprintf("quotes(of=%s,min_engagement=%g,tweets=%d,per_tweet=%d)", filepath.Base(input), *minEngagement, len(selected), *perTweet)},
+		Provenance: manifest.ProvenanceFromEnv(),
+	})
+	if err != nil {
+		log.Fatalf("Failed to save quotes: %v", err)
+	}
+
+	fmt.Printf("✅ Collected %d quote tweets of %d tweets to %s\n", out.TotalTweets, len(selected), outPath)
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "❌ Collecting quotes failed for %d of %d tweets\n", failed, len(selected))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/grant/sn42/pkg/apiclient"
+	"github.com/grant/sn42/pkg/collect"
+	"github.com/grant/sn42/pkg/compliance"
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/signing"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Recheck modes
+const (
+	recheckRemove = "remove"
+	recheckFlag   = "flag"
+)
+
+func runRecheck(args []string) {
+	flags := flag.NewFlagSet("recheck", flag.ExitOnError)
+	mode := flags.String("mode", recheckRemove, "\"remove\" drops unavailable tweets; \"flag\" keeps every tweet and records compliance_status and compliance_checked_at in its metadata")
+	concurrency := flags.Int("concurrency", 4, "Parallel lookups")
+	retries := flags.Int("retries", 2, "Retry a failed lookup this many times before leaving the tweet in place")
+	retryDelay := flags.Duration("retry-delay", 5*time.Second, "Wait before the first retry, doubling each time")
+	auditPath := flags.String("audit", "data/deletions.audit.jsonl", "Append a line per removed tweet to this audit log")
+	dryRun := flags.Bool("dry-run", false, "Look the tweets up and report, without changing any file")
+	apiFlags := apiclient.RegisterFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dataset recheck [-mode remove|flag] [-concurrency 4] [-dry-run] <dataset or dir>...\n\n")
+		fmt.Fprintf(os.Stderr, "Looks every tweet up again and removes or flags those that were deleted or made\nprotected since collection, updating manifests.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *mode != recheckRemove && *mode != recheckFlag {
+		log.Fatalf("Invalid -mode %q (expected remove or flag)", *mode)
+	}
+
+	files, err := dataset.Files(flags.Args()...)
+	if err != nil {
+		log.Fatalf("Failed to list datasets: %v", err)
+	}
+	ids, err := tweetIDs(files)
+	if err != nil {
+		log.Fatalf("Failed to read dataset: %v", err)
+	}
+	if len(ids) == 0 {
+		log.Fatal("No tweets to check")
+	}
+
+	c, err := apiFlags.New()
+	if err != nil {
+		log.Fatalf("Failed to create client from config: %v\nMake sure GOPHER_CLIENT_TOKEN is set in your .env file", err)
+	}
+	if c.Token == "" {
+		log.Fatal("GOPHER_CLIENT_TOKEN is not set. Please set it in your .env file")
+	}
+
+	// Look every tweet up once, however many files it is in
+	fmt.Printf("🔎 Re-checking %d tweets from %d files...\n", len(ids), len(files))
+	checker := &compliance.Checker{Lookup: collect.Lookup{Client: c, Concurrency: *concurrency, Retries: *retries, RetryDelay: *retryDelay}}
+	counts := map[compliance.Status]int{}
+	statuses := checker.Check(context.Background(), ids, func(done int, _ string, s compliance.Status) {
+		counts[s]++
+		if done%100 == 0 || done == len(ids) {
+			fmt.Printf("  %d/%d checked (%d unavailable)\n", done, len(ids), counts[compliance.Unavailable])
+		}
+	})
+	fmt.Printf("📊 %d available, %d unavailable, %d unknown\n", counts[compliance.Available], counts[compliance.Unavailable], counts[compliance.Unknown])
+
+	var audit *json.Encoder
+	if !*dryRun && *mode == recheckRemove && counts[compliance.Unavailable] > 0 {
+		if err := os.MkdirAll(filepath.Dir(*auditPath), 0755); err != nil {
+			log.Fatalf("Failed to create audit log directory: %v", err)
+		}
+		f, err := os.OpenFile(*auditPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Fatalf("Failed to open audit log: %v", err)
+		}
+		defer f.Close()
+		audit = json.NewEncoder(f)
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	affected, changed, stale := 0, 0, 0
+	for _, path := range files {
+		var unavailable []auditEntry
+		edit := func(doc *types.Document) (bool, bool) {
+			s, ok := statuses[doc.Id]
+			if !ok || s == compliance.Unknown {
+				return true, false
+			}
+			if s == compliance.Unavailable {
+				unavailable = append(unavailable, auditEntry{DeletedAt: now, Dataset: path, TweetID: doc.Id, Reason: "unavailable"})
+			}
+			if *mode == recheckRemove {
+				return s != compliance.Unavailable, false
+			}
+			if doc.Metadata == nil {
+				doc.Metadata = map[string]any{}
+			}
+			doc.Metadata["compliance_status"] = string(s)
+			doc.Metadata["compliance_checked_at"] = now
+			return true, true
+		}
+
+		var kept int
+		rewritable := canRewrite(path)
+		if *dryRun || !rewritable {
+			kept, err = countKept(path, edit)
+		} else {
+			kept, _, err = rewrite(path, edit)
+		}
+		if err != nil {
+			log.Fatalf("Failed to update %s: %v", path, err)
+		}
+		if len(unavailable) == 0 && (*mode == recheckRemove || *dryRun || !rewritable) {
+			continue
+		}
+
+		verb := "removed"
+		if *mode == recheckFlag {
+			verb = "flagged"
+		}
+		switch {
+		case !rewritable && *mode == recheckFlag:
+			fmt.Printf("⚠️  %s: %d unavailable tweets, but this format cannot be rewritten to flag them\n", path, len(unavailable))
+			continue
+		case !rewritable:
+			fmt.Printf("⚠️  %s: %d unavailable tweets, but this format cannot be rewritten; regenerate it from the cleaned dataset\n", path, len(unavailable))
+			stale++
+			continue
+		case *dryRun:
+			fmt.Printf("🔎 %s: would have %d tweets %s\n", path, len(unavailable), verb)
+		default:
+			if audit != nil {
+				for _, e := range unavailable {
+					if err := audit.Encode(e); err != nil {
+						log.Fatalf("Failed to write audit log: %v", err)
+					}
+				}
+			}
+			if err := updateManifest(path, kept, fmt.Sprintf("recheck(%s=%d)", verb, len(unavailable))); err != nil {
+				log.Fatalf("Failed to update manifest for %s: %v", path, err)
+			}
+			fmt.Printf("🧹 %s: %d unavailable tweets %s (%d left)\n", path, len(unavailable), verb, kept)
+			if _, err := os.Stat(path + signing.Extension); err == nil {
+				fmt.Printf("⚠️  %s%s no longer matches the file; sign it again\n", path, signing.Extension)
+			}
+		}
+		affected += len(unavailable)
+		changed++
+	}
+
+	switch {
+	case *dryRun:
+		fmt.Printf("✅ Dry run: %d unavailable tweets in %d of %d files\n", affected, changed, len(files))
+	case *mode == recheckFlag:
+		fmt.Printf("✅ Flagged %d unavailable tweets in %d of %d files\n", affected, changed, len(files))
+	default:
+		fmt.Printf("✅ Removed %d unavailable tweets from %d of %d files\n", affected, changed, len(files))
+	}
+	if stale > 0 {
+		fmt.Fprintf(os.Stderr, "❌ %d files still contain unavailable tweets\n", stale)
+		os.Exit(1)
+	}
+}
+
+// tweetIDs returns the distinct tweet IDs of the files in the order first seen
+func tweetIDs(files []string) ([]string, error) {
+	var ids []string
+	seen := map[string]bool{}
+	for _, path := range files {
+		it, err := dataset.OpenDataset(path)
+		if err != nil {
+			return nil, err
+		}
+		for doc, err := range it.All() {
+			if err != nil {
+				it.Close()
+				return nil, fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			if doc.Id != "" && !seen[doc.Id] {
+				seen[doc.Id] = true
+				ids = append(ids, doc.Id)
+			}
+		}
+		it.Close()
+	}
+	return ids, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/grant/sn42/pkg/apiclient"
+	"github.com/grant/sn42/pkg/audience"
+	"github.com/grant/sn42/pkg/collect"
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/manifest"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+func runRetweeters(args []string) {
+	flags := flag.NewFlagSet("retweeters", flag.ExitOnError)
+	output := flags.String("o", "", "Write the accounts to this dataset (default: <dataset>.retweeters.json, or .retweeters.json.enc for an encrypted dataset)")
+	minEngagement := flags.Float64("min-engagement", 100, "Only look up tweets with at least this many likes, retweets and replies together")
+	limit := flags.Int("limit", 100, "Look up at most this many tweets, the most engaged first (0 = all above -min-engagement)")
+	perTweet := flags.Int("per-tweet", 100, fmt.Sprintf("Collect at most this many retweeters of each tweet (at most %d)", audience.MaxPerTweet))
+	concurrency := flags.Int("concurrency", 4, "Parallel lookups")
+	retries := flags.Int("retries", 2, "Retry a failed lookup this many times")
+	retryDelay := flags.Duration("retry-delay", 5*time.Second, "Wait before the first retry, doubling each time")
+	apiFlags := apiclient.RegisterFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dataset retweeters [-min-engagement 100] [-limit 100] [-per-tweet 100] [-o retweeters.json] <dataset>\n\n")
+		fmt.Fprintf(os.Stderr, "Collects the accounts that retweeted the most engaged tweets in the dataset into an\naudience dataset, one record per account with the IDs of the tweets it retweeted.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *limit < 0 || *perTweet <= 0 || *perTweet > audience.MaxPerTweet {
+		log.Fatalf("-limit must not be negative and -per-tweet must be between 1 and %d", audience.MaxPerTweet)
+	}
+	input := flags.Arg(0)
+	outPath := *output
+	if outPath == "" {
+		outPath = companionPath(input, ".retweeters.json")
+	}
+
+	selected, header, err := topTweets(input, *minEngagement, *limit)
+	if err != nil {
+		log.Fatalf("Failed to read dataset: %v", err)
+	}
+	if len(selected) == 0 {
+		log.Fatalf("No tweets in %s have an engagement of at least %g", input, *minEngagement)
+	}
+	var ids []string
+	for _, doc := range selected {
+		if doc.Id != "" {
+			ids = append(ids, doc.Id)
+		}
+	}
+
+	c, err := apiFlags.New()
+	if err != nil {
+		log.Fatalf("Failed to create client from config: %v\nMake sure GOPHER_CLIENT_TOKEN is set in your .env file", err)
+	}
+	if c.Token == "" {
+		log.Fatal("GOPHER_CLIENT_TOKEN is not set. Please set it in your .env file")
+	}
+
+	fmt.Printf("👥 Collecting retweeters of %d tweets from %s...\n", len(ids), input)
+	fetcher := &audience.Fetcher{Lookup: collect.Lookup{Client: c, Concurrency: *concurrency, Retries: *retries, RetryDelay: *retryDelay}}
+	accounts, failed := fetcher.Retweeters(context.Background(), ids, *perTweet, func(done int, id string, found int, err error) {
+		if err != nil {
+			fmt.Printf("⚠️  %d/%d: %v\n", done, len(ids), err)
+			return
+		}
+		fmt.Printf("  %d/%d: %d retweeters of %s\n", done, len(ids), found, id)
+	})
+
+	records := make([]types.Document, len(accounts))
+	for i, a := range accounts {
+		doc := a.Profile
+		doc.Metadata = maps.Clone(doc.Metadata)
+		if doc.Metadata == nil {
+			doc.Metadata = map[string]any{}
+		}
+		doc.Metadata["retweeted_ids"] = a.Retweeted
+		doc.Metadata["retweeted_count"] = len(a.Retweeted)
+		records[i] = doc
+	}
+	out := &dataset.File{Query: "getretweeters:<id>", Trend: header.Trend, Tweets: records}
+	err = saveCompanion(outPath, out, &manifest.Manifest{
+		Tool:       "dataset retweeters",
+		Query:      out.Query,
+		Trend:      header.Trend,
+		Pipeline:   []string{fmt.Sprintf("retweeters(of=%s,min_engagement=%g,tweets=%d,per_tweet=%d)", filepath.Base(input), *minEngagement, len(ids), *perTweet)},
+		Provenance: manifest.ProvenanceFromEnv(),
+	})
+	if err != nil {
+		log.Fatalf("Failed to save retweeters: %v", err)
+	}
+
+	fmt.Printf("✅ Collected %d accounts retweeting %d tweets to %s\n", out.TotalTweets, len(ids)-len(failed), outPath)
+	if len(failed) > 0 {
+		fmt.Fprintf(os.Stderr, "❌ Looking up retweeters failed for %d of %d tweets\n", len(failed), len(ids))
+		os.Exit(1)
+	}
+}
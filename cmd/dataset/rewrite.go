@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/grant/sn42/pkg/crypt"
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/manifest"
+	"github.com/grant/sn42/pkg/sink"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// editFunc decides the fate of a record when a file is rewritten in place. It
+// reports whether to keep the record and whether it changed it.
+type editFunc func(doc *types.Document) (keep, changed bool)
+
+// canRewrite reports whether a file can be rewritten in place: plain or
+// encrypted dataset JSON, and plain JSONL
+func canRewrite(path string) bool {
+	name := strings.TrimSuffix(path, crypt.Extension)
+	if strings.HasSuffix(name, ".json") {
+		return true
+	}
+	return name == path && (strings.HasSuffix(path, ".jsonl") || strings.HasSuffix(path, ".ndjson"))
+}
+
+// countKept counts the records edit keeps without changing the file
+func countKept(path string, edit editFunc) (int, error) {
+	it, err := dataset.OpenDataset(path)
+	if err != nil {
+		return 0, err
+	}
+	defer it.Close()
+	kept := 0
+	for doc, err := range it.All() {
+		if err != nil {
+			return kept, err
+		}
+		if keep, _ := edit(&doc); keep {
+			kept++
+		}
+	}
+	return kept, nil
+}
+
+// rewrite replaces the file with the records edit keeps, writing a temporary
+// file first so an interrupted run leaves the original intact. Records on the
+// denylist are dropped too. The file is left untouched when no record is
+// dropped or changed. It returns the number of records kept and of those
+// dropped for the denylist.
+func rewrite(path string, edit editFunc) (int, int, error) {
+	tmp := path + ".tmp"
+	defer os.Remove(tmp)
+
+	// Encryption is told from the contents, so it is kept whatever the name
+	encrypted, err := isEncrypted(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	var kept, touched, denied int
+	switch {
+	case encrypted:
+		kept, touched, denied, err = rewriteEncrypted(path, tmp, edit)
+	case strings.HasSuffix(path, ".json"):
+		kept, touched, denied, err = rewriteJSON(path, tmp, edit)
+	default:
+		kept, touched, denied, err = rewriteJSONL(path, tmp, edit)
+	}
+	if err != nil || touched+denied == 0 {
+		return kept, 0, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return kept, 0, fmt.Errorf("failed to replace dataset: %w", err)
+	}
+	if denied > 0 {
+		fmt.Printf("🚫 %s: dropped %d denylisted tweets\n", path, denied)
+		if err := updateManifest(path, kept, fmt.Sprintf("denylist(removed=%d)", denied)); err != nil {
+			return kept, denied, fmt.Errorf("failed to update manifest: %w", err)
+		}
+	}
+	return kept, denied, nil
+}
+
+func isEncrypted(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	head := make([]byte, 64)
+	n, _ := io.ReadFull(f, head)
+	return crypt.IsEncrypted(head[:n]), nil
+}
+
+// The rewrite functions return the records kept, those edit dropped or
+// changed and those dropped for the denylist
+
+func rewriteJSON(path, tmp string, edit editFunc) (int, int, int, error) {
+	it, err := dataset.OpenDataset(path)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer it.Close()
+	header := dataset.File{}
+	if h := it.Header(); h != nil {
+		header = *h
+	}
+	writer, err := dataset.NewWriter(tmp, header)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	touched := 0
+	for doc, err := range it.All() {
+		if err == nil {
+			keep, changed := edit(&doc)
+			if !keep || changed {
+				touched++
+			}
+			if !keep {
+				continue
+			}
+			err = writer.Write(context.Background(), sink.Batch{Docs: []types.Document{doc}})
+		}
+		if err != nil {
+			writer.Discard()
+			return 0, 0, 0, err
+		}
+	}
+	if touched+it.Denied() == 0 {
+		return writer.Count(), 0, 0, writer.Discard()
+	}
+	return writer.Count(), touched, it.Denied(), writer.Close()
+}
+
+// rewriteEncrypted rewrites an encrypted dataset, which is decrypted whole
+func rewriteEncrypted(path, tmp string, edit editFunc) (int, int, int, error) {
+	key, err := crypt.KeyFromEnv()
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("file is encrypted: %w", err)
+	}
+	ds, err := dataset.Load(path)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	kept := ds.Tweets[:0]
+	touched := 0
+	for _, doc := range ds.Tweets {
+		keep, changed := edit(&doc)
+		if !keep || changed {
+			touched++
+		}
+		if keep {
+			kept = append(kept, doc)
+		}
+	}
+	ds.Tweets = kept
+	if touched+ds.Denied == 0 {
+		return len(kept), 0, 0, nil
+	}
+	return len(kept), touched, ds.Denied, ds.Save(tmp, key)
+}
+
+// rewriteJSONL keeps the lines of unchanged records byte for byte
+func rewriteJSONL(path, tmp string, edit editFunc) (int, int, int, error) {
+	deny, err := dataset.Denylist()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer in.Close()
+	out, err := os.Create(tmp)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer out.Close()
+
+	w := bufio.NewWriter(out)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 1<<16), 64<<20)
+	kept, touched, denied, n := 0, 0, 0, 0
+	for scanner.Scan() {
+		n++
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) > 0 {
+			var doc types.Document
+			if err := json.Unmarshal(line, &doc); err != nil {
+				return 0, 0, 0, fmt.Errorf("line %d: %w", n, err)
+			}
+			if deny.Denies(doc) {
+				denied++
+				continue
+			}
+			keep, changed := edit(&doc)
+			if !keep || changed {
+				touched++
+			}
+			if !keep {
+				continue
+			}
+			if changed {
+				if line, err = json.Marshal(doc); err != nil {
+					return 0, 0, 0, fmt.Errorf("failed to marshal record: %w", err)
+				}
+			}
+			kept++
+		}
+		w.Write(line)
+		w.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, 0, err
+	}
+	if err := w.Flush(); err != nil {
+		return 0, 0, 0, err
+	}
+	return kept, touched, denied, out.Close()
+}
+
+// updateManifest records the new count and checksum in the dataset's
+// manifest, if it has one, adds stage to its pipeline and regenerates its card
+func updateManifest(path string, kept int, stage string) error {
+	m, err := manifest.Load(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	m.Records = kept
+	m.Pipeline = append(m.Pipeline, stage)
+	_, err = manifest.Write(path, m)
+	return err
+}
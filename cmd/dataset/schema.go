@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/expr"
+)
+
+// maxExamples is the number of distinct example values kept per field
+const maxExamples = 3
+
+// fieldSchema is what was seen of one field across the scanned records
+type fieldSchema struct {
+	Path     string         `json:"path"`
+	Type     string         `json:"type"`    // Non-null types, most common first
+	Types    map[string]int `json:"types"`   // Records per value type
+	Present  int            `json:"present"` // Records with the field, null or not
+	Null     int            `json:"null"`
+	Nullable bool           `json:"nullable"` // Missing from or null in some record
+	Examples []string       `json:"examples,omitempty"`
+}
+
+// schema accumulates field schemas over the records of a dataset
+type schema struct {
+	Records int
+	Fields  map[string]*fieldSchema
+}
+
+func runSchema(args []string) {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	limit := fs.Int("n", 0, "Scan only the first N records of each file (0 = all)")
+	jsonOutput := fs.Bool("json", false, "Print the schema as JSON")
+	markdown := fs.Bool("markdown", false, "Print the schema as a Markdown table, e.g. for a dataset card")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dataset schema [-n N] [-json | -markdown] <dataset or dir>...\n\n")
+		fmt.Fprintf(os.Stderr, "Infers the fields, types, nullability and example values of the records as written.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *jsonOutput && *markdown {
+		log.Fatal("-json and -markdown cannot be used together")
+	}
+
+	files, err := dataset.Files(fs.Args()...)
+	if err != nil {
+		log.Fatalf("Failed to list datasets: %v", err)
+	}
+	s := &schema{Fields: map[string]*fieldSchema{}}
+	for _, path := range files {
+		if err := s.scan(path, *limit); err != nil {
+			log.Fatalf("Failed to read dataset: %v", err)
+		}
+	}
+	fields := s.sorted()
+
+	switch {
+	case *jsonOutput:
+		data, err := json.MarshalIndent(struct {
+			Records int            `json:"records"`
+			Fields  []*fieldSchema `json:"fields"`
+		}{s.Records, fields}, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to marshal schema: %v", err)
+		}
+		fmt.Println(string(data))
+	case *markdown:
+		fmt.Println("| Field | Type | Nullable | Present | Examples |")
+		fmt.Println("|---|---|---|---|---|")
+		for _, f := range fields {
+			fmt.Printf("| `%s` | %s | %s | %s | %s |\n", f.Path, f.Type, yesNo(f.Nullable), percent(f.Present, s.Records),
+				strings.ReplaceAll(strings.Join(f.Examples, ", "), "|", `\|`))
+		}
+	default:
+		width := len("FIELD")
+		for _, f := range fields {
+			width = max(width, len(f.Path))
+		}
+		fmt.Printf("%d records scanned in %d files\n\n", s.Records, len(files))
+		fmt.Printf("%-*s  %-18s %-8s %8s  %s\n", width, "FIELD", "TYPE", "NULLABLE", "PRESENT", "EXAMPLES")
+		for _, f := range fields {
+			fmt.Printf("%-*s  %-18s %-8s %8s  %s\n", width, f.Path, f.Type, yesNo(f.Nullable), percent(f.Present, s.Records), strings.Join(f.Examples, ", "))
+		}
+	}
+}
+
+// scan adds up to limit records of a file (all if 0) to the schema. Records
+// are taken as they serialize, so the schema is that of the JSON written.
+func (s *schema) scan(path string, limit int) error {
+	it, err := dataset.OpenDataset(path)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+	n := 0
+	for doc, err := range it.All() {
+		if limit > 0 && n >= limit {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("failed to marshal record: %w", err)
+		}
+		var record map[string]any
+		if err := json.Unmarshal(data, &record); err != nil {
+			return fmt.Errorf("failed to decode record: %w", err)
+		}
+		s.Records++
+		s.add("", record, map[string]bool{})
+		n++
+	}
+	return nil
+}
+
+// add records the fields of an object under prefix. seen holds the paths
+// already counted for the current record, as array elements share a path.
+func (s *schema) add(prefix string, obj map[string]any, seen map[string]bool) {
+	for name, v := range obj {
+		s.addValue(prefix+name, v, seen)
+	}
+}
+
+func (s *schema) addValue(path string, v any, seen map[string]bool) {
+	f := s.Fields[path]
+	if f == nil {
+		f = &fieldSchema{Path: path, Types: map[string]int{}}
+		s.Fields[path] = f
+	}
+	t := valueType(v)
+	if !seen[path] {
+		seen[path] = true
+		f.Present++
+		if v == nil {
+			f.Null++
+		}
+	}
+	if !seen[path+"\x00"+t] {
+		seen[path+"\x00"+t] = true
+		f.Types[t]++
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		s.add(path+".", v, seen)
+	case []any:
+		for _, e := range v {
+			s.addValue(path+"[]", e, seen)
+		}
+	default:
+		f.example(v)
+	}
+}
+
+// example keeps the first few distinct scalar values of a field
+func (f *fieldSchema) example(v any) {
+	if v == nil || len(f.Examples) >= maxExamples {
+		return
+	}
+	data, _ := json.Marshal(v)
+	e := string(data)
+	if len([]rune(e)) > 40 {
+		e = string([]rune(e)[:39]) + "…"
+	}
+	if !slices.Contains(f.Examples, e) {
+		f.Examples = append(f.Examples, e)
+	}
+}
+
+// valueType names the JSON type of a decoded value, telling integers from
+// other numbers and RFC 3339 timestamps from other strings
+func valueType(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		if _, ok := expr.ParseTime(v); ok && strings.Contains(v, "T") {
+			return "timestamp"
+		}
+		return "string"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// sorted returns the fields in path order, with type and nullability filled in
+func (s *schema) sorted() []*fieldSchema {
+	var fields []*fieldSchema
+	for _, f := range s.Fields {
+		f.Type = f.typeName()
+		f.Nullable = f.Null > 0 || (!strings.Contains(f.Path, "[]") && f.Present < s.Records)
+		fields = append(fields, f)
+	}
+	slices.SortFunc(fields, func(a, b *fieldSchema) int { return strings.Compare(a.Path, b.Path) })
+	return fields
+}
+
+// typeName lists the non-null types seen, most common first; an integer
+// field that also has fractions is a number
+func (f *fieldSchema) typeName() string {
+	types := map[string]int{}
+	for t, n := range f.Types {
+		if t != "null" {
+			types[t] = n
+		}
+	}
+	if n, ok := types["number"]; ok {
+		types["number"] = n + types["integer"]
+		delete(types, "integer")
+	}
+	if len(types) == 0 {
+		return "null"
+	}
+	names := make([]string, 0, len(types))
+	for t := range types {
+		names = append(names, t)
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		if types[a] != types[b] {
+			return types[b] - types[a]
+		}
+		return strings.Compare(a, b)
+	})
+	return strings.Join(names, "|")
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func percent(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(n)/float64(total))
+}
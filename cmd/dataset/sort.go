@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/manifest"
+	"github.com/grant/sn42/pkg/sink"
+	"github.com/grant/sn42/pkg/spill"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+func runSort(args []string) {
+	flags := flag.NewFlagSet("sort", flag.ExitOnError)
+	output := flags.String("o", "", "Write the sorted records to this dataset file, with a manifest (required)")
+	by := flags.String("by", "created_at", "Sort order: "+dataset.SortOrders)
+	spillFlags := spill.RegisterFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dataset sort -o output.json [-by id|created_at|engagement] [-memory-budget-mb MB] <dataset>\n\n")
+		fmt.Fprintf(os.Stderr, "Writes the records of the dataset to a new file in the given order. Ties keep their input order.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 || *output == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+	key, err := dataset.SortKey(*by)
+	if err != nil {
+		log.Fatalf("Invalid -by: %v", err)
+	}
+	input := flags.Arg(0)
+
+	// Records are spilled to sorted runs on disk once they exceed the budget
+	sorter := spill.NewSorter(spillFlags.Dir, spillFlags.Budget())
+	defer sorter.Close()
+	fmt.Printf("Reading %s...\n", input)
+	header, err := dataset.Scan(input, func(doc types.Document) error {
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("failed to marshal tweet: %w", err)
+		}
+		return sorter.Add(key(doc), data)
+	})
+	if err != nil {
+		log.Fatalf("Failed to read dataset: %v", err)
+	}
+
+	writer, err := dataset.NewWriter(*output, dataset.File{})
+	if err != nil {
+		log.Fatalf("Failed to create output: %v", err)
+	}
+	fmt.Printf("Sorting %d tweets by %s (%d spill runs)...\n", sorter.Len(), *by, sorter.Runs())
+	err = sorter.Each(func(data []byte) error {
+		var doc types.Document
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("failed to decode spilled tweet: %w", err)
+		}
+		return writer.Write(context.Background(), sink.Batch{Docs: []types.Document{doc}})
+	})
+	if err != nil {
+		writer.Discard()
+		log.Fatalf("Failed to sort tweets: %v", err)
+	}
+
+	// Keep the input's header so reruns are byte-identical
+	out := writer.Header()
+	if header != nil {
+		out.CollectedAt, out.Trend, out.Query, out.SavedQuery = header.CollectedAt, header.Trend, header.Query, header.SavedQuery
+	}
+	if err := writer.Close(); err != nil {
+		log.Fatalf("Failed to save sorted dataset: %v", err)
+	}
+	m, err := sortedManifest(input, out, writer.Count(), *by)
+	if err != nil {
+		log.Fatalf("Failed to read manifest: %v", err)
+	}
+	if _, err := manifest.Write(*output, m); err != nil {
+		log.Fatalf("Failed to write manifest: %v", err)
+	}
+
+	fmt.Printf("✅ Sorted %d tweets from %s by %s to %s\n", writer.Count(), input, *by, *output)
+}
+
+// sortedManifest describes the sorted copy of input: the input's manifest
+// with the sort added to its pipeline, or a new one if it has none
+func sortedManifest(input string, header *dataset.File, records int, by string) (*manifest.Manifest, error) {
+	stage := "sort(" + by + ")"
+	m, err := manifest.Load(input)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &manifest.Manifest{
+			Tool:       "dataset sort",
+			Query:      header.Query,
+			Trend:      header.Trend,
+			Records:    records,
+			Pipeline:   []string{stage},
+			Provenance: manifest.ProvenanceFromEnv(),
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m.Records = records
+	m.Pipeline = append(m.Pipeline, stage)
+	m.CreatedAt = ""
+	return m, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/stats"
+)
+
+func runTop(args []string) {
+	fs := flag.NewFlagSet("top", flag.ExitOnError)
+	limit := fs.Int("n", 10, "Number of entries to list per kind (0 = all)")
+	jsonOutput := fs.Bool("json", false, "Print the counts as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dataset top [-n N] [-json] <dataset or dir>...\n\n")
+		fmt.Fprintf(os.Stderr, "Lists the most frequent hashtags, mentioned users, authors and link domains,\nwith the number and share of tweets carrying each.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	files, err := dataset.Files(fs.Args()...)
+	if err != nil {
+		log.Fatalf("Failed to list datasets: %v", err)
+	}
+	if len(files) == 0 {
+		log.Fatal("No dataset files found")
+	}
+	acc := stats.NewEntityAccumulator()
+	for _, path := range files {
+		it, err := dataset.OpenDataset(path)
+		if err != nil {
+			log.Fatalf("Failed to read dataset: %v", err)
+		}
+		for doc, err := range it.All() {
+			if err != nil {
+				log.Fatalf("Failed to read %s: %v", path, err)
+			}
+			acc.Add(doc)
+		}
+		it.Close()
+	}
+	top := acc.Top(*limit)
+
+	if *jsonOutput {
+		data, err := json.MarshalIndent(top, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to marshal counts: %v", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Printf("%d records scanned in %d files\n", top.Records, len(files))
+	printTop("HASHTAG", "#", top.Hashtags)
+	printTop("MENTION", "@", top.Mentions)
+	printTop("AUTHOR", "@", top.Authors)
+	printTop("DOMAIN", "", top.Domains)
+}
+
+// printTop prints one kind of entity as a table, values shown with prefix
+func printTop(heading, prefix string, entities []stats.EntityCount) {
+	fmt.Println()
+	if len(entities) == 0 {
+		fmt.Printf("%s: none\n", heading)
+		return
+	}
+	width := len(heading)
+	for _, e := range entities {
+		width = max(width, len(prefix)+len(e.Value))
+	}
+	fmt.Printf("%-*s  %8s  %7s\n", width, heading, "TWEETS", "SHARE")
+	for _, e := range entities {
+		fmt.Printf("%-*s  %8d  %6.2f%%\n", width, prefix+e.Value, e.Count, 100*e.Share)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/expr"
+	"github.com/grant/sn42/pkg/unified"
+)
+
+func runUnify(args []string) {
+	fs := flag.NewFlagSet("unify", flag.ExitOnError)
+	output := fs.String("o", "", "Write the records to this JSONL file (default: stdout)")
+	where := fs.String("where", "", "Keep only records matching this expression over the unified fields, e.g. 'source == \"reddit\" && likes > 10'")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dataset unify [-where expression] [-o output.jsonl] <dataset or dir>...\n\n")
+		fmt.Fprintf(os.Stderr, "Writes the records of datasets from any source in the unified schema, as JSON lines.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	var match *expr.Expr
+	if *where != "" {
+		var err error
+		if match, err = expr.Parse(*where); err != nil {
+			log.Fatalf("Invalid -where expression: %v", err)
+		}
+	}
+
+	inputs, err := dataset.Files(fs.Args()...)
+	if err != nil {
+		log.Fatalf("Failed to list datasets: %v", err)
+	}
+	out, status := os.Stdout, os.Stderr
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", *output, err)
+		}
+		defer f.Close()
+		out, status = f, os.Stdout
+	}
+	w := bufio.NewWriter(out)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	written := 0
+	bySource := map[string]int{}
+	for _, path := range inputs {
+		it, err := dataset.OpenDataset(path)
+		if err != nil {
+			log.Fatalf("Failed to read dataset: %v", err)
+		}
+		for doc, err := range it.All() {
+			if err != nil {
+				log.Fatalf("Failed to read %s: %v", path, err)
+			}
+			r := unified.FromDocument(doc)
+			if match != nil && !match.Match(r.Document()) {
+				continue
+			}
+			if err := enc.Encode(r); err != nil {
+				log.Fatalf("Failed to write record: %v", err)
+			}
+			written++
+			bySource[r.Source]++
+		}
+		it.Close()
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatalf("Failed to write records: %v", err)
+	}
+	parts := make([]string, 0, len(bySource))
+	for _, source := range slices.Sorted(maps.Keys(bySource)) {
+		parts = append(parts, fmt.Sprintf("%s: %d", source, bySource[source]))
+	}
+	fmt.Fprintf(status, "✅ Wrote %d unified records from %d datasets (%s)\n", written, len(inputs), strings.Join(parts, ", "))
+}
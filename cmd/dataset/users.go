@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/grant/sn42/pkg/apiclient"
+	"github.com/grant/sn42/pkg/collect"
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/hydrate"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// userRow is one line of the users table
+type userRow struct {
+	User      string          `json:"user"` // Join key with the tweets
+	Username  string          `json:"username,omitempty"`
+	UserID    string          `json:"user_id,omitempty"`
+	Tweets    int             `json:"tweets"` // Tweets by the user in the datasets
+	Status    string          `json:"status"`
+	FetchedAt string          `json:"fetched_at,omitempty"`
+	Profile   *types.Document `json:"profile,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+func runUsers(args []string) {
+	flags := flag.NewFlagSet("users", flag.ExitOnError)
+	output := flags.String("o", "", "Write the users table to this JSONL file (default: <dataset>.users.jsonl, or - for stdout)")
+	cachePath := flags.String("cache", hydrate.DefaultCache, "Profile cache shared between runs (empty to disable)")
+	maxAge := flags.Duration("max-age", 7*24*time.Hour, "Look up again the cached profiles older than this")
+	batch := flags.Int("batch", 100, "Save the cache after every this many lookups, so an interrupted run keeps what it fetched")
+	concurrency := flags.Int("concurrency", 4, "Parallel lookups")
+	retries := flags.Int("retries", 2, "Retry a failed lookup this many times")
+	retryDelay := flags.Duration("retry-delay", 5*time.Second, "Wait before the first retry, doubling each time")
+	apiFlags := apiclient.RegisterFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dataset users [-cache %s] [-max-age 168h] [-o users.jsonl] <dataset or dir>...\n\n", hydrate.DefaultCache)
+		fmt.Fprintf(os.Stderr, "Looks up the current profile of every author in the datasets and writes a users table,\none line per author, joining the tweets on its user key.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *batch <= 0 {
+		log.Fatalf("-batch must be positive, got %d", *batch)
+	}
+	files, err := dataset.Files(flags.Args()...)
+	if err != nil {
+		log.Fatalf("Failed to list datasets: %v", err)
+	}
+	outPath := *output
+	if outPath == "" {
+		if len(files) > 1 {
+			log.Fatal("-o is required with several datasets")
+		}
+		outPath = companionPath(files[0], ".users.jsonl")
+	}
+
+	index := hydrate.NewIndex()
+	for _, path := range files {
+		if _, err := dataset.Scan(path, func(doc types.Document) error {
+			index.Add(doc)
+			return nil
+		}); err != nil {
+			log.Fatalf("Failed to read dataset: %v", err)
+		}
+	}
+	authors := index.Authors()
+	if len(authors) == 0 {
+		log.Fatal("No tweets in the datasets have a username or user ID")
+	}
+
+	cache := &hydrate.Cache{Profiles: map[string]*hydrate.Entry{}}
+	if *cachePath != "" {
+		if cache, err = hydrate.LoadCache(*cachePath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	now := time.Now()
+	var stale []*hydrate.Author
+	for _, a := range authors {
+		if e := cache.Profiles[a.Key]; e == nil || !e.Fresh(*maxAge, now) {
+			stale = append(stale, a)
+		}
+	}
+	fmt.Printf("👤 %d authors in %d files: %d cached, %d to look up\n", len(authors), len(files), len(authors)-len(stale), len(stale))
+
+	// Failed lookups are kept out of the cache, so the next run tries them again
+	failed := map[string]*hydrate.Entry{}
+	if len(stale) > 0 {
+		c, err := apiFlags.New()
+		if err != nil {
+			log.Fatalf("Failed to create client from config: %v\nMake sure GOPHER_CLIENT_TOKEN is set in your .env file", err)
+		}
+		if c.Token == "" {
+			log.Fatal("GOPHER_CLIENT_TOKEN is not set. Please set it in your .env file")
+		}
+		fetcher := &hydrate.Fetcher{Lookup: collect.Lookup{Client: c, Concurrency: *concurrency, Retries: *retries, RetryDelay: *retryDelay}}
+		for start := 0; start < len(stale); start += *batch {
+			chunk := stale[start:min(start+*batch, len(stale))]
+			for i, e := range fetcher.Fetch(context.Background(), chunk) {
+				if e.Status == hydrate.Failed {
+					failed[chunk[i].Key] = e
+					continue
+				}
+				cache.Profiles[chunk[i].Key] = e
+			}
+			if *cachePath != "" {
+				if err := cache.Save(); err != nil {
+					log.Fatal(err)
+				}
+			}
+			fmt.Printf("  %d/%d looked up (%d failed)\n", start+len(chunk), len(stale), len(failed))
+		}
+	}
+
+	out := os.Stdout
+	if outPath != "-" {
+		if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+			log.Fatalf("Failed to create output directory: %v", err)
+		}
+		f, err := os.Create(outPath)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", outPath, err)
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriter(out)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	counts := map[string]int{}
+	for _, a := range authors {
+		e := failed[a.Key]
+		if e == nil {
+			e = cache.Profiles[a.Key]
+		}
+		counts[e.Status]++
+		row := userRow{User: a.Key, Username: a.Username, UserID: a.UserID, Tweets: a.Tweets, Status: e.Status, FetchedAt: e.FetchedAt, Profile: e.Profile, Error: e.Error}
+		if err := enc.Encode(row); err != nil {
+			log.Fatalf("Failed to write users table: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatalf("Failed to write %s: %v", outPath, err)
+	}
+
+	if outPath != "-" {
+		fmt.Printf("✅ Wrote %d users (%d found, %d not found, %d failed) to %s\n", len(authors), counts[hydrate.Found], counts[hydrate.NotFound], counts[hydrate.Failed], outPath)
+	}
+	if counts[hydrate.Failed] > 0 {
+		fmt.Fprintf(os.Stderr, "❌ %d profile lookups failed; run again to retry them\n", counts[hydrate.Failed])
+		os.Exit(1)
+	}
+}
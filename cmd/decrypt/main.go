@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/grant/sn42/pkg/crypt"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	output := flag.String("o", "", "Output path (default: input path without the .enc suffix, or - for stdout)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: decrypt [-o output] <file.enc>\n\nDecrypts a dataset file written with --encrypt using ENCRYPTION_KEY.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	input := flag.Arg(0)
+
+	// Load .env file so ENCRYPTION_KEY can live next to the other settings
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Warning: failed to load .env file: %v", err)
+	}
+
+	key, err := crypt.KeyFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load encryption key: %v", err)
+	}
+
+	data, err := os.ReadFile(input)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", input, err)
+	}
+
+	plaintext, err := crypt.Decrypt(key, data)
+	if err != nil {
+		log.Fatalf("Failed to decrypt %s: %v", input, err)
+	}
+
+	if *output == "-" {
+		os.Stdout.Write(plaintext)
+		return
+	}
+
+	outPath := *output
+	if outPath == "" {
+		outPath = strings.TrimSuffix(input, crypt.Extension)
+		if outPath == input {
+			log.Fatalf("Input %s has no %s suffix, specify the output path with -o", input, crypt.Extension)
+		}
+	}
+
+	if err := os.WriteFile(outPath, plaintext, 0644); err != nil {
+		log.Fatalf("Failed to write %s: %v", outPath, err)
+	}
+	fmt.Printf("✅ Decrypted %s to %s\n", input, outPath)
+}
@@ -0,0 +1,185 @@
+// Command doctor checks that the collectors can run: the configuration and
+// token, that the API is reachable, that a search succeeds and that trends
+// can be fetched, printing what to fix for each check that fails.
+package main
+
+import (
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gopher-lab/gopher-client/client"
+	"github.com/gopher-lab/gopher-client/config"
+	"github.com/grant/sn42/pkg/apiclient"
+	"github.com/grant/sn42/pkg/audit"
+	"github.com/grant/sn42/pkg/collect"
+	"github.com/grant/sn42/pkg/envprofile"
+	"github.com/grant/sn42/pkg/trending"
+	"github.com/joho/godotenv"
+)
+
+// statusCode finds the HTTP status in the client's error messages
+var statusCode = regexp.MustCompile(`Status code (\d{3})`)
+
+// doctor runs the checks and counts the failures
+type doctor struct {
+	failed int
+}
+
+func (d *doctor) ok(format string, v ...any) {
+	fmt.Printf("✅ "+format+"\n", v...)
+}
+
+func (d *doctor) warn(format string, v ...any) {
+	fmt.Printf("⚠️  "+format+"\n", v...)
+}
+
+// fail reports a failed check and what to do about it
+func (d *doctor) fail(hint string, format string, v ...any) {
+	d.failed++
+	fmt.Printf("❌ "+format+"\n", v...)
+	fmt.Printf("   → %s\n", hint)
+}
+
+func main() {
+	probe := flag.String("query", "bitcoin", "Query for the 1-result probe search")
+	timeout := flag.Duration("timeout", 10*time.Second, "Timeout for the reachability check")
+	skipTrends := flag.Bool("skip-trends", false, "Skip the trends fetch")
+	profileName := envprofile.RegisterFlag(flag.CommandLine)
+	apiFlags := apiclient.RegisterFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: doctor [-profile name] [-api-url url] [-proxy url] [-query q] [-timeout 10s] [-skip-trends]\n\n")
+		fmt.Fprintf(os.Stderr, "Checks the token, API reachability, a probe search and a trends fetch, and says how to fix what fails.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	d := &doctor{}
+
+	fmt.Println("🩺 Configuration")
+	if err := godotenv.Load(); err != nil {
+		d.warn("No .env file in the current directory; using environment variables only")
+	} else {
+		d.ok(".env loaded")
+	}
+	if err := envprofile.Use(*profileName); err != nil {
+		d.fail("Fix the profile in "+envprofile.Path()+", or set the variable it reads the token from", "Invalid profile: %v", err)
+		os.Exit(1)
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		d.fail("Fix the GOPHER_CLIENT_* variables, e.g. GOPHER_CLIENT_TIMEOUT=60s", "Invalid configuration: %v", err)
+		os.Exit(1)
+	}
+	c, err := apiFlags.New()
+	if err != nil {
+		d.fail("Fix -api-url and -proxy, or GOPHER_CLIENT_URL and GOPHER_CLIENT_PROXY", "Invalid connection settings: %v", err)
+		os.Exit(1)
+	}
+	d.ok("API URL: %s, timeout %s", c.BaseURL, cfg.Timeout)
+	proxy := apiclient.Proxy(c)
+	if proxy != "" {
+		d.ok("Proxy: %s", proxy)
+	}
+	if cfg.Token == "" {
+		d.fail("Set GOPHER_CLIENT_TOKEN to your Gopher AI API token in .env or the environment", "GOPHER_CLIENT_TOKEN is not set")
+	} else {
+		d.ok("Token set (%s)", audit.TokenID(cfg.Token))
+	}
+
+	fmt.Println("\n🩺 Reachability")
+	reachable := d.reach(c, proxy, *timeout)
+
+	if reachable && cfg.Token != "" {
+		fmt.Println("\n🩺 Search")
+		start := time.Now()
+		err = collect.Preflight(c, *probe)
+		switch {
+		case err == nil:
+			d.ok("Probe search for %q returned a result in %s", *probe, time.Since(start).Round(time.Millisecond))
+		case errors.Is(err, collect.ErrNoResults):
+			d.warn("Probe search for %q succeeded but matched nothing; try -query with a busier term", *probe)
+		default:
+			d.fail(apiHint(err), "Probe search failed: %v", err)
+		}
+
+		if !*skipTrends {
+			fmt.Println("\n🩺 Trends")
+			start = time.Now()
+			trends, err := trending.Fetch(c)
+			if err != nil {
+				d.fail(apiHint(err), "Trends fetch failed: %v", err)
+			} else {
+				d.ok("Fetched %d trends in %s", len(trends), time.Since(start).Round(time.Millisecond))
+			}
+		}
+	}
+
+	fmt.Println()
+	if d.failed > 0 {
+		fmt.Printf("❌ %d checks failed\n", d.failed)
+		os.Exit(1)
+	}
+	fmt.Println("✅ Ready to collect")
+}
+
+// reach checks that the API host answers HTTP at all. Any response counts,
+// whatever its status: it is the token and search checks that judge it. The
+// request goes through c's transport, so through its proxy if it has one.
+func (d *doctor) reach(c *client.Client, proxy string, timeout time.Duration) bool {
+	baseURL := c.BaseURL
+	httpClient := &http.Client{Timeout: timeout, Transport: c.HTTPClient.Transport}
+	start := time.Now()
+	resp, err := httpClient.Get(baseURL)
+	if err == nil {
+		resp.Body.Close()
+		d.ok("%s answered (HTTP %d) in %s", baseURL, resp.StatusCode, time.Since(start).Round(time.Millisecond))
+		return true
+	}
+	var dnsErr *net.DNSError
+	var certErr *x509.UnknownAuthorityError
+	var hostErr x509.HostnameError
+	var netErr net.Error
+	var opErr *net.OpError
+	switch {
+	case proxy != "" && errors.As(err, &opErr) && (opErr.Op == "proxyconnect" || strings.HasPrefix(opErr.Op, "socks")):
+		d.fail("Check the proxy address and credentials (-proxy, GOPHER_CLIENT_PROXY or HTTPS_PROXY), or pass -proxy none to connect directly", "Cannot connect through proxy %s: %v", proxy, err)
+	case errors.As(err, &dnsErr):
+		d.fail("Check the host in GOPHER_CLIENT_URL and your DNS settings", "Cannot resolve %s: %v", dnsErr.Name, err)
+	case errors.As(err, &certErr), errors.As(err, &hostErr):
+		d.fail("The TLS certificate is not trusted; if you are behind an intercepting proxy, add its CA to the system trust store", "TLS verification failed: %v", err)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		d.fail("Check your network, firewall or egress proxy; raise -timeout on a slow link", "No answer from %s within %s", baseURL, timeout)
+	default:
+		d.fail("Check that GOPHER_CLIENT_URL is right and the API is up", "Cannot connect to %s: %v", baseURL, err)
+	}
+	return false
+}
+
+// apiHint suggests a fix for an API error from its status code
+func apiHint(err error) string {
+	m := statusCode.FindStringSubmatch(err.Error())
+	if m == nil {
+		return "Retry in a minute; if it keeps failing, the API may be degraded"
+	}
+	code, _ := strconv.Atoi(m[1])
+	switch {
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return "The token was rejected: check GOPHER_CLIENT_TOKEN for typos or expiry, and that it is a token for this API URL"
+	case code == http.StatusTooManyRequests:
+		return "The token is rate limited: wait for the limit to reset, or check `usage` for runs using the same token"
+	case code == http.StatusNotFound:
+		return "The endpoint was not found: GOPHER_CLIENT_URL should end in /api, e.g. https://data.gopher-ai.com/api"
+	case code >= 500:
+		return "The API is failing on its side; retry later"
+	default:
+		return fmt.Sprintf("The API rejected the request with HTTP %d; see the message above", code)
+	}
+}
@@ -0,0 +1,230 @@
+// Package e2e runs the collectors end to end against the fake gopher API,
+// one fresh API per scenario, and checks each run's summary and files. The
+// scenarios build and start real binaries, so -short skips them.
+package e2e
+
+import (
+	"encoding/json"
+	"flag"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grant/sn42/pkg/fakeapi"
+	"github.com/grant/sn42/pkg/report"
+	"github.com/grant/sn42/pkg/rundir"
+)
+
+var (
+	binDir = flag.String("bin", "", "Directory with prebuilt fetch-tweets and fetch-trends binaries (default: build them)")
+	keep   = flag.Bool("keep", false, "Keep the scenario directories for inspection")
+)
+
+// scenario runs one collector against a fake API and checks the run
+type scenario struct {
+	name  string
+	api   fakeapi.Config
+	tool  string // fetch-tweets or fetch-trends
+	env   []string
+	args  []string
+	check func(t *testing.T, r *result)
+}
+
+// result is what a scenario's run left behind
+type result struct {
+	dir     string // Run directory
+	exitErr error
+	output  string
+	summary report.Summary
+	stats   fakeapi.Stats
+}
+
+// Every run leaves the circuit breaker off, so injected failures reach the
+// retry logic instead of pausing the run
+var quick = []string{"-breaker-failures", "0"}
+
+var scenarios = []scenario{
+	{
+		name: "paginates-and-dedups",
+		tool: "fetch-tweets",
+		env:  []string{"QUERY=bitcoin", "AMOUNT=150"},
+		args: []string{"-dedup", "text"},
+		check: func(t *testing.T, r *result) {
+			r.status(t, report.StatusOK)
+			if r.stats.Results < 3 {
+				t.Errorf("expected at least 2 pages after preflight, got %d results", r.stats.Results)
+			}
+			if r.summary.Totals.Dropped == 0 {
+				t.Error("expected dedup to drop the repeated fixture texts")
+			}
+			r.files(t, "bitcoin_150.json", 1)
+		},
+	},
+	{
+		name: "steps-batch-size-down",
+		tool: "fetch-tweets",
+		api:  fakeapi.Config{MaxResults: 30},
+		env:  []string{"QUERY=bitcoin", "AMOUNT=60"},
+		args: []string{"-preflight=false"},
+		check: func(t *testing.T, r *result) {
+			r.status(t, report.StatusOK)
+			if r.summary.Totals.Saved < 60 {
+				t.Errorf("expected 60 tweets at the smaller batch size, got %d", r.summary.Totals.Saved)
+			}
+		},
+	},
+	{
+		name: "retries-flaky-submissions",
+		tool: "fetch-tweets",
+		api:  fakeapi.Config{FailRate: 0.3, Seed: 7},
+		env:  []string{"QUERY=ethereum", "AMOUNT=20"},
+		args: []string{"-preflight=false", "-adaptive-batch=false", "-retries", "4"},
+		check: func(t *testing.T, r *result) {
+			if r.stats.Rejected == 0 {
+				t.Error("expected injected 503s")
+			}
+			r.status(t, report.StatusOK)
+		},
+	},
+	{
+		name: "queues-failed-batches",
+		tool: "fetch-tweets",
+		api:  fakeapi.Config{JobFailRate: 1},
+		env:  []string{"QUERY=bitcoin", "AMOUNT=20"},
+		args: []string{"-preflight=false", "-adaptive-batch=false", "-retries", "0"},
+		check: func(t *testing.T, r *result) {
+			r.status(t, report.StatusFailed)
+			r.files(t, "retry_queue.jsonl", 1)
+		},
+	},
+	{
+		name: "waits-for-rate-limit-reset",
+		tool: "fetch-tweets",
+		api:  fakeapi.Config{RateLimit: 1, RateWindow: 2 * time.Second},
+		env:  []string{"QUERY=bitcoin", "AMOUNT=150"},
+		args: []string{"-preflight=false", "-adaptive-batch=false", "-retries", "0"},
+		check: func(t *testing.T, r *result) {
+			if r.stats.Limited == 0 {
+				t.Error("expected injected 429s")
+			}
+			if !strings.Contains(r.output, "Rate limit reached") {
+				t.Error("expected the run to wait for the rate limit to reset")
+			}
+			r.status(t, report.StatusOK)
+		},
+	},
+	{
+		name: "rejects-bad-token",
+		tool: "fetch-tweets",
+		api:  fakeapi.Config{Token: "right"},
+		env:  []string{"QUERY=bitcoin", "AMOUNT=20"},
+		check: func(t *testing.T, r *result) {
+			if r.exitErr == nil {
+				t.Error("expected the preflight check to fail the run")
+			}
+			if !strings.Contains(r.output, "401") {
+				t.Error("expected a 401 in the output")
+			}
+		},
+	},
+	{
+		name: "collects-every-trend",
+		tool: "fetch-trends",
+		env:  []string{"AMOUNT=10"},
+		check: func(t *testing.T, r *result) {
+			r.status(t, report.StatusOK)
+			r.files(t, "*/tweets.json", 5)
+		},
+	},
+}
+
+func TestScenarios(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the end-to-end scenarios in -short mode")
+	}
+
+	work := t.TempDir()
+	if *keep {
+		var err error
+		if work, err = os.MkdirTemp("", "sn42-e2e-"); err != nil {
+			t.Fatalf("failed to create work directory: %v", err)
+		}
+		t.Logf("Scenario directories are kept in %s", work)
+	}
+
+	bin := *binDir
+	if bin == "" {
+		bin = filepath.Join(work, "bin")
+		build := exec.Command("go", "build", "-o", bin+string(filepath.Separator), "github.com/grant/sn42/cmd/fetch-tweets", "github.com/grant/sn42/cmd/fetch-trends")
+		if out, err := build.CombinedOutput(); err != nil {
+			t.Fatalf("failed to build the collectors: %v\n%s", err, out)
+		}
+	}
+
+	for _, sc := range scenarios {
+		t.Run(sc.name, func(t *testing.T) {
+			r := sc.run(t, filepath.Join(work, sc.name), bin)
+			sc.check(t, r)
+			if t.Failed() {
+				t.Logf("output of %s:\n%s", sc.tool, r.output)
+			}
+		})
+	}
+}
+
+// run starts a fake API for the scenario and runs its tool in dir
+func (sc scenario) run(t *testing.T, dir, bin string) *result {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("failed to create scenario directory: %v", err)
+	}
+	api, err := fakeapi.New(sc.api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	srv := &http.Server{Handler: api, ReadHeaderTimeout: 5 * time.Second}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	cmd := exec.Command(filepath.Join(bin, sc.tool), append(append([]string{}, quick...), sc.args...)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOPHER_CLIENT_URL=http://"+ln.Addr().String(), "GOPHER_CLIENT_TOKEN=e2e")
+	cmd.Env = append(cmd.Env, sc.env...)
+	out, runErr := cmd.CombinedOutput()
+
+	r := &result{dir: filepath.Join(dir, rundir.Root, rundir.Latest), exitErr: runErr, output: string(out), stats: api.Stats()}
+	if data, err := os.ReadFile(filepath.Join(r.dir, "summary.json")); err == nil {
+		if err := json.Unmarshal(data, &r.summary); err != nil {
+			t.Fatalf("failed to parse summary: %v", err)
+		}
+	}
+	return r
+}
+
+func (r *result) status(t *testing.T, want string) {
+	t.Helper()
+	if r.summary.Status != want {
+		t.Errorf("expected run status %q, got %q (%s)", want, r.summary.Status, r.summary.Note)
+	}
+}
+
+// files checks that the run directory holds n files matching pattern
+func (r *result) files(t *testing.T, pattern string, n int) {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(r.dir, pattern))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != n {
+		t.Errorf("expected %d files matching %s in the run directory, got %d", n, pattern, len(matches))
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/grant/sn42/pkg/crypt"
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// dictString is the type used for low-cardinality, highly repetitive string columns
+var dictString = &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Int32, ValueType: arrow.BinaryTypes.String}
+
+var schema = arrow.NewSchema([]arrow.Field{
+	{Name: "id", Type: arrow.BinaryTypes.String},
+	{Name: "source", Type: dictString},
+	{Name: "content", Type: arrow.BinaryTypes.String},
+	{Name: "username", Type: dictString, Nullable: true},
+	{Name: "lang", Type: dictString, Nullable: true},
+	{Name: "created_at", Type: &arrow.TimestampType{Unit: arrow.Millisecond, TimeZone: "UTC"}, Nullable: true},
+	{Name: "likes", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+	{Name: "retweets", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+	{Name: "replies", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+	{Name: "metadata", Type: arrow.BinaryTypes.String, Nullable: true}, // Full metadata as JSON
+}, nil)
+
+func main() {
+	output := flag.String("o", "", "Output path (default: <dataset>.arrow)")
+	compression := flag.String("compression", "none", "Record batch compression: none, lz4 or zstd")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: export-arrow [-compression none|lz4|zstd] [-o out.arrow] <dataset.json>\n\nWrites the dataset as an Arrow IPC file (Feather v2).\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	input := flag.Arg(0)
+
+	opts := []ipc.Option{ipc.WithSchema(schema)}
+	switch *compression {
+	case "none":
+	case "lz4":
+		opts = append(opts, ipc.WithLZ4())
+	case "zstd":
+		opts = append(opts, ipc.WithZstd())
+	default:
+		log.Fatalf("Invalid -compression %q (must be none, lz4 or zstd)", *compression)
+	}
+
+	dataset.LoadEnv()
+
+	ds, err := dataset.Load(input)
+	if err != nil {
+		log.Fatalf("Failed to load dataset: %v", err)
+	}
+
+	outPath := *output
+	if outPath == "" {
+		outPath = strings.TrimSuffix(strings.TrimSuffix(input, crypt.Extension), ".json") + ".arrow"
+	}
+
+	record := buildRecord(memory.NewGoAllocator(), ds.Tweets)
+	defer record.Release()
+
+	f, err := os.Create(outPath)
+	if err != nil {
+		log.Fatalf("Failed to create %s: %v", outPath, err)
+	}
+	defer f.Close()
+
+	w, err := ipc.NewFileWriter(f, opts...)
+	if err != nil {
+		log.Fatalf("Failed to create Arrow writer: %v", err)
+	}
+	if err := w.Write(record); err != nil {
+		log.Fatalf("Failed to write record batch: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		log.Fatalf("Failed to finalize Arrow file: %v", err)
+	}
+
+	fmt.Printf("✅ Exported %d tweets to %s\n", record.NumRows(), outPath)
+}
+
+// buildRecord converts the documents into a single record batch. A single batch
+// keeps one dictionary per column, which the IPC file format requires.
+func buildRecord(mem memory.Allocator, docs []types.Document) arrow.Record {
+	b := array.NewRecordBuilder(mem, schema)
+	defer b.Release()
+
+	ids := b.Field(0).(*array.StringBuilder)
+	sources := b.Field(1).(*array.BinaryDictionaryBuilder)
+	contents := b.Field(2).(*array.StringBuilder)
+	usernames := b.Field(3).(*array.BinaryDictionaryBuilder)
+	langs := b.Field(4).(*array.BinaryDictionaryBuilder)
+	createdAt := b.Field(5).(*array.TimestampBuilder)
+	likes := b.Field(6).(*array.Int64Builder)
+	retweets := b.Field(7).(*array.Int64Builder)
+	replies := b.Field(8).(*array.Int64Builder)
+	metadata := b.Field(9).(*array.StringBuilder)
+
+	for _, doc := range docs {
+		ids.Append(doc.Id)
+		sources.AppendString(string(doc.Source))
+		contents.Append(doc.Content)
+		appendDictString(usernames, doc.Metadata["username"])
+		appendDictString(langs, doc.Metadata["lang"])
+
+		if s, ok := doc.Metadata["created_at"].(string); ok {
+			if t, err := time.Parse(time.RFC3339, s); err == nil {
+				createdAt.Append(arrow.Timestamp(t.UnixMilli()))
+			} else {
+				createdAt.AppendNull()
+			}
+		} else {
+			createdAt.AppendNull()
+		}
+
+		appendInt(likes, doc.Metadata["likes"])
+		appendInt(retweets, doc.Metadata["retweets"])
+		appendInt(replies, doc.Metadata["replies"])
+
+		if doc.Metadata == nil {
+			metadata.AppendNull()
+		} else if data, err := json.Marshal(doc.Metadata); err == nil {
+			metadata.Append(string(data))
+		} else {
+			metadata.AppendNull()
+		}
+	}
+
+	return b.NewRecord()
+}
+
+func appendDictString(b *array.BinaryDictionaryBuilder, v any) {
+	if s, ok := v.(string); ok && s != "" {
+		b.AppendString(s)
+		return
+	}
+	b.AppendNull()
+}
+
+func appendInt(b *array.Int64Builder, v any) {
+	switch n := v.(type) {
+	case float64:
+		b.Append(int64(n))
+	case int64:
+		b.Append(n)
+	case int:
+		b.Append(int64(n))
+	default:
+		b.AppendNull()
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/grant/sn42/pkg/cascade"
+	"github.com/grant/sn42/pkg/crypt"
+	"github.com/grant/sn42/pkg/dataset"
+)
+
+func main() {
+	output := flag.String("o", "", "Output path (default: <dataset>.cascades.jsonl, or - for stdout)")
+	minSize := flag.Int("min-size", 1, "Leave out cascades with fewer shares than this")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: export-cascades [-min-size N] [-o out.jsonl] <dataset>...\n\n")
+		fmt.Fprintf(os.Stderr, "Reconstructs the retweet and quote cascade of each original tweet and writes one per line.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	outPath := *output
+	if outPath == "" {
+		if flag.NArg() > 1 {
+			log.Fatal("-o is required with several datasets")
+		}
+		outPath = strings.TrimSuffix(strings.TrimSuffix(flag.Arg(0), crypt.Extension), ".json") + ".cascades.jsonl"
+	}
+
+	dataset.LoadEnv()
+
+	b := cascade.NewBuilder()
+	for _, path := range flag.Args() {
+		it, err := dataset.OpenDataset(path)
+		if err != nil {
+			log.Fatalf("Failed to load dataset: %v", err)
+		}
+		for doc, err := range it.All() {
+			if err != nil {
+				log.Fatalf("Failed to read %s: %v", path, err)
+			}
+			b.Add(doc)
+		}
+		it.Close()
+	}
+	cascades := b.Cascades(*minSize)
+
+	out := os.Stdout
+	if outPath != "-" {
+		f, err := os.Create(outPath)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", outPath, err)
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriter(out)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, c := range cascades {
+		if err := enc.Encode(c); err != nil {
+			log.Fatalf("Failed to write cascade: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatalf("Failed to write %s: %v", outPath, err)
+	}
+
+	if outPath != "-" {
+		fmt.Printf("✅ Exported %s to %s\n", cascade.Summary(cascades), outPath)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/grant/sn42/pkg/apiclient"
+	"github.com/grant/sn42/pkg/collect"
+	"github.com/grant/sn42/pkg/crypt"
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/thread"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	output := flag.String("o", "", "Output path (default: <dataset>.conversations.jsonl, or - for stdout)")
+	minTurns := flag.Int("min-turns", 2, "Leave out conversations with fewer tweets than this")
+	fetchMissing := flag.Bool("fetch-missing", false, "Look up parent and root tweets missing from the datasets (needs GOPHER_CLIENT_TOKEN)")
+	rounds := flag.Int("fetch-rounds", 3, "With -fetch-missing, how many levels of missing ancestors to look up")
+	concurrency := flag.Int("concurrency", 4, "Parallel lookups")
+	retries := flag.Int("retries", 2, "Retry a failed lookup this many times")
+	retryDelay := flag.Duration("retry-delay", 5*time.Second, "Wait before the first retry, doubling each time")
+	apiFlags := apiclient.RegisterFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: export-conversations [-min-turns 2] [-fetch-missing] [-o out.jsonl] <dataset>...\n\n")
+		fmt.Fprintf(os.Stderr, "Groups tweets by conversation and writes each conversation as ordered turns, one per line.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	outPath := *output
+	if outPath == "" {
+		if flag.NArg() > 1 {
+			log.Fatal("-o is required with several datasets")
+		}
+		outPath = strings.TrimSuffix(strings.TrimSuffix(flag.Arg(0), crypt.Extension), ".json") + ".conversations.jsonl"
+	}
+
+	// Load .env file so ENCRYPTION_KEY and GOPHER_CLIENT_TOKEN are available
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Warning: failed to load .env file: %v", err)
+	}
+
+	b := thread.NewBuilder()
+	for _, path := range flag.Args() {
+		it, err := dataset.OpenDataset(path)
+		if err != nil {
+			log.Fatalf("Failed to load dataset: %v", err)
+		}
+		for doc, err := range it.All() {
+			if err != nil {
+				log.Fatalf("Failed to read %s: %v", path, err)
+			}
+			b.Add(doc)
+		}
+		it.Close()
+	}
+
+	if missing := b.Missing(); len(missing) > 0 && !*fetchMissing {
+		fmt.Printf("ℹ️  %d parent or root tweets are not in the datasets; use -fetch-missing to look them up\n", len(missing))
+	} else if len(missing) > 0 {
+		c, err := apiFlags.New()
+		if err != nil {
+			log.Fatalf("Failed to create client from config: %v\nMake sure GOPHER_CLIENT_TOKEN is set in your .env file", err)
+		}
+		if c.Token == "" {
+			log.Fatal("GOPHER_CLIENT_TOKEN is not set. Please set it in your .env file")
+		}
+		f := &thread.Fetcher{Lookup: collect.Lookup{Client: c, Concurrency: *concurrency, Retries: *retries, RetryDelay: *retryDelay}}
+		added, failed := f.Fill(context.Background(), b, *rounds)
+		fmt.Printf("📥 Fetched %d missing tweets (%d not found or failed)\n", added, failed)
+	}
+	convos := b.Conversations(*minTurns)
+
+	out := os.Stdout
+	if outPath != "-" {
+		f, err := os.Create(outPath)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", outPath, err)
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriter(out)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	turns, complete := 0, 0
+	for _, c := range convos {
+		if err := enc.Encode(c); err != nil {
+			log.Fatalf("Failed to write conversation: %v", err)
+		}
+		turns += len(c.Turns)
+		if c.Complete {
+			complete++
+		}
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatalf("Failed to write %s: %v", outPath, err)
+	}
+
+	if outPath != "-" {
+		fmt.Printf("✅ Exported %d conversations (%d complete, %d turns) to %s\n", len(convos), complete, turns, outPath)
+	}
+}
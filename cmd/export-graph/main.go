@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/grant/sn42/pkg/crypt"
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/graph"
+)
+
+func main() {
+	output := flag.String("o", "", "Output path (default: <dataset>.<format>, or - for stdout)")
+	format := flag.String("format", "graphml", "Graph format: "+strings.Join(graph.Formats, ", "))
+	edges := flag.String("edges", strings.Join(graph.EdgeTypes, ","), "Comma-separated interactions to include as edges")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: export-graph [-format graphml|gexf] [-edges mention,retweet,reply] [-o out] <dataset>...\n\n")
+		fmt.Fprintf(os.Stderr, "Writes the user interaction graph of the datasets for Gephi, NetworkX and other graph tools.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	g, err := graph.New(strings.Split(*edges, ",")...)
+	if err != nil {
+		log.Fatalf("Invalid -edges: %v", err)
+	}
+	outPath := *output
+	if outPath == "" {
+		if flag.NArg() > 1 {
+			log.Fatal("-o is required with several datasets")
+		}
+		outPath = strings.TrimSuffix(strings.TrimSuffix(flag.Arg(0), crypt.Extension), ".json") + "." + *format
+	}
+	switch *format {
+	case "graphml", "gexf":
+	default:
+		log.Fatalf("Invalid -format %q (must be %s)", *format, strings.Join(graph.Formats, " or "))
+	}
+
+	dataset.LoadEnv()
+
+	for _, path := range flag.Args() {
+		it, err := dataset.OpenDataset(path)
+		if err != nil {
+			log.Fatalf("Failed to load dataset: %v", err)
+		}
+		for doc, err := range it.All() {
+			if err != nil {
+				log.Fatalf("Failed to read %s: %v", path, err)
+			}
+			g.Add(doc)
+		}
+		it.Close()
+	}
+
+	out := os.Stdout
+	if outPath != "-" {
+		f, err := os.Create(outPath)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", outPath, err)
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriter(out)
+	if err := g.Write(w, *format); err != nil {
+		log.Fatalf("Failed to write %s: %v", outPath, err)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatalf("Failed to write %s: %v", outPath, err)
+	}
+
+	if outPath != "-" {
+		fmt.Printf("✅ Exported %d users and %d interactions to %s\n", len(g.Nodes()), len(g.Edges()), outPath)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/grant/sn42/pkg/crypt"
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/manifest"
+)
+
+// metadataFields are the tweet metadata keys copied into each task's data for display in Label Studio
+var metadataFields = []string{"username", "created_at", "lang", "likes", "retweets", "replies"}
+
+// task is a Label Studio task in the JSON import format
+type task struct {
+	ID          int            `json:"id,omitempty"`
+	Data        map[string]any `json:"data"`
+	Annotations []annotation   `json:"annotations,omitempty"`
+}
+
+// annotation is a completed annotation as found in a Label Studio JSON export
+type annotation struct {
+	CompletedBy  any               `json:"completed_by,omitempty"`
+	CreatedAt    string            `json:"created_at,omitempty"`
+	WasCancelled bool              `json:"was_cancelled,omitempty"`
+	Result       []annotationValue `json:"result"`
+}
+
+// annotationValue is one labeling result (e.g. a choice or a span) inside an annotation
+type annotationValue struct {
+	FromName string         `json:"from_name"`
+	ToName   string         `json:"to_name"`
+	Type     string         `json:"type"`
+	Value    map[string]any `json:"value"`
+}
+
+func main() {
+	output := flag.String("o", "", "Output path (default: <dataset>.labelstudio.json, or <dataset>.annotated.json with -import)")
+	importPath := flag.String("import", "", "Label Studio JSON export with completed annotations to merge back into the dataset")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: export-labelstudio [-o tasks.json] <dataset.json>\n       export-labelstudio -import export.json [-o annotated.json] <dataset.json>\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	input := flag.Arg(0)
+
+	dataset.LoadEnv()
+
+	ds, err := dataset.Load(input)
+	if err != nil {
+		log.Fatalf("Failed to load dataset: %v", err)
+	}
+
+	if *importPath != "" {
+		outPath := *output
+		if outPath == "" {
+			outPath = outputPath(input, ".annotated.json")
+		}
+		if err := importAnnotations(ds, *importPath, outPath); err != nil {
+			log.Fatalf("Failed to import annotations: %v", err)
+		}
+		return
+	}
+
+	outPath := *output
+	if outPath == "" {
+		outPath = outputPath(input, ".labelstudio.json")
+	}
+	if err := exportTasks(ds, outPath); err != nil {
+		log.Fatalf("Failed to export tasks: %v", err)
+	}
+}
+
+// exportTasks writes one text task per tweet, keyed by tweet ID so annotations can be merged back
+func exportTasks(ds *dataset.File, outPath string) error {
+	tasks := make([]task, 0, len(ds.Tweets))
+	for _, doc := range ds.Tweets {
+		data := map[string]any{
+			"text":     doc.Content,
+			"tweet_id": doc.Id,
+		}
+		if ds.Trend != "" {
+			data["trend"] = ds.Trend
+		}
+		for _, field := range metadataFields {
+			if v, ok := doc.Metadata[field]; ok {
+				data[field] = v
+			}
+		}
+		tasks = append(tasks, task{Data: data})
+	}
+
+	out, err := json.MarshalIndent(tasks, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal tasks: %w", err)
+	}
+	if err := os.WriteFile(outPath, out, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	fmt.Printf("✅ Exported %d Label Studio tasks to %s\n", len(tasks), outPath)
+	return nil
+}
+
+// importAnnotations merges the annotations from a Label Studio export into the
+// matching tweets' metadata and saves the result as a new dataset
+func importAnnotations(ds *dataset.File, exportPath, outPath string) error {
+	data, err := os.ReadFile(exportPath)
+	if err != nil {
+		return fmt.Errorf("failed to read export: %w", err)
+	}
+	var tasks []task
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		return fmt.Errorf("failed to parse export (expected Label Studio JSON format): %w", err)
+	}
+
+	byID := make(map[string][]annotation, len(tasks))
+	for _, t := range tasks {
+		id, _ := t.Data["tweet_id"].(string)
+		if id == "" {
+			continue
+		}
+		for _, a := range t.Annotations {
+			if !a.WasCancelled {
+				byID[id] = append(byID[id], a)
+			}
+		}
+	}
+
+	merged := 0
+	for i := range ds.Tweets {
+		doc := &ds.Tweets[i]
+		annotations, ok := byID[doc.Id]
+		if !ok {
+			continue
+		}
+		if doc.Metadata == nil {
+			doc.Metadata = map[string]any{}
+		}
+		doc.Metadata["annotations"] = annotations
+		doc.Metadata["labels"] = collectLabels(annotations)
+		merged++
+	}
+
+	if err := ds.Save(outPath, nil); err != nil {
+		return err
+	}
+	if _, err := manifest.Write(outPath, &manifest.Manifest{
+		Tool:       "export-labelstudio",
+		Query:      ds.Query,
+		Trend:      ds.Trend,
+		Records:    len(ds.Tweets),
+		Provenance: manifest.ProvenanceFromEnv(),
+	}); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Merged annotations into %d of %d tweets, saved to %s\n", merged, len(ds.Tweets), outPath)
+	return nil
+}
+
+// collectLabels flattens choice/label results into a from_name -> labels map,
+// e.g. {"sentiment": ["Positive"]}, for easy filtering downstream
+func collectLabels(annotations []annotation) map[string][]string {
+	labels := map[string][]string{}
+	for _, a := range annotations {
+		for _, r := range a.Result {
+			for _, key := range []string{"choices", "labels"} {
+				values, ok := r.Value[key].([]any)
+				if !ok {
+					continue
+				}
+				for _, v := range values {
+					if s, ok := v.(string); ok {
+						labels[r.FromName] = append(labels[r.FromName], s)
+					}
+				}
+			}
+		}
+	}
+	return labels
+}
+
+// outputPath derives an output path from the dataset path by replacing its .json suffix
+func outputPath(input, suffix string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(input, crypt.Extension), ".json") + suffix
+}
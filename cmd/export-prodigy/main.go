@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/grant/sn42/pkg/crypt"
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// metaFields are the tweet metadata keys copied into each example's meta (shown in the Prodigy UI)
+var metaFields = []string{"username", "created_at", "lang", "likes"}
+
+// example is one line of Prodigy JSONL input
+type example struct {
+	Text   string         `json:"text"`
+	Meta   map[string]any `json:"meta"`
+	Spans  []span         `json:"spans,omitempty"`
+	Label  string         `json:"label,omitempty"`
+	Accept []string       `json:"accept,omitempty"`
+}
+
+// span is a Prodigy character-offset span annotation
+type span struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Label string `json:"label"`
+}
+
+func main() {
+	output := flag.String("o", "", "Output path (default: <dataset>.prodigy.jsonl, or - for stdout)")
+	preAnnotate := flag.Bool("pre-annotate", false, "Include existing annotations from tweet metadata (spans and labels) as pre-annotations")
+	labelField := flag.String("label-field", "", "With -pre-annotate, use labels[<field>] as the example category (e.g. sentiment)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: export-prodigy [-pre-annotate] [-label-field name] [-o out.jsonl] <dataset.json>\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	input := flag.Arg(0)
+
+	dataset.LoadEnv()
+
+	ds, err := dataset.Load(input)
+	if err != nil {
+		log.Fatalf("Failed to load dataset: %v", err)
+	}
+
+	outPath := *output
+	if outPath == "" {
+		outPath = strings.TrimSuffix(strings.TrimSuffix(input, crypt.Extension), ".json") + ".prodigy.jsonl"
+	}
+
+	out := os.Stdout
+	if outPath != "-" {
+		f, err := os.Create(outPath)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", outPath, err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	w := bufio.NewWriter(out)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, doc := range ds.Tweets {
+		ex := toExample(doc, ds.Trend)
+		if *preAnnotate {
+			addPreAnnotations(&ex, doc, *labelField)
+		}
+		if err := enc.Encode(ex); err != nil {
+			log.Fatalf("Failed to write example: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatalf("Failed to write %s: %v", outPath, err)
+	}
+
+	if outPath != "-" {
+		fmt.Printf("✅ Exported %d Prodigy examples to %s\n", len(ds.Tweets), outPath)
+	}
+}
+
+// toExample builds the text + meta example for a tweet
+func toExample(doc types.Document, trend string) example {
+	meta := map[string]any{"tweet_id": doc.Id}
+	if trend != "" {
+		meta["trend"] = trend
+	}
+	for _, field := range metaFields {
+		if v, ok := doc.Metadata[field]; ok {
+			meta[field] = v
+		}
+	}
+	return example{Text: doc.Content, Meta: meta}
+}
+
+// addPreAnnotations copies annotations already attached to the tweet into the
+// example: metadata "spans" ([{start, end, label}]) become NER spans, and
+// metadata "labels" (as merged by export-labelstudio -import) become either the
+// category label (with labelField) or the accepted choices
+func addPreAnnotations(ex *example, doc types.Document, labelField string) {
+	if raw, ok := doc.Metadata["spans"]; ok {
+		// Round-trip through JSON to accept any decoded representation
+		if data, err := json.Marshal(raw); err == nil {
+			var spans []span
+			if json.Unmarshal(data, &spans) == nil {
+				ex.Spans = spans
+			}
+		}
+	}
+
+	labels, ok := doc.Metadata["labels"].(map[string]any)
+	if !ok {
+		return
+	}
+	if labelField != "" {
+		if values, ok := labels[labelField].([]any); ok && len(values) > 0 {
+			ex.Label, _ = values[0].(string)
+		}
+		return
+	}
+
+	seen := map[string]bool{}
+	for _, values := range labels {
+		list, _ := values.([]any)
+		for _, v := range list {
+			if s, ok := v.(string); ok && !seen[s] {
+				seen[s] = true
+				ex.Accept = append(ex.Accept, s)
+			}
+		}
+	}
+	sort.Strings(ex.Accept)
+}
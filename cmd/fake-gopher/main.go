@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/grant/sn42/pkg/fakeapi"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8080", "Address to listen on")
+	var cfg fakeapi.Config
+	flag.StringVar(&cfg.Token, "token", "", "Require this bearer token (any token is accepted if empty)")
+	flag.DurationVar(&cfg.Latency, "latency", 0, "Delay every response by this long")
+	flag.DurationVar(&cfg.JobDuration, "job-duration", 0, "Keep each job in progress this long before its results are ready")
+	flag.Float64Var(&cfg.FailRate, "fail-rate", 0, "Answer this fraction of job submissions with 503")
+	flag.Float64Var(&cfg.JobFailRate, "job-fail-rate", 0, "Fail this fraction of accepted jobs with status \"error\"")
+	flag.IntVar(&cfg.MaxResults, "max-results", 0, "Time out (504) submissions asking for more results than this (0 = no limit)")
+	flag.IntVar(&cfg.RateLimit, "rate-limit", 0, "Answer submissions beyond this many per -rate-window with 429 and rate limit headers (0 = no limit)")
+	flag.DurationVar(&cfg.RateWindow, "rate-window", time.Minute, "Window -rate-limit counts submissions over")
+	flag.Uint64Var(&cfg.Seed, "seed", 1, "Seed for the injected failures")
+	flag.Parse()
+	if cfg.RateLimit > 0 && cfg.RateWindow <= 0 {
+		log.Fatalf("-rate-window must be positive, got %s", cfg.RateWindow)
+	}
+
+	srv, err := fakeapi.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create fake API: %v", err)
+	}
+	fmt.Printf("🧪 Fake gopher API listening on http://%s (point GOPHER_CLIENT_URL at it; stats on /fake/stats)\n", *addr)
+	server := &http.Server{Addr: *addr, Handler: srv, ReadHeaderTimeout: 5 * time.Second}
+	log.Fatal(server.ListenAndServe())
+}
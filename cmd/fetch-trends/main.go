@@ -1,56 +1,321 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"math/rand/v2"
 	"os"
 	"path/filepath"
-	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gopher-lab/gopher-client/client"
+	"github.com/grant/sn42/pkg/alert"
+	"github.com/grant/sn42/pkg/apiclient"
+	"github.com/grant/sn42/pkg/audit"
+	"github.com/grant/sn42/pkg/collect"
+	"github.com/grant/sn42/pkg/crypt"
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/envprofile"
+	"github.com/grant/sn42/pkg/health"
+	"github.com/grant/sn42/pkg/lock"
+	"github.com/grant/sn42/pkg/manifest"
+	"github.com/grant/sn42/pkg/mock"
+	"github.com/grant/sn42/pkg/notify"
+	"github.com/grant/sn42/pkg/pipeline"
+	"github.com/grant/sn42/pkg/profile"
+	"github.com/grant/sn42/pkg/ratelimit"
+	"github.com/grant/sn42/pkg/report"
+	"github.com/grant/sn42/pkg/retry"
+	"github.com/grant/sn42/pkg/rundir"
+	"github.com/grant/sn42/pkg/sample"
+	"github.com/grant/sn42/pkg/sink"
+	"github.com/grant/sn42/pkg/slug"
+	"github.com/grant/sn42/pkg/stats"
+	"github.com/grant/sn42/pkg/store"
+	"github.com/grant/sn42/pkg/trending"
+	"github.com/grant/sn42/pkg/twitterquery"
+	"github.com/grant/sn42/pkg/vcr"
 	"github.com/joho/godotenv"
-	"github.com/masa-finance/tee-worker/v2/api/args/twitter"
 	"github.com/masa-finance/tee-worker/v2/api/types"
 )
 
 const (
-	dataDir         = "data"
-	defaultAmount   = 10000
-	minLikesFilter  = " min_faves:100"
-	apiMaxResults   = 100 // Maximum results per API request
+	trendFile     = "tweets.json" // Name of each trend's dataset in its directory
+	defaultAmount = 10000
+	minLikes      = 100
+)
+
+// Selection modes for -balanced
+const (
+	pickTop    = "top"
+	pickRandom = "random"
 )
 
 func main() {
+	encrypt := flag.Bool("encrypt", false, "Encrypt output files with AES-256-GCM (key from ENCRYPTION_KEY)")
+	pipeFlags := pipeline.RegisterFlags(flag.CommandLine)
+	balanced := flag.Int("balanced", 0, "Write one combined dataset with this many tweets per trend instead of one file per trend")
+	sortBy := flag.String("sort", "", "Save the tweets ordered by created_at or tweet_id (both oldest first) or engagement (highest first), so reruns give identically ordered files (default: API order)")
+	pick := flag.String("pick", pickTop, "How -balanced selects tweets within a trend: top (highest engagement) or random")
+	seed := sample.RegisterSeedFlag(flag.CommandLine)
+	maxTotal := flag.Int("max-total-tweets", 0, "Stop the whole run once this many tweets have been collected across all queries (0 = no limit)")
+	healthFlags := health.RegisterFlags(flag.CommandLine)
+	alertFlags := alert.RegisterFlags(flag.CommandLine)
+	profileFlags := profile.RegisterFlags(flag.CommandLine)
+	maxRuntime := flag.Duration("max-runtime", 0, "Stop the whole run after this long, e.g. 2h (0 = no limit)")
+	auditPath := flag.String("audit-log", "", "Append who started the run, its arguments and its outcome to this audit log, shared by all runs (default: "+audit.FileName+" in the runs directory; empty to disable)")
+	profileName := envprofile.RegisterFlag(flag.CommandLine)
+	apiFlags := apiclient.RegisterFlags(flag.CommandLine)
+	trendsDir := flag.String("trends-dir", trending.DefaultDir, "Save each fetched trends list, ranked and timestamped, as a snapshot file in this directory (empty to disable; not under -mock or -replay)")
+	trendsRegion := flag.String("trends-region", trending.DefaultRegion, "Region recorded in trend snapshots; the API returns the trends of its own default location")
+	trendsRetries := flag.Int("trends-retries", 3, "Retry a failed trends job this many times with exponential backoff")
+	trendsRetryDelay := flag.Duration("trends-retry-delay", 5*time.Second, "Wait before the first trends job retry, doubling each time")
+	fallbackAge := flag.Duration("trends-fallback-age", 24*time.Hour, "When the trends cannot be fetched, collect the latest snapshot in -trends-dir instead if it is no older than this (0 = fail the run)")
+	var trendArgs trendList
+	flag.Var(&trendArgs, "trend", "Collect this topic instead of the live trends; repeat the flag for several")
+	trendsFile := flag.String("trends-file", "", "Collect the trends in this file instead of the live ones: a snapshot saved in -trends-dir (.json) or one topic per line (with -trend, both are collected)")
+	minTrendLength := flag.Int("trend-min-length", 2, "Skip trends with fewer letters and digits than this, such as single characters and pure emoji (0 = keep all)")
+	trendScripts := flag.String("trend-scripts", "", "Comma-separated Unicode scripts trends must be written in, e.g. \"Latin,Cyrillic\"; trends with letters in others are skipped (default: any)")
+	slugStyle := flag.String("slug", slug.ASCII, "How trend directories are named: "+slug.ASCII+" (accents dropped, a hash added for letters of other scripts) or "+slug.Unicode+" (letters of every script kept)")
+	topN := flag.Int("top-n", 0, "Collect only the N usable trends with the highest tweet volume (0 = all); trends are collected biggest first whenever their volume is known")
+	dedupTrends := flag.Bool("dedup-trends", false, "Keep each tweet only for the first trend that finds it, listing every trend each tweet matched in "+trendMatchesFile+" in the run directory")
+	summaryPath := flag.String("summary", "", "Write the run summary here as JSON, plus a Markdown table next to it (default: summary.json in the run directory; empty to disable)")
+	sinkQueue := flag.Int("sink-queue", 0, "Write to the sink in the background through a queue of this many batches, so fetching only waits when it is full (0 writes synchronously)")
+	flushEvery := flag.Int("flush-every", 0, "Write tweets to each trend's file and the sink every N records instead of holding them until the trend finishes (0 = disabled)")
+	maxBufferMB := flag.Int("max-buffer-mb", 0, "Also flush once the buffered tweets take this many MB, bounding memory whatever AMOUNT is (0 = no limit)")
+	retries := flag.Int("retries", 2, "Retry a failed API request this many times with exponential backoff")
+	mockAPI := flag.Bool("mock", false, "Serve canned trends and tweets from built-in fixtures instead of calling the API, for CI and demos (no token or network needed)")
+	vcrFlags := vcr.RegisterFlags(flag.CommandLine)
+	rateFlags := ratelimit.RegisterFlags(flag.CommandLine)
+	adaptiveBatch := flag.Bool("adaptive-batch", true, "Halve the batch size when a request fails and retry with it, stepping back up after successes, before using up -retries")
+	stampProvenance := flag.Bool("provenance", true, "Stamp each record with its run ID, query, capability, client version and fetch time under metadata.provenance")
+	breakerFailures := flag.Int("breaker-failures", 10, "Pause all requests for -breaker-cooldown after this many failed API requests in a row (0 disables)")
+	breakerCooldown := flag.Duration("breaker-cooldown", time.Minute, "How long requests pause once -breaker-failures is reached")
+	retryQueue := flag.String("retry-queue", "", "Queue batches that still fail after -retries in this file, for fetch-tweets -retry-file (default: "+retry.QueueFile+" in the run directory; empty to disable)")
+	storeDir := flag.String("store", "", "Ingest each trend's tweets into the dataset store in this directory, which keeps them deduplicated and partitioned by day, instead of writing per-trend files")
+	lockWait := flag.Duration("lock-wait", 0, "Wait this long for another run collecting the same trend query to finish instead of skipping the trend (0 = skip immediately)")
+	preflight := flag.Bool("preflight", true, "Check each trend query with a 1-result probe up front and skip trends that are rejected or empty")
+	flag.Parse()
+
+	// Trends given on the command line replace the live ones, so collection
+	// can be rerun on an earlier snapshot without fetching the trends
+	var given []trending.Trend
+	if *trendsFile != "" {
+		loaded, err := trending.LoadList(*trendsFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		given = loaded
+	}
+	given = trending.Unique(append(given, trending.Ranked(trendArgs)...))
+	trendFilter, err := trending.NewFilter(*minTrendLength, *trendScripts)
+	if err != nil {
+		log.Fatalf("Invalid trend filter: %v", err)
+	}
+	sanitizeTrend, err := slug.New(*slugStyle)
+	if err != nil {
+		log.Fatalf("Invalid -slug: %v", err)
+	}
+
+	// Run-wide caps start counting now, so they also cover preflight and probes
+	budget := collect.NewBudget(*maxTotal, *maxRuntime)
+
+	// Optional liveness and readiness endpoints for running under Kubernetes
+	monitor, err := healthFlags.Start()
+	if err != nil {
+		log.Fatalf("Failed to start health endpoints: %v", err)
+	}
+
+	// Optional pprof endpoints and CPU/heap profiles of the run
+	profiler, err := profileFlags.Start()
+	if err != nil {
+		log.Fatalf("Failed to start profiling: %v", err)
+	}
+	defer stopProfiler(profiler)
+
+	if *pick != pickTop && *pick != pickRandom {
+		log.Fatalf("Invalid -pick %q (expected %s or %s)", *pick, pickTop, pickRandom)
+	}
+	if *topN < 0 {
+		log.Fatalf("-top-n must not be negative, got %d", *topN)
+	}
+	if *balanced < 0 {
+		log.Fatalf("-balanced must not be negative, got %d", *balanced)
+	}
+	if *flushEvery < 0 || *maxBufferMB < 0 {
+		log.Fatalf("-flush-every and -max-buffer-mb must not be negative")
+	}
+	// Balanced selection needs every tweet of a trend, and an encrypted file is
+	// sealed as a whole, so neither can be written in parts
+	if (*balanced > 0 || *encrypt) && (*flushEvery > 0 || *maxBufferMB > 0) {
+		log.Fatalf("-flush-every and -max-buffer-mb cannot be combined with -balanced or -encrypt")
+	}
+	if *sortBy != "" {
+		if _, err := dataset.SortKey(*sortBy); err != nil {
+			log.Fatalf("Invalid -sort: %v", err)
+		}
+		if *flushEvery > 0 || *maxBufferMB > 0 {
+			log.Fatalf("-sort cannot be combined with -flush-every or -max-buffer-mb")
+		}
+	}
+	if *storeDir != "" && (*balanced > 0 || *flushEvery > 0 || *maxBufferMB > 0) {
+		log.Fatalf("-store cannot be combined with -balanced, -flush-every or -max-buffer-mb")
+	}
+
 	// Load .env file
 	if err := godotenv.Load(); err != nil {
 		log.Printf("Warning: failed to load .env file: %v", err)
 	}
 
-	// Initialize gopher-client
-	c, err := client.NewClientFromConfig()
+	// A profile selects the API, token, data directory and sinks to use
+	if err := envprofile.Use(*profileName); err != nil {
+		log.Fatalf("Failed to apply profile: %v", err)
+	}
+	rundir.DefaultRoot("audit-log", auditPath, audit.FileName)
+
+	// Optional notifications with the run summary, e.g. to a Slack or Discord webhook
+	notifier, err := notify.FromEnv()
 	if err != nil {
-		log.Fatalf("Failed to create client: %v", err)
+		log.Fatalf("Failed to configure notifications: %v", err)
 	}
+	summary := report.New("fetch-trends")
 
-	if c.Token == "" {
-		log.Fatal("GOPHER_CLIENT_TOKEN is not set")
+	// Optional alerting on a sustained API error rate
+	alerts, err := alertFlags.Build("fetch-trends")
+	if err != nil {
+		log.Fatalf("Failed to configure alerting: %v", err)
 	}
 
-	fmt.Println("Fetching Twitter trends...")
+	// Initialize gopher-client, or the fixtures with -mock
+	var c *client.Client
+	var mockClient *mock.Client
+	var api collect.Searcher
+	var cassette *vcr.Cassette
+	var pacer *ratelimit.Pacer
+	var meter *audit.Meter
+	if *mockAPI {
+		if vcrFlags.Record != "" || vcrFlags.Replaying() {
+			log.Fatal("-mock cannot be combined with -record or -replay")
+		}
+		if mockClient, err = mock.New(); err != nil {
+			log.Fatalf("Failed to load mock fixtures: %v", err)
+		}
+		fmt.Println("🧪 Mock mode: serving canned trends and tweets instead of calling the API")
+		api = mockClient
+	} else {
+		if c, err = apiFlags.New(); err != nil {
+			log.Fatalf("Failed to create client: %v", err)
+		}
+		if cassette, err = vcrFlags.Attach(c); err != nil {
+			log.Fatalf("Failed to open cassette: %v", err)
+		}
+		// Requests wait for an exhausted rate limit to reset instead of failing
+		if pacer, err = rateFlags.Attach(c); err != nil {
+			log.Fatal(err)
+		}
+		// API calls are counted for the audit log; a replay makes none
+		if !vcrFlags.Replaying() {
+			meter = audit.NewMeter(c)
+		}
+		if c.Token == "" && !vcrFlags.Replaying() {
+			log.Fatal("GOPHER_CLIENT_TOKEN is not set")
+		}
+		api = c
+	}
 
-	// Get trends using the client
-	trends, err := getTrends(c)
+	// Requests go through a circuit breaker, so a failing API is given time
+	// to recover instead of being hit by every retry of every query
+	var breaker *collect.Breaker
+	if *breakerFailures > 0 {
+		if *breakerCooldown <= 0 {
+			log.Fatalf("-breaker-cooldown must be positive, got %s", *breakerCooldown)
+		}
+		breaker = collect.NewBreaker(api, *breakerFailures, *breakerCooldown, monitor)
+		api = breaker
+	}
+
+	var encryptionKey []byte
+	if *encrypt {
+		encryptionKey, err = crypt.KeyFromEnv()
+		if err != nil {
+			log.Fatalf("Failed to load encryption key: %v", err)
+		}
+	}
+
+	// Everything the run writes goes under runs/<id>/, one subdirectory per trend
+	runDir, err := rundir.New()
+	if err != nil {
+		log.Fatalf("Failed to create run directory: %v", err)
+	}
+	defer runDir.Close()
+	fmt.Printf("Run %s: writing to %s\n", runDir.ID, runDir.Path)
+	runDir.Default("summary", summaryPath, "summary.json")
+	runDir.Default("retry-queue", retryQueue, retry.QueueFile)
+	runDir.Default("quarantine", &pipeFlags.Quarantine, "quarantine.jsonl")
+	auditLog, err := audit.Start(*auditPath, runDir.ID, "fetch-trends", runDir.Path, meter)
 	if err != nil {
-		log.Fatalf("Failed to fetch trends: %v", err)
+		runDir.Fatalf("Failed to start audit log: %v", err)
+	}
+
+	// Get trends: the given ones, the fixtures with -mock, or the live ones
+	var trends []trending.Trend
+	live := false
+	switch {
+	case len(given) > 0:
+		trends = given
+	case mockClient != nil:
+		trends = trending.FromDocs(mockClient.Trends())
+	default:
+		fmt.Println("Fetching Twitter trends...")
+		fetcher := &trending.Fetcher{Client: c, Retries: *trendsRetries, RetryDelay: *trendsRetryDelay}
+		trends, err = fetcher.Fetch()
+		live = err == nil
+	}
+	capturedAt := time.Now()
+	if err != nil {
+		// A transient API failure need not cost the run: the trends of a
+		// recent earlier run are collected instead
+		snapshot, path, ferr := latestSnapshot(*trendsDir, *trendsRegion, *fallbackAge, capturedAt)
+		if ferr != nil {
+			abort(summary, notifier, auditLog, runDir, fmt.Errorf("failed to fetch trends: %w (no fallback: %v)", err, ferr))
+		}
+		fmt.Fprintf(os.Stderr, "⚠️ Failed to fetch trends: %v\n", err)
+		fmt.Printf("🗂️ Using the trends snapshot %s, captured %s\n", path, snapshot.CapturedAt)
+		summary.Note = fmt.Sprintf("The trends could not be fetched (%v); collected the snapshot captured %s instead.", err, snapshot.CapturedAt)
+		trends = snapshot.Trends
+	}
+
+	if len(given) > 0 {
+		fmt.Printf("Collecting %d given trends instead of fetching them:\n", len(trends))
+	} else {
+		fmt.Printf("Found %d trending topics:\n", len(trends))
+	}
+	for _, t := range trends {
+		if t.Volume > 0 {
+			fmt.Printf("%d. %s (%d tweets)\n", t.Rank, t.Name, t.Volume)
+		} else {
+			fmt.Printf("%d. %s\n", t.Rank, t.Name)
+		}
 	}
 
-	fmt.Printf("Found %d trending topics:\n", len(trends))
-	for i, trend := range trends {
-		fmt.Printf("%d. %s\n", i+1, trend)
+	// Live trend lists are kept as snapshots, a dataset of their own; a
+	// snapshot that cannot be saved does not stop the collection
+	if *trendsDir != "" && live && !vcrFlags.Replaying() {
+		snapshot := trending.NewSnapshot(trends, *trendsRegion, runDir.ID, capturedAt)
+		if path, err := snapshot.Save(*trendsDir); err != nil {
+			fmt.Printf("⚠️ Failed to save trends snapshot: %v\n", err)
+		} else {
+			fmt.Printf("🗂️ Trends snapshot saved to %s\n", path)
+			summary.Files = append(summary.Files, path)
+		}
 	}
 
 	// Get target tweet count from env
@@ -58,212 +323,597 @@ func main() {
 	if amountStr := os.Getenv("AMOUNT"); amountStr != "" {
 		amount, err := strconv.Atoi(amountStr)
 		if err != nil {
-			log.Fatalf("Invalid AMOUNT: %s", amountStr)
+			abort(summary, notifier, auditLog, runDir, fmt.Errorf("invalid AMOUNT: %s", amountStr))
 		}
 		targetTweets = amount
 	}
 
-	// Process each trend
-	for _, trend := range trends {
-		fmt.Printf("\n=== Processing trend: %s ===\n", trend)
-		
-		// Sanitize trend for filename
-		sanitizedTrend := sanitizeTrend(trend)
-		if sanitizedTrend == "" {
+	// Optional streaming sink (e.g. Elasticsearch) shared by all trends
+	out, err := sink.FromEnv()
+	if err != nil {
+		abort(summary, notifier, auditLog, runDir, fmt.Errorf("failed to initialize sink: %w", err))
+	}
+	fanOut, _ := out.(*sink.FanOut)
+	var buffered *sink.Buffered
+	if *sinkQueue > 0 && out != nil {
+		buffered = sink.NewBuffered(out, *sinkQueue)
+		out = buffered
+	}
+
+	// Optional text processing applied to each batch before it is written
+	pipe, err := pipeFlags.Build()
+	if err != nil {
+		abort(summary, notifier, auditLog, runDir, fmt.Errorf("failed to configure processing: %w", err))
+	}
+
+	collector := &collect.Collector{Client: api, Pipeline: pipe, Sink: out, Budget: budget, Health: monitor, Alerts: alerts, Retries: *retries, AdaptiveBatch: *adaptiveBatch}
+	collector.FlushEvery, collector.MaxBufferBytes = *flushEvery, *maxBufferMB<<20
+	if *stampProvenance {
+		collector.Provenance = collect.NewProvenance(runDir.ID, "fetch-trends")
+	}
+	collector.Metrics = collect.NewMetrics()
+	provenance := manifest.ProvenanceFromEnv()
+
+	// A viral tweet matching several trends is kept for the first of them, so
+	// the run's datasets do not count it twice
+	runStages := pipe.Names()
+	var trendDedup *pipeline.TrendDedup
+	if *dedupTrends {
+		trendDedup = pipeline.NewTrendDedup()
+		collector.Pipeline = append(pipeline.Pipeline{trendDedup}, pipe...)
+		runStages = append([]string{trendDedup.Name()}, runStages...)
+	}
+
+	// The store is locked for the whole run, and the tweets it already holds
+	// are dropped as they arrive
+	var st *store.Store
+	if *storeDir != "" {
+		storeLock, err := lock.File("fetch-trends", runDir.ID, *storeDir, *lockWait)
+		if err != nil {
+			runDir.Fatalf("%v", err)
+		}
+		defer storeLock.Release()
+		if st, err = store.Open(*storeDir, encryptionKey); err != nil {
+			storeLock.Release()
+			runDir.Fatalf("Failed to open store: %v", err)
+		}
+		collector.Pipeline = append(pipeline.Pipeline{pipeline.NewSkipExisting(st.IDs())}, collector.Pipeline...)
+		fmt.Printf("Ingesting into store %s (%d tweets)\n", *storeDir, st.Meta().Records)
+	}
+
+	// Each trend's records are stamped with its trend and query first, so
+	// they keep them wherever they end up
+	runPipeline := collector.Pipeline
+	runStages = append([]string{"topic"}, runStages...)
+
+	// Only random selection consumes the seed, so only then is it reported and recorded
+	var seedValue *uint64
+	var rng *rand.Rand
+	if *balanced > 0 && *pick == pickRandom {
+		v := seed.Value()
+		seedValue = &v
+		rng = sample.NewRand(v)
+		fmt.Printf("Random seed: %d\n", v)
+	}
+
+	// With -balanced, each trend's selection is kept here instead of written to its own file
+	var selections []trendSelection
+
+	// The biggest trends are collected first, so a run that hits its caps
+	// or -top-n has spent them on those
+	ordered := trending.ByVolume(trends)
+	if *topN > 0 || !slices.Equal(ordered, trends) {
+		fmt.Println("Collecting the trends by tweet volume, biggest first")
+	}
+
+	// Build the query for every trend, checking them up front if requested.
+	// Trends whose names sanitize the same (#Bitcoin and Bitcoin) get
+	// directories of their own rather than overwriting each other's files.
+	var jobs []trendJob
+	dirs := slug.NewUnique(sanitizeTrend)
+	for i, t := range ordered {
+		if *topN > 0 && len(jobs) == *topN {
+			fmt.Printf("Reached -top-n %d; skipping the remaining %d trends\n", *topN, len(ordered)-i)
+			break
+		}
+		trend := t.Name
+		// Junk trends would only waste a query and leave a junk file
+		if reason := trendFilter.Reject(trend); reason != "" {
+			fmt.Printf("Skipping trend '%s' (%s)\n", trend, reason)
+			continue
+		}
+
+		// A trend with nothing left to name its directory after is skipped
+		if sanitizeTrend(trend) == "" {
 			fmt.Printf("Skipping trend (empty after sanitization): %s\n", trend)
 			continue
 		}
 
-		// Create query: trend + min likes filter
-		query := fmt.Sprintf(`"%s"%s`, trend, minLikesFilter)
-		outputFile := generateOutputFilename(sanitizedTrend, targetTweets)
+		// Create query: exact trend + min likes filter
+		query, err := twitterquery.New().Exact(trend).MinFaves(minLikes).Build()
+		if err != nil {
+			fmt.Printf("Skipping trend (invalid query): %v\n", err)
+			continue
+		}
+
+		if *preflight {
+			err := collect.Preflight(api, query)
+			monitor.Beat()
+			if err != nil {
+				fmt.Printf("Skipping trend '%s' (preflight: %v)\n", trend, err)
+				continue
+			}
+		}
+		sanitizedTrend, clash := dirs.Slug(trend)
+		if clash != "" {
+			fmt.Printf("⚠️ Trend '%s' sanitizes like '%s'; saving it to '%s'\n", trend, clash, sanitizedTrend)
+		}
+		jobs = append(jobs, trendJob{trend: trend, sanitized: sanitizedTrend, query: query})
+	}
+	if len(jobs) == 0 {
+		abort(summary, notifier, auditLog, runDir, fmt.Errorf("no usable trends to collect"))
+	}
+	monitor.SetReady(true)
+
+	// Process each trend, holding a lock on its query so concurrent runs do not collect it twice
+	var held *lock.Lock
+	for i, job := range jobs {
+		held.Release()
+		if err := budget.Check(); err != nil {
+			fmt.Printf("\n⚠️ %v; skipping the remaining %d trends\n", err, len(jobs)-i)
+			summary.Note = fmt.Sprintf("Stopped early: %v. Skipped the remaining %d of %d trends.", err, len(jobs)-i, len(jobs))
+			break
+		}
+		trend, sanitizedTrend, query := job.trend, job.sanitized, job.query
+		start := time.Now()
+		var counts collect.Stats
+		collector.Stats = &counts
+		result := report.Query{Query: query, Label: trend, Requested: targetTweets}
+		fmt.Printf("\n=== Processing trend: %s ===\n", trend)
+		collector.Pipeline = append(pipeline.Pipeline{pipeline.NewTopic(trend, query)}, runPipeline...)
+		if trendDedup != nil {
+			trendDedup.SetTrend(trend)
+		}
+		if held, err = lock.Query("fetch-trends", runDir.ID, query, *lockWait); err != nil {
+			fmt.Printf("Skipping trend '%s': %v\n", trend, err)
+			result.Error = err.Error()
+			summary.Add(result)
+			continue
+		}
+
+		outputFile := filepath.Join(runDir.Path, sanitizedTrend, trendFile)
+		if *encrypt {
+			outputFile += crypt.Extension
+		}
+		if *balanced == 0 && st == nil {
+			if err := os.MkdirAll(filepath.Dir(outputFile), 0755); err != nil {
+				held.Release()
+				abort(summary, notifier, auditLog, runDir, fmt.Errorf("failed to create trend directory: %w", err))
+			}
+		}
 
 		fmt.Printf("Query: %s\n", query)
-		fmt.Printf("Output file: %s\n", outputFile)
+		if *balanced == 0 && st == nil {
+			fmt.Printf("Output file: %s\n", outputFile)
+		}
 		fmt.Printf("Target tweets: %d\n", targetTweets)
 
-		// Fetch tweets for this trend
-		tweets, err := fetchTrendTweets(c, query, targetTweets)
-		if err != nil {
+		// With flush thresholds the tweets are streamed into the trend's file as
+		// they are flushed rather than returned
+		var writer *dataset.Writer
+		collector.Sink = out
+		if collector.Flushing() {
+			if writer, err = dataset.NewWriter(outputFile, dataset.File{Trend: trend, Query: query}); err != nil {
+				fmt.Printf("Error saving tweets for trend '%s': %v\n", trend, err)
+				result.Error = err.Error()
+				result.Seconds = report.Since(start)
+				summary.Add(result)
+				continue
+			}
+			collector.Sink = sink.Join(writer, out)
+		}
+
+		// Fetch tweets for this trend; on error keep what was collected so far
+		tweets, err := collector.Collect(context.Background(), query, targetTweets)
+		result.Fetched, result.Dropped = counts.Fetched, counts.Dropped
+		kept := len(tweets)
+		if writer != nil {
+			kept = writer.Count()
+		}
+		if errors.Is(err, collect.ErrBudgetExhausted) {
+			fmt.Printf("⚠️ %v\n", err)
+		} else if err != nil {
 			fmt.Printf("Error fetching tweets for trend '%s': %v\n", trend, err)
+			result.Error = err.Error()
+		}
+
+		// Queue a batch that failed after all retries so it can be finished later;
+		// balanced selections and stores have no per-trend file to add to, so
+		// they are not queued
+		var batchErr *collect.BatchError
+		if errors.As(err, &batchErr) && *retryQueue != "" && *balanced == 0 && st == nil {
+			entry := retry.NewEntry("fetch-trends", outputFile, batchErr)
+			entry.Trend = trend
+			if qerr := retry.Append(*retryQueue, entry); qerr != nil {
+				fmt.Printf("Error queueing failed batch: %v\n", qerr)
+			} else {
+				fmt.Printf("🔁 Queued the failed batch in %s\n", *retryQueue)
+			}
+		}
+
+		if kept == 0 {
+			if writer != nil {
+				writer.Discard()
+			}
+			fmt.Printf("No tweets collected for trend '%s', skipping\n", trend)
+			result.Seconds = report.Since(start)
+			summary.Add(result)
+			continue
+		}
+
+		// Sorting last makes the order independent of how the API paged the results
+		stages := slices.Clone(runStages)
+		if *sortBy != "" && *balanced == 0 {
+			dataset.Sort(tweets, *sortBy)
+			stages = append(stages, "sort("+*sortBy+")")
+		}
+
+		if *balanced > 0 {
+			selected := selectTweets(tweets, *balanced, *pick, rng)
+			for i := range selected {
+				if selected[i].Metadata == nil {
+					selected[i].Metadata = map[string]any{}
+				}
+				selected[i].Metadata["trend"] = trend
+			}
+			selections = append(selections, trendSelection{trend: trend, tweets: selected})
+			fmt.Printf("✅ Selected %d of %d tweets for trend '%s'\n", len(selected), len(tweets), trend)
+			result.Saved = len(selected)
+			result.Seconds = report.Since(start)
+			summary.Add(result)
+			continue
+		}
+
+		saveStart := time.Now()
+		if st != nil {
+			res, err := st.Ingest(tweets, store.Source{Tool: "fetch-trends", Run: runDir.ID, Query: query, Trend: trend, Pipeline: stages, Provenance: provenance})
+			collector.Metrics.Add(collect.PhaseWrite, "save", len(tweets), res.Added, time.Since(saveStart))
+			result.Saved, result.Files = res.Added, res.Files
+			if err != nil {
+				fmt.Printf("Error ingesting tweets for trend '%s': %v\n", trend, err)
+				result.Error = err.Error()
+			} else {
+				fmt.Printf("✅ Added %d new tweets for trend '%s' to store %s (%d duplicates skipped)\n", res.Added, trend, st.Dir(), res.Duplicates)
+			}
+			result.Seconds = report.Since(start)
+			summary.Add(result)
 			continue
 		}
 
 		// Save to file
-		if err := saveTrendTweets(tweets, trend, query, outputFile); err != nil {
+		if writer != nil {
+			err = writer.Close()
+		} else {
+			err = saveTrendTweets(tweets, trend, query, outputFile, encryptionKey)
+		}
+		collector.Metrics.Add(collect.PhaseWrite, "save", kept, kept, time.Since(saveStart))
+		if err != nil {
 			fmt.Printf("Error saving tweets for trend '%s': %v\n", trend, err)
+			result.Error = err.Error()
+			result.Seconds = report.Since(start)
+			summary.Add(result)
 			continue
 		}
+		result.Saved = kept
+		result.Files = []string{outputFile}
+
+		if manifestPath, err := manifest.Write(outputFile, &manifest.Manifest{
+			Tool:       "fetch-trends",
+			Query:      query,
+			Trend:      trend,
+			Records:    kept,
+			Pipeline:   stages,
+			Provenance: provenance,
+		}); err != nil {
+			fmt.Printf("Error writing manifest for trend '%s': %v\n", trend, err)
+			result.Error = err.Error()
+		} else {
+			result.Files = append(result.Files, manifestPath, manifest.CardPath(outputFile))
+		}
+		if statsPath, err := writeTrendStats(outputFile, tweets, writer != nil); err != nil {
+			fmt.Printf("Error writing statistics for trend '%s': %v\n", trend, err)
+		} else {
+			result.Files = append(result.Files, statsPath)
+		}
 
-		fmt.Printf("✅ Successfully saved %d tweets for trend '%s'\n", len(tweets), trend)
+		fmt.Printf("✅ Successfully saved %d tweets for trend '%s'\n", kept, trend)
+		result.Seconds = report.Since(start)
+		summary.Add(result)
+	}
+	held.Release()
+
+	if trendDedup != nil {
+		// Per-trend files are written as each trend finishes, before the later
+		// trends a tweet matches are known, so the matches go in a file of their own
+		path := filepath.Join(runDir.Path, trendMatchesFile)
+		if err := writeTrendMatches(path, trendDedup.Matches()); err != nil {
+			fmt.Printf("Error writing trend matches: %v\n", err)
+		} else {
+			fmt.Printf("🔗 %d tweets matched more than one trend and were kept once; trends per tweet written to %s\n", trendDedup.Shared(), path)
+			summary.Files = append(summary.Files, path)
+		}
+		for _, sel := range selections {
+			for i := range sel.tweets {
+				sel.tweets[i].Metadata["matched_trends"] = trendDedup.Trends(sel.tweets[i].Id)
+			}
+		}
 	}
 
-	fmt.Println("\n✅ All trends processed!")
-}
-
-// getTrends fetches trending topics using the gopher client.
-// It submits a GetTrends job via SearchTwitterWithArgsAsync with Type=CapGetTrends,
-// waits for completion, then extracts trend strings from the returned documents.
-func getTrends(c *client.Client) ([]string, error) {
-	args := twitter.NewSearchArguments()
-	args.Type = types.CapGetTrends
+	if *balanced > 0 {
+		filename, count, err := saveBalanced(runDir.Path, selections, *balanced, *pick, *sortBy, rng, seedValue, runStages, provenance, encryptionKey)
+		if err != nil {
+			fmt.Printf("Error saving balanced dataset: %v\n", err)
+			summary.Note = strings.TrimSpace(summary.Note + " Saving the balanced dataset failed: " + err.Error())
+		} else {
+			summary.Files = append(summary.Files, filename, manifest.Path(filename), manifest.CardPath(filename))
+			// Trends were cut down to the smallest selection in the combined file
+			for i := range summary.Queries {
+				summary.Queries[i].Saved = min(summary.Queries[i].Saved, count)
+			}
+		}
+	}
 
-	resp, err := c.SearchTwitterWithArgsAsync(args)
-	if err != nil {
-		return nil, fmt.Errorf("failed to submit get trends job: %w", err)
+	if out != nil {
+		if err := out.Close(); err != nil {
+			fmt.Printf("Error closing sink: %v\n", err)
+		}
 	}
-	if resp.Error != "" {
-		return nil, fmt.Errorf("get trends job error: %s", resp.Error)
+	if buffered != nil {
+		fmt.Printf("📊 Sink queue: %s\n", buffered.Stats())
 	}
-	if resp.UUID == "" {
-		return nil, fmt.Errorf("get trends job returned no job ID")
+	if fanOut != nil {
+		for _, s := range fanOut.Stats() {
+			fmt.Printf("📤 Sink %s\n", s)
+		}
+		if missed := fanOut.Failures(); missed != "" {
+			summary.Note = strings.TrimSpace(summary.Note + " Optional sinks missed batches: " + missed + ".")
+		}
 	}
 
-	fmt.Printf("Get trends job submitted, waiting for completion (job ID: %s)...\n", resp.UUID)
-	docs, err := c.WaitForJobCompletion(resp.UUID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to wait for trends job: %w", err)
+	if err := pipe.Close(); err != nil {
+		fmt.Printf("Error closing processing stages: %v\n", err)
 	}
 
-	if len(docs) == 0 {
-		return nil, fmt.Errorf("no trends returned")
+	if err := budget.Check(); err != nil && summary.Note == "" {
+		summary.Note = fmt.Sprintf("Stopped early: %v.", err)
 	}
-
-	trends := make([]string, 0, len(docs))
-	for _, d := range docs {
-		// tee-indexer getDocsFromTrends uses Id and Content as the trend string
-		s := d.Id
-		if s == "" {
-			s = d.Content
-		}
-		s = strings.TrimSpace(s)
-		if s != "" {
-			trends = append(trends, s)
+	if err := cassette.Close(); err != nil {
+		fmt.Printf("⚠️ %v\n", err)
+	}
+	if n, waited := pacer.Waits(); n > 0 {
+		fmt.Printf("⏳ Rate limit: %s\n", pacer.Stats())
+		summary.Note = strings.TrimSpace(summary.Note + fmt.Sprintf(" Requests waited %s for the rate limit to reset.", waited.Round(time.Second)))
+	}
+	if breaker != nil && breaker.Opens() > 0 {
+		fmt.Printf("🔌 Circuit breaker: %s\n", breaker.Stats())
+		summary.Note = strings.TrimSpace(summary.Note + fmt.Sprintf(" The circuit breaker paused requests %d times.", breaker.Opens()))
+	}
+	if summary.Stages = collector.Metrics.Stages(); len(summary.Stages) > 0 {
+		fmt.Printf("⏱️ Time by stage: %s\n", report.StageShares(summary.Stages))
+	}
+	summary.Finish()
+	recordAudit(auditLog, summary)
+	if *summaryPath != "" {
+		if mdPath, err := summary.Write(*summaryPath); err != nil {
+			fmt.Printf("Error writing run summary: %v\n", err)
+		} else {
+			fmt.Printf("\n📝 Run summary written to %s and %s\n", *summaryPath, mdPath)
 		}
 	}
-	return trends, nil
+	sendNotification(notifier, summary)
+
+	fmt.Println("\n✅ All trends processed!")
 }
 
-// fetchTrendTweets fetches tweets for a specific trend query
-func fetchTrendTweets(c *client.Client, query string, targetCount int) ([]types.Document, error) {
-	var allTweets []types.Document
-	currentQuery := query
-	maxResults := apiMaxResults
-	
-	if targetCount < maxResults {
-		maxResults = targetCount
+// writeTrendStats writes the statistics of a trend's tweets to stats.json in
+// its directory. Tweets flushed to the file as they arrived are read back
+// from it one at a time.
+func writeTrendStats(outputFile string, tweets []types.Document, flushed bool) (string, error) {
+	var s *stats.Stats
+	if flushed {
+		acc := stats.NewAccumulator()
+		if _, err := dataset.Scan(outputFile, func(doc types.Document) error {
+			acc.Add(doc)
+			return nil
+		}); err != nil {
+			return "", err
+		}
+		s = acc.Stats()
+	} else {
+		s = stats.Compute(tweets)
 	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal statistics: %w", err)
+	}
+	path := filepath.Join(filepath.Dir(outputFile), "stats.json")
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return "", fmt.Errorf("failed to write statistics: %w", err)
+	}
+	return path, nil
+}
 
-	for len(allTweets) < targetCount {
-		fmt.Printf("Fetching batch... (current: %d/%d tweets)\n", len(allTweets), targetCount)
-
-		// Create search arguments
-		args := twitter.NewSearchArguments()
-		args.Query = currentQuery
-		args.MaxResults = maxResults
-		args.Type = types.CapSearchByQuery
+// trendJob is a trend whose query has been built (and preflighted)
+type trendJob struct {
+	trend     string
+	sanitized string
+	query     string
+}
 
-		// Search for tweets
-		results, err := c.SearchTwitterWithArgs(args)
-		if err != nil {
-			fmt.Printf("Error searching tweets: %v\n", err)
-			// Don't fail entirely, just return what we have
-			break
-		}
+// trendSelection holds the tweets chosen for one trend in balanced mode
+type trendSelection struct {
+	trend  string
+	tweets []types.Document
+}
 
-		if len(results) == 0 {
-			fmt.Println("No more results available.")
-			break
-		}
+// selectTweets picks n tweets of a trend, either the most engaged or a uniform random subset
+func selectTweets(tweets []types.Document, n int, pick string, rng *rand.Rand) []types.Document {
+	if pick == pickRandom {
+		return sample.Random(tweets, n, rng)
+	}
+	return sample.Top(tweets, n)
+}
 
-		allTweets = append(allTweets, results...)
-		fmt.Printf("Fetched %d tweets. Total: %d/%d\n", len(results), len(allTweets), targetCount)
+// trendMatchesFile lists the trends each tweet matched under -dedup-trends
+const trendMatchesFile = "trend_matches.jsonl"
 
-		if len(allTweets) >= targetCount {
-			break
+// writeTrendMatches writes one line per tweet with the trends it matched
+func writeTrendMatches(path string, matches []pipeline.TrendMatch) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, m := range matches {
+		if err := enc.Encode(m); err != nil {
+			return fmt.Errorf("failed to marshal trend matches: %w", err)
 		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write trend matches: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write trend matches: %w", err)
+	}
+	return nil
+}
 
-		// Get last tweet ID for pagination
-		lastTweetID, err := getLastTweetID(results)
-		if err != nil {
-			fmt.Printf("Error getting last tweet ID: %v\n", err)
-			break
-		}
+// trendList collects the values of a repeated -trend flag
+type trendList []string
 
-		// Update query with max_id for pagination
-		currentQuery = fmt.Sprintf("%s max_id:%d", query, lastTweetID)
-	}
+func (l *trendList) String() string {
+	return strings.Join(*l, ", ")
+}
 
-	return allTweets, nil
+func (l *trendList) Set(trend string) error {
+	if trend = strings.TrimSpace(trend); trend == "" {
+		return fmt.Errorf("empty trend")
+	}
+	*l = append(*l, trend)
+	return nil
 }
 
-// getLastTweetID extracts the tweet ID from the last document
-func getLastTweetID(results []types.Document) (int64, error) {
-	if len(results) == 0 {
-		return 0, fmt.Errorf("no results")
-	}
-
-	lastDoc := results[len(results)-1]
-	if metadata := lastDoc.Metadata; metadata != nil {
-		if tweetID, ok := metadata["tweet_id"]; ok {
-			switch v := tweetID.(type) {
-			case int64:
-				return v, nil
-			case float64:
-				return int64(v), nil
-			case string:
-				id, err := strconv.ParseInt(v, 10, 64)
-				if err != nil {
-					return 0, err
-				}
-				return id, nil
-			}
-		}
+// latestSnapshot returns the latest trends snapshot of region in dir, if it
+// was captured no longer than maxAge before now
+func latestSnapshot(dir, region string, maxAge time.Duration, now time.Time) (*trending.Snapshot, string, error) {
+	if dir == "" || maxAge <= 0 {
+		return nil, "", fmt.Errorf("falling back to a snapshot is disabled")
+	}
+	snapshot, path, err := trending.Latest(dir, region)
+	if err != nil {
+		return nil, "", err
+	}
+	age, err := snapshot.Age(now)
+	if err != nil {
+		return nil, "", err
 	}
+	if age > maxAge {
+		return nil, "", fmt.Errorf("the latest snapshot, %s, is %s old (-trends-fallback-age %s)", path, age.Round(time.Second), maxAge)
+	}
+	return snapshot, path, nil
+}
 
-	if lastDoc.Id != "" {
-		id, err := strconv.ParseInt(lastDoc.Id, 10, 64)
-		if err == nil {
-			return id, nil
-		}
+// stopProfiler writes the run's profiles, if any were requested
+func stopProfiler(p *profile.Profiler) {
+	if err := p.Stop(); err != nil {
+		fmt.Printf("⚠️ %v\n", err)
 	}
+}
 
-	return 0, fmt.Errorf("could not extract tweet_id")
+// abort records a run that stopped before collecting, notifies and exits
+func abort(summary *report.Summary, notifier *notify.Dispatcher, auditLog *audit.Log, runDir *rundir.Run, err error) {
+	summary.Abort(err)
+	recordAudit(auditLog, summary)
+	sendNotification(notifier, summary)
+	runDir.Fatalf("%v", err)
 }
 
-// sanitizeTrend sanitizes a trend string for use in filenames
-func sanitizeTrend(trend string) string {
-	// Convert to lowercase
-	sanitized := strings.ToLower(trend)
-	
-	// Replace spaces with underscores
-	sanitized = strings.ReplaceAll(sanitized, " ", "_")
-	
-	// Remove special characters (keep alphanumeric and underscore)
-	reg := regexp.MustCompile(`[^a-z0-9_]`)
-	sanitized = reg.ReplaceAllString(sanitized, "")
-	
-	// Remove multiple consecutive underscores
-	reg = regexp.MustCompile(`_+`)
-	sanitized = reg.ReplaceAllString(sanitized, "_")
-	
-	// Trim leading/trailing underscores
-	sanitized = strings.Trim(sanitized, "_")
-	
-	return sanitized
+// recordAudit appends the outcome of the finished run to the audit log
+func recordAudit(auditLog *audit.Log, summary *report.Summary) {
+	if err := auditLog.Finish(summary); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️ %v\n", err)
+	}
 }
 
-// generateOutputFilename creates a filename for trend tweets
-func generateOutputFilename(trend string, targetCount int) string {
-	// Ensure data directory exists
-	os.MkdirAll(dataDir, 0755)
-	
-	filename := fmt.Sprintf("trend_%s_%d.json", trend, targetCount)
-	return filepath.Join(dataDir, filename)
+// sendNotification sends the run summary to the configured notifiers
+func sendNotification(notifier *notify.Dispatcher, summary *report.Summary) {
+	sent, err := notifier.Send(context.Background(), summary)
+	if err != nil {
+		fmt.Printf("Error sending notification: %v\n", err)
+		return
+	}
+	if sent {
+		fmt.Println("📣 Notification sent")
+	}
 }
 
-// saveTrendTweets saves tweets to a JSON file
-func saveTrendTweets(tweets []types.Document, trend, query, filename string) error {
+// saveBalanced writes all trend selections to one combined dataset. Every trend
+// contributes the same number of tweets: if a trend has fewer than perTrend, the
+// others are cut down to match it.
+// It returns the file written and the number of tweets taken per trend.
+func saveBalanced(runDir string, selections []trendSelection, perTrend int, pick, sortBy string, rng *rand.Rand, seed *uint64, stages []string, provenance manifest.Provenance, key []byte) (string, int, error) {
+	if len(selections) == 0 {
+		return "", 0, fmt.Errorf("no trend returned any tweets")
+	}
+
+	count := perTrend
+	for _, sel := range selections {
+		count = min(count, len(sel.tweets))
+	}
+	if count < perTrend {
+		fmt.Printf("⚠️ Some trends have fewer than %d tweets; using %d per trend\n", perTrend, count)
+	}
+
+	var tweets []types.Document
+	trends := make([]string, len(selections))
+	for i, sel := range selections {
+		tweets = append(tweets, selectTweets(sel.tweets, count, pick, rng)...)
+		trends[i] = sel.trend
+	}
+
+	stages = append(stages, fmt.Sprintf("balance(per_trend=%d,trends=%d,pick=%s)", count, len(selections), pick))
+	if sortBy != "" {
+		dataset.Sort(tweets, sortBy)
+		stages = append(stages, "sort("+sortBy+")")
+	}
+
+	filename := filepath.Join(runDir, fmt.Sprintf("trends_balanced_%d.json", count))
+	if key != nil {
+		filename += crypt.Extension
+	}
+	query := fmt.Sprintf(`"<trend>" min_faves:%d`, minLikes)
+	if err := saveTrendTweets(tweets, strings.Join(trends, ", "), query, filename, key); err != nil {
+		return "", 0, err
+	}
+
+	if _, err := manifest.Write(filename, &manifest.Manifest{
+		Tool:       "fetch-trends",
+		Query:      query,
+		Trend:      strings.Join(trends, ", "),
+		Records:    len(tweets),
+		Pipeline:   stages,
+		Seed:       seed,
+		Provenance: provenance,
+	}); err != nil {
+		return "", 0, fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	fmt.Printf("✅ Saved %d tweets (%d per trend, %d trends) to %s\n", len(tweets), count, len(selections), filename)
+	return filename, count, nil
+}
+
+// saveTrendTweets saves tweets to a JSON file, encrypted with key if non-nil
+func saveTrendTweets(tweets []types.Document, trend, query, filename string, key []byte) error {
 	output := struct {
 		TotalTweets int              `json:"total_tweets"`
 		Trend       string           `json:"trend"`
@@ -283,6 +933,12 @@ func saveTrendTweets(tweets []types.Document, trend, query, filename string) err
 		return fmt.Errorf("failed to marshal: %w", err)
 	}
 
+	if key != nil {
+		if data, err = crypt.Encrypt(key, data); err != nil {
+			return fmt.Errorf("failed to encrypt: %w", err)
+		}
+	}
+
 	if err := os.WriteFile(filename, data, 0644); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/grant/sn42/pkg/authors"
+	"github.com/grant/sn42/pkg/collect"
+	"github.com/grant/sn42/pkg/crypt"
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/lock"
+	"github.com/grant/sn42/pkg/pipeline"
+	"github.com/grant/sn42/pkg/report"
+	"github.com/grant/sn42/pkg/store"
+	"github.com/grant/sn42/pkg/twitterquery"
+)
+
+// followRoot is where -follow keeps a query's store unless -store is given
+const followRoot = "data/follow"
+
+// followStore returns the default store for following query
+func followStore(query string) string {
+	return filepath.Join(followRoot, sanitizeQuery(query))
+}
+
+// follow polls job every interval for tweets newer than the newest one in the
+// store, and ingests them, until ctx is done or the run's caps are reached.
+// Each poll fetches at most the run's target. A poll that fails is reported
+// and tried again at the next one, as since_id only moves on after a poll
+// that succeeded.
+func (r *run) follow(ctx context.Context, job queryJob, every time.Duration) (report.Query, error) {
+	result := report.Query{Query: job.query, Label: job.label, Requested: r.target}
+
+	// Another run collecting the same query would repeat its API calls and sink writes
+	held, err := lock.Query("fetch-tweets", r.runID, job.query, r.lockWait)
+	if err != nil {
+		return result, err
+	}
+	defer held.Release()
+
+	collector := *r.collector
+	if len(job.keywords) > 0 {
+		collector.Pipeline = append(pipeline.Pipeline{pipeline.NewMatchedKeywords(job.keywords)}, collector.Pipeline...)
+	}
+	collector.Pipeline = append(pipeline.Pipeline{pipeline.NewTopic("", job.query)}, collector.Pipeline...)
+	collector.Pipeline = append(pipeline.Pipeline{pipeline.NewSkipExisting(r.existing)}, collector.Pipeline...)
+	stages := append(collector.Pipeline.Names(), fmt.Sprintf("follow(every=%s)", every))
+
+	sinceID := newestID(r.existing)
+	fmt.Printf("Following %s every %s into store %s\n", job.query, every, r.store.Dir())
+	if sinceID > 0 {
+		fmt.Printf("Resuming after tweet %d, the newest in the store\n", sinceID)
+	} else {
+		fmt.Printf("The store is empty: the first poll collects the latest %d tweets\n", r.target)
+	}
+
+	for poll := 1; ; poll++ {
+		query := job.query
+		if sinceID > 0 {
+			if query, err = twitterquery.Raw(job.query).SinceID(sinceID).Build(); err != nil {
+				return result, fmt.Errorf("failed to build poll query: %w", err)
+			}
+		}
+		var counts collect.Stats
+		collector.Stats = &counts
+		fmt.Printf("\n🔄 Poll %d: %s\n", poll, query)
+		tweets, err := collector.Collect(ctx, query, r.target)
+		result.Fetched += counts.Fetched
+		result.Dropped += counts.Dropped
+
+		if len(tweets) > 0 {
+			res, ierr := r.store.Ingest(tweets, store.Source{
+				Tool:       "fetch-tweets",
+				Run:        r.runID,
+				Query:      job.query,
+				SavedQuery: r.savedName,
+				Pipeline:   stages,
+				Provenance: r.provenance,
+			})
+			result.Saved += res.Added
+			result.Files = append(result.Files, res.Files...)
+			if ierr != nil {
+				return result, fmt.Errorf("failed to ingest tweets: %w", ierr)
+			}
+		}
+
+		switch {
+		case errors.Is(err, collect.ErrBudgetExhausted):
+			fmt.Fprintf(os.Stderr, "\n⚠️ %v; no longer following\n", err)
+			return result, nil
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "⚠️ Poll %d failed, trying again at the next poll: %v\n", poll, err)
+		default:
+			// Polls page back from the newest tweet, so one that stops at the
+			// target leaves out the new tweets older than those it fetched
+			if sinceID > 0 && counts.Kept >= r.target {
+				fmt.Fprintf(os.Stderr, "⚠️ Poll %d reached AMOUNT (%d) before catching up; older new tweets were skipped. Poll more often or raise AMOUNT\n", poll, r.target)
+			}
+			sinceID = max(sinceID, counts.Newest)
+			fmt.Printf("✅ Poll %d: %d new tweets, %d in the store\n", poll, len(tweets), r.store.Meta().Records)
+		}
+
+		select {
+		case <-ctx.Done():
+			fmt.Println("\nStopped following")
+			return result, nil
+		case <-time.After(every):
+		}
+	}
+}
+
+// newestID returns the highest tweet ID in ids, or 0 if there are none
+func newestID(ids map[string]bool) int64 {
+	var newest int64
+	for id := range ids {
+		if n, err := strconv.ParseInt(id, 10, 64); err == nil && n > newest {
+			newest = n
+		}
+	}
+	return newest
+}
+
+// followUsers polls the timeline query of every account in jobs, one after
+// the other, for tweets newer than the newest collected from it, and appends
+// them to the account's dataset in dir. With every > 0 it goes round again
+// every interval until ctx is done or the run's caps are reached; otherwise
+// it stops after one round. Each account's since_id is kept in dir's state
+// file, so a later run carries on where this one stopped. A poll that fails
+// is tried again at the next round; the account's result keeps the error
+// until a poll of it succeeds.
+func (r *run) followUsers(ctx context.Context, jobs []queryJob, every time.Duration, dir string) ([]report.Query, error) {
+	results := make([]report.Query, len(jobs))
+	for i, job := range jobs {
+		results[i] = report.Query{Query: job.query, Label: job.label, Requested: r.target}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return results, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	// The datasets and state file are rewritten by every poll
+	held, err := lock.File("fetch-tweets", r.runID, dir, r.lockWait)
+	if err != nil {
+		return results, err
+	}
+	defer held.Release()
+	state, err := authors.LoadState(dir)
+	if err != nil {
+		return results, err
+	}
+	// An account missing from the state, e.g. after it was deleted, resumes
+	// from the newest tweet in its dataset
+	for _, job := range jobs {
+		if a := state.Get(job.label); a.SinceID == 0 {
+			ids, err := dataset.IDs(userDataset(dir, job.label, r.key))
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return results, fmt.Errorf("failed to read the dataset of @%s: %w", job.label, err)
+			}
+			a.SinceID, a.Tweets = newestID(ids), len(ids)
+		}
+	}
+	fmt.Printf("Following %d accounts into %s\n", len(jobs), dir)
+
+	for round := 1; ; round++ {
+		if every > 0 {
+			fmt.Printf("\n🔄 Round %d\n", round)
+		}
+		for i, job := range jobs {
+			if ctx.Err() != nil {
+				break
+			}
+			user := job.label
+			sinceID := state.Get(user).SinceID
+			query := job.query
+			if sinceID > 0 {
+				if query, err = twitterquery.Raw(job.query).SinceID(sinceID).Build(); err != nil {
+					return results, fmt.Errorf("failed to build poll query for @%s: %w", user, err)
+				}
+			}
+			collector := *r.collector
+			collector.Pipeline = append(pipeline.Pipeline{pipeline.NewTopic("", job.query)}, collector.Pipeline...)
+			stages := collector.Pipeline.Names()
+			if every > 0 {
+				stages = append(stages, fmt.Sprintf("follow(every=%s)", every))
+			}
+			var counts collect.Stats
+			collector.Stats = &counts
+			fmt.Printf("\n=== @%s (%d/%d): %s ===\n", user, i+1, len(jobs), query)
+			start := time.Now()
+			tweets, err := collector.Collect(ctx, query, r.target)
+			results[i].Fetched += counts.Fetched
+			results[i].Dropped += counts.Dropped
+
+			total := state.Get(user).Tweets
+			if len(tweets) > 0 {
+				ur := *r
+				ur.appendTo = userDataset(dir, user, r.key)
+				res, aerr := ur.appendTweets(report.Query{}, tweets, job.query, stages)
+				results[i].Saved += res.Saved
+				results[i].Files = appendNew(results[i].Files, res.Files...)
+				if aerr != nil {
+					results[i].Error = aerr.Error()
+					return results, aerr
+				}
+				total += res.Saved
+			}
+			results[i].Seconds += report.Since(start)
+
+			if errors.Is(err, collect.ErrBudgetExhausted) {
+				fmt.Fprintf(os.Stderr, "\n⚠️ %v; no longer following\n", err)
+				return results, nil
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "⚠️ Poll of @%s failed, trying again at the next round: %v\n", user, err)
+				results[i].Error = err.Error()
+				continue
+			}
+			results[i].Error = ""
+			if sinceID > 0 && counts.Kept >= r.target {
+				fmt.Fprintf(os.Stderr, "⚠️ Poll of @%s reached AMOUNT (%d) before catching up; older new tweets were skipped\n", user, r.target)
+			}
+			state.Polled(user, counts.Newest, total, time.Now())
+			if err := state.Save(); err != nil {
+				return results, err
+			}
+		}
+
+		if every == 0 {
+			return results, nil
+		}
+		select {
+		case <-ctx.Done():
+			fmt.Println("\nStopped following")
+			return results, nil
+		case <-time.After(every):
+		}
+	}
+}
+
+// userDataset returns the path of user's dataset in dir, encrypted with key if set
+func userDataset(dir, user string, key []byte) string {
+	if key != nil {
+		return authors.Dataset(dir, user) + crypt.Extension
+	}
+	return authors.Dataset(dir, user)
+}
+
+// appendNew adds the paths not already in files
+func appendNew(files []string, paths ...string) []string {
+	for _, p := range paths {
+		if !slices.Contains(files, p) {
+			files = append(files, p)
+		}
+	}
+	return files
+}
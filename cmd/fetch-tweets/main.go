@@ -1,57 +1,351 @@
 package main
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
-	"github.com/gopher-lab/gopher-client/client"
+	"github.com/grant/sn42/pkg/alert"
+	"github.com/grant/sn42/pkg/apiclient"
+	"github.com/grant/sn42/pkg/audit"
+	"github.com/grant/sn42/pkg/authors"
+	"github.com/grant/sn42/pkg/collect"
+	"github.com/grant/sn42/pkg/crypt"
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/envprofile"
+	"github.com/grant/sn42/pkg/estimate"
+	"github.com/grant/sn42/pkg/health"
+	"github.com/grant/sn42/pkg/lock"
+	"github.com/grant/sn42/pkg/manifest"
+	"github.com/grant/sn42/pkg/mock"
+	"github.com/grant/sn42/pkg/notify"
+	"github.com/grant/sn42/pkg/pipeline"
+	"github.com/grant/sn42/pkg/profile"
+	"github.com/grant/sn42/pkg/query"
+	"github.com/grant/sn42/pkg/ratelimit"
+	"github.com/grant/sn42/pkg/report"
+	"github.com/grant/sn42/pkg/retry"
+	"github.com/grant/sn42/pkg/rundir"
+	"github.com/grant/sn42/pkg/sample"
+	"github.com/grant/sn42/pkg/sink"
+	"github.com/grant/sn42/pkg/store"
+	"github.com/grant/sn42/pkg/twitterquery"
+	"github.com/grant/sn42/pkg/vcr"
 	"github.com/joho/godotenv"
-	"github.com/masa-finance/tee-worker/v2/api/args/twitter"
 	"github.com/masa-finance/tee-worker/v2/api/types"
 )
 
 const (
 	defaultQuery  = `"bitcoin" min_faves:1000`
 	defaultAmount = 10000
-	apiMaxResults = 100 // Maximum results per API request
-	dataDir       = "data"
 )
 
 func main() {
+	encrypt := flag.Bool("encrypt", false, "Encrypt the output file with AES-256-GCM (key from ENCRYPTION_KEY)")
+	pipeFlags := pipeline.RegisterFlags(flag.CommandLine)
+	reservoir := flag.Int("reservoir", 0, "Keep a uniform random sample of this many tweets out of the AMOUNT collected")
+	seed := sample.RegisterSeedFlag(flag.CommandLine)
+	maxTotal := flag.Int("max-total-tweets", 0, "Stop the whole run once this many tweets have been collected across all queries (0 = no limit)")
+	healthFlags := health.RegisterFlags(flag.CommandLine)
+	alertFlags := alert.RegisterFlags(flag.CommandLine)
+	profileFlags := profile.RegisterFlags(flag.CommandLine)
+	maxRuntime := flag.Duration("max-runtime", 0, "Stop the whole run after this long, e.g. 2h (0 = no limit)")
+	expand := flag.Int("expand", 0, "Widen the query with up to this many co-occurring hashtags found by a probe fetch")
+	probe := flag.Int("probe", collect.APIMaxResults, "Number of tweets fetched by the -expand probe")
+	lockWait := flag.Duration("lock-wait", 0, "Wait this long for another run collecting the same query (or replaying the same -retry-file) to finish instead of failing (0 = fail immediately)")
+	preflight := flag.Bool("preflight", true, "Check every query with a 1-result probe before collecting and stop if any is rejected or empty")
+	keywordsFile := flag.String("keywords", "", "File with one keyword per line, packed into as few OR queries as fit (QUERY adds operators)")
+	auditPath := flag.String("audit-log", "", "Append who started the run, its arguments and its outcome to this audit log, shared by all runs (default: "+audit.FileName+" in the runs directory; empty to disable)")
+	profileName := envprofile.RegisterFlag(flag.CommandLine)
+	apiFlags := apiclient.RegisterFlags(flag.CommandLine)
+	summaryPath := flag.String("summary", "", "Write the run summary here as JSON, plus a Markdown table next to it (default: summary.json in the run directory; empty to disable)")
+	sinkQueue := flag.Int("sink-queue", 0, "Write to the sink in the background through a queue of this many batches, so fetching only waits when it is full (0 writes synchronously)")
+	flushEvery := flag.Int("flush-every", 0, "Write tweets to the output file and sink every N records instead of holding them until the query finishes (0 = disabled)")
+	maxBufferMB := flag.Int("max-buffer-mb", 0, "Also flush once the buffered tweets take this many MB, bounding memory whatever AMOUNT is (0 = no limit)")
+	retries := flag.Int("retries", 2, "Retry a failed API request this many times with exponential backoff")
+	mockAPI := flag.Bool("mock", false, "Serve canned trends and tweets from built-in fixtures instead of calling the API, for CI and demos (no token or network needed)")
+	vcrFlags := vcr.RegisterFlags(flag.CommandLine)
+	rateFlags := ratelimit.RegisterFlags(flag.CommandLine)
+	adaptiveBatch := flag.Bool("adaptive-batch", true, "Halve the batch size when a request fails and retry with it, stepping back up after successes, before using up -retries")
+	stampProvenance := flag.Bool("provenance", true, "Stamp each record with its run ID, query, capability, client version and fetch time under metadata.provenance")
+	breakerFailures := flag.Int("breaker-failures", 10, "Pause all requests for -breaker-cooldown after this many failed API requests in a row (0 disables)")
+	breakerCooldown := flag.Duration("breaker-cooldown", time.Minute, "How long requests pause once -breaker-failures is reached")
+	retryQueue := flag.String("retry-queue", "", "Queue batches that still fail after -retries in this file (default: "+retry.QueueFile+" in the run directory; empty to disable)")
+	sortBy := flag.String("sort", "", "Save the tweets ordered by created_at or tweet_id (both oldest first) or engagement (highest first), so reruns give identically ordered files (default: API order)")
+	appendTo := flag.String("append", "", "Add the new tweets to this existing dataset (.json or .json.enc) instead of writing a new file in the run directory")
+	storeDir := flag.String("store", "", "Ingest the tweets into the dataset store in this directory, which keeps them deduplicated and partitioned by day, instead of writing a new file in the run directory")
+	retryFile := flag.String("retry-file", "", "Replay the failed batches queued in this file instead of running queries")
+	estimateOnly := flag.Bool("estimate", false, "Print the API requests, duration and output size the run would take, from the averages of past runs in -audit-log, and exit without calling the API")
+	estimateRate := flag.Float64("estimate-rate", 0, "Requests per minute the token's rate limit allows, for -estimate (0 = unknown)")
+	savedName := flag.String("saved", "", "Run a named query from the saved queries file (SAVED_QUERIES, default queries.yaml)")
+	follow := flag.Duration("follow", 0, "Keep polling the query this often for tweets newer than the newest collected, ingesting them into -store (default: "+followRoot+"/<query>), until interrupted or a run cap is reached (0 = collect once)")
+	followUsers := flag.String("follow-users", "", "Collect the new tweets of each account in this file (one username per line) into its own dataset in -users-dir, resuming from the newest tweet collected from it; with -follow, keep polling the accounts at that interval. QUERY, if set, adds operators to every account's query")
+	usersDir := flag.String("users-dir", authors.DefaultDir, "Directory of the per-account datasets and since_id state for -follow-users")
+	var queryArgs queryList
+	flag.Var(&queryArgs, "query", "Collect this query instead of QUERY; repeat the flag to collect several, each into its own file")
+	queriesFile := flag.String("queries", "", "File with one query per line to collect, each into its own file (with -query, both are collected)")
+	parallel := flag.Int("parallel", 1, "Collect up to this many queries at once, sharing the API client, rate limit, run caps and sink")
+	flag.Parse()
+
+	// Run-wide caps start counting now, so they also cover preflight and probes
+	budget := collect.NewBudget(*maxTotal, *maxRuntime)
+
+	// Optional liveness and readiness endpoints for running under Kubernetes
+	monitor, err := healthFlags.Start()
+	if err != nil {
+		log.Fatalf("Failed to start health endpoints: %v", err)
+	}
+
+	// Optional pprof endpoints and CPU/heap profiles of the run
+	profiler, err := profileFlags.Start()
+	if err != nil {
+		log.Fatalf("Failed to start profiling: %v", err)
+	}
+	defer stopProfiler(profiler)
+
+	if *expand < 0 || *probe <= 0 {
+		log.Fatalf("-expand must not be negative and -probe must be positive")
+	}
+	if *flushEvery < 0 || *maxBufferMB < 0 {
+		log.Fatalf("-flush-every and -max-buffer-mb must not be negative")
+	}
+	if *parallel < 1 {
+		log.Fatalf("-parallel must be at least 1, got %d", *parallel)
+	}
+	// Parallel queries each write their own file; a shared dataset or store
+	// would be rewritten by all of them at once
+	if *parallel > 1 && (*appendTo != "" || *storeDir != "" || *follow > 0 || *followUsers != "" || *retryFile != "") {
+		log.Fatalf("-parallel cannot be combined with -append, -store, -follow, -follow-users or -retry-file")
+	}
+	// An encrypted file is sealed as a whole, so it cannot be written in parts
+	if *encrypt && (*flushEvery > 0 || *maxBufferMB > 0) {
+		log.Fatalf("-flush-every and -max-buffer-mb cannot be combined with -encrypt")
+	}
+	// Appending rewrites the dataset with the new tweets, keeping its encryption
+	if *appendTo != "" {
+		if !strings.HasSuffix(strings.TrimSuffix(*appendTo, crypt.Extension), ".json") {
+			log.Fatalf("-append needs a .json or .json.enc dataset, got %s", *appendTo)
+		}
+		if *flushEvery > 0 || *maxBufferMB > 0 || *retryFile != "" {
+			log.Fatalf("-append cannot be combined with -flush-every, -max-buffer-mb or -retry-file")
+		}
+		encrypted := strings.HasSuffix(*appendTo, crypt.Extension)
+		if *encrypt && !encrypted {
+			log.Fatalf("-encrypt cannot be used to append to the unencrypted dataset %s", *appendTo)
+		}
+		*encrypt = encrypted
+	}
+	if *sortBy != "" {
+		if _, err := dataset.SortKey(*sortBy); err != nil {
+			log.Fatalf("Invalid -sort: %v", err)
+		}
+		if *flushEvery > 0 || *maxBufferMB > 0 || *retryFile != "" {
+			log.Fatalf("-sort cannot be combined with -flush-every, -max-buffer-mb or -retry-file")
+		}
+	}
+	if *storeDir != "" && (*appendTo != "" || *flushEvery > 0 || *maxBufferMB > 0 || *retryFile != "") {
+		log.Fatalf("-store cannot be combined with -append, -flush-every, -max-buffer-mb or -retry-file")
+	}
+	// Following ingests every poll into a store, so it shares the store's limits
+	if *follow < 0 {
+		log.Fatalf("-follow must not be negative, got %s", *follow)
+	}
+	if *follow > 0 && (*appendTo != "" || *flushEvery > 0 || *maxBufferMB > 0 || *retryFile != "" || *reservoir > 0 || *expand > 0 || *sortBy != "" || *estimateOnly) {
+		log.Fatalf("-follow cannot be combined with -append, -flush-every, -max-buffer-mb, -retry-file, -reservoir, -expand, -sort or -estimate")
+	}
+	// Following accounts appends every poll to the account's own dataset
+	if *followUsers != "" && (*appendTo != "" || *storeDir != "" || *flushEvery > 0 || *maxBufferMB > 0 || *retryFile != "" || *reservoir > 0 || *expand > 0 || *sortBy != "" || *savedName != "" || *keywordsFile != "") {
+		log.Fatalf("-follow-users cannot be combined with -append, -store, -flush-every, -max-buffer-mb, -retry-file, -reservoir, -expand, -sort, -saved or -keywords")
+	}
+
 	// Load .env file explicitly to ensure environment variables are available
 	if err := godotenv.Load(); err != nil {
 		// Don't fail if .env doesn't exist, but log a warning
 		log.Printf("Warning: failed to load .env file: %v (continuing with environment variables)", err)
 	}
 
-	// Initialize gopher-client from .env file
-	c, err := client.NewClientFromConfig()
+	// A profile selects the API, token, data directory and sinks to use
+	if err := envprofile.Use(*profileName); err != nil {
+		log.Fatalf("Failed to apply profile: %v", err)
+	}
+	rundir.DefaultRoot("audit-log", auditPath, audit.FileName)
+
+	// Optional notifications with the run summary, e.g. to a Slack or Discord webhook
+	notifier, err := notify.FromEnv()
 	if err != nil {
-		log.Fatalf("Failed to create client from config: %v\nMake sure GOPHER_CLIENT_TOKEN is set in your .env file", err)
+		log.Fatalf("Failed to configure notifications: %v", err)
 	}
+	summary := report.New("fetch-tweets")
 
-	// Verify token is set
-	if c.Token == "" {
-		log.Fatal("GOPHER_CLIENT_TOKEN is not set. Please set it in your .env file")
+	// Optional alerting on a sustained API error rate
+	alerts, err := alertFlags.Build("fetch-tweets")
+	if err != nil {
+		log.Fatalf("Failed to configure alerting: %v", err)
 	}
 
-	// Get query from environment variable, fallback to default
-	baseQuery := os.Getenv("QUERY")
-	if baseQuery == "" {
-		baseQuery = defaultQuery
+	// Initialize gopher-client from .env file, or the fixtures with -mock
+	var api collect.Searcher
+	var cassette *vcr.Cassette
+	var pacer *ratelimit.Pacer
+	var meter *audit.Meter
+	if *mockAPI {
+		if vcrFlags.Record != "" || vcrFlags.Replaying() {
+			log.Fatal("-mock cannot be combined with -record or -replay")
+		}
+		m, err := mock.New()
+		if err != nil {
+			log.Fatalf("Failed to load mock fixtures: %v", err)
+		}
+		fmt.Println("🧪 Mock mode: serving canned tweets instead of calling the API")
+		api = m
+	} else if !*estimateOnly {
+		c, err := apiFlags.New()
+		if err != nil {
+			log.Fatalf("Failed to create client from config: %v\nMake sure GOPHER_CLIENT_TOKEN is set in your .env file", err)
+		}
+		if cassette, err = vcrFlags.Attach(c); err != nil {
+			log.Fatalf("Failed to open cassette: %v", err)
+		}
+		// Requests wait for an exhausted rate limit to reset instead of failing
+		if pacer, err = rateFlags.Attach(c); err != nil {
+			log.Fatal(err)
+		}
+		// API calls are counted for the audit log; a replay makes none
+		if !vcrFlags.Replaying() {
+			meter = audit.NewMeter(c)
+		}
+
+		// Verify token is set; a replay never reaches the API
+		if c.Token == "" && !vcrFlags.Replaying() {
+			log.Fatal("GOPHER_CLIENT_TOKEN is not set. Please set it in your .env file")
+		}
+		api = c
+	}
+
+	// Requests go through a circuit breaker, so a failing API is given time
+	// to recover instead of being hit by every retry of every query
+	var breaker *collect.Breaker
+	if *breakerFailures > 0 {
+		if *breakerCooldown <= 0 {
+			log.Fatalf("-breaker-cooldown must be positive, got %s", *breakerCooldown)
+		}
+		breaker = collect.NewBreaker(api, *breakerFailures, *breakerCooldown, monitor)
+		api = breaker
+	}
+
+	// Get queries: -query and -queries, a saved query by name, a keywords
+	// file, a template matrix from QUERY_MATRIX, or a single QUERY
+	var queries []queryJob
+	var saved *query.Saved
+	if *savedName != "" && *keywordsFile != "" {
+		log.Fatal("-saved cannot be combined with -keywords")
+	}
+	listed := len(queryArgs) > 0 || *queriesFile != ""
+	if listed && (*savedName != "" || *keywordsFile != "" || *followUsers != "" || os.Getenv("QUERY_MATRIX") != "") {
+		log.Fatal("-query and -queries cannot be combined with -saved, -keywords, -follow-users or QUERY_MATRIX")
+	}
+	if (*savedName != "" || *keywordsFile != "" || *followUsers != "") && os.Getenv("QUERY_MATRIX") != "" {
+		log.Fatal("-saved, -keywords and -follow-users cannot be combined with QUERY_MATRIX")
+	}
+	if listed {
+		list := []string(queryArgs)
+		if *queriesFile != "" {
+			loaded, err := query.LoadQueries(*queriesFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			list = append(list, loaded...)
+		}
+		fmt.Printf("Collecting %d queries:\n", len(list))
+		seen := map[string]bool{}
+		for _, q := range list {
+			if !seen[q] {
+				seen[q] = true
+				queries = append(queries, queryJob{query: q})
+				fmt.Printf("%d. %s\n", len(queries), q)
+			}
+		}
+	} else if *followUsers != "" {
+		users, err := authors.LoadList(*followUsers)
+		if err != nil {
+			log.Fatal(err)
+		}
+		operators := os.Getenv("QUERY")
+		for _, user := range users {
+			q, err := twitterquery.Raw(operators).From(user).Build()
+			if err != nil {
+				log.Fatalf("Invalid query for @%s: %v", user, err)
+			}
+			queries = append(queries, queryJob{query: q, label: user})
+		}
+		fmt.Printf("Following %d accounts from %s\n", len(users), *followUsers)
+	} else if *savedName != "" {
+		all, err := query.LoadSaved(query.SavedQueriesPath())
+		if err != nil {
+			log.Fatalf("Failed to load saved queries: %v", err)
+		}
+		if saved, err = query.FindSaved(all, *savedName); err != nil {
+			log.Fatal(err)
+		}
+		q, err := saved.Build()
+		if err != nil {
+			log.Fatal(err)
+		}
+		queries = []queryJob{{query: q, label: saved.Name}}
+		fmt.Printf("Saved query %s: %s\n", saved.Name, q)
+	} else if *keywordsFile != "" {
+		keywords, err := query.LoadKeywords(*keywordsFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		batches, err := query.PackKeywords(keywords, os.Getenv("QUERY"))
+		if err != nil {
+			log.Fatalf("Failed to pack keywords: %v", err)
+		}
+		stem := strings.TrimSuffix(filepath.Base(*keywordsFile), filepath.Ext(*keywordsFile))
+		fmt.Printf("Packed %d keywords into %d queries:\n", len(keywords), len(batches))
+		for i, b := range batches {
+			label := fmt.Sprintf("%s_%dof%d", stem, i+1, len(batches))
+			queries = append(queries, queryJob{query: b.Query, label: label, keywords: b.Keywords})
+			fmt.Printf("%d. %s (%d keywords, %d chars)\n", i+1, label, len(b.Keywords), len(b.Query))
+		}
+	} else if matrixPath := os.Getenv("QUERY_MATRIX"); matrixPath != "" {
+		matrix, err := query.LoadMatrix(matrixPath)
+		if err != nil {
+			log.Fatalf("Failed to load QUERY_MATRIX: %v", err)
+		}
+		expanded, err := matrix.Expand()
+		if err != nil {
+			log.Fatalf("Invalid query matrix %s: %v", matrixPath, err)
+		}
+		fmt.Printf("QUERY_MATRIX expanded to %d queries:\n", len(expanded))
+		for i, q := range expanded {
+			queries = append(queries, queryJob{query: q})
+			fmt.Printf("%d. %s\n", i+1, q)
+		}
+	} else if baseQuery := os.Getenv("QUERY"); baseQuery == "" {
+		queries = []queryJob{{query: defaultQuery}}
 		fmt.Printf("QUERY not set in .env, using default: %s\n", defaultQuery)
 	} else {
+		queries = []queryJob{{query: baseQuery}}
 		// Debug: verify quotes are preserved in the query
 		fmt.Printf("QUERY loaded from .env (quotes preserved for API): %s\n", baseQuery)
 	}
 
+	distinctOutputs(queries)
+
 	// Get amount from environment variable, fallback to default
 	targetTweets := defaultAmount
 	if amountStr := os.Getenv("AMOUNT"); amountStr != "" {
@@ -66,130 +360,656 @@ func main() {
 	} else {
 		fmt.Printf("AMOUNT not set in .env, using default: %d\n", defaultAmount)
 	}
+	if saved != nil && saved.Amount > 0 {
+		targetTweets = saved.Amount
+		fmt.Printf("Using amount %d from saved query %s\n", targetTweets, saved.Name)
+	}
+	if *reservoir < 0 || *reservoir >= targetTweets {
+		log.Fatalf("-reservoir must be smaller than AMOUNT (%d), got %d", targetTweets, *reservoir)
+	}
 
-	// Set maxResults: use AMOUNT if less than API max, otherwise use API max
-	maxResults := targetTweets
-	if maxResults > apiMaxResults {
-		maxResults = apiMaxResults
+	if *follow > 0 && *followUsers == "" {
+		if len(queries) != 1 {
+			log.Fatalf("-follow polls a single query, got %d", len(queries))
+		}
+		if *storeDir == "" {
+			*storeDir = followStore(cmp.Or(queries[0].label, queries[0].query))
+		}
 	}
 
-	// Generate output filename from query and target count
-	outputFile := generateOutputFilename(baseQuery, targetTweets)
+	// An estimate needs the queries and amount, but no run or API calls
+	if *estimateOnly {
+		probes := 0
+		if *expand > 0 {
+			probes = (*probe + collect.APIMaxResults - 1) / collect.APIMaxResults
+		}
+		printEstimate(queries, targetTweets, *auditPath, estimate.Options{Preflight: *preflight, ProbeRequests: probes, RequestsPerMinute: *estimateRate})
+		return
+	}
 
-	fmt.Println("Starting tweet collection...")
-	fmt.Printf("Query (for API, quotes preserved): %s\n", baseQuery)
-	fmt.Printf("Target: %d tweets\n", targetTweets)
-	fmt.Printf("Output file (quotes removed from filename): %s\n", outputFile)
-	fmt.Printf("Batch size: %d tweets per request\n\n", maxResults)
+	// Everything the run writes goes under runs/<id>/
+	runDir, err := rundir.New()
+	if err != nil {
+		log.Fatalf("Failed to create run directory: %v", err)
+	}
+	defer runDir.Close()
+	fmt.Printf("Run %s: writing to %s\n", runDir.ID, runDir.Path)
+	runDir.Default("summary", summaryPath, "summary.json")
+	runDir.Default("retry-queue", retryQueue, retry.QueueFile)
+	runDir.Default("quarantine", &pipeFlags.Quarantine, "quarantine.jsonl")
+	auditLog, err := audit.Start(*auditPath, runDir.ID, "fetch-tweets", runDir.Path, meter)
+	if err != nil {
+		runDir.Fatalf("Failed to start audit log: %v", err)
+	}
 
-	// Initialize tweets array
-	var allTweets []types.Document
-	query := baseQuery
+	// Optional text processing applied to each batch before it is written
+	pipe, err := pipeFlags.Build()
+	if err != nil {
+		abort(summary, notifier, auditLog, runDir, fmt.Errorf("failed to configure processing: %w", err))
+	}
 
-	// Loop until we have 10,000 tweets or no more results
-	for len(allTweets) < targetTweets {
-		fmt.Printf("Fetching batch... (current: %d/%d tweets)\n", len(allTweets), targetTweets)
+	// Load the encryption key up front so a missing key fails before any API calls
+	var encryptionKey []byte
+	if *encrypt {
+		encryptionKey, err = crypt.KeyFromEnv()
+		if err != nil {
+			abort(summary, notifier, auditLog, runDir, fmt.Errorf("failed to load encryption key: %w", err))
+		}
+	}
 
-		// Create search arguments
-		args := twitter.NewSearchArguments()
-		args.Query = query
-		args.MaxResults = maxResults
-		args.Type = types.CapSearchByQuery // Explicitly set search type
+	// Optional streaming sink (e.g. Elasticsearch) that receives each batch as it arrives
+	out, err := sink.FromEnv()
+	if err != nil {
+		abort(summary, notifier, auditLog, runDir, fmt.Errorf("failed to initialize sink: %w", err))
+	}
+	fanOut, _ := out.(*sink.FanOut)
+	var buffered *sink.Buffered
+	if *sinkQueue > 0 && out != nil {
+		buffered = sink.NewBuffered(out, *sinkQueue)
+		out = buffered
+	}
 
-		// Make API request (synchronous - waits for completion)
-		results, err := c.SearchTwitterWithArgs(args)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "\n❌ Error fetching tweets: %v\n", err)
-			break
+	// Validate all queries before committing to a long run
+	if *preflight && *retryFile == "" {
+		fmt.Printf("Preflight: checking %d queries...\n", len(queries))
+		failed := 0
+		for _, job := range queries {
+			err := collect.Preflight(api, job.query)
+			monitor.Beat()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "❌ %s: %v\n", cmp.Or(job.label, job.query), err)
+				failed++
+			}
 		}
+		if failed > 0 {
+			abort(summary, notifier, auditLog, runDir, fmt.Errorf("preflight failed for %d of %d queries; fix them or rerun with -preflight=false", failed, len(queries)))
+		}
+		fmt.Println("✅ Preflight passed")
+	}
+	monitor.SetReady(true)
+
+	collector := &collect.Collector{Client: api, Pipeline: pipe, Sink: out, Budget: budget, Health: monitor, Alerts: alerts, Retries: *retries, AdaptiveBatch: *adaptiveBatch}
+	collector.FlushEvery, collector.MaxBufferBytes = *flushEvery, *maxBufferMB<<20
+	if *stampProvenance {
+		collector.Provenance = collect.NewProvenance(runDir.ID, "fetch-tweets")
+	}
+	collector.Metrics = collect.NewMetrics()
+	session := &run{
+		collector:  collector,
+		target:     targetTweets,
+		reservoir:  *reservoir,
+		expand:     *expand,
+		probe:      *probe,
+		savedName:  *savedName,
+		seed:       seed,
+		dir:        runDir.Path,
+		runID:      runDir.ID,
+		lockWait:   *lockWait,
+		key:        encryptionKey,
+		provenance: manifest.ProvenanceFromEnv(),
+		retryQueue: *retryQueue,
+		sortBy:     *sortBy,
+	}
+
+	// An -append dataset or a -store is locked for the whole run, and the
+	// tweets it already holds are dropped as they arrive
+	var targetLock *lock.Lock
+	if target := cmp.Or(*appendTo, *storeDir); target != "" {
+		if targetLock, err = lock.File("fetch-tweets", runDir.ID, target, *lockWait); err != nil {
+			runDir.Fatalf("%v", err)
+		}
+	}
+	if *appendTo != "" {
+		session.appendTo = *appendTo
+		session.existing, err = dataset.IDs(*appendTo)
+		if errors.Is(err, os.ErrNotExist) {
+			session.existing = make(map[string]bool)
+			fmt.Printf("Appending to %s (new dataset)\n", *appendTo)
+		} else if err != nil {
+			targetLock.Release()
+			runDir.Fatalf("Failed to read the dataset to append to: %v", err)
+		} else {
+			fmt.Printf("Appending to %s (%d tweets)\n", *appendTo, len(session.existing))
+		}
+	}
+	if *storeDir != "" {
+		if session.store, err = store.Open(*storeDir, encryptionKey); err != nil {
+			targetLock.Release()
+			runDir.Fatalf("Failed to open store: %v", err)
+		}
+		session.existing = session.store.IDs()
+		fmt.Printf("Ingesting into store %s (%d tweets)\n", *storeDir, len(session.existing))
+	}
 
-		// Check if we got any results
-		if len(results) == 0 {
-			if len(allTweets) == 0 {
-				fmt.Fprintf(os.Stderr, "\n⚠️ API returned 0 results on first request. Possible causes:\n")
-				fmt.Fprintf(os.Stderr, "  - No tweets match query: %q\n", baseQuery)
-				fmt.Fprintf(os.Stderr, "  - API rate limit or authentication issue (check GOPHER_CLIENT_TOKEN)\n")
-				fmt.Fprintf(os.Stderr, "  - Query format may not be supported by the API\n")
-			} else {
-				fmt.Println("No more results available.")
+	failed := 0
+	if *retryFile != "" {
+		// Replay mode: collect only the batches that failed in earlier runs
+		held, err := lock.File("fetch-tweets", runDir.ID, *retryFile, *lockWait)
+		if err != nil {
+			abort(summary, notifier, auditLog, runDir, err)
+		}
+		results, err := retry.Replay(context.Background(), session.collector, *retryFile)
+		held.Release()
+		if err != nil {
+			abort(summary, notifier, auditLog, runDir, err)
+		}
+		for _, result := range results {
+			if result.Error != "" {
+				failed++
 			}
+			summary.Add(result)
+		}
+		queries = nil
+	}
+	if *followUsers != "" {
+		ctx, stop := interruptContext()
+		results, err := session.followUsers(ctx, queries, *follow, *usersDir)
+		stop()
+		for _, result := range results {
+			if result.Error != "" {
+				failed++
+			}
+			summary.Add(result)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			summary.Note = fmt.Sprintf("Stopped following: %v", err)
+			failed = max(failed, 1)
+		}
+		queries = nil
+	} else if *follow > 0 {
+		ctx, stop := interruptContext()
+		start := time.Now()
+		result, err := session.follow(ctx, queries[0], *follow)
+		stop()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			result.Error = err.Error()
+			failed++
+		}
+		result.Seconds = report.Since(start)
+		summary.Add(result)
+		queries = nil
+	}
+	if *parallel > 1 && len(queries) > 1 {
+		results, errs, skipped := session.collectParallel(queries, *parallel)
+		for i, result := range results {
+			if errs[i] != nil {
+				failed++
+			}
+			summary.Add(result)
+		}
+		if skipped > 0 {
+			err := budget.Check()
+			summary.Note = fmt.Sprintf("Stopped early: %v. Skipped the remaining %d of %d queries.", err, skipped, len(queries))
+		}
+		queries = nil
+	}
+	for i, job := range queries {
+		if err := budget.Check(); err != nil {
+			fmt.Fprintf(os.Stderr, "\n⚠️ %v; skipping the remaining %d queries\n", err, len(queries)-i)
+			summary.Note = fmt.Sprintf("Stopped early: %v. Skipped the remaining %d of %d queries.", err, len(queries)-i, len(queries))
 			break
 		}
+		if len(queries) > 1 {
+			fmt.Printf("\n=== Query %d/%d: %s ===\n", i+1, len(queries), cmp.Or(job.label, job.query))
+		}
+		start := time.Now()
+		result, err := session.collectQuery(job)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			result.Error = err.Error()
+			failed++
+		}
+		result.Seconds = report.Since(start)
+		summary.Add(result)
+	}
 
-		// Append results to our collection
-		allTweets = append(allTweets, results...)
-		fmt.Printf("Fetched %d tweets in this batch. Total: %d/%d\n\n", len(results), len(allTweets), targetTweets)
+	targetLock.Release()
 
-		// If we've reached our target, break
-		if len(allTweets) >= targetTweets {
-			break
+	if out != nil {
+		if err := out.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️ Error closing sink: %v\n", err)
+		}
+	}
+	if buffered != nil {
+		fmt.Printf("📊 Sink queue: %s\n", buffered.Stats())
+	}
+	if fanOut != nil {
+		for _, s := range fanOut.Stats() {
+			fmt.Printf("📤 Sink %s\n", s)
+		}
+		if missed := fanOut.Failures(); missed != "" {
+			summary.Note = strings.TrimSpace(summary.Note + " Optional sinks missed batches: " + missed + ".")
+		}
+	}
+
+	if err := pipe.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️ Error closing processing stages: %v\n", err)
+	}
+
+	if err := budget.Check(); err != nil && summary.Note == "" {
+		summary.Note = fmt.Sprintf("Stopped early: %v.", err)
+	}
+	if err := cassette.Close(); err != nil {
+		fmt.Printf("⚠️ %v\n", err)
+	}
+	if n, waited := pacer.Waits(); n > 0 {
+		fmt.Printf("⏳ Rate limit: %s\n", pacer.Stats())
+		summary.Note = strings.TrimSpace(summary.Note + fmt.Sprintf(" Requests waited %s for the rate limit to reset.", waited.Round(time.Second)))
+	}
+	if breaker != nil && breaker.Opens() > 0 {
+		fmt.Printf("🔌 Circuit breaker: %s\n", breaker.Stats())
+		summary.Note = strings.TrimSpace(summary.Note + fmt.Sprintf(" The circuit breaker paused requests %d times.", breaker.Opens()))
+	}
+	if summary.Stages = collector.Metrics.Stages(); len(summary.Stages) > 0 {
+		fmt.Printf("⏱️ Time by stage: %s\n", report.StageShares(summary.Stages))
+	}
+	summary.Finish()
+	recordAudit(auditLog, summary)
+	if *summaryPath != "" {
+		if mdPath, err := summary.Write(*summaryPath); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️ %v\n", err)
+		} else {
+			fmt.Printf("\n📝 Run summary written to %s and %s\n", *summaryPath, mdPath)
+		}
+	}
+	sendNotification(notifier, summary)
+
+	if failed > 0 {
+		// log.Fatalf skips deferred calls
+		stopProfiler(profiler)
+		runDir.Fatalf("%d of %d queries failed", failed, len(summary.Queries))
+	}
+}
+
+// printEstimate predicts the run of queries at amount tweets each from the
+// history in the audit log at auditPath
+func printEstimate(queries []queryJob, amount int, auditPath string, opts estimate.Options) {
+	var history estimate.History
+	if auditPath != "" {
+		var err error
+		if history, err = estimate.FromAudit(auditPath); err != nil {
+			log.Fatalf("Failed to read run history: %v", err)
+		}
+	}
+	targets := make([]estimate.Query, len(queries))
+	for i, job := range queries {
+		targets[i] = estimate.Query{Query: job.query, Amount: amount}
+	}
+	e := estimate.Compute(targets, history, opts)
+
+	fmt.Println("\n🧮 Estimate (assuming every query reaches AMOUNT):")
+	for _, q := range e.Queries {
+		fmt.Printf("  %s: %d tweets, %d requests, ~%s, ~%s\n", q.Query.Query, q.Amount, q.Requests,
+			time.Duration(q.Seconds*float64(time.Second)).Round(time.Second), estimate.FormatBytes(q.Bytes))
+	}
+	fmt.Printf("  Total: %d API requests, ~%s, ~%s of output\n", e.Requests, e.Duration(), estimate.FormatBytes(e.Bytes))
+	if e.Limited {
+		fmt.Printf("  The rate limit of %g requests per minute sets the duration\n", opts.RequestsPerMinute)
+	}
+	h := e.History
+	if h.SecondsPerTweet > 0 {
+		fmt.Printf("  Speed from %d past runs (%d tweets): %.2fs per tweet\n", h.Runs, h.Tweets, h.SecondsPerTweet)
+	} else {
+		fmt.Println("  No timed past runs in the audit log: assuming a 100-tweet page every 10s")
+	}
+	if h.BytesPerRecord > 0 {
+		fmt.Printf("  Size from %d past datasets: %s per record\n", h.Datasets, estimate.FormatBytes(int64(h.BytesPerRecord)))
+	} else {
+		fmt.Println("  No past datasets to measure: assuming 2.5 KB per record")
+	}
+}
+
+// stopProfiler writes the run's profiles, if any were requested
+func stopProfiler(p *profile.Profiler) {
+	if err := p.Stop(); err != nil {
+		fmt.Printf("⚠️ %v\n", err)
+	}
+}
+
+// abort records a run that stopped before collecting, notifies and exits
+func abort(summary *report.Summary, notifier *notify.Dispatcher, auditLog *audit.Log, runDir *rundir.Run, err error) {
+	summary.Abort(err)
+	recordAudit(auditLog, summary)
+	sendNotification(notifier, summary)
+	runDir.Fatalf("%v", err)
+}
+
+// recordAudit appends the outcome of the finished run to the audit log
+func recordAudit(auditLog *audit.Log, summary *report.Summary) {
+	if err := auditLog.Finish(summary); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️ %v\n", err)
+	}
+}
+
+// sendNotification sends the run summary to the configured notifiers
+func sendNotification(notifier *notify.Dispatcher, summary *report.Summary) {
+	sent, err := notifier.Send(context.Background(), summary)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️ Failed to send notification: %v\n", err)
+		return
+	}
+	if sent {
+		fmt.Println("📣 Notification sent")
+	}
+}
+
+// run holds the settings shared by every query collected in one invocation
+type run struct {
+	collector  *collect.Collector
+	target     int
+	reservoir  int
+	expand     int    // Number of hashtags to add to each query, 0 to disable
+	probe      int    // Probe size for expand
+	savedName  string // Name of the saved query being run, if any
+	seed       *sample.Seed
+	dir        string // Run directory the datasets are written to
+	runID      string
+	lockWait   time.Duration   // How long to wait for a query locked by another run
+	appendTo   string          // Existing dataset the tweets are added to, if set
+	store      *store.Store    // Store the tweets are ingested into, if set
+	existing   map[string]bool // IDs of the tweets already in appendTo or store
+	key        []byte
+	provenance manifest.Provenance
+	retryQueue string // File failed batches are queued in, empty to disable
+	sortBy     string // Sort order of the saved tweets, empty for API order
+}
+
+// queryJob is one query to collect
+type queryJob struct {
+	query    string
+	label    string   // Names the output file instead of the query, if set
+	keywords []string // Keywords packed into query, attributed to tweets in metadata
+}
+
+// collectQuery collects tweets for one query and saves them with a manifest.
+// Collection errors are reported, and the tweets gathered so far are still saved.
+// The result describes the query for the run summary, also when it failed.
+func (r *run) collectQuery(job queryJob) (report.Query, error) {
+	baseQuery := job.query
+	result := report.Query{Query: baseQuery, Label: job.label, Requested: r.target}
+
+	// Another run collecting the same query would repeat its API calls and sink writes
+	held, err := lock.Query("fetch-tweets", r.runID, baseQuery, r.lockWait)
+	if err != nil {
+		return result, err
+	}
+	defer held.Release()
+
+	// Generate output filename from query (or its label) and target count
+	outputFile := r.appendTo
+	if r.store != nil {
+		outputFile = r.store.Dir()
+	}
+	if outputFile == "" {
+		outputFile = generateOutputFilename(r.dir, cmp.Or(strings.ReplaceAll(job.label, "-", "_"), baseQuery), r.target)
+		if r.reservoir > 0 {
+			outputFile = strings.TrimSuffix(outputFile, ".json") + fmt.Sprintf("_reservoir_%d.json", r.reservoir)
 		}
+		if r.key != nil {
+			outputFile += crypt.Extension
+		}
+	}
+
+	// Tweets from packed keyword queries are attributed before other stages change their text
+	collector := *r.collector
+	var counts collect.Stats
+	collector.Stats = &counts
+	if len(job.keywords) > 0 {
+		collector.Pipeline = append(pipeline.Pipeline{pipeline.NewMatchedKeywords(job.keywords)}, collector.Pipeline...)
+	}
+	collector.Pipeline = append(pipeline.Pipeline{pipeline.NewTopic("", job.query)}, collector.Pipeline...)
+	if r.existing != nil {
+		collector.Pipeline = append(pipeline.Pipeline{pipeline.NewSkipExisting(r.existing)}, collector.Pipeline...)
+	}
+	pipe := collector.Pipeline
+	stages := pipe.Names()
 
-		// Get the last tweet ID for pagination
-		lastTweetID, err := getLastTweetID(results)
+	// Optionally widen the query with hashtags that co-occur with it; the
+	// output file keeps the name of the original query
+	if r.expand > 0 {
+		expanded, hashtags, err := r.expandQuery(baseQuery)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "\n❌ Error extracting last tweet ID: %v\n", err)
-			break
+			return result, err
+		}
+		if len(hashtags) == 0 {
+			fmt.Println("No co-occurring hashtags found, keeping the original query")
+		} else {
+			fmt.Printf("Expanded query with %d hashtags: %s\n\n", len(hashtags), expanded)
+			stages = append(stages, fmt.Sprintf("expand(probe=%d,original=%s)", r.probe, baseQuery))
+			baseQuery = expanded
+			result.Query = expanded
 		}
+	}
+
+	fmt.Println("Starting tweet collection...")
+	fmt.Printf("Query (for API, quotes preserved): %s\n", baseQuery)
+	fmt.Printf("Target: %d tweets\n", r.target)
+	if r.reservoir > 0 {
+		fmt.Printf("Reservoir: keeping a uniform sample of %d tweets\n", r.reservoir)
+	}
+	fmt.Printf("Output file (quotes removed from filename): %s\n", outputFile)
+	fmt.Printf("Batch size: %d tweets per request\n", min(r.target, collect.APIMaxResults))
+	if len(pipe) > 0 {
+		fmt.Printf("Processing: %s\n", strings.Join(pipe.Names(), " -> "))
+	}
+	fmt.Println()
+
+	var seedValue *uint64
+	if r.reservoir > 0 {
+		v := r.seed.Value()
+		seedValue = &v
+		fmt.Printf("Random seed: %d\n", v)
+		collector.Reservoir = sample.NewReservoir(r.reservoir, sample.NewRand(v))
+	}
 
-		// Update query with max_id for next iteration
-		query = fmt.Sprintf("%s max_id:%d", baseQuery, lastTweetID)
+	// With flush thresholds the tweets are streamed into the output file as
+	// they are flushed rather than returned; a reservoir sample is saved as usual
+	var writer *dataset.Writer
+	if collector.Flushing() && r.reservoir == 0 {
+		var err error
+		if writer, err = dataset.NewWriter(outputFile, dataset.File{Query: baseQuery, SavedQuery: r.savedName}); err != nil {
+			return result, fmt.Errorf("failed to save tweets: %w", err)
+		}
+		collector.Sink = sink.Join(writer, collector.Sink)
+	}
+	allTweets, err := collector.Collect(context.Background(), baseQuery, r.target)
+	result.Fetched, result.Dropped = counts.Fetched, counts.Dropped
+	kept := len(allTweets)
+	if writer != nil {
+		kept = writer.Count()
+	}
+	if err != nil && !errors.Is(err, collect.ErrBudgetExhausted) {
+		result.Error = err.Error()
+	}
+	if collector.Reservoir != nil {
+		fmt.Printf("\nReservoir sampled %d of %d collected tweets\n", len(allTweets), collector.Reservoir.Seen())
+		stages = append(stages, fmt.Sprintf("reservoir(n=%d,scanned=%d)", r.reservoir, collector.Reservoir.Seen()))
+	}
+	if errors.Is(err, collect.ErrBudgetExhausted) {
+		fmt.Fprintf(os.Stderr, "\n⚠️ %v\n", err)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "\n❌ Error: %v\n", err)
+	} else if kept == 0 {
+		fmt.Fprintf(os.Stderr, "\n⚠️ API returned 0 results on first request. Possible causes:\n")
+		fmt.Fprintf(os.Stderr, "  - No tweets match query: %q\n", baseQuery)
+		fmt.Fprintf(os.Stderr, "  - API rate limit or authentication issue (check GOPHER_CLIENT_TOKEN)\n")
+		fmt.Fprintf(os.Stderr, "  - Query format may not be supported by the API\n")
+	}
+
+	// Queue a batch that failed after all retries so -retry-file can finish it
+	// later; a reservoir sample cannot be topped up, and replay merges into
+	// files rather than stores, so neither is queued
+	var batchErr *collect.BatchError
+	if errors.As(err, &batchErr) && r.retryQueue != "" && r.reservoir == 0 && r.store == nil {
+		if qerr := retry.Append(r.retryQueue, retry.NewEntry("fetch-tweets", outputFile, batchErr)); qerr != nil {
+			fmt.Fprintf(os.Stderr, "⚠️ %v\n", qerr)
+		} else {
+			fmt.Printf("🔁 Queued the failed batch in %s (replay with -retry-file %s)\n", r.retryQueue, r.retryQueue)
+		}
+	}
+
+	// Sorting last makes the order independent of how the API paged the results
+	if r.sortBy != "" {
+		dataset.Sort(allTweets, r.sortBy)
+		stages = append(stages, "sort("+r.sortBy+")")
+	}
+
+	saveStart := time.Now()
+	if r.appendTo != "" {
+		result, err := r.appendTweets(result, allTweets, baseQuery, stages)
+		r.collector.Metrics.Add(collect.PhaseWrite, "save", len(allTweets), result.Saved, time.Since(saveStart))
+		return result, err
+	}
+	if r.store != nil {
+		result, err := r.ingestTweets(result, allTweets, baseQuery, stages)
+		r.collector.Metrics.Add(collect.PhaseWrite, "save", len(allTweets), result.Saved, time.Since(saveStart))
+		return result, err
 	}
 
 	// Save to JSON file
-	fmt.Printf("\nSaving %d tweets to %s...\n", len(allTweets), outputFile)
-	if err := saveTweetsToFile(allTweets, baseQuery, outputFile); err != nil {
-		log.Fatalf("Failed to save tweets: %v", err)
+	fmt.Printf("\nSaving %d tweets to %s...\n", kept, outputFile)
+	if writer != nil {
+		err = writer.Close()
+	} else {
+		err = saveTweetsToFile(allTweets, baseQuery, r.savedName, outputFile, r.key)
+	}
+	r.collector.Metrics.Add(collect.PhaseWrite, "save", kept, kept, time.Since(saveStart))
+	if err != nil {
+		return result, fmt.Errorf("failed to save tweets: %w", err)
 	}
+	result.Saved = kept
+	result.Files = []string{outputFile}
 
-	fmt.Printf("✅ Successfully collected and saved %d tweets to %s\n", len(allTweets), outputFile)
+	// Describe the file with a manifest and dataset card carrying provenance
+	manifestPath, err := manifest.Write(outputFile, &manifest.Manifest{
+		Tool:       "fetch-tweets",
+		Query:      baseQuery,
+		SavedQuery: r.savedName,
+		Records:    kept,
+		Pipeline:   stages,
+		Seed:       seedValue,
+		Provenance: r.provenance,
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to write manifest: %w", err)
+	}
+	fmt.Printf("Manifest written to %s\n", manifestPath)
+	result.Files = append(result.Files, manifestPath, manifest.CardPath(outputFile))
+
+	fmt.Printf("✅ Successfully collected and saved %d tweets to %s\n", kept, outputFile)
+	return result, nil
 }
 
-// getLastTweetID extracts the tweet ID from the last document in the results
-func getLastTweetID(results []types.Document) (int64, error) {
-	if len(results) == 0 {
-		return 0, fmt.Errorf("no results to extract tweet ID from")
-	}
-
-	// Get the last tweet (oldest in the batch)
-	lastDoc := results[len(results)-1]
-
-	// Try to get tweet_id from metadata
-	if metadata := lastDoc.Metadata; metadata != nil {
-		if tweetID, ok := metadata["tweet_id"]; ok {
-			switch v := tweetID.(type) {
-			case int64:
-				return v, nil
-			case float64:
-				// JSON numbers are unmarshaled as float64
-				return int64(v), nil
-			case string:
-				id, err := strconv.ParseInt(v, 10, 64)
-				if err != nil {
-					return 0, fmt.Errorf("failed to parse tweet_id string: %w", err)
-				}
-				return id, nil
-			}
+// appendTweets adds the new tweets to the -append dataset and records the
+// append in its manifest, creating both if the dataset does not exist yet
+func (r *run) appendTweets(result report.Query, tweets []types.Document, baseQuery string, stages []string) (report.Query, error) {
+	path := r.appendTo
+	fmt.Printf("\nAppending %d tweets to %s...\n", len(tweets), path)
+	added, total, err := dataset.Append(path, dataset.File{Query: baseQuery, SavedQuery: r.savedName}, tweets, r.key)
+	if err != nil {
+		return result, fmt.Errorf("failed to save tweets: %w", err)
+	}
+	result.Saved = added
+	result.Files = []string{path}
+	if r.sortBy != "" && added > 0 {
+		// The new tweets are in order, but the dataset as a whole has to be re-sorted
+		if err := dataset.SortFile(path, r.sortBy, r.key); err != nil {
+			return result, err
 		}
 	}
 
-	// Fallback: try to parse the Id field
-	if lastDoc.Id != "" {
-		id, err := strconv.ParseInt(lastDoc.Id, 10, 64)
-		if err == nil {
-			return id, nil
-		}
+	m, err := manifest.Load(path)
+	resorted := err == nil && r.sortBy != "" && added > 0
+	if err != nil {
+		m = &manifest.Manifest{Tool: "fetch-tweets", Query: baseQuery, SavedQuery: r.savedName, Pipeline: stages, Provenance: r.provenance}
+	}
+	m.Records = total
+	m.Pipeline = append(m.Pipeline, fmt.Sprintf("append(run=%s,added=%d)", r.runID, added))
+	if resorted {
+		m.Pipeline = append(m.Pipeline, "sort("+r.sortBy+")")
+	}
+	manifestPath, err := manifest.Write(path, m)
+	if err != nil {
+		return result, fmt.Errorf("failed to write manifest: %w", err)
+	}
+	fmt.Printf("Manifest written to %s\n", manifestPath)
+	result.Files = append(result.Files, manifestPath, manifest.CardPath(path))
+
+	fmt.Printf("✅ Added %d new tweets to %s (%d in total)\n", added, path, total)
+	return result, nil
+}
+
+// ingestTweets adds the new tweets to the -store
+func (r *run) ingestTweets(result report.Query, tweets []types.Document, baseQuery string, stages []string) (report.Query, error) {
+	fmt.Printf("\nIngesting %d tweets into store %s...\n", len(tweets), r.store.Dir())
+	res, err := r.store.Ingest(tweets, store.Source{
+		Tool:       "fetch-tweets",
+		Run:        r.runID,
+		Query:      baseQuery,
+		SavedQuery: r.savedName,
+		Pipeline:   stages,
+		Provenance: r.provenance,
+	})
+	result.Saved = res.Added
+	result.Files = res.Files
+	if err != nil {
+		return result, fmt.Errorf("failed to ingest tweets: %w", err)
+	}
+	fmt.Printf("✅ Added %d new tweets to store %s (%d duplicates skipped, %d in total)\n", res.Added, r.store.Dir(), res.Duplicates, r.store.Meta().Records)
+	return result, nil
+}
+
+// expandQuery probes the query with a small unprocessed fetch and returns it
+// widened with the most frequent co-occurring hashtags
+func (r *run) expandQuery(baseQuery string) (string, []string, error) {
+	fmt.Printf("Probing %d tweets to discover co-occurring hashtags...\n", r.probe)
+	prober := &collect.Collector{Client: r.collector.Client, Health: r.collector.Health}
+	docs, err := prober.Collect(context.Background(), baseQuery, r.probe)
+	if err != nil && len(docs) == 0 {
+		return "", nil, fmt.Errorf("expansion probe failed: %w", err)
 	}
 
-	return 0, fmt.Errorf("could not extract tweet_id from document")
+	// Require a hashtag to appear in at least two probed tweets to skip one-off tags
+	hashtags := query.CoOccurringHashtags(docs, baseQuery, r.expand, 2)
+	return query.WithHashtags(baseQuery, hashtags), hashtags, nil
 }
 
 // generateOutputFilename creates a filesystem-safe filename from the query and target count
 // Note: This function sanitizes the query for filename use, but the original query
 // (with quotes preserved) is still used for the actual API calls
 // Example: "bitcoin" min_faves:1000 -> bitcoin_min_faves:1000_10000.json
-func generateOutputFilename(query string, targetCount int) string {
-	// Sanitize the query for use in filename
+func generateOutputFilename(dir, query string, targetCount int) string {
+	// Create filename: query_targetCount.json
+	filename := fmt.Sprintf("%s_%d.json", sanitizeQuery(query), targetCount)
+
+	// Return full path
+	return filepath.Join(dir, filename)
+}
+
+// sanitizeQuery makes a query safe to use in a file name
+func sanitizeQuery(query string) string {
 	// First, remove quotes (they're needed for the API query but not for filename)
 	sanitized := query
 
@@ -215,31 +1035,23 @@ func generateOutputFilename(query string, targetCount int) string {
 	sanitized = reg.ReplaceAllString(sanitized, "_")
 
 	// Remove leading/trailing underscores
-	sanitized = strings.Trim(sanitized, "_")
-
-	// Create filename: query_targetCount.json
-	filename := fmt.Sprintf("%s_%d.json", sanitized, targetCount)
-
-	// Ensure data directory exists
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		log.Printf("Warning: failed to create data directory: %v", err)
-	}
-
-	// Return full path
-	return filepath.Join(dataDir, filename)
+	return strings.Trim(sanitized, "_")
 }
 
-// saveTweetsToFile saves the tweets to a JSON file with proper formatting
-func saveTweetsToFile(tweets []types.Document, query string, filename string) error {
+// saveTweetsToFile saves the tweets to a JSON file with proper formatting.
+// If key is non-nil the file contents are encrypted with it before writing.
+func saveTweetsToFile(tweets []types.Document, query, savedQuery, filename string, key []byte) error {
 	// Create output structure with metadata
 	output := struct {
 		TotalTweets int              `json:"total_tweets"`
 		Query       string           `json:"query"`
+		SavedQuery  string           `json:"saved_query,omitempty"`
 		CollectedAt string           `json:"collected_at"`
 		Tweets      []types.Document `json:"tweets"`
 	}{
 		TotalTweets: len(tweets),
 		Query:       query,
+		SavedQuery:  savedQuery,
 		CollectedAt: time.Now().UTC().Format(time.RFC3339),
 		Tweets:      tweets,
 	}
@@ -250,6 +1062,12 @@ func saveTweetsToFile(tweets []types.Document, query string, filename string) er
 		return fmt.Errorf("failed to marshal tweets: %w", err)
 	}
 
+	if key != nil {
+		if data, err = crypt.Encrypt(key, data); err != nil {
+			return fmt.Errorf("failed to encrypt tweets: %w", err)
+		}
+	}
+
 	// Write to file
 	if err := os.WriteFile(filename, data, 0644); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
@@ -257,3 +1075,15 @@ func saveTweetsToFile(tweets []types.Document, query string, filename string) er
 
 	return nil
 }
+
+// interruptContext returns a context that is done at the first interrupt, so
+// following stops after the current poll; a second interrupt stops the run
+// at once
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
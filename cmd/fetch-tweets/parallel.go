@@ -0,0 +1,113 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grant/sn42/pkg/report"
+)
+
+// queryList collects the values of a repeated -query flag
+type queryList []string
+
+func (l *queryList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *queryList) Set(q string) error {
+	if q = strings.TrimSpace(q); q == "" {
+		return fmt.Errorf("empty query")
+	}
+	*l = append(*l, q)
+	return nil
+}
+
+// distinctOutputs makes sure no two queries are saved to the same file,
+// whose name is derived from the query (or its label) without its quotes and
+// case: a query whose name is taken is labelled with the name and a number,
+// so "Bitcoin" after bitcoin is saved as bitcoin_2
+func distinctOutputs(queries []queryJob) {
+	names := map[string]string{}
+	for i, job := range queries {
+		q := cmp.Or(job.label, job.query)
+		name := sanitizeQuery(cmp.Or(strings.ReplaceAll(job.label, "-", "_"), job.query))
+		if other, ok := names[name]; ok {
+			label := name
+			for n := 2; names[label] != ""; n++ {
+				label = fmt.Sprintf("%s_%d", name, n)
+			}
+			fmt.Printf("⚠️ Query %q would be saved to the same file as %q; labelling it %s\n", q, other, label)
+			queries[i].label, name = label, label
+		}
+		names[name] = q
+	}
+}
+
+// collectParallel collects queries with up to n of them running at once.
+// They share the run's collector, so the API client with its rate limit
+// pacing and circuit breaker, the run caps and the sink are shared too; the
+// collector's Lock keeps the pipeline and sink to one batch at a time. It
+// returns the result and error of every query started, in the order of
+// queries, and how many were skipped once a run cap was reached.
+func (r *run) collectParallel(queries []queryJob, n int) ([]report.Query, []error, int) {
+	results := make([]*report.Query, len(queries))
+	errs := make([]error, len(queries))
+	shared := *r
+	collector := *r.collector
+	collector.Lock = &sync.Mutex{}
+	shared.collector = &collector
+	// Every reservoir is seeded with the run's seed, picked before the
+	// queries race for it
+	if r.reservoir > 0 {
+		r.seed.Value()
+	}
+	fmt.Printf("\nCollecting %d queries, up to %d at once\n", len(queries), n)
+
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for range min(n, len(queries)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				job := queries[i]
+				label := cmp.Or(job.label, job.query)
+				if collector.Budget.Exhausted() {
+					continue
+				}
+				fmt.Printf("\n=== Query %d/%d started: %s ===\n", i+1, len(queries), label)
+				start := time.Now()
+				result, err := shared.collectQuery(job)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "❌ %s: %v\n", label, err)
+					result.Error = err.Error()
+				}
+				result.Seconds = report.Since(start)
+				fmt.Printf("\n=== Query %d/%d finished: %s (%d tweets saved) ===\n", i+1, len(queries), label, result.Saved)
+				results[i], errs[i] = &result, err
+			}
+		}()
+	}
+	for i := range queries {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	var started []report.Query
+	var startedErrs []error
+	for i, result := range results {
+		if result != nil {
+			started = append(started, *result)
+			startedErrs = append(startedErrs, errs[i])
+		}
+	}
+	if skipped := len(queries) - len(started); skipped > 0 {
+		fmt.Fprintf(os.Stderr, "\n⚠️ %v; skipped %d queries\n", collector.Budget.Check(), skipped)
+	}
+	return started, startedErrs, len(queries) - len(started)
+}
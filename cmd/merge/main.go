@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/manifest"
+	"github.com/grant/sn42/pkg/pipeline"
+	"github.com/grant/sn42/pkg/sink"
+	"github.com/grant/sn42/pkg/spill"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+func main() {
+	output := flag.String("o", "", "Output path (required)")
+	dedup := flag.String("dedup", pipeline.DedupByID, "Drop duplicate tweets by \"id\" or by normalized \"text\" (empty to keep all)")
+	sortBy := flag.String("sort", "", "Order tweets by id, created_at (both oldest first) or engagement (highest first) instead of input order")
+	spillFlags := spill.RegisterFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: merge -o output.json [-dedup id|text] [-sort id|created_at|engagement] [-memory-budget-mb MB] <dataset.json>...\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 || *output == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	var sortKey func(types.Document) string
+	if *sortBy != "" {
+		var err error
+		if sortKey, err = dataset.SortKey(*sortBy); err != nil {
+			log.Fatalf("Invalid -sort: %v", err)
+		}
+	}
+
+	dataset.LoadEnv()
+
+	// The dedup set and the sorter share the memory budget
+	budget := spillFlags.Budget()
+	if *dedup != "" && *sortBy != "" {
+		budget /= 2
+	}
+	var stages []string
+	var dedupStage *pipeline.Dedup
+	if *dedup != "" {
+		var err error
+		if dedupStage, err = pipeline.NewDedup(*dedup, spill.NewSet(spillFlags.Dir, budget)); err != nil {
+			log.Fatalf("Invalid -dedup: %v", err)
+		}
+		defer dedupStage.Close()
+	}
+	sorter := spill.NewSorter(spillFlags.Dir, budget)
+	defer sorter.Close()
+
+	writer, err := dataset.NewWriter(*output, dataset.File{})
+	if err != nil {
+		log.Fatalf("Failed to create output: %v", err)
+	}
+
+	// Stream every input through dedup, straight to the output or into the sorter
+	read, dropped := 0, 0
+	var first *dataset.File
+	collectedAt := ""
+	for _, path := range flag.Args() {
+		fmt.Printf("Reading %s...\n", path)
+		header, err := dataset.Scan(path, func(doc types.Document) error {
+			read++
+			if dedupStage != nil {
+				dup, err := dedupStage.Seen(doc)
+				if err != nil || dup {
+					dropped++
+					return err
+				}
+			}
+			if sortKey != nil {
+				data, err := json.Marshal(doc)
+				if err != nil {
+					return fmt.Errorf("failed to marshal tweet: %w", err)
+				}
+				return sorter.Add(sortKey(doc), data)
+			}
+			return writer.Write(context.Background(), sink.Batch{Docs: []types.Document{doc}})
+		})
+		if err != nil {
+			writer.Discard()
+			log.Fatalf("Failed to read dataset: %v", err)
+		}
+		if first == nil {
+			first = header
+		}
+		collectedAt = max(collectedAt, header.CollectedAt)
+	}
+	stages = append(stages, fmt.Sprintf("merge(inputs=%d)", flag.NArg()))
+	if dedupStage != nil {
+		stages = append(stages, dedupStage.Name())
+	}
+
+	if sortKey != nil {
+		fmt.Printf("Sorting %d tweets by %s (%d spill runs)...\n", sorter.Len(), *sortBy, sorter.Runs())
+		err := sorter.Each(func(data []byte) error {
+			var doc types.Document
+			if err := json.Unmarshal(data, &doc); err != nil {
+				return fmt.Errorf("failed to decode spilled tweet: %w", err)
+			}
+			return writer.Write(context.Background(), sink.Batch{Docs: []types.Document{doc}})
+		})
+		if err != nil {
+			writer.Discard()
+			log.Fatalf("Failed to sort tweets: %v", err)
+		}
+		stages = append(stages, "sort("+*sortBy+")")
+	}
+
+	// Take collected_at from the inputs rather than the clock so reruns are byte-identical
+	header := writer.Header()
+	header.CollectedAt = collectedAt
+	if flag.NArg() == 1 {
+		header.Trend, header.Query, header.SavedQuery = first.Trend, first.Query, first.SavedQuery
+	}
+	if err := writer.Close(); err != nil {
+		log.Fatalf("Failed to save merged dataset: %v", err)
+	}
+	if _, err := manifest.Write(*output, &manifest.Manifest{
+		Tool:       "merge",
+		Query:      header.Query,
+		Trend:      header.Trend,
+		Records:    writer.Count(),
+		Pipeline:   stages,
+		Provenance: manifest.ProvenanceFromEnv(),
+	}); err != nil {
+		log.Fatalf("Failed to write manifest: %v", err)
+	}
+
+	fmt.Printf("✅ Merged %d of %d tweets from %d datasets to %s (%d duplicates dropped)\n", writer.Count(), read, flag.NArg(), *output, dropped)
+}
@@ -0,0 +1,206 @@
+// Command runs lists and shows the collection runs recorded in the audit log
+// that fetch-tweets and fetch-trends append to.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/grant/sn42/pkg/audit"
+	"github.com/grant/sn42/pkg/envprofile"
+)
+
+// command is a runs subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(args []string)
+}
+
+var commands = []command{
+	{"list", "List the recorded runs, most recent first", runList},
+	{"show", "Show who started a run, its arguments, queries, outputs and errors", runShow},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	name := os.Args[1]
+	for _, cmd := range commands {
+		if cmd.name == name {
+			cmd.run(os.Args[2:])
+			return
+		}
+	}
+	if name != "-h" && name != "-help" && name != "help" {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+	}
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: runs <command> [flags]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-5s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun \"runs <command> -h\" for the flags of a command.\n")
+}
+
+func runList(args []string) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	path := fs.String("audit-log", "", "Audit log to read (default: "+audit.FileName+" in the runs directory)")
+	profileName := envprofile.RegisterFlag(fs)
+	limit := fs.Int("n", 20, "Number of runs to list (0 = all)")
+	tool := fs.String("tool", "", "Only list runs of this tool, e.g. fetch-tweets")
+	status := fs.String("status", "", "Only list runs with this status: ok, partial, failed or incomplete")
+	user := fs.String("user", "", "Only list runs started by this user")
+	jsonOutput := fs.Bool("json", false, "Print the runs as JSON")
+	fs.Parse(args)
+
+	readPath := auditLogPath(*path, *profileName)
+	runs, err := audit.Read(readPath)
+	if err != nil {
+		log.Fatalf("Failed to read audit log: %v", err)
+	}
+	var matched []audit.Run
+	for i := len(runs) - 1; i >= 0; i-- {
+		r := runs[i]
+		if (*tool != "" && r.Tool != *tool) || (*status != "" && r.Status != *status) || (*user != "" && r.User != *user) {
+			continue
+		}
+		matched = append(matched, r)
+		if *limit > 0 && len(matched) == *limit {
+			break
+		}
+	}
+
+	if *jsonOutput {
+		printJSON(matched)
+		return
+	}
+	if len(matched) == 0 {
+		fmt.Printf("No runs recorded in %s\n", readPath)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RUN\tTOOL\tUSER\tSTARTED\tDURATION\tSTATUS\tQUERIES\tFETCHED\tSAVED\tERRORS")
+	for _, r := range matched {
+		duration, queries, fetched, saved, errs := "-", "-", "-", "-", "-"
+		if s := r.Summary; s != nil {
+			duration = formatSeconds(s.Seconds)
+			queries = fmt.Sprint(len(s.Queries))
+			fetched, saved, errs = fmt.Sprint(s.Totals.Fetched), fmt.Sprint(s.Totals.Saved), fmt.Sprint(s.Totals.Errors)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.ID, r.Tool, r.User, r.StartedAt, duration, r.Status, queries, fetched, saved, errs)
+	}
+	w.Flush()
+}
+
+func runShow(args []string) {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	path := fs.String("audit-log", "", "Audit log to read (default: "+audit.FileName+" in the runs directory)")
+	profileName := envprofile.RegisterFlag(fs)
+	jsonOutput := fs.Bool("json", false, "Print the run as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: runs show [-json] <run ID or prefix>\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	readPath := auditLogPath(*path, *profileName)
+	runs, err := audit.Read(readPath)
+	if err != nil {
+		log.Fatalf("Failed to read audit log: %v", err)
+	}
+	r, err := audit.Find(runs, fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *jsonOutput {
+		printJSON(r)
+		return
+	}
+
+	fmt.Printf("Run:       %s\n", r.ID)
+	fmt.Printf("Tool:      %s\n", r.Tool)
+	fmt.Printf("Started:   %s by %s on %s (pid %d)\n", r.StartedAt, r.User, r.Host, r.PID)
+	fmt.Printf("Arguments: %s\n", strings.Join(r.Args, " "))
+	fmt.Printf("Directory: %s\n", r.Dir)
+	if len(r.Sinks) > 0 {
+		fmt.Printf("Sinks:     %s\n", strings.Join(r.Sinks, ", "))
+	}
+	if r.Token != "" {
+		fmt.Printf("Token:     %s\n", r.Token)
+	}
+	fmt.Printf("Status:    %s\n", r.Status)
+	s := r.Summary
+	if s == nil {
+		fmt.Println("\nNo outcome was recorded: the run is still going, or it crashed or was killed.")
+		return
+	}
+	fmt.Printf("Finished:  %s (%s)\n", s.FinishedAt, formatSeconds(s.Seconds))
+	fmt.Printf("API calls: %d\n", r.Calls)
+	if s.Note != "" {
+		fmt.Printf("Note:      %s\n", s.Note)
+	}
+
+	fmt.Printf("\nQueries (%d):\n", len(s.Queries))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  QUERY\tREQUESTED\tFETCHED\tDROPPED\tSAVED\tERROR")
+	for _, q := range s.Queries {
+		name := q.Query
+		if q.Label != "" {
+			name = q.Label + " (" + q.Query + ")"
+		}
+		fmt.Fprintf(w, "  %s\t%d\t%d\t%d\t%d\t%s\n", name, q.Requested, q.Fetched, q.Dropped, q.Saved, q.Error)
+	}
+	t := s.Totals
+	fmt.Fprintf(w, "  Total\t%d\t%d\t%d\t%d\t%d errors\n", t.Requested, t.Fetched, t.Dropped, t.Saved, t.Errors)
+	w.Flush()
+
+	if len(r.Destinations) > 0 {
+		fmt.Printf("\nDestinations (%d):\n", len(r.Destinations))
+		for _, d := range r.Destinations {
+			fmt.Printf("  %s\n", d)
+		}
+	}
+}
+
+// auditLogPath returns the audit log to read: path if given, else the one in
+// the runs directory of the profile
+func auditLogPath(path, profile string) string {
+	if path != "" {
+		return path
+	}
+	dir, err := envprofile.DataDir(profile)
+	if err != nil {
+		log.Fatalf("Failed to load profile: %v", err)
+	}
+	return filepath.Join(dir, audit.FileName)
+}
+
+func printJSON(v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to marshal runs: %v", err)
+	}
+	fmt.Println(string(data))
+}
+
+func formatSeconds(s float64) string {
+	return (time.Duration(s * float64(time.Second))).Round(100 * time.Millisecond).String()
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/grant/sn42/pkg/crypt"
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/manifest"
+	"github.com/grant/sn42/pkg/sample"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+func main() {
+	n := flag.Int("n", 0, "Number of tweets to sample (required)")
+	stratify := flag.String("stratify", "", "Stratify by attribute: "+strings.Join(sample.Attributes, ", "))
+	proportions := flag.String("proportions", "", "Target proportions per stratum instead of the collection's own, e.g. en=0.5,es=0.5 (requires -stratify)")
+	seed := sample.RegisterSeedFlag(flag.CommandLine)
+	output := flag.String("o", "", "Output path (default: <dataset>.sample.json; required with several inputs)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: sample -n count [-stratify attribute [-proportions name=weight,...]] [-seed n] [-o output.json] <dataset.json>...\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 || *n <= 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *proportions != "" && *stratify == "" {
+		log.Fatal("-proportions requires -stratify")
+	}
+	outPath := *output
+	if outPath == "" {
+		if flag.NArg() > 1 {
+			log.Fatal("-o is required when sampling from several datasets")
+		}
+		outPath = outputPath(flag.Arg(0), ".sample.json")
+	}
+
+	dataset.LoadEnv()
+
+	// Pool the tweets of all inputs
+	var docs []types.Document
+	var first *dataset.File
+	collectedAt := ""
+	for _, path := range flag.Args() {
+		ds, err := dataset.Load(path)
+		if err != nil {
+			log.Fatalf("Failed to load dataset: %v", err)
+		}
+		if first == nil {
+			first = ds
+		}
+		docs = append(docs, ds.Tweets...)
+		collectedAt = max(collectedAt, ds.CollectedAt)
+	}
+
+	seedValue := seed.Value()
+	rng := sample.NewRand(seedValue)
+	fmt.Printf("Random seed: %d\n", seedValue)
+
+	var sampled []types.Document
+	description := fmt.Sprintf("sample(n=%d)", *n)
+	if *stratify == "" {
+		sampled = sample.Random(docs, *n, rng)
+	} else {
+		key, err := sample.Key(*stratify)
+		if err != nil {
+			log.Fatalf("Invalid -stratify: %v", err)
+		}
+		var targets map[string]float64
+		if *proportions != "" {
+			if targets, err = sample.ParseProportions(*proportions); err != nil {
+				log.Fatalf("Invalid -proportions: %v", err)
+			}
+		}
+
+		var strata []sample.Stratum
+		sampled, strata = sample.Stratified(docs, *n, key, targets, rng)
+		description = fmt.Sprintf("sample(n=%d,stratify=%s)", *n, *stratify)
+		if targets != nil {
+			description = fmt.Sprintf("sample(n=%d,stratify=%s,proportions=%s)", *n, *stratify, *proportions)
+		}
+		printStrata(*stratify, strata)
+	}
+
+	// Take collected_at from the inputs rather than the clock so reruns are byte-identical
+	out := &dataset.File{Tweets: sampled, CollectedAt: collectedAt}
+	if flag.NArg() == 1 {
+		out.Trend, out.Query = first.Trend, first.Query
+	}
+	if err := out.Save(outPath, nil); err != nil {
+		log.Fatalf("Failed to save sample: %v", err)
+	}
+	if _, err := manifest.Write(outPath, &manifest.Manifest{
+		Tool:       "sample",
+		Query:      out.Query,
+		Trend:      out.Trend,
+		Records:    len(sampled),
+		Pipeline:   []string{description},
+		Seed:       &seedValue,
+		Provenance: manifest.ProvenanceFromEnv(),
+	}); err != nil {
+		log.Fatalf("Failed to write manifest: %v", err)
+	}
+
+	if len(sampled) < *n {
+		fmt.Printf("⚠️ Only %d of the requested %d tweets could be sampled\n", len(sampled), *n)
+	}
+	fmt.Printf("✅ Sampled %d of %d tweets to %s\n", len(sampled), len(docs), outPath)
+}
+
+// printStrata prints the per-stratum sample sizes, flagging strata that were too small
+func printStrata(attribute string, strata []sample.Stratum) {
+	fmt.Printf("%-24s %10s %8s %8s\n", attribute, "available", "target", "selected")
+	for _, s := range strata {
+		marker := ""
+		if s.Selected < s.Target {
+			marker = "  ⚠️ short"
+		}
+		fmt.Printf("%-24s %10d %8d %8d%s\n", s.Name, s.Available, s.Target, s.Selected, marker)
+	}
+	fmt.Println()
+}
+
+// outputPath derives an output path from the dataset path by replacing its .json suffix
+func outputPath(input, suffix string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(input, crypt.Extension), ".json") + suffix
+}
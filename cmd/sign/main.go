@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/grant/sn42/pkg/signing"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	keyPath := flag.String("key", "", "Path to the ed25519 private key (default: SIGNING_KEY env var)")
+	genKey := flag.Bool("genkey", false, "Generate a new key pair at -key (public key written to <key>.pub) and exit")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: sign [-key signing.pem] <file>...\n       sign -genkey -key signing.pem\n\nWrites a detached <file>.sig signature for each file.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Warning: failed to load .env file: %v", err)
+	}
+
+	if *keyPath == "" {
+		*keyPath = os.Getenv("SIGNING_KEY")
+	}
+	if *keyPath == "" {
+		log.Fatal("No signing key specified. Use -key or set SIGNING_KEY")
+	}
+
+	if *genKey {
+		if _, err := os.Stat(*keyPath); err == nil {
+			log.Fatalf("Refusing to overwrite existing key %s", *keyPath)
+		}
+		if err := signing.GenerateKey(*keyPath); err != nil {
+			log.Fatalf("Failed to generate key: %v", err)
+		}
+		fmt.Printf("✅ Wrote private key to %s and public key to %s.pub\n", *keyPath, *keyPath)
+		return
+	}
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	priv, err := signing.LoadPrivateKey(*keyPath)
+	if err != nil {
+		log.Fatalf("Failed to load signing key: %v", err)
+	}
+
+	for _, path := range flag.Args() {
+		sig, err := signing.SignFile(priv, path)
+		if err != nil {
+			log.Fatalf("Failed to sign %s: %v", path, err)
+		}
+		fmt.Printf("✅ Signed %s (sha256 %s) -> %s%s\n", path, sig.SHA256, path, signing.Extension)
+	}
+}
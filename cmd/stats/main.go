@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/manifest"
+	"github.com/grant/sn42/pkg/stats"
+)
+
+func main() {
+	tokens := flag.Bool("tokens", false, "Count tokens of the tweet text (for sizing LLM training runs)")
+	tokenizer := flag.String("tokenizer", stats.DefaultTokenizer, "Tokenizer for -tokens: "+strings.Join(stats.Tokenizers, ", "))
+	updateManifest := flag.Bool("update-manifest", false, "Record token counts in each dataset's manifest and dataset card")
+	histogram := flag.String("histogram", "", "Count tweets per interval to show coverage gaps: "+strings.Join(stats.Intervals, ", "))
+	jsonOutput := flag.Bool("json", false, "Print statistics as JSON")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: stats [-tokens [-tokenizer name] [-update-manifest]] [-histogram hour|day] [-json] <dataset.json>...\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *updateManifest && !*tokens {
+		log.Fatal("-update-manifest requires -tokens")
+	}
+
+	dataset.LoadEnv()
+
+	results := map[string]*stats.Stats{}
+	for _, path := range flag.Args() {
+		ds, err := dataset.Load(path)
+		if err != nil {
+			log.Fatalf("Failed to load dataset: %v", err)
+		}
+
+		s := stats.Compute(ds.Tweets)
+		if *tokens {
+			if s.Tokens, err = stats.CountTokens(ds.Tweets, *tokenizer); err != nil {
+				log.Fatalf("Failed to count tokens: %v", err)
+			}
+			if *updateManifest {
+				if err := recordTokens(path, s.Tokens); err != nil {
+					log.Fatalf("Failed to update manifest for %s: %v", path, err)
+				}
+			}
+		}
+		if *histogram != "" {
+			if s.Histogram, err = stats.ComputeHistogram(ds.Tweets, *histogram); err != nil {
+				log.Fatalf("Failed to compute histogram: %v", err)
+			}
+		}
+
+		if *jsonOutput {
+			results[path] = s
+		} else {
+			printStats(path, s)
+		}
+	}
+
+	if *jsonOutput {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to marshal statistics: %v", err)
+		}
+		fmt.Println(string(data))
+	}
+}
+
+// recordTokens stores the token statistics in the dataset's manifest and rewrites its card
+func recordTokens(path string, ts *stats.TokenStats) error {
+	m, err := manifest.Load(path)
+	if err != nil {
+		return err
+	}
+	m.Tokens = ts
+	_, err = manifest.Write(path, m)
+	return err
+}
+
+// histogramWidth is the length of the longest bar of the histogram chart
+const histogramWidth = 50
+
+func printStats(path string, s *stats.Stats) {
+	fmt.Printf("=== %s ===\n", path)
+	fmt.Printf("Records:        %d\n", s.Records)
+	fmt.Printf("Unique authors: %d\n", s.UniqueAuthors)
+	fmt.Printf("Characters:     %d total, %.1f average\n", s.TotalChars, s.AvgChars)
+	if s.Earliest != "" {
+		fmt.Printf("Time range:     %s to %s\n", s.Earliest, s.Latest)
+	}
+	if langs := s.TopLanguages(); len(langs) > 0 {
+		parts := make([]string, 0, len(langs))
+		for _, l := range langs {
+			parts = append(parts, fmt.Sprintf("%s (%d)", l.Lang, l.Count))
+		}
+		fmt.Printf("Languages:      %s\n", strings.Join(parts, ", "))
+	}
+	if s.Tokens != nil {
+		fmt.Printf("Tokens (%s): %d total, %.1f average, %d max\n", s.Tokens.Tokenizer, s.Tokens.Total, s.Tokens.Average, s.Tokens.Max)
+	}
+	if h := s.Histogram; h != nil {
+		fmt.Printf("Tweets per %s (%d buckets, %d empty", h.Interval, len(h.Buckets), h.EmptyBuckets)
+		if h.Undated > 0 {
+			fmt.Printf(", %d undated tweets", h.Undated)
+		}
+		fmt.Printf("):\n%s", h.Chart(histogramWidth))
+	}
+	fmt.Println()
+}
@@ -0,0 +1,135 @@
+// Command trends works with the trend snapshots fetch-trends saves, to follow
+// how topics rise and fall between collection runs, and watches the trends
+// for topics worth collecting.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/grant/sn42/pkg/trending"
+)
+
+// command is a trends subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(args []string)
+}
+
+var commands = []command{
+	{"diff", "Compare two trend snapshots: new, dropped and rank-changed trends", runDiff},
+	{"watch", "Poll the trends and alert when a new trend matches a pattern", runWatch},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	name := os.Args[1]
+	for _, cmd := range commands {
+		if cmd.name == name {
+			cmd.run(os.Args[2:])
+			return
+		}
+	}
+	if name != "-h" && name != "-help" && name != "help" {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+	}
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: trends <command> [flags]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-5s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun \"trends <command> -h\" for the flags of a command.\n")
+}
+
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	dir := fs.String("dir", trending.DefaultDir, "Directory of the snapshots, for comparing the latest two")
+	region := fs.String("region", trending.DefaultRegion, "Region of the snapshots, for comparing the latest two")
+	jsonOutput := fs.Bool("json", false, "Print the diff as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: trends diff [-dir %s] [-region name] [-json] [<older snapshot> <newer snapshot>]\n\n", trending.DefaultDir)
+		fmt.Fprintf(os.Stderr, "Reports the trends that are new, dropped or at another rank in the newer snapshot.\nWithout files, compares the latest two snapshots of -region in -dir.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	paths := fs.Args()
+	switch len(paths) {
+	case 0:
+		all, err := trending.List(*dir, *region)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(all) < 2 {
+			log.Fatalf("Need two snapshots of region %q in %s to compare, found %d", *region, *dir, len(all))
+		}
+		paths = all[len(all)-2:]
+	case 2:
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+	from, err := trending.LoadSnapshot(paths[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	to, err := trending.LoadSnapshot(paths[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if from.CapturedAt > to.CapturedAt {
+		from, to = to, from
+	}
+	diff := trending.Compare(from, to)
+
+	if *jsonOutput {
+		data, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to marshal diff: %v", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Printf("📊 Trends (%s): %s → %s\n", diff.Region, diff.From, diff.To)
+	if len(diff.New) > 0 {
+		fmt.Printf("\n🆕 New (%d):\n", len(diff.New))
+		for _, c := range diff.New {
+			fmt.Printf("  %3d. %s\n", c.NewRank, c.Name)
+		}
+	}
+	if len(diff.Dropped) > 0 {
+		fmt.Printf("\n🗑️  Dropped (%d):\n", len(diff.Dropped))
+		for _, c := range diff.Dropped {
+			fmt.Printf("  %3d. %s\n", c.OldRank, c.Name)
+		}
+	}
+	if len(diff.Moved) > 0 {
+		fmt.Printf("\n↕️  Rank changes (%d):\n", len(diff.Moved))
+		for _, c := range diff.Moved {
+			arrow := "▲"
+			if c.Moved() < 0 {
+				arrow = "▼"
+			}
+			fmt.Printf("  %3d. %s (was %d, %s%d)\n", c.NewRank, c.Name, c.OldRank, arrow, abs(c.Moved()))
+		}
+	}
+	fmt.Printf("\n%d new, %d dropped, %d moved, %d unchanged\n", len(diff.New), len(diff.Dropped), len(diff.Moved), diff.Unchanged)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
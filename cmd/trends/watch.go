@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopher-lab/gopher-client/client"
+	"github.com/grant/sn42/pkg/apiclient"
+	"github.com/grant/sn42/pkg/envprofile"
+	"github.com/grant/sn42/pkg/notify"
+	"github.com/grant/sn42/pkg/trending"
+	"github.com/grant/sn42/pkg/twitterquery"
+	"github.com/joho/godotenv"
+)
+
+// patternList collects the repeatable -match flag
+type patternList []string
+
+func (p *patternList) String() string { return strings.Join(*p, ", ") }
+
+func (p *patternList) Set(v string) error {
+	*p = append(*p, v)
+	return nil
+}
+
+// Match is a newly appearing trend that matched a pattern
+type Match struct {
+	Trend   string `json:"trend"`
+	Rank    int    `json:"rank"`
+	Pattern string `json:"pattern"`
+}
+
+// Alert is the payload sent to a JSON webhook for one poll
+type Alert struct {
+	CapturedAt string  `json:"captured_at"`
+	Region     string  `json:"region"`
+	Matches    []Match `json:"matches"`
+}
+
+// watcher polls the trends and alerts on new ones matching its patterns
+type watcher struct {
+	client    *client.Client
+	patterns  []*regexp.Regexp
+	dir       string
+	region    string
+	webhook   *notify.Webhook
+	collector *collector
+	last      *trending.Snapshot // Trends of the previous poll
+}
+
+// collector starts a tweet collection for a matching trend
+type collector struct {
+	command  string
+	args     []string
+	minLikes int
+	amount   int
+	wg       sync.WaitGroup
+}
+
+func runWatch(args []string) {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	var exprs patternList
+	fs.Var(&exprs, "match", "Alert when a new trend matches this regex, ignoring case (repeatable)")
+	matchFile := fs.String("match-file", "", "File of regexes to match, one per line (# starts a comment)")
+	interval := fs.Duration("interval", 15*time.Minute, "Time between polls")
+	once := fs.Bool("once", false, "Poll once and exit, e.g. from cron; the latest snapshot in -dir is the previous poll")
+	dir := fs.String("dir", trending.DefaultDir, "Save each poll as a trend snapshot in this directory, and compare the first poll with the latest one there (empty to disable)")
+	region := fs.String("region", trending.DefaultRegion, "Region recorded in the snapshots")
+	webhookURL := fs.String("webhook", os.Getenv("TRENDS_WEBHOOK_URL"), "Post alerts to this Slack, Discord or JSON webhook (default: TRENDS_WEBHOOK_URL); alerts are always printed")
+	webhookFormat := fs.String("webhook-format", "", "Webhook payload: slack, discord or json (default: detected from the URL)")
+	collectCmd := fs.String("collect", "", "Start this collector, e.g. fetch-tweets, for each matching trend with QUERY set to the exact trend; arguments after -- are passed to it")
+	minLikes := fs.Int("collect-min-likes", 100, "min_faves of the query the collector is started with")
+	amount := fs.Int("collect-amount", 1000, "AMOUNT the collector is started with")
+	profileName := envprofile.RegisterFlag(fs)
+	apiFlags := apiclient.RegisterFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: trends watch -match regex [-match regex...] [-interval 15m] [-webhook url] [-collect fetch-tweets [-- collector flags]]\n\n")
+		fmt.Fprintf(os.Stderr, "Polls the trends and alerts when a trend that was not in the previous poll matches\none of the patterns, optionally starting a tweet collection for it.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *matchFile != "" {
+		lines, err := readPatterns(*matchFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		exprs = append(exprs, lines...)
+	}
+	if len(exprs) == 0 {
+		log.Fatal("Nothing to watch for: give -match or -match-file")
+	}
+	if *interval <= 0 && !*once {
+		log.Fatalf("-interval must be positive, got %s", *interval)
+	}
+	w := &watcher{dir: *dir, region: *region}
+	for _, expr := range exprs {
+		re, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			log.Fatalf("Invalid pattern %q: %v", expr, err)
+		}
+		w.patterns = append(w.patterns, re)
+	}
+	if *webhookURL != "" {
+		var err error
+		if w.webhook, err = notify.NewWebhook(notify.WebhookConfig{URL: *webhookURL, Format: *webhookFormat}); err != nil {
+			log.Fatalf("Invalid webhook: %v", err)
+		}
+	}
+	if *collectCmd != "" {
+		if *minLikes < 0 || *amount <= 0 {
+			log.Fatal("-collect-min-likes must not be negative and -collect-amount must be positive")
+		}
+		w.collector = &collector{command: *collectCmd, args: fs.Args(), minLikes: *minLikes, amount: *amount}
+		if *profileName != "" {
+			w.collector.args = append([]string{"-profile", *profileName}, w.collector.args...)
+		}
+	} else if fs.NArg() > 0 {
+		log.Fatalf("Arguments after the flags are passed to -collect, which is not set: %s", strings.Join(fs.Args(), " "))
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Warning: Could not load .env file: %v", err)
+	}
+	if err := envprofile.Use(*profileName); err != nil {
+		log.Fatalf("Failed to apply profile: %v", err)
+	}
+	var err error
+	if w.client, err = apiFlags.New(); err != nil {
+		log.Fatalf("Failed to create client: %v", err)
+	}
+	if w.client.Token == "" {
+		log.Fatal("GOPHER_CLIENT_TOKEN is not set")
+	}
+	if w.dir != "" {
+		if paths, err := trending.List(w.dir, w.region); err == nil && len(paths) > 0 {
+			if w.last, err = trending.LoadSnapshot(paths[len(paths)-1]); err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("Comparing with the snapshot of %s\n", w.last.CapturedAt)
+		}
+	}
+
+	fmt.Printf("👀 Watching trends for %s\n", strings.Join(exprs, ", "))
+	for {
+		if err := w.poll(); err != nil {
+			if *once {
+				log.Fatal(err)
+			}
+			fmt.Printf("⚠️ %v; retrying in %s\n", err, *interval)
+		}
+		if *once {
+			break
+		}
+		time.Sleep(*interval)
+	}
+	if w.collector != nil {
+		w.collector.wg.Wait()
+	}
+}
+
+// poll fetches the trends and alerts on the new ones that match
+func (w *watcher) poll() error {
+	trends, err := (&trending.Fetcher{Client: w.client}).Fetch()
+	if err != nil {
+		return fmt.Errorf("failed to fetch trends: %w", err)
+	}
+	snapshot := trending.NewSnapshot(trends, w.region, "", time.Now())
+	if w.dir != "" {
+		if _, err := snapshot.Save(w.dir); err != nil {
+			fmt.Printf("⚠️ Failed to save trends snapshot: %v\n", err)
+		}
+	}
+	// Without a previous poll, every trend is new
+	fresh := trending.Compare(&trending.Snapshot{}, snapshot).New
+	if w.last != nil {
+		fresh = trending.Compare(w.last, snapshot).New
+	}
+	w.last = snapshot
+
+	var matches []Match
+	for _, t := range fresh {
+		for _, re := range w.patterns {
+			if re.MatchString(t.Name) {
+				matches = append(matches, Match{Trend: t.Name, Rank: t.NewRank, Pattern: strings.TrimPrefix(re.String(), "(?i)")})
+				break
+			}
+		}
+	}
+	fmt.Printf("%s: %d trends, %d new, %d matching\n", snapshot.CapturedAt, len(trends), len(fresh), len(matches))
+	if len(matches) == 0 {
+		return nil
+	}
+
+	lines := make([]string, len(matches))
+	for i, m := range matches {
+		lines[i] = fmt.Sprintf("• %d. %s (matches %s)", m.Rank, m.Trend, m.Pattern)
+	}
+	text := fmt.Sprintf("🚨 %d new trends matching the watch list (%s, %s):\n%s", len(matches), w.region, snapshot.CapturedAt, strings.Join(lines, "\n"))
+	fmt.Println(text)
+	if w.webhook != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		err := w.webhook.Post(ctx, text, Alert{CapturedAt: snapshot.CapturedAt, Region: w.region, Matches: matches})
+		cancel()
+		if err != nil {
+			fmt.Printf("⚠️ Failed to post alert: %v\n", err)
+		}
+	}
+	if w.collector != nil {
+		for _, m := range matches {
+			w.collector.start(m.Trend)
+		}
+	}
+	return nil
+}
+
+// start runs the collector for trend in the background. Its output goes to
+// its own run directory, so only its start and outcome are reported here.
+func (c *collector) start(trend string) {
+	query, err := twitterquery.New().Exact(trend).MinFaves(c.minLikes).Build()
+	if err != nil {
+		fmt.Printf("⚠️ Not collecting %s: %v\n", trend, err)
+		return
+	}
+	cmd := exec.Command(c.command, c.args...)
+	cmd.Env = append(os.Environ(), "QUERY="+query, "AMOUNT="+strconv.Itoa(c.amount))
+	if err := cmd.Start(); err != nil {
+		fmt.Printf("⚠️ Failed to start %s for %s: %v\n", c.command, trend, err)
+		return
+	}
+	fmt.Printf("🚀 Started %s for %s (pid %d)\n", c.command, query, cmd.Process.Pid)
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		if err := cmd.Wait(); err != nil {
+			fmt.Printf("❌ Collection for %s failed: %v\n", trend, err)
+			return
+		}
+		fmt.Printf("✅ Collection for %s finished\n", trend)
+	}()
+}
+
+// readPatterns reads one regex per line, skipping blank lines and comments
+func readPatterns(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open patterns: %w", err)
+	}
+	defer f.Close()
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read patterns: %w", err)
+	}
+	return patterns, nil
+}
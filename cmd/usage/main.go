@@ -0,0 +1,144 @@
+// Command usage reports the API calls made and documents fetched per token
+// per day or week, from the audit log the collectors append to, so quota can
+// be allocated across projects.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/grant/sn42/pkg/audit"
+	"github.com/grant/sn42/pkg/envprofile"
+)
+
+// noToken labels runs made without an API token, e.g. with -mock
+const noToken = "(none)"
+
+// row is the usage of one token over one period
+type row struct {
+	Period  string `json:"period"`
+	Token   string `json:"token"`
+	Runs    int    `json:"runs"`
+	Calls   int    `json:"calls"`
+	Fetched int    `json:"fetched"`
+	Saved   int    `json:"saved"`
+	Errors  int    `json:"errors"`
+}
+
+func main() {
+	path := flag.String("audit-log", "", "Audit log to read (default: "+audit.FileName+" in the runs directory)")
+	profileName := envprofile.RegisterFlag(flag.CommandLine)
+	by := flag.String("by", "day", "Period to sum usage over: day or week (ISO weeks, starting Monday)")
+	since := flag.String("since", "", "Only count runs started on or after this date (YYYY-MM-DD)")
+	token := flag.String("token", "", "Only report this token ID, as shown in the report")
+	jsonOutput := flag.Bool("json", false, "Print the usage as JSON")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: usage [-by day|week] [-since YYYY-MM-DD] [-token tok_...] [-json]\n\n")
+		fmt.Fprintf(os.Stderr, "Reports API calls and documents fetched per token per day or week from the audit log.\nTokens are shown by ID (a hash prefix), never in full.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var period func(time.Time) string
+	switch *by {
+	case "day":
+		period = func(t time.Time) string { return t.Format("2006-01-02") }
+	case "week":
+		period = func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}
+	default:
+		log.Fatalf("Invalid -by %q: must be day or week", *by)
+	}
+	var from time.Time
+	if *since != "" {
+		var err error
+		if from, err = time.Parse("2006-01-02", *since); err != nil {
+			log.Fatalf("Invalid -since %q: must be YYYY-MM-DD", *since)
+		}
+	}
+
+	if *path == "" {
+		dir, err := envprofile.DataDir(*profileName)
+		if err != nil {
+			log.Fatalf("Failed to load profile: %v", err)
+		}
+		*path = filepath.Join(dir, audit.FileName)
+	}
+	runs, err := audit.Read(*path)
+	if err != nil {
+		log.Fatalf("Failed to read audit log: %v", err)
+	}
+	type key struct{ period, token string }
+	rows := map[key]*row{}
+	for _, r := range runs {
+		started, err := time.Parse(time.RFC3339, r.StartedAt)
+		if err != nil || started.Before(from) {
+			continue
+		}
+		tok := r.Token
+		if tok == "" {
+			tok = noToken
+		}
+		if *token != "" && tok != *token {
+			continue
+		}
+		k := key{period(started.UTC()), tok}
+		u := rows[k]
+		if u == nil {
+			u = &row{Period: k.period, Token: tok}
+			rows[k] = u
+		}
+		u.Runs++
+		u.Calls += r.Calls
+		if s := r.Summary; s != nil {
+			u.Fetched += s.Totals.Fetched
+			u.Saved += s.Totals.Saved
+			u.Errors += s.Totals.Errors
+		}
+	}
+	report := make([]row, 0, len(rows))
+	for _, u := range rows {
+		report = append(report, *u)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Period != report[j].Period {
+			return report[i].Period < report[j].Period
+		}
+		return report[i].Token < report[j].Token
+	})
+
+	if *jsonOutput {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to marshal usage: %v", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+	if len(report) == 0 {
+		fmt.Printf("No runs recorded in %s\n", *path)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PERIOD\tTOKEN\tRUNS\tCALLS\tFETCHED\tSAVED\tERRORS")
+	var total row
+	for _, u := range report {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%d\n", u.Period, u.Token, u.Runs, u.Calls, u.Fetched, u.Saved, u.Errors)
+		total.Runs += u.Runs
+		total.Calls += u.Calls
+		total.Fetched += u.Fetched
+		total.Saved += u.Saved
+		total.Errors += u.Errors
+	}
+	fmt.Fprintf(w, "Total\t\t%d\t%d\t%d\t%d\t%d\n", total.Runs, total.Calls, total.Fetched, total.Saved, total.Errors)
+	w.Flush()
+}
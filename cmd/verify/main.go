@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/grant/sn42/pkg/signing"
+)
+
+func main() {
+	pubPath := flag.String("pubkey", "", "Path to the signer's ed25519 public key")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: verify -pubkey signing.pem.pub <file>...\n\nVerifies each file against its detached <file>.sig signature.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *pubPath == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	pub, err := signing.LoadPublicKey(*pubPath)
+	if err != nil {
+		log.Fatalf("Failed to load public key: %v", err)
+	}
+
+	failed := 0
+	for _, path := range flag.Args() {
+		sig, err := signing.VerifyFile(pub, path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s: %v\n", path, err)
+			failed++
+			continue
+		}
+		fmt.Printf("✅ %s: valid signature (signed %s, key %s)\n", path, sig.SignedAt, sig.KeyID[:16])
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,48 @@
+//go:build wasip1
+
+// Example --plugin module: drops follower-selling spam and adds a word_count
+// metadata field. Build it with
+//
+//	GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared -o plugin.wasm ./examples/wasm-plugin
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"unsafe"
+)
+
+// in and out hold the current buffers, which must outlive each call
+var in, out []byte
+
+//go:wasmexport alloc
+func alloc(size uint32) uint32 {
+	in = make([]byte, size)
+	return uint32(uintptr(unsafe.Pointer(unsafe.SliceData(in))))
+}
+
+//go:wasmexport process
+func process(ptr, size uint32) uint64 {
+	var tweet map[string]any
+	if err := json.Unmarshal(in[:size], &tweet); err != nil {
+		panic(err)
+	}
+	content, _ := tweet["content"].(string)
+	if strings.Contains(strings.ToLower(content), "cheap followers") {
+		return 0
+	}
+	metadata, ok := tweet["metadata"].(map[string]any)
+	if !ok {
+		metadata = map[string]any{}
+		tweet["metadata"] = metadata
+	}
+	metadata["word_count"] = len(strings.Fields(content))
+
+	var err error
+	if out, err = json.Marshal(tweet); err != nil {
+		panic(err)
+	}
+	return uint64(uintptr(unsafe.Pointer(unsafe.SliceData(out))))<<32 | uint64(len(out))
+}
+
+func main() {}
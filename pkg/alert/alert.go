@@ -0,0 +1,105 @@
+// Package alert watches the API error rate of a run and raises an alert
+// through a PagerDuty-compatible webhook when it stays above a threshold,
+// optionally pausing the run instead of letting it burn hours producing nothing.
+package alert
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Config configures a Monitor
+type Config struct {
+	Threshold   float64       // Error rate (0-1) that triggers the alert
+	Window      time.Duration // Sliding window the rate is measured over
+	MinRequests int           // Requests needed in the window before the rate counts
+	Pause       time.Duration // How long to pause after a failure while over the threshold, 0 to keep going
+}
+
+// Monitor tracks the outcome of API requests over a sliding window. A nil
+// Monitor ignores all calls. It is safe for concurrent use; a pause holds
+// back every request recorded while it lasts.
+type Monitor struct {
+	cfg       Config
+	alerter   *PagerDuty // Optional; without it alerts are only printed
+	mu        sync.Mutex
+	events    []event
+	triggered bool
+}
+
+type event struct {
+	at     time.Time
+	failed bool
+}
+
+// NewMonitor creates a monitor that sends alerts through alerter, if set
+func NewMonitor(cfg Config, alerter *PagerDuty) (*Monitor, error) {
+	if cfg.Threshold <= 0 || cfg.Threshold > 1 {
+		return nil, fmt.Errorf("error rate threshold must be in (0, 1], got %g", cfg.Threshold)
+	}
+	if cfg.Window <= 0 {
+		return nil, fmt.Errorf("error rate window must be positive, got %s", cfg.Window)
+	}
+	if cfg.MinRequests < 1 {
+		cfg.MinRequests = 1
+	}
+	return &Monitor{cfg: cfg, alerter: alerter}, nil
+}
+
+// Record counts the outcome of one API request (err is nil on success). When
+// the error rate crosses the threshold it triggers the alert, and when it falls
+// back below it resolves it. While over the threshold, a failure pauses the run
+// for the configured time.
+func (m *Monitor) Record(err error) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.events = append(m.events, event{at: now, failed: err != nil})
+	for len(m.events) > 0 && now.Sub(m.events[0].at) > m.cfg.Window {
+		m.events = m.events[1:]
+	}
+	if len(m.events) < m.cfg.MinRequests {
+		return
+	}
+
+	failed := 0
+	for _, e := range m.events {
+		if e.failed {
+			failed++
+		}
+	}
+	rate := float64(failed) / float64(len(m.events))
+
+	switch {
+	case rate >= m.cfg.Threshold && !m.triggered:
+		m.triggered = true
+		summary := fmt.Sprintf("API error rate %.0f%% (%d of %d requests in the last %s), last error: %v",
+			rate*100, failed, len(m.events), m.cfg.Window, err)
+		fmt.Printf("🚨 %s\n", summary)
+		m.send(m.alerter.Trigger, summary)
+	case rate < m.cfg.Threshold && m.triggered:
+		m.triggered = false
+		fmt.Printf("✅ API error rate back to %.0f%%, resolving alert\n", rate*100)
+		m.send(m.alerter.Resolve, "")
+	}
+
+	if m.triggered && err != nil && m.cfg.Pause > 0 {
+		fmt.Printf("⏸️ Pausing for %s before the next request\n", m.cfg.Pause)
+		time.Sleep(m.cfg.Pause)
+		// Judge the run afresh after the pause
+		m.events = nil
+	}
+}
+
+func (m *Monitor) send(fn func(string) error, summary string) {
+	if m.alerter == nil {
+		return
+	}
+	if err := fn(summary); err != nil {
+		fmt.Printf("⚠️ Failed to send alert: %v\n", err)
+	}
+}
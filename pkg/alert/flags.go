@@ -0,0 +1,35 @@
+package alert
+
+import (
+	"flag"
+	"time"
+)
+
+// Flags holds the error-rate alerting command-line flags shared by the collectors
+type Flags struct {
+	Config
+}
+
+// RegisterFlags registers the -alert-* flags on fs
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	fs.Float64Var(&f.Threshold, "alert-error-rate", 0, "Alert when this fraction of API requests fail within -alert-window, e.g. 0.5 (0 disables)")
+	fs.DurationVar(&f.Window, "alert-window", 10*time.Minute, "Sliding window for -alert-error-rate")
+	fs.IntVar(&f.MinRequests, "alert-min-requests", 5, "Requests needed in the window before -alert-error-rate applies")
+	fs.DurationVar(&f.Pause, "alert-pause", 0, "Pause this long after each failure while the error rate is over the threshold (0 keeps going)")
+	return f
+}
+
+// Build creates the monitor configured by the flags, sending alerts to the
+// PagerDuty-compatible endpoint from the environment if one is configured.
+// It returns nil when -alert-error-rate is not set.
+func (f *Flags) Build(tool string) (*Monitor, error) {
+	if f.Threshold == 0 {
+		return nil, nil
+	}
+	alerter, err := NewPagerDutyFromEnv(tool)
+	if err != nil {
+		return nil, err
+	}
+	return NewMonitor(f.Config, alerter)
+}
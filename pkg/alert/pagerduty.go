@@ -0,0 +1,109 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultEventsURL is the PagerDuty Events API v2 endpoint
+const DefaultEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDutyConfig configures alert delivery. Any receiver accepting PagerDuty
+// Events API v2 payloads works, e.g. Opsgenie or Grafana OnCall integrations.
+type PagerDutyConfig struct {
+	URL        string // Events endpoint, defaults to DefaultEventsURL
+	RoutingKey string // Integration (routing) key
+	Source     string // Reported source of the alert, e.g. the tool and host
+}
+
+// PagerDuty triggers and resolves one incident per run through the Events API v2
+type PagerDuty struct {
+	cfg        PagerDutyConfig
+	dedupKey   string
+	httpClient *http.Client
+}
+
+// NewPagerDutyFromEnv creates an alerter from ALERT_ROUTING_KEY and the
+// optional ALERT_WEBHOOK_URL. It returns nil (and no error) when no routing
+// key is set.
+func NewPagerDutyFromEnv(tool string) (*PagerDuty, error) {
+	if os.Getenv("ALERT_ROUTING_KEY") == "" {
+		if os.Getenv("ALERT_WEBHOOK_URL") != "" {
+			return nil, fmt.Errorf("ALERT_ROUTING_KEY is required when ALERT_WEBHOOK_URL is set")
+		}
+		return nil, nil
+	}
+	host, _ := os.Hostname()
+	return NewPagerDuty(PagerDutyConfig{
+		URL:        os.Getenv("ALERT_WEBHOOK_URL"),
+		RoutingKey: os.Getenv("ALERT_ROUTING_KEY"),
+		Source:     strings.TrimSuffix(tool+"@"+host, "@"),
+	})
+}
+
+// NewPagerDuty creates an alerter
+func NewPagerDuty(cfg PagerDutyConfig) (*PagerDuty, error) {
+	if cfg.RoutingKey == "" {
+		return nil, fmt.Errorf("alert routing key is required")
+	}
+	if cfg.URL == "" {
+		cfg.URL = DefaultEventsURL
+	}
+	return &PagerDuty{
+		cfg:        cfg,
+		dedupKey:   fmt.Sprintf("%s-%d", cfg.Source, time.Now().Unix()),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// maxSummary is the longest incident summary the Events API accepts
+const maxSummary = 1024
+
+// Trigger opens (or updates) the run's incident with summary
+func (p *PagerDuty) Trigger(summary string) error {
+	if len(summary) > maxSummary {
+		summary = summary[:maxSummary-3] + "..."
+	}
+	return p.post(map[string]any{
+		"routing_key":  p.cfg.RoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    p.dedupKey,
+		"payload": map[string]any{
+			"summary":  summary,
+			"source":   p.cfg.Source,
+			"severity": "error",
+		},
+	})
+}
+
+// Resolve closes the run's incident
+func (p *PagerDuty) Resolve(string) error {
+	return p.post(map[string]any{
+		"routing_key":  p.cfg.RoutingKey,
+		"event_action": "resolve",
+		"dedup_key":    p.dedupKey,
+	})
+}
+
+func (p *PagerDuty) post(event map[string]any) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %w", err)
+	}
+	resp, err := p.httpClient.Post(p.cfg.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("alert request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("alert endpoint returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+// Package apiclient builds the gopher API client the commands share, with
+// the API base URL and the egress proxy taken from the command line or the
+// environment, for collectors that can only reach the API through a proxy.
+package apiclient
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/gopher-lab/gopher-client/client"
+)
+
+// Environment variables read when the flags are not given
+const (
+	URLEnv   = "GOPHER_CLIENT_URL"
+	ProxyEnv = "GOPHER_CLIENT_PROXY"
+)
+
+// NoProxy as the proxy connects directly, ignoring HTTPS_PROXY and the like
+const NoProxy = "none"
+
+// Flags holds the API connection command-line flags
+type Flags struct {
+	URL   string
+	Proxy string
+}
+
+// RegisterFlags registers -api-url and -proxy on fs
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	fs.StringVar(&f.URL, "api-url", "", "API base URL (default: "+URLEnv+", or https://data.gopher-ai.com/api)")
+	fs.StringVar(&f.Proxy, "proxy", "", "Proxy for API requests: http://, https://, socks5:// or socks5h:// URL, credentials in the URL if needed, or "+NoProxy+" to connect directly (default: "+ProxyEnv+", then HTTPS_PROXY, HTTP_PROXY and NO_PROXY)")
+	return f
+}
+
+// New creates a client from the GOPHER_CLIENT_* configuration, with the base
+// URL and proxy of the flags, or their variables, applied over it. A nil
+// Flags reads the variables only.
+func (f *Flags) New() (*client.Client, error) {
+	c, err := client.NewClientFromConfig()
+	if err != nil {
+		return nil, err
+	}
+	var flagURL, flagProxy string
+	if f != nil {
+		flagURL, flagProxy = f.URL, f.Proxy
+	}
+	if c.BaseURL, err = ParseBaseURL(cmp.Or(flagURL, c.BaseURL)); err != nil {
+		return nil, err
+	}
+	if err := SetProxy(c, cmp.Or(flagProxy, os.Getenv(ProxyEnv))); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ParseBaseURL checks that raw is an http or https URL with a host, and
+// returns it without a trailing slash, as the client appends paths to it
+func ParseBaseURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid API URL %q: %w", raw, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid API URL %q: must be http:// or https:// with a host, e.g. https://data.gopher-ai.com/api", raw)
+	}
+	return strings.TrimRight(raw, "/"), nil
+}
+
+// ParseProxy parses a proxy URL, rejecting schemes the transport cannot use
+func ParseProxy(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %w", raw, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy %q: scheme must be http, https, socks5 or socks5h", u.Redacted())
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q: no host", u.Redacted())
+	}
+	return u, nil
+}
+
+// SetProxy routes c's requests through the proxy at raw, or directly for
+// NoProxy. An empty raw leaves the standard proxy variables in charge. It
+// must be called before any other transport wraps c's.
+func SetProxy(c *client.Client, raw string) error {
+	if raw == "" {
+		return nil
+	}
+	t, ok := transport(c)
+	if !ok {
+		return fmt.Errorf("cannot set a proxy on the client's %T transport", c.HTTPClient.Transport)
+	}
+	if raw == NoProxy {
+		t.Proxy = nil
+		return nil
+	}
+	u, err := ParseProxy(raw)
+	if err != nil {
+		return err
+	}
+	t.Proxy = http.ProxyURL(u)
+	return nil
+}
+
+// Proxy returns the proxy c's requests to its base URL go through, with any
+// password redacted, or "" when they connect directly
+func Proxy(c *client.Client) string {
+	t, ok := transport(c)
+	if !ok || t.Proxy == nil {
+		return ""
+	}
+	req, err := http.NewRequest(http.MethodGet, c.BaseURL, nil)
+	if err != nil {
+		return ""
+	}
+	u, err := t.Proxy(req)
+	if err != nil || u == nil {
+		return ""
+	}
+	return u.Redacted()
+}
+
+// transport returns c's transport if it is still the client's own
+func transport(c *client.Client) (*http.Transport, bool) {
+	if c.HTTPClient == nil {
+		return nil, false
+	}
+	t, ok := c.HTTPClient.Transport.(*http.Transport)
+	return t, ok
+}
@@ -0,0 +1,101 @@
+// Package audience collects the accounts engaging with tweets, for datasets
+// of who the audience of the top content in a collection is. The API only
+// exposes retweeters (the getretweeters capability); likers are not
+// available.
+package audience
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/grant/sn42/pkg/collect"
+	"github.com/masa-finance/tee-worker/v2/api/args/twitter"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// MaxPerTweet is the most retweeters one request returns
+const MaxPerTweet = 1000
+
+// Account is an account that retweeted one or more of the tweets looked up
+type Account struct {
+	Profile   types.Document // As returned by the API
+	Retweeted []string       // IDs of the tweets it retweeted, in lookup order
+}
+
+// Fetcher looks up retweeters with the getretweeters capability
+type Fetcher struct {
+	collect.Lookup
+}
+
+// Retweeters looks up at most perTweet retweeters of each tweet and returns
+// every account found once, in the order first found, with the tweets it
+// retweeted. progress (if set) is called after each lookup with its result.
+// The IDs of the tweets whose lookup kept failing are returned too.
+func (f *Fetcher) Retweeters(ctx context.Context, ids []string, perTweet int, progress func(done int, id string, found int, err error)) ([]*Account, []string) {
+	results := make([][]types.Document, len(ids))
+	errs := make([]error, len(ids))
+	for i := range errs {
+		// Left for the lookups ctx stops before they start
+		errs[i] = context.Canceled
+	}
+	var mu sync.Mutex
+	done := 0
+	f.Each(ctx, len(ids), func(i int) {
+		results[i], errs[i] = f.lookup(ctx, ids[i], perTweet)
+		mu.Lock()
+		defer mu.Unlock()
+		done++
+		if progress != nil {
+			progress(done, ids[i], len(results[i]), errs[i])
+		}
+	})
+
+	var accounts []*Account
+	var failed []string
+	byKey := map[string]*Account{}
+	for i, id := range ids {
+		if errs[i] != nil {
+			failed = append(failed, id)
+			continue
+		}
+		for _, doc := range results[i] {
+			key := accountKey(doc)
+			if key == "" {
+				continue
+			}
+			a := byKey[key]
+			if a == nil {
+				a = &Account{Profile: doc}
+				byKey[key] = a
+				accounts = append(accounts, a)
+			}
+			a.Retweeted = append(a.Retweeted, id)
+		}
+	}
+	return accounts, failed
+}
+
+// lookup fetches the retweeters of one tweet
+func (f *Fetcher) lookup(ctx context.Context, id string, perTweet int) ([]types.Document, error) {
+	args := twitter.NewSearchArguments()
+	args.Type = types.CapGetRetweeters
+	args.Query = id
+	args.MaxResults = min(perTweet, MaxPerTweet)
+
+	results, err := f.Search(ctx, args, nil)
+	if err != nil && ctx.Err() == nil {
+		return nil, fmt.Errorf("failed to look up retweeters of %s: %w", id, err)
+	}
+	return results, err
+}
+
+// accountKey identifies an account across lookups: its ID, or its username
+// if the profile has no ID
+func accountKey(doc types.Document) string {
+	if doc.Id != "" {
+		return doc.Id
+	}
+	name, _ := doc.Metadata["username"].(string)
+	return name
+}
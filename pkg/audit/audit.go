@@ -0,0 +1,253 @@
+// Package audit keeps an append-only log of every collection run for data
+// governance: who ran what and when, with which arguments, the queries and
+// their counts, where the records went and what failed. Each run appends a
+// start entry when it begins and a finish entry when it ends, so a run that
+// crashed or was killed still shows up, as incomplete.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grant/sn42/pkg/report"
+	"github.com/grant/sn42/pkg/sink"
+)
+
+// FileName is the name of the audit log in the runs directory, where
+// collectors append to unless -audit-log says otherwise
+const FileName = "audit.jsonl"
+
+// Entry events
+const (
+	EventStart  = "start"
+	EventFinish = "finish"
+)
+
+// StatusIncomplete is the status of a run that has no finish entry: it is
+// still running, or it crashed or was killed before recording its outcome
+const StatusIncomplete = "incomplete"
+
+// Entry is one line of the audit log
+type Entry struct {
+	Event string `json:"event"`
+	Time  string `json:"time"`
+	RunID string `json:"run_id"`
+	Tool  string `json:"tool"`
+
+	// Set on start entries
+	User  string   `json:"user,omitempty"`
+	Host  string   `json:"host,omitempty"`
+	PID   int      `json:"pid,omitempty"`
+	Args  []string `json:"args,omitempty"`
+	Dir   string   `json:"dir,omitempty"`   // Run directory
+	Sinks []string `json:"sinks,omitempty"` // Streaming sinks configured for the run
+	Token string   `json:"token,omitempty"` // TokenID of the API token, empty without one
+
+	// Set on finish entries
+	Calls        int             `json:"calls,omitempty"` // API calls made, as counted by the Meter
+	Summary      *report.Summary `json:"summary,omitempty"`
+	Destinations []string        `json:"destinations,omitempty"` // Every file the run wrote records to
+}
+
+// Log appends the entries of one run to an audit log file
+type Log struct {
+	path  string
+	start Entry
+	meter *Meter
+}
+
+// Start appends the start entry of a run of tool and returns the Log to
+// finish it with. meter, if set, counts the run's API calls and identifies
+// its token; it is nil for runs that do not call the API. An empty path
+// disables auditing and returns a nil Log, whose methods do nothing.
+func Start(path, runID, tool, dir string, meter *Meter) (*Log, error) {
+	if path == "" {
+		return nil, nil
+	}
+	e := Entry{
+		Event: EventStart,
+		Time:  time.Now().UTC().Format(time.RFC3339),
+		RunID: runID,
+		Tool:  tool,
+		User:  currentUser(),
+		PID:   os.Getpid(),
+		Args:  os.Args[1:],
+		Dir:   dir,
+		Sinks: sink.Configured(),
+		Token: meter.Token(),
+	}
+	e.Host, _ = os.Hostname()
+	l := &Log{path: path, start: e, meter: meter}
+	if err := l.append(e); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Finish appends the finish entry of the run with its summary, which must
+// already be finished
+func (l *Log) Finish(s *report.Summary) error {
+	if l == nil {
+		return nil
+	}
+	return l.append(Entry{
+		Event:        EventFinish,
+		Time:         time.Now().UTC().Format(time.RFC3339),
+		RunID:        l.start.RunID,
+		Tool:         l.start.Tool,
+		Calls:        l.meter.Calls(),
+		Summary:      s,
+		Destinations: destinations(s),
+	})
+}
+
+// append writes e as one line in a single write to the file opened for
+// appending, so entries of concurrent runs do not interleave
+func (l *Log) append(e Entry) error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return f.Close()
+}
+
+// currentUser is the login name of the user running the process, or $USER
+// where it cannot be looked up
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// destinations lists the files a run wrote, in order and without duplicates
+func destinations(s *report.Summary) []string {
+	var files []string
+	seen := map[string]bool{}
+	add := func(paths []string) {
+		for _, p := range paths {
+			if !seen[p] {
+				seen[p] = true
+				files = append(files, p)
+			}
+		}
+	}
+	for _, q := range s.Queries {
+		add(q.Files)
+	}
+	add(s.Files)
+	return files
+}
+
+// Run is one run as read back from the log: its start entry combined with
+// its finish entry, if it has one
+type Run struct {
+	ID        string          `json:"run_id"`
+	Tool      string          `json:"tool"`
+	User      string          `json:"user,omitempty"`
+	Host      string          `json:"host,omitempty"`
+	PID       int             `json:"pid,omitempty"`
+	Args      []string        `json:"args,omitempty"`
+	Dir       string          `json:"dir,omitempty"`
+	Sinks     []string        `json:"sinks,omitempty"`
+	Token     string          `json:"token,omitempty"`
+	Calls     int             `json:"calls"`
+	StartedAt string          `json:"started_at"`
+	Status    string          `json:"status"` // A report status, or StatusIncomplete
+	Summary   *report.Summary `json:"summary,omitempty"`
+
+	Destinations []string `json:"destinations,omitempty"`
+}
+
+// Read reads the runs recorded in the audit log at path, oldest first. A
+// missing log has no runs.
+func Read(path string) ([]Run, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	var runs []*Run
+	byID := map[string]*Run{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: failed to parse audit entry: %w", path, line, err)
+		}
+		r := byID[e.RunID]
+		if r == nil {
+			r = &Run{ID: e.RunID, Tool: e.Tool, StartedAt: e.Time, Status: StatusIncomplete}
+			byID[e.RunID] = r
+			runs = append(runs, r)
+		}
+		switch e.Event {
+		case EventStart:
+			r.User, r.Host, r.PID, r.Args, r.Dir, r.Sinks, r.Token, r.StartedAt = e.User, e.Host, e.PID, e.Args, e.Dir, e.Sinks, e.Token, e.Time
+		case EventFinish:
+			r.Calls, r.Summary, r.Destinations = e.Calls, e.Summary, e.Destinations
+			if e.Summary != nil {
+				r.Status = e.Summary.Status
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	out := make([]Run, len(runs))
+	for i, r := range runs {
+		out[i] = *r
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].StartedAt < out[j].StartedAt })
+	return out, nil
+}
+
+// Find returns the run whose ID is id or starts with it, and an error if
+// none or several match
+func Find(runs []Run, id string) (Run, error) {
+	var found []Run
+	for _, r := range runs {
+		if r.ID == id {
+			return r, nil
+		}
+		if strings.HasPrefix(r.ID, id) {
+			found = append(found, r)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return Run{}, fmt.Errorf("no run %s in the audit log", id)
+	case 1:
+		return found[0], nil
+	default:
+		return Run{}, fmt.Errorf("%d runs start with %s; give more of the ID", len(found), id)
+	}
+}
@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gopher-lab/gopher-client/client"
+)
+
+// Meter counts the API calls a client makes, for the usage recorded in the
+// audit log. A call is a job submission; polling a job for its status and
+// results is part of the same call.
+type Meter struct {
+	base  http.RoundTripper
+	token string
+	calls atomic.Int64
+}
+
+// NewMeter routes c's requests through a new Meter, which records the
+// token they are made with by its TokenID
+func NewMeter(c *client.Client) *Meter {
+	if c.HTTPClient == nil {
+		c.HTTPClient = &http.Client{}
+	}
+	base := c.HTTPClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	m := &Meter{base: base, token: TokenID(c.Token)}
+	c.HTTPClient.Transport = m
+	return m
+}
+
+func (m *Meter) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodPost {
+		m.calls.Add(1)
+	}
+	return m.base.RoundTrip(req)
+}
+
+// Calls returns the number of calls made so far. A nil Meter has made none.
+func (m *Meter) Calls() int {
+	if m == nil {
+		return 0
+	}
+	return int(m.calls.Load())
+}
+
+// Token returns the ID of the token the calls are made with, or "" for a
+// nil Meter
+func (m *Meter) Token() string {
+	if m == nil {
+		return ""
+	}
+	return m.token
+}
+
+// TokenID identifies an API token in the audit log without revealing it:
+// "tok_" and the first 12 hex digits of its SHA-256, or "" for no token
+func TokenID(token string) string {
+	if token == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(token))
+	return "tok_" + hex.EncodeToString(sum[:6])
+}
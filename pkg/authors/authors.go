@@ -0,0 +1,139 @@
+// Package authors keeps the list of accounts fetch-tweets follows and, per
+// account, where the last poll left off, for building longitudinal corpora
+// of what each author posts.
+package authors
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultDir is where the per-account datasets and state are kept
+	DefaultDir = "data/authors"
+	// StateFile is the name of the state file in the authors directory
+	StateFile = "state.json"
+)
+
+var usernameRegex = regexp.MustCompile(`^[A-Za-z0-9_]{1,15}$`)
+
+// LoadList reads usernames from a file, one per line, with or without the
+// leading @. Blank lines, lines starting with # and repeated usernames are
+// skipped.
+func LoadList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open accounts file: %w", err)
+	}
+	defer f.Close()
+
+	var users []string
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		user := strings.TrimPrefix(strings.TrimSpace(scanner.Text()), "@")
+		if user == "" || strings.HasPrefix(user, "#") {
+			continue
+		}
+		if !usernameRegex.MatchString(user) {
+			return nil, fmt.Errorf("%s:%d: invalid username %q", path, line, user)
+		}
+		if key := strings.ToLower(user); !seen[key] {
+			seen[key] = true
+			users = append(users, user)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read accounts file: %w", err)
+	}
+	if len(users) == 0 {
+		return nil, fmt.Errorf("accounts file %s is empty", path)
+	}
+	return users, nil
+}
+
+// Author is where following one account stands
+type Author struct {
+	SinceID    int64  `json:"since_id,omitempty"` // Newest tweet collected, 0 before the first poll
+	LastPolled string `json:"last_polled,omitempty"`
+	Tweets     int    `json:"tweets"` // Tweets in the author's dataset
+}
+
+// State is the content of the state file, by lowercase username
+type State struct {
+	path    string
+	Authors map[string]*Author `json:"authors"`
+}
+
+// LoadState reads the state file in dir. A missing file is an empty state.
+func LoadState(dir string) (*State, error) {
+	s := &State{path: filepath.Join(dir, StateFile), Authors: map[string]*Author{}}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read authors state: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse authors state %s: %w", s.path, err)
+	}
+	if s.Authors == nil {
+		s.Authors = map[string]*Author{}
+	}
+	return s, nil
+}
+
+// Get returns the state of user, adding it if it is new
+func (s *State) Get(user string) *Author {
+	key := strings.ToLower(user)
+	a := s.Authors[key]
+	if a == nil {
+		a = &Author{}
+		s.Authors[key] = a
+	}
+	return a
+}
+
+// Polled records a successful poll of user that found tweets up to newest
+// and left total tweets in its dataset
+func (s *State) Polled(user string, newest int64, total int, at time.Time) {
+	a := s.Get(user)
+	a.SinceID = max(a.SinceID, newest)
+	a.Tweets = total
+	a.LastPolled = at.UTC().Format(time.RFC3339)
+}
+
+// Save writes the state file, replacing it in one step
+func (s *State) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create authors directory: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal authors state: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write authors state: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write authors state: %w", err)
+	}
+	return nil
+}
+
+// Dataset returns the path of user's dataset in dir
+func Dataset(dir, user string) string {
+	return filepath.Join(dir, strings.ToLower(user)+".json")
+}
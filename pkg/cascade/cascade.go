@@ -0,0 +1,207 @@
+// Package cascade reconstructs retweet and quote cascades, the trees of
+// shares spreading from an original tweet, from collected tweets.
+package cascade
+
+import (
+	"cmp"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Share types
+const (
+	Retweet = "retweet"
+	Quote   = "quote"
+)
+
+// Event is one share in a cascade, at Depth 1 when it shares the root and
+// deeper when it quotes a quote
+type Event struct {
+	TweetID  string  `json:"tweet_id"`
+	Username string  `json:"username,omitempty"`
+	Type     string  `json:"type"`
+	ParentID string  `json:"parent_id"`
+	Depth    int     `json:"depth"`
+	At       string  `json:"created_at,omitempty"`
+	Delay    float64 `json:"delay_seconds,omitempty"` // Since the root, when both times are known
+}
+
+// Cascade is every share traced back to one original tweet. The root itself
+// need not be in the dataset; RootInDataset tells whether it is.
+type Cascade struct {
+	RootID        string  `json:"root_id"`
+	RootUsername  string  `json:"root_username,omitempty"`
+	RootAt        string  `json:"root_created_at,omitempty"`
+	RootInDataset bool    `json:"root_in_dataset"`
+	Size          int     `json:"size"`
+	Retweets      int     `json:"retweets"`
+	Quotes        int     `json:"quotes"`
+	Depth         int     `json:"depth"`
+	FirstAt       string  `json:"first_share_at,omitempty"`
+	LastAt        string  `json:"last_share_at,omitempty"`
+	Duration      float64 `json:"duration_seconds,omitempty"` // From the root, or the first share, to the last share
+	Timeline      []Event `json:"timeline"`
+}
+
+// tweet is what is kept of each tweet to rebuild the cascades
+type tweet struct {
+	id, username, kind, parent string
+	at                         time.Time
+}
+
+// Builder collects tweets one at a time and reconstructs their cascades
+type Builder struct {
+	tweets map[string]*tweet
+}
+
+// NewBuilder returns an empty Builder
+func NewBuilder() *Builder {
+	return &Builder{tweets: map[string]*tweet{}}
+}
+
+// Add records a tweet. Retweets are recognized by retweeted_status_id and
+// quotes by quoted_status_id metadata, or the quoted_id `dataset quotes`
+// records; other tweets can only be roots. A tweet seen twice keeps its
+// first record.
+func (b *Builder) Add(doc types.Document) {
+	id := dataset.MetadataID(doc, "tweet_id")
+	if id == "" {
+		id = doc.Id
+	}
+	if id == "" || b.tweets[id] != nil {
+		return
+	}
+	t := &tweet{id: id}
+	t.username, _ = doc.Metadata["username"].(string)
+	if s, ok := doc.Metadata["created_at"].(string); ok {
+		t.at, _ = time.Parse(time.RFC3339, s)
+	}
+	if parent := dataset.MetadataID(doc, "retweeted_status_id"); parent != "" {
+		t.kind, t.parent = Retweet, parent
+	} else if parent := cmp.Or(dataset.MetadataID(doc, "quoted_status_id"), dataset.MetadataID(doc, "quoted_id")); parent != "" {
+		t.kind, t.parent = Quote, parent
+	}
+	b.tweets[id] = t
+}
+
+// Cascades returns the cascades with at least minSize shares, largest first
+// (ties by root ID), each with its timeline in time order
+func (b *Builder) Cascades(minSize int) []*Cascade {
+	byRoot := map[string]*Cascade{}
+	for _, t := range b.tweets {
+		if t.parent == "" {
+			continue
+		}
+		rootID, depth := b.root(t)
+		c := byRoot[rootID]
+		if c == nil {
+			c = &Cascade{RootID: rootID}
+			if root := b.tweets[rootID]; root != nil {
+				c.RootInDataset = true
+				c.RootUsername = root.username
+				c.RootAt = formatTime(root.at)
+			}
+			byRoot[rootID] = c
+		}
+		c.Timeline = append(c.Timeline, Event{TweetID: t.id, Username: t.username, Type: t.kind,
+			ParentID: t.parent, Depth: depth, At: formatTime(t.at)})
+	}
+
+	var cascades []*Cascade
+	for _, c := range byRoot {
+		if len(c.Timeline) >= max(minSize, 1) {
+			b.summarize(c)
+			cascades = append(cascades, c)
+		}
+	}
+	sort.Slice(cascades, func(i, j int) bool {
+		if cascades[i].Size != cascades[j].Size {
+			return cascades[i].Size > cascades[j].Size
+		}
+		return cascades[i].RootID < cascades[j].RootID
+	})
+	return cascades
+}
+
+// root follows a share's parents to the original tweet, returning its ID and
+// the share's depth below it. Parents missing from the dataset end the walk.
+func (b *Builder) root(t *tweet) (string, int) {
+	depth := 0
+	seen := map[string]bool{}
+	for t.parent != "" && !seen[t.id] {
+		seen[t.id] = true
+		depth++
+		parent := b.tweets[t.parent]
+		if parent == nil {
+			return t.parent, depth
+		}
+		t = parent
+	}
+	return t.id, depth
+}
+
+// summarize sorts the timeline and fills in the cascade's counts and times
+func (b *Builder) summarize(c *Cascade) {
+	sort.SliceStable(c.Timeline, func(i, j int) bool {
+		ti, tj := c.Timeline[i].At, c.Timeline[j].At
+		if ti != tj {
+			// Shares without a time go last
+			return tj == "" || (ti != "" && ti < tj)
+		}
+		return c.Timeline[i].TweetID < c.Timeline[j].TweetID
+	})
+
+	var start, first, last time.Time
+	if root := b.tweets[c.RootID]; root != nil {
+		start = root.at
+	}
+	c.Size = len(c.Timeline)
+	for i := range c.Timeline {
+		e := &c.Timeline[i]
+		if e.Type == Retweet {
+			c.Retweets++
+		} else {
+			c.Quotes++
+		}
+		c.Depth = max(c.Depth, e.Depth)
+		at := b.tweets[e.TweetID].at
+		if at.IsZero() {
+			continue
+		}
+		if first.IsZero() {
+			first = at
+		}
+		last = at
+		if !start.IsZero() {
+			e.Delay = at.Sub(start).Seconds()
+		}
+	}
+	c.FirstAt, c.LastAt = formatTime(first), formatTime(last)
+	if start.IsZero() {
+		start = first
+	}
+	if !start.IsZero() && !last.IsZero() {
+		c.Duration = last.Sub(start).Seconds()
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Summary describes a set of cascades in one line
+func Summary(cascades []*Cascade) string {
+	shares, depth := 0, 0
+	for _, c := range cascades {
+		shares += c.Size
+		depth = max(depth, c.Depth)
+	}
+	return fmt.Sprintf("%d cascades, %d shares, max depth %d", len(cascades), shares, depth)
+}
@@ -0,0 +1,51 @@
+package collect
+
+// MinBatchSize is the smallest batch size adaptive batching steps down to
+const MinBatchSize = 10
+
+// recoverAfter is how many requests in a row must succeed before adaptive
+// batching first steps the batch size back up. It doubles with every failure,
+// up to maxRecoverAfter, so a size that keeps failing is tried less often.
+const (
+	recoverAfter    = 3
+	maxRecoverAfter = 48
+)
+
+// batchSizer tracks the batch size of one collection. Without adaptive
+// batching it stays at max.
+type batchSizer struct {
+	max, size int
+	adaptive  bool
+	streak    int // Successful requests since the last change
+	need      int // Successful requests needed to step up
+}
+
+func newBatchSizer(size int, adaptive bool) *batchSizer {
+	return &batchSizer{max: size, size: size, adaptive: adaptive, need: recoverAfter}
+}
+
+// failed halves the batch size after a failed request, reporting false if
+// it is already as small as it gets
+func (b *batchSizer) failed() bool {
+	b.streak = 0
+	if !b.adaptive || b.size <= MinBatchSize {
+		return false
+	}
+	b.size = max(b.size/2, MinBatchSize)
+	b.need = min(b.need*2, maxRecoverAfter)
+	return true
+}
+
+// succeeded doubles the batch size, up to max, once enough requests in a row
+// have succeeded at the current size. It reports whether the size changed.
+func (b *batchSizer) succeeded() bool {
+	if b.size >= b.max {
+		return false
+	}
+	if b.streak++; b.streak < b.need {
+		return false
+	}
+	b.streak = 0
+	b.size = min(b.size*2, b.max)
+	return true
+}
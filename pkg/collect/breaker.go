@@ -0,0 +1,86 @@
+package collect
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grant/sn42/pkg/health"
+	"github.com/masa-finance/tee-worker/v2/api/args/twitter"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Breaker is a circuit breaker around a Searcher. After Threshold requests
+// in a row have failed, the circuit opens and requests wait out Cooldown
+// before going through again, so a flapping upstream is not hammered with
+// failing calls. The first request after the cool-down is a trial: a failure
+// opens the circuit again straight away, a success closes it.
+type Breaker struct {
+	client    Searcher
+	threshold int
+	cooldown  time.Duration
+	health    *health.Monitor
+
+	mu        sync.Mutex
+	failures  int // Failed requests in a row
+	openUntil time.Time
+	opens     int
+	openFor   time.Duration
+}
+
+// NewBreaker wraps client in a circuit breaker opening after threshold
+// consecutive failures for cooldown. monitor, if set, reports the circuit.
+func NewBreaker(client Searcher, threshold int, cooldown time.Duration, monitor *health.Monitor) *Breaker {
+	return &Breaker{client: client, threshold: threshold, cooldown: cooldown, health: monitor}
+}
+
+// SearchTwitterWithArgs waits for an open circuit to cool down, then runs
+// the search
+func (b *Breaker) SearchTwitterWithArgs(args twitter.SearchArguments) ([]types.Document, error) {
+	b.mu.Lock()
+	wait := time.Until(b.openUntil)
+	b.mu.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+
+	results, err := b.client.SearchTwitterWithArgs(args)
+	b.record(err)
+	return results, err
+}
+
+func (b *Breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		if b.failures >= b.threshold {
+			fmt.Println("🔌 Circuit closed: requests are succeeding again")
+			b.health.SetCircuitOpen(false)
+		}
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures < b.threshold {
+		return
+	}
+	b.opens++
+	b.openFor += b.cooldown
+	b.openUntil = time.Now().Add(b.cooldown)
+	b.health.SetCircuitOpen(true)
+	fmt.Printf("🔌 Circuit open after %d failed requests in a row; pausing requests for %s: %v\n", b.failures, b.cooldown, err)
+}
+
+// Opens returns how many times the circuit has opened
+func (b *Breaker) Opens() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.opens
+}
+
+// Stats describes how often the circuit opened, for the end of a run
+func (b *Breaker) Stats() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return fmt.Sprintf("opened %d times, requests paused for %s in total", b.opens, b.openFor)
+}
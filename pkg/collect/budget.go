@@ -0,0 +1,78 @@
+package collect
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExhausted is returned by Collect when a run-wide cap is reached
+var ErrBudgetExhausted = errors.New("run cap reached")
+
+// Budget caps the tweets collected and the time spent across every query of a
+// run. Share one Budget between the collectors of a run, also when they run
+// in parallel. A nil Budget has no limits.
+type Budget struct {
+	maxTweets int
+	deadline  time.Time
+	maxTime   time.Duration
+
+	mu        sync.Mutex
+	collected int
+}
+
+// NewBudget creates a budget allowing maxTweets tweets in total and maxRuntime
+// from now. Zero disables either limit.
+func NewBudget(maxTweets int, maxRuntime time.Duration) *Budget {
+	b := &Budget{maxTweets: maxTweets, maxTime: maxRuntime}
+	if maxRuntime > 0 {
+		b.deadline = time.Now().Add(maxRuntime)
+	}
+	return b
+}
+
+// Check returns an error wrapping ErrBudgetExhausted once a cap is reached
+func (b *Budget) Check() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.maxTweets > 0 && b.collected >= b.maxTweets {
+		return fmt.Errorf("%w: %d tweets collected (--max-total-tweets %d)", ErrBudgetExhausted, b.collected, b.maxTweets)
+	}
+	if !b.deadline.IsZero() && time.Now().After(b.deadline) {
+		return fmt.Errorf("%w: run time exceeded --max-runtime %s", ErrBudgetExhausted, b.maxTime)
+	}
+	return nil
+}
+
+// Exhausted reports whether a cap has been reached
+func (b *Budget) Exhausted() bool {
+	return b.Check() != nil
+}
+
+// Collected returns the number of tweets counted against the budget
+func (b *Budget) Collected() int {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.collected
+}
+
+// take counts up to n tweets against the budget and returns how many fit
+func (b *Budget) take(n int) int {
+	if b == nil {
+		return n
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.maxTweets > 0 {
+		n = min(n, b.maxTweets-b.collected)
+	}
+	b.collected += n
+	return n
+}
@@ -0,0 +1,379 @@
+// Package collect implements the paginated tweet search loop shared by the
+// fetch-tweets and fetch-trends collectors.
+package collect
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grant/sn42/pkg/alert"
+	"github.com/grant/sn42/pkg/health"
+	"github.com/grant/sn42/pkg/pipeline"
+	"github.com/grant/sn42/pkg/sample"
+	"github.com/grant/sn42/pkg/sink"
+	"github.com/grant/sn42/pkg/twitterquery"
+	"github.com/masa-finance/tee-worker/v2/api/args/twitter"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// APIMaxResults is the maximum number of results per API request
+const APIMaxResults = 100
+
+// Searcher runs a Twitter search job and waits for its results.
+// *client.Client from gopher-client satisfies it.
+type Searcher interface {
+	SearchTwitterWithArgs(args twitter.SearchArguments) ([]types.Document, error)
+}
+
+// Collector pages through search results for a query using max_id pagination
+type Collector struct {
+	Client   Searcher
+	Pipeline pipeline.Pipeline // Optional processing applied to each batch
+	Sink     sink.Sink         // Optional sink receiving each processed batch
+
+	// Reservoir, if set, receives every processed batch instead of the result
+	// buffer, so Collect returns a uniform sample of everything it collected
+	Reservoir *sample.Reservoir
+
+	// Budget, if set, caps tweets and time across all collections sharing it
+	Budget *Budget
+
+	// Health, if set, is told about every completed API request
+	Health *health.Monitor
+
+	// Stats, if set, is updated with the counts of every batch
+	Stats *Stats
+
+	// Alerts, if set, watches the error rate of API requests
+	Alerts *alert.Monitor
+
+	// Provenance, if set, is stamped on every record kept, after the pipeline
+	// so that field projection cannot remove it
+	Provenance *Provenance
+
+	// Metrics, if set, is told how long the API requests, each pipeline stage
+	// and the sink writes took
+	Metrics *Metrics
+
+	// Lock, if set, is held while a batch goes through the Pipeline and into
+	// the Sink. Collectors running in parallel share one, so they can share
+	// stages and sinks that are not safe for concurrent use while their API
+	// requests still overlap.
+	Lock sync.Locker
+
+	// Retries is how many times a failed request is retried, waiting RetryDelay
+	// (default 5s) before the first retry and doubling it after each one
+	Retries    int
+	RetryDelay time.Duration
+
+	// AdaptiveBatch halves the batch size when a request fails, down to
+	// MinBatchSize, and retries at once without using up Retries. The size is
+	// doubled again after a few successful requests in a row.
+	AdaptiveBatch bool
+
+	// FlushEvery and MaxBufferBytes bound the tweets held in memory. When
+	// either is set, processed tweets are buffered and written to the Sink once
+	// FlushEvery records or MaxBufferBytes of JSON are pending, and when
+	// collection stops. Collect then returns no tweets (except a Reservoir's
+	// sample): the Sink is the only output.
+	FlushEvery     int
+	MaxBufferBytes int
+}
+
+// BatchError reports a page that could not be collected, with what is needed
+// to resume collection from it later
+type BatchError struct {
+	Query     string // Query passed to Collect (without max_id)
+	MaxID     int64  // max_id of the failed page, 0 for the first page
+	Remaining int    // Tweets still missing from the target
+	Err       error
+}
+
+func (e *BatchError) Error() string { return e.Err.Error() }
+
+func (e *BatchError) Unwrap() error { return e.Err }
+
+// Stats counts what a collection fetched and kept
+type Stats struct {
+	Requests int   // Completed API requests
+	Fetched  int   // Tweets returned by the API
+	Dropped  int   // Tweets removed by the pipeline
+	Kept     int   // Tweets kept after processing and run caps
+	Newest   int64 // Highest tweet ID returned by the API, for since_id
+}
+
+// Collect fetches up to target tweets for query. It stops early when the API
+// runs out of results. If an error interrupts collection, the tweets gathered
+// so far are returned together with the error. With a Reservoir, target is the
+// number of tweets to scan and the reservoir's sample is returned. With
+// FlushEvery or MaxBufferBytes set, the tweets go to the Sink instead.
+func (c *Collector) Collect(ctx context.Context, query string, target int) ([]types.Document, error) {
+	tweets, err := c.collect(ctx, query, 0, target)
+	if c.Reservoir != nil {
+		return c.Reservoir.Docs(), err
+	}
+	return tweets, err
+}
+
+// Resume continues a collection of query from the page starting at maxID, as
+// recorded in a BatchError, fetching up to target more tweets
+func (c *Collector) Resume(ctx context.Context, query string, maxID int64, target int) ([]types.Document, error) {
+	return c.collect(ctx, query, maxID, target)
+}
+
+func (c *Collector) collect(ctx context.Context, query string, maxID int64, target int) (allTweets []types.Document, err error) {
+	// Use the target as batch size if it is below the API maximum
+	sizer := newBatchSizer(min(target, APIMaxResults), c.AdaptiveBatch)
+
+	// Whatever is still buffered is flushed however collection stops, so a
+	// BatchError's resume point matches what the Sink received
+	buf := &flushBuffer{query: query}
+	defer func() {
+		if ferr := c.flush(ctx, buf); ferr != nil {
+			err = errors.Join(err, ferr)
+		}
+	}()
+
+	collected := 0
+	currentQuery := query
+	prevTweetID := maxID
+	if maxID > 0 {
+		if currentQuery, err = twitterquery.Raw(query).MaxID(maxID).Build(); err != nil {
+			return nil, fmt.Errorf("failed to build resume query: %w", err)
+		}
+	}
+
+	for collected < target {
+		if err := c.Budget.Check(); err != nil {
+			return allTweets, err
+		}
+		fmt.Printf("Fetching batch... (current: %d/%d tweets)\n", collected, target)
+
+		args := twitter.NewSearchArguments()
+		args.Query = currentQuery
+		args.Type = types.CapSearchByQuery // Explicitly set search type
+
+		// Make API request (synchronous - waits for completion)
+		requested := time.Now()
+		results, err := c.search(args, sizer)
+		fetchedAt := time.Now()
+		c.Metrics.Add(PhaseFetch, "fetch", 0, len(results), fetchedAt.Sub(requested))
+		if err != nil {
+			return allTweets, &BatchError{query, prevTweetID, target - collected, fmt.Errorf("failed to fetch tweets: %w", err)}
+		}
+		c.Health.Beat()
+
+		if len(results) == 0 {
+			if collected > 0 {
+				fmt.Println("No more results available.")
+			}
+			break
+		}
+
+		// Get the last tweet ID for pagination before stages modify or drop documents
+		lastTweetID, idErr := GetLastTweetID(results)
+		if c.Stats != nil {
+			for _, doc := range results {
+				// The Id string is exact, where tweet_id may have been rounded
+				// through a float64
+				id, err := strconv.ParseInt(doc.Id, 10, 64)
+				if err != nil {
+					id, err = TweetID(doc)
+				}
+				if err == nil && id > c.Stats.Newest {
+					c.Stats.Newest = id
+				}
+			}
+		}
+
+		batch, err := c.process(results)
+		if err != nil {
+			return allTweets, &BatchError{query, prevTweetID, target - collected, err}
+		}
+		dropped := len(results) - len(batch)
+		batch = batch[:c.Budget.take(len(batch))]
+		c.Provenance.stamp(batch, query, args.Type, fetchedAt)
+		if c.Stats != nil {
+			c.Stats.Requests++
+			c.Stats.Fetched += len(results)
+			c.Stats.Dropped += dropped
+			c.Stats.Kept += len(batch)
+		}
+		collected += len(batch)
+		if c.Reservoir != nil {
+			c.Reservoir.Add(batch...)
+		} else if !c.Flushing() {
+			allTweets = append(allTweets, batch...)
+		}
+		fmt.Printf("Fetched %d tweets in this batch (%d kept). Total: %d/%d\n\n", len(results), len(batch), collected, target)
+		if sizer.succeeded() {
+			fmt.Printf("📈 Requests are succeeding again, raising the batch size to %d\n\n", sizer.size)
+		}
+
+		if c.Flushing() {
+			if buf.add(batch); buf.full(c.FlushEvery, c.MaxBufferBytes) {
+				if err := c.flush(ctx, buf); err != nil {
+					return allTweets, err
+				}
+			}
+		} else if c.Sink != nil && len(batch) > 0 {
+			if err := c.write(ctx, sink.Batch{Query: query, Docs: batch}); err != nil {
+				return allTweets, fmt.Errorf("failed to write batch to sink: %w", err)
+			}
+		}
+
+		if collected >= target {
+			break
+		}
+		if idErr != nil {
+			return allTweets, fmt.Errorf("failed to extract last tweet ID: %w", idErr)
+		}
+		// max_id is inclusive, so an exhausted query keeps returning its last tweet
+		if lastTweetID == prevTweetID {
+			fmt.Println("No more results available.")
+			break
+		}
+		prevTweetID = lastTweetID
+
+		// Update query with max_id for next iteration
+		if currentQuery, err = twitterquery.Raw(query).MaxID(lastTweetID).Build(); err != nil {
+			return allTweets, fmt.Errorf("failed to build next page query: %w", err)
+		}
+	}
+
+	return allTweets, nil
+}
+
+// search runs one API request with the sizer's batch size, retrying
+// failures with exponential backoff. While the sizer can step the batch size
+// down, a failure is retried with the smaller size instead.
+func (c *Collector) search(args twitter.SearchArguments, sizer *batchSizer) ([]types.Document, error) {
+	base := cmp.Or(c.RetryDelay, 5*time.Second)
+	delay := base
+	for attempt := 1; ; {
+		args.MaxResults = sizer.size
+		results, err := c.Client.SearchTwitterWithArgs(args)
+		c.Alerts.Record(err)
+		if err == nil {
+			return results, nil
+		}
+		if previous := sizer.size; sizer.failed() {
+			fmt.Printf("⚠️ Request for %d tweets failed, retrying in %s with %d: %v\n", previous, base, sizer.size, err)
+			time.Sleep(base)
+			continue
+		}
+		if attempt > c.Retries {
+			return results, err
+		}
+		fmt.Printf("⚠️ Request failed, retrying in %s (%d/%d): %v\n", delay, attempt, c.Retries, err)
+		time.Sleep(delay)
+		delay *= 2
+		attempt++
+	}
+}
+
+// GetLastTweetID extracts the tweet ID from the last document in the results
+func GetLastTweetID(results []types.Document) (int64, error) {
+	if len(results) == 0 {
+		return 0, fmt.Errorf("no results to extract tweet ID from")
+	}
+
+	// Get the last tweet (oldest in the batch)
+	return TweetID(results[len(results)-1])
+}
+
+// TweetID extracts the tweet ID of a document from its tweet_id metadata,
+// or failing that its Id
+func TweetID(doc types.Document) (int64, error) {
+	// Try to get tweet_id from metadata
+	if metadata := doc.Metadata; metadata != nil {
+		if tweetID, ok := metadata["tweet_id"]; ok {
+			switch v := tweetID.(type) {
+			case int64:
+				return v, nil
+			case float64:
+				// JSON numbers are unmarshaled as float64
+				return int64(v), nil
+			case string:
+				id, err := strconv.ParseInt(v, 10, 64)
+				if err != nil {
+					return 0, fmt.Errorf("failed to parse tweet_id string: %w", err)
+				}
+				return id, nil
+			}
+		}
+	}
+
+	// Fallback: try to parse the Id field
+	if doc.Id != "" {
+		id, err := strconv.ParseInt(doc.Id, 10, 64)
+		if err == nil {
+			return id, nil
+		}
+	}
+
+	return 0, fmt.Errorf("could not extract tweet_id from document")
+}
+
+// ErrNoResults is returned by Preflight when a query matches no tweets
+var ErrNoResults = errors.New("query returned no results")
+
+// Preflight checks that query is accepted by the API and matches at least one
+// tweet by requesting a single result, so a bad query fails before a long run
+func Preflight(client Searcher, query string) error {
+	args := twitter.NewSearchArguments()
+	args.Query = query
+	args.MaxResults = 1
+	args.Type = types.CapSearchByQuery
+
+	results, err := client.SearchTwitterWithArgs(args)
+	if err != nil {
+		return fmt.Errorf("query rejected by the API: %w", err)
+	}
+	if len(results) == 0 {
+		return ErrNoResults
+	}
+	return nil
+}
+
+// process runs a page through the Pipeline, holding the Lock if set
+func (c *Collector) process(docs []types.Document) ([]types.Document, error) {
+	if c.Lock != nil {
+		c.Lock.Lock()
+		defer c.Lock.Unlock()
+	}
+	return c.Pipeline.Observe(docs, func(stage string, in, out int, took time.Duration) {
+		c.Metrics.Add(PhaseProcess, stage, in, out, took)
+	})
+}
+
+// write sends a batch to the Sink, holding the Lock if set
+func (c *Collector) write(ctx context.Context, batch sink.Batch) error {
+	if c.Lock != nil {
+		c.Lock.Lock()
+		defer c.Lock.Unlock()
+	}
+	start := time.Now()
+	err := c.Sink.Write(ctx, batch)
+	c.Metrics.Add(PhaseWrite, "sink", len(batch.Docs), len(batch.Docs), time.Since(start))
+	return err
+}
+
+// flush writes the buffered tweets to the Sink, holding the Lock if set
+func (c *Collector) flush(ctx context.Context, buf *flushBuffer) error {
+	if c.Lock != nil {
+		c.Lock.Lock()
+		defer c.Lock.Unlock()
+	}
+	n, start := len(buf.docs), time.Now()
+	err := buf.flush(ctx, c.Sink)
+	if n > 0 && c.Sink != nil {
+		c.Metrics.Add(PhaseWrite, "sink", n, n, time.Since(start))
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package collect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/grant/sn42/pkg/sink"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Flushing reports whether processed tweets are buffered and flushed to the
+// Sink instead of being returned
+func (c *Collector) Flushing() bool {
+	return c.FlushEvery > 0 || c.MaxBufferBytes > 0
+}
+
+// flushBuffer holds processed tweets until a flush threshold is reached
+type flushBuffer struct {
+	query string
+	docs  []types.Document
+	bytes int // Encoded JSON size of docs
+}
+
+func (b *flushBuffer) add(docs []types.Document) {
+	for _, d := range docs {
+		// The encoded size tracks what the tweet costs in memory and on disk
+		// closely enough, whatever metadata it carries
+		data, _ := json.Marshal(d)
+		b.bytes += len(data)
+	}
+	b.docs = append(b.docs, docs...)
+}
+
+// full reports whether the buffer has reached either threshold; zero disables one
+func (b *flushBuffer) full(records, bytes int) bool {
+	return (records > 0 && len(b.docs) >= records) || (bytes > 0 && b.bytes >= bytes)
+}
+
+// flush writes the buffered tweets to s as one batch and empties the buffer
+func (b *flushBuffer) flush(ctx context.Context, s sink.Sink) error {
+	if len(b.docs) == 0 || s == nil {
+		return nil
+	}
+	fmt.Printf("Flushing %d buffered tweets (%d KB)\n", len(b.docs), b.bytes>>10)
+	err := s.Write(ctx, sink.Batch{Query: b.query, Docs: b.docs})
+	// A fresh slice, as a queued sink may still hold the flushed one
+	b.docs, b.bytes = nil, 0
+	if err != nil {
+		return fmt.Errorf("failed to flush tweets to sink: %w", err)
+	}
+	return nil
+}
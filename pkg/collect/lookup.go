@@ -0,0 +1,70 @@
+package collect
+
+import (
+	"cmp"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/masa-finance/tee-worker/v2/api/args/twitter"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Lookup runs one request per item (a tweet by ID, a profile, the
+// retweeters of a tweet...) for many items in parallel, retrying failed
+// requests. The tools re-checking, hydrating or expanding datasets embed it.
+type Lookup struct {
+	Client      Searcher
+	Concurrency int // Parallel lookups (default 4)
+
+	// Retries is how many times a failed request is retried, waiting
+	// RetryDelay (default 5s) and doubling it each time
+	Retries    int
+	RetryDelay time.Duration
+}
+
+// Each calls fn with every index below n, from Concurrency goroutines at
+// once. Stopping ctx stops handing out indexes, so fn is not called for the
+// remaining ones.
+func (l *Lookup) Each(ctx context.Context, n int, fn func(i int)) {
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for range max(l.Concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				fn(i)
+			}
+		}()
+	}
+feed:
+	for i := range n {
+		select {
+		case work <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+}
+
+// Search runs one request, retrying it while it fails. Errors final reports
+// true for (if set) are returned at once, as retrying cannot help them.
+// Stopping ctx during a wait returns ctx.Err().
+func (l *Lookup) Search(ctx context.Context, args twitter.SearchArguments, final func(error) bool) ([]types.Document, error) {
+	delay := cmp.Or(l.RetryDelay, 5*time.Second)
+	for attempt := 1; ; attempt++ {
+		results, err := l.Client.SearchTwitterWithArgs(args)
+		if err == nil || (final != nil && final(err)) || attempt > l.Retries {
+			return results, err
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,67 @@
+package collect
+
+import (
+	"sync"
+	"time"
+
+	"github.com/grant/sn42/pkg/report"
+)
+
+// Phases of collection timed by Metrics
+const (
+	PhaseFetch   = "fetch"   // API requests, including retries and rate limit waits
+	PhaseProcess = "process" // One pipeline stage
+	PhaseWrite   = "write"   // The sink and the output files
+)
+
+// Metrics sums the time and records of every step of collection across the
+// Collectors sharing it, so a run can tell whether the API or its own
+// processing holds it back. A nil Metrics records nothing. It is safe for
+// concurrent use.
+type Metrics struct {
+	mu     sync.Mutex
+	stages []report.Stage
+	took   []time.Duration
+	index  map[string]int
+}
+
+// NewMetrics creates empty metrics
+func NewMetrics() *Metrics {
+	return &Metrics{index: map[string]int{}}
+}
+
+// Add records one batch of in records handled by the step name of phase,
+// which kept out of them and took took
+func (m *Metrics) Add(phase, name string, in, out int, took time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, ok := m.index[name]
+	if !ok {
+		i = len(m.stages)
+		m.index[name] = i
+		m.stages = append(m.stages, report.Stage{Name: name, Phase: phase})
+		m.took = append(m.took, 0)
+	}
+	m.stages[i].Calls++
+	m.stages[i].In += in
+	m.stages[i].Out += out
+	m.took[i] += took
+}
+
+// Stages returns the steps recorded so far, in the order they were first seen
+func (m *Metrics) Stages() []report.Stage {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stages := make([]report.Stage, len(m.stages))
+	for i, st := range m.stages {
+		st.Seconds = m.took[i].Round(time.Microsecond).Seconds()
+		stages[i] = st
+	}
+	return stages
+}
@@ -0,0 +1,63 @@
+package collect
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// ProvenanceKey is the metadata key collected records carry their provenance under
+const ProvenanceKey = "provenance"
+
+// clientModule is the module whose version is recorded as client_version
+const clientModule = "github.com/gopher-lab/gopher-client"
+
+// Provenance describes the run a Collector collects for. Every record it
+// keeps is stamped with it, together with the query, capability and fetch
+// time of its request, so the record can be traced back to its collection
+// run wherever it ends up.
+type Provenance struct {
+	RunID         string
+	Tool          string // Command that collected the record, e.g. "fetch-tweets"
+	ClientVersion string // gopher-client version the binary was built with
+}
+
+// NewProvenance returns the provenance of a run of tool, with the client
+// version read from the binary's build information
+func NewProvenance(runID, tool string) *Provenance {
+	p := &Provenance{RunID: runID, Tool: tool, ClientVersion: "unknown"}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == clientModule {
+				p.ClientVersion = dep.Version
+				if dep.Replace != nil {
+					p.ClientVersion = dep.Replace.Version
+				}
+			}
+		}
+	}
+	return p
+}
+
+// stamp sets the provenance metadata of docs fetched at fetchedAt by a
+// request of the given capability for query. A nil Provenance does nothing.
+func (p *Provenance) stamp(docs []types.Document, query string, capability types.Capability, fetchedAt time.Time) {
+	if p == nil {
+		return
+	}
+	at := fetchedAt.UTC().Format(time.RFC3339)
+	for i := range docs {
+		if docs[i].Metadata == nil {
+			docs[i].Metadata = map[string]any{}
+		}
+		docs[i].Metadata[ProvenanceKey] = map[string]any{
+			"run_id":         p.RunID,
+			"tool":           p.Tool,
+			"query":          query,
+			"capability":     string(capability),
+			"client_version": p.ClientVersion,
+			"fetched_at":     at,
+		}
+	}
+}
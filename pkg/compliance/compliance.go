@@ -0,0 +1,92 @@
+// Package compliance re-checks collected tweets against the API, so that
+// datasets redistributed after collection can drop tweets that have since
+// been deleted or made protected, as platform policies require.
+package compliance
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/grant/sn42/pkg/collect"
+	"github.com/masa-finance/tee-worker/v2/api/args/twitter"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Status is the result of re-checking one tweet
+type Status string
+
+const (
+	Available   Status = "available"
+	Unavailable Status = "unavailable" // Deleted, protected or from a suspended account
+	Unknown     Status = "unknown"     // The lookup kept failing
+)
+
+// unavailableErrors are fragments of the API errors for tweets that cannot be
+// shown any more, as opposed to failed requests worth retrying
+var unavailableErrors = []string{"not found", "no status", "does not exist", "deleted", "protected", "suspended", "unauthorized to see"}
+
+// Checker looks tweets up by ID with the getbyid capability. Tweets whose
+// lookup still fails after all retries are Unknown.
+type Checker struct {
+	collect.Lookup
+}
+
+// Check looks up every ID and returns the status of each, calling progress
+// (if set) after every lookup. Stopping ctx leaves the remaining IDs Unknown.
+func (c *Checker) Check(ctx context.Context, ids []string, progress func(done int, id string, s Status)) map[string]Status {
+	statuses := make(map[string]Status, len(ids))
+	var mu sync.Mutex
+	c.Each(ctx, len(ids), func(i int) {
+		s := c.lookup(ctx, ids[i])
+		mu.Lock()
+		defer mu.Unlock()
+		statuses[ids[i]] = s
+		if progress != nil {
+			progress(len(statuses), ids[i], s)
+		}
+	})
+
+	for _, id := range ids {
+		if _, ok := statuses[id]; !ok {
+			statuses[id] = Unknown
+		}
+	}
+	return statuses
+}
+
+// lookup fetches one tweet
+func (c *Checker) lookup(ctx context.Context, id string) Status {
+	results, err := c.Search(ctx, ByID(id), IsUnavailable)
+	switch {
+	case err == nil && len(results) > 0:
+		return Available
+	case err == nil, IsUnavailable(err):
+		return Unavailable
+	case ctx.Err() == nil:
+		fmt.Printf("⚠️ Lookup of tweet %s failed, leaving it in place: %v\n", id, err)
+	}
+	return Unknown
+}
+
+// ByID returns the getbyid search for the tweet with the given ID
+func ByID(id string) twitter.SearchArguments {
+	args := twitter.NewSearchArguments()
+	args.Type = types.CapGetById
+	args.Query = id
+	args.MaxResults = 1
+	return args
+}
+
+// IsUnavailable reports whether a lookup error says the tweet itself is gone
+// or hidden, rather than that the request failed
+func IsUnavailable(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, fragment := range unavailableErrors {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+	return false
+}
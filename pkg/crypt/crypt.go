@@ -0,0 +1,108 @@
+// Package crypt implements at-rest encryption of dataset files with AES-256-GCM.
+//
+// Encrypted files start with a short magic header followed by the random nonce
+// and the sealed payload, so readers can detect encrypted input without relying
+// on the file extension.
+package crypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Extension is appended to the names of encrypted output files
+const Extension = ".enc"
+
+// KeyEnv is the environment variable holding the encryption key
+const KeyEnv = "ENCRYPTION_KEY"
+
+var magic = []byte("SN42ENC1")
+
+// ErrNotEncrypted is returned by Decrypt for data without the encryption header
+var ErrNotEncrypted = errors.New("data is not encrypted")
+
+// KeyFromEnv reads the 32-byte key from ENCRYPTION_KEY, given as hex (64 chars) or base64
+func KeyFromEnv() ([]byte, error) {
+	raw := strings.TrimSpace(os.Getenv(KeyEnv))
+	if raw == "" {
+		return nil, fmt.Errorf("%s is not set (generate one with: openssl rand -hex 32)", KeyEnv)
+	}
+	return ParseKey(raw)
+}
+
+// ParseKey decodes a 32-byte key from its hex or base64 representation
+func ParseKey(raw string) ([]byte, error) {
+	if key, err := hex.DecodeString(raw); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(raw); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, fmt.Errorf("encryption key must be 32 bytes encoded as hex or base64")
+}
+
+// IsEncrypted reports whether data starts with the encryption header
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// Encrypt seals plaintext with AES-256-GCM under a fresh random nonce
+func Encrypt(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	out := make([]byte, 0, len(magic)+len(nonce)+len(plaintext)+gcm.Overhead())
+	out = append(out, magic...)
+	out = append(out, nonce...)
+	// The header is authenticated as additional data so it cannot be swapped
+	return gcm.Seal(out, nonce, plaintext, magic), nil
+}
+
+// Decrypt opens data produced by Encrypt
+func Decrypt(key, data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return nil, ErrNotEncrypted
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data = data[len(magic):]
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted data is truncated")
+	}
+	nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+
+	plaintext, err := gcm.Open(nil, nonce, sealed, magic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt (wrong key or corrupted file): %w", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
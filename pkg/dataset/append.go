@@ -0,0 +1,58 @@
+package dataset
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// IDs returns the IDs of the tweets in the dataset file at path
+func IDs(path string) (map[string]bool, error) {
+	ids := make(map[string]bool)
+	_, err := Scan(path, func(doc types.Document) error {
+		ids[doc.Id] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Append adds the tweets not already in the dataset file at path and saves
+// it, encrypted with key if non-nil. A missing file is created with header.
+// It returns the number of tweets added and the new total.
+func Append(path string, header File, tweets []types.Document, key []byte) (int, int, error) {
+	f, err := Load(path)
+	created := errors.Is(err, os.ErrNotExist)
+	if created {
+		f, err = &header, nil
+		f.Tweets = nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	seen := make(map[string]bool, len(f.Tweets))
+	for _, t := range f.Tweets {
+		seen[t.Id] = true
+	}
+	added := 0
+	for _, t := range tweets {
+		if t.Id != "" && seen[t.Id] {
+			continue
+		}
+		seen[t.Id] = true
+		f.Tweets = append(f.Tweets, t)
+		added++
+	}
+	if added == 0 && f.Denied == 0 && !created {
+		return 0, len(f.Tweets), nil
+	}
+	if err := f.Save(path, key); err != nil {
+		return 0, 0, fmt.Errorf("failed to append to %s: %w", path, err)
+	}
+	return added, len(f.Tweets), nil
+}
@@ -0,0 +1,315 @@
+package dataset
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/metadata"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/apache/arrow-go/v18/parquet/schema"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// columnarSource turns the rows of Arrow record batches back into records.
+// It reads the columns written by export-arrow; when the full metadata column
+// is present it is used as is, otherwise metadata is rebuilt from the others.
+type columnarSource struct {
+	nextBatch func() (arrow.Record, error) // io.EOF after the last batch
+	filters   []Filter
+	pushed    bool // Some filter has column conditions
+
+	rec  arrow.Record
+	row  int
+	cols map[string]arrow.Array
+}
+
+func (s *columnarSource) next() (types.Document, error) {
+	for {
+		if s.rec == nil || s.row >= int(s.rec.NumRows()) {
+			if s.rec != nil {
+				s.rec.Release()
+				s.rec = nil
+			}
+			rec, err := s.nextBatch()
+			if err != nil {
+				return types.Document{}, err
+			}
+			s.rec, s.row = rec, 0
+			s.cols = make(map[string]arrow.Array, rec.NumCols())
+			for i, f := range rec.Schema().Fields() {
+				s.cols[f.Name] = rec.Column(i)
+			}
+			continue
+		}
+		i := s.row
+		s.row++
+
+		// Column conditions are checked before the record is built, when the
+		// file has the columns; otherwise on the record's metadata
+		columns := s.cols["lang"] != nil && s.cols["created_at"] != nil && s.cols["likes"] != nil
+		if s.pushed && columns {
+			lang, _ := stringAt(s.cols["lang"], i)
+			created, _ := timeAt(s.cols["created_at"], i)
+			likes, _ := intAt(s.cols["likes"], i)
+			if !s.keepFields(lang, created, likes) {
+				continue
+			}
+		}
+		doc, err := s.build(i)
+		if err != nil {
+			return types.Document{}, err
+		}
+		keep := s.match(doc)
+		if !columns {
+			keep = keepAll(s.filters, doc)
+		}
+		if keep {
+			return doc, nil
+		}
+	}
+}
+
+func (s *columnarSource) keepFields(lang string, created time.Time, likes int64) bool {
+	for _, f := range s.filters {
+		if f.pushed() && !f.keepFields(lang, created, likes) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *columnarSource) match(doc types.Document) bool {
+	for _, f := range s.filters {
+		if f.Match != nil && !f.Match(doc) {
+			return false
+		}
+	}
+	return true
+}
+
+// build assembles the record in row i
+func (s *columnarSource) build(i int) (types.Document, error) {
+	var doc types.Document
+	doc.Id, _ = stringAt(s.cols["id"], i)
+	source, _ := stringAt(s.cols["source"], i)
+	doc.Source = types.Source(source)
+	doc.Content, _ = stringAt(s.cols["content"], i)
+
+	if raw, ok := stringAt(s.cols["metadata"], i); ok {
+		if err := json.Unmarshal([]byte(raw), &doc.Metadata); err != nil {
+			return doc, err
+		}
+		return doc, nil
+	}
+	doc.Metadata = map[string]any{}
+	for _, name := range []string{"username", "lang"} {
+		if v, ok := stringAt(s.cols[name], i); ok {
+			doc.Metadata[name] = v
+		}
+	}
+	if t, ok := timeAt(s.cols["created_at"], i); ok {
+		doc.Metadata["created_at"] = t.UTC().Format(time.RFC3339)
+	}
+	// Counts are float64, as when a dataset file is decoded
+	for _, name := range []string{"likes", "retweets", "replies"} {
+		if v, ok := intAt(s.cols[name], i); ok {
+			doc.Metadata[name] = float64(v)
+		}
+	}
+	return doc, nil
+}
+
+// release frees the current batch
+func (s *columnarSource) release() {
+	if s.rec != nil {
+		s.rec.Release()
+		s.rec = nil
+	}
+}
+
+func stringAt(a arrow.Array, i int) (string, bool) {
+	if a == nil || a.IsNull(i) {
+		return "", false
+	}
+	switch a := a.(type) {
+	case *array.String:
+		return a.Value(i), true
+	case *array.LargeString:
+		return a.Value(i), true
+	case *array.Binary:
+		return string(a.Value(i)), true
+	case *array.Dictionary:
+		return stringAt(a.Dictionary(), a.GetValueIndex(i))
+	}
+	return "", false
+}
+
+func intAt(a arrow.Array, i int) (int64, bool) {
+	if a == nil || a.IsNull(i) {
+		return 0, false
+	}
+	switch a := a.(type) {
+	case *array.Int64:
+		return a.Value(i), true
+	case *array.Int32:
+		return int64(a.Value(i)), true
+	case *array.Uint64:
+		return int64(a.Value(i)), true
+	case *array.Float64:
+		return int64(a.Value(i)), true
+	}
+	return 0, false
+}
+
+func timeAt(a arrow.Array, i int) (time.Time, bool) {
+	if a == nil || a.IsNull(i) {
+		return time.Time{}, false
+	}
+	if ts, ok := a.(*array.Timestamp); ok {
+		return ts.Value(i).ToTime(ts.DataType().(*arrow.TimestampType).Unit), true
+	}
+	return time.Time{}, false
+}
+
+// newArrowSource reads the record batches of an Arrow IPC file in order
+func newArrowSource(r readerAtSeeker, filters []Filter) (source, io.Closer, error) {
+	fr, err := ipc.NewFileReader(r, ipc.WithAllocator(memory.DefaultAllocator))
+	if err != nil {
+		return nil, nil, err
+	}
+	batch := 0
+	s := &columnarSource{filters: filters, pushed: anyPushed(filters)}
+	s.nextBatch = func() (arrow.Record, error) {
+		if batch >= fr.NumRecords() {
+			return nil, io.EOF
+		}
+		rec, err := fr.RecordAt(batch)
+		batch++
+		return rec, err
+	}
+	return s, closerFunc(func() error {
+		s.release()
+		return fr.Close()
+	}), nil
+}
+
+// newParquetSource reads a Parquet file, skipping row groups whose column
+// statistics show that no row can pass the filters
+func newParquetSource(r readerAtSeeker, filters []Filter) (source, io.Closer, error) {
+	pf, err := file.NewParquetReader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	fr, err := pqarrow.NewFileReader(pf, pqarrow.ArrowReadProperties{BatchSize: 4096}, memory.DefaultAllocator)
+	if err != nil {
+		pf.Close()
+		return nil, nil, err
+	}
+
+	groups := []int{}
+	for g := range pf.NumRowGroups() {
+		if !skipRowGroup(pf.MetaData(), g, filters) {
+			groups = append(groups, g)
+		}
+	}
+	s := &columnarSource{filters: filters, pushed: anyPushed(filters)}
+	var rr pqarrow.RecordReader
+	if len(groups) == 0 {
+		s.nextBatch = func() (arrow.Record, error) { return nil, io.EOF }
+	} else {
+		if rr, err = fr.GetRecordReader(context.Background(), nil, groups); err != nil {
+			pf.Close()
+			return nil, nil, err
+		}
+		s.nextBatch = func() (arrow.Record, error) {
+			if !rr.Next() {
+				if err := rr.Err(); err != nil && !errors.Is(err, io.EOF) {
+					return nil, err
+				}
+				return nil, io.EOF
+			}
+			rec := rr.Record()
+			rec.Retain() // The reader releases it on the next call
+			return rec, nil
+		}
+	}
+	return s, closerFunc(func() error {
+		s.release()
+		if rr != nil {
+			rr.Release()
+		}
+		return pf.Close()
+	}), nil
+}
+
+// skipRowGroup reports whether the min/max statistics of row group g rule out
+// every row for some filter's created_at or likes condition
+func skipRowGroup(md *metadata.FileMetaData, g int, filters []Filter) bool {
+	rg := md.RowGroup(g)
+	minMax := func(name string) (int64, int64, bool) {
+		col := md.Schema.ColumnIndexByName(name)
+		if col < 0 {
+			return 0, 0, false
+		}
+		chunk, err := rg.ColumnChunk(col)
+		if err != nil {
+			return 0, 0, false
+		}
+		stats, err := chunk.Statistics()
+		if err != nil || stats == nil || !stats.HasMinMax() {
+			return 0, 0, false
+		}
+		if s, ok := stats.(*metadata.Int64Statistics); ok {
+			return s.Min(), s.Max(), true
+		}
+		return 0, 0, false
+	}
+	createdMin, createdMax, haveCreated := minMax("created_at")
+	_, likesMax, haveLikes := minMax("likes")
+
+	// Timestamps are compared in the unit the column is stored in
+	toUnit := func(t time.Time) int64 { return t.UnixMilli() }
+	if col := md.Schema.ColumnIndexByName("created_at"); col >= 0 {
+		ts, ok := md.Schema.Column(col).LogicalType().(*schema.TimestampLogicalType)
+		if !ok {
+			haveCreated = false
+		} else if ts.TimeUnit() == schema.TimeUnitMicros {
+			toUnit = func(t time.Time) int64 { return t.UnixMicro() }
+		} else if ts.TimeUnit() == schema.TimeUnitNanos {
+			toUnit = func(t time.Time) int64 { return t.UnixNano() }
+		}
+	}
+	for _, f := range filters {
+		if haveCreated && !f.Since.IsZero() && createdMax < toUnit(f.Since) {
+			return true
+		}
+		if haveCreated && !f.Until.IsZero() && createdMin >= toUnit(f.Until) {
+			return true
+		}
+		if haveLikes && f.MinLikes > 0 && likesMax < f.MinLikes {
+			return true
+		}
+	}
+	return false
+}
+
+func anyPushed(filters []Filter) bool {
+	for _, f := range filters {
+		if f.pushed() {
+			return true
+		}
+	}
+	return false
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
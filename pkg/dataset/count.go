@@ -0,0 +1,106 @@
+package dataset
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/file"
+)
+
+// Count returns the number of records in a dataset file and its format,
+// without decoding the records: JSONL lines are counted, Parquet row counts
+// come from the footer, Arrow batch lengths are summed, and the elements of a
+// JSON array are skipped over by the scanner.
+func Count(path string) (int64, string, error) {
+	raw, err := openRaw(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer raw.close()
+
+	var n int64
+	switch raw.format {
+	case FormatJSON:
+		n, err = countJSON(raw)
+	case FormatJSONL:
+		n, err = countLines(raw)
+	case FormatArrow:
+		n, err = countArrow(raw)
+	case FormatParquet:
+		n, err = countParquet(raw)
+	default:
+		err = fmt.Errorf("unrecognized format")
+	}
+	if err != nil {
+		return 0, raw.format, fmt.Errorf("failed to count records in %s: %w", path, err)
+	}
+	return n, raw.format, nil
+}
+
+func countJSON(raw *rawFile) (int64, error) {
+	s, err := newJSONSource(raw.r, nil)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	for !s.done && s.dec.More() {
+		var skip json.RawMessage
+		if err := s.dec.Decode(&skip); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// countLines counts the non-blank lines, as the JSONL reader returns
+func countLines(raw *rawFile) (int64, error) {
+	scanner := bufio.NewScanner(raw.r)
+	scanner.Buffer(make([]byte, 0, 1<<16), 64<<20)
+	var n int64
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) > 0 {
+			n++
+		}
+	}
+	return n, scanner.Err()
+}
+
+func countArrow(raw *rawFile) (int64, error) {
+	ra, err := raw.readerAt()
+	if err != nil {
+		return 0, err
+	}
+	fr, err := ipc.NewFileReader(ra, ipc.WithAllocator(memory.DefaultAllocator))
+	if err != nil {
+		return 0, err
+	}
+	defer fr.Close()
+	var n int64
+	for i := range fr.NumRecords() {
+		rec, err := fr.RecordAt(i)
+		if err != nil {
+			return n, err
+		}
+		n += rec.NumRows()
+		rec.Release()
+	}
+	return n, nil
+}
+
+func countParquet(raw *rawFile) (int64, error) {
+	ra, err := raw.readerAt()
+	if err != nil {
+		return 0, err
+	}
+	pf, err := file.NewParquetReader(ra)
+	if err != nil {
+		return 0, err
+	}
+	defer pf.Close()
+	return pf.NumRows(), nil
+}
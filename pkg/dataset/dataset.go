@@ -0,0 +1,120 @@
+// Package dataset reads and writes the JSON dataset files produced by the
+// collectors in this repository.
+package dataset
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/grant/sn42/pkg/crypt"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// File is the on-disk layout of a dataset file written by fetch-tweets or fetch-trends
+type File struct {
+	TotalTweets int              `json:"total_tweets"`
+	Trend       string           `json:"trend,omitempty"`
+	Query       string           `json:"query"`
+	SavedQuery  string           `json:"saved_query,omitempty"`
+	CollectedAt string           `json:"collected_at"`
+	Tweets      []types.Document `json:"tweets"`
+
+	// Denied is the number of tweets Load left out because of the denylist
+	Denied int `json:"-"`
+}
+
+// Load reads a dataset file. Encrypted files are decrypted transparently with
+// the key from ENCRYPTION_KEY. Tweets on the denylist are left out.
+func Load(path string) (*File, error) {
+	deny, err := Denylist()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dataset: %w", err)
+	}
+
+	if crypt.IsEncrypted(data) {
+		key, err := crypt.KeyFromEnv()
+		if err != nil {
+			return nil, fmt.Errorf("%s is encrypted: %w", path, err)
+		}
+		if data, err = crypt.Decrypt(key, data); err != nil {
+			return nil, err
+		}
+	}
+
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse dataset %s: %w", path, err)
+	}
+	n := len(f.Tweets)
+	f.Tweets = deny.Filter(f.Tweets)
+	f.Denied = n - len(f.Tweets)
+	return &f, nil
+}
+
+// Save writes the dataset to path, updating TotalTweets and stamping
+// CollectedAt if it is empty. Tweets on the denylist are dropped first. If
+// key is non-nil the file is encrypted.
+func (f *File) Save(path string, key []byte) error {
+	deny, err := Denylist()
+	if err != nil {
+		return err
+	}
+	f.Tweets = deny.Filter(f.Tweets)
+	f.TotalTweets = len(f.Tweets)
+	if f.CollectedAt == "" {
+		f.CollectedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal dataset: %w", err)
+	}
+	if key != nil {
+		if data, err = crypt.Encrypt(key, data); err != nil {
+			return fmt.Errorf("failed to encrypt dataset: %w", err)
+		}
+	}
+
+	// Written aside and renamed, so readers never see a partial file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+// Scan streams the tweets of a dataset file to fn one at a time and returns the
+// file's header with Tweets left empty, so files larger than memory can be
+// processed. Any format OpenDataset reads is accepted; files without a header
+// return an empty one.
+func Scan(path string, fn func(types.Document) error) (*File, error) {
+	it, err := OpenDataset(path)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	for doc, err := range it.All() {
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse dataset %s: %w", path, err)
+		}
+		if err := fn(doc); err != nil {
+			return nil, err
+		}
+	}
+	if header := it.Header(); header != nil {
+		return header, nil
+	}
+	return &File{}, nil
+}
@@ -0,0 +1,18 @@
+package dataset
+
+import (
+	"sync"
+
+	"github.com/grant/sn42/pkg/denylist"
+)
+
+// activeDenylist is loaded on first use, once the command has read its .env
+var activeDenylist = sync.OnceValues(denylist.FromEnv)
+
+// Denylist returns the denylist configured by DENYLIST_FILE (see
+// denylist.FromEnv), nil if there is none. Every read and write in this
+// package leaves out the tweets it denies; code handling records some other
+// way should check it too.
+func Denylist() (*denylist.Denylist, error) {
+	return activeDenylist()
+}
@@ -0,0 +1,16 @@
+package dataset
+
+import (
+	"log"
+
+	"github.com/joho/godotenv"
+)
+
+// LoadEnv loads the .env file of the working directory into the
+// environment, so the dataset tools find ENCRYPTION_KEY and DENYLIST_FILE
+// where the collectors keep them. A missing file is only a warning.
+func LoadEnv() {
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Warning: failed to load .env file: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package dataset
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/grant/sn42/pkg/crypt"
+)
+
+// sidecars are files the tools write next to datasets that hold no records
+var sidecars = []string{".manifest.json", ".labelstudio.json", ".prodigy.jsonl", ".audit.jsonl"}
+
+// reportFiles are the files the collectors write into run directories and
+// stores besides datasets
+var reportFiles = []string{"summary.json", "stats.json", "store.json"}
+
+// Files expands the directories among paths to the dataset files under them,
+// in name order, so a dataset split into shards can be read as one. Manifests,
+// labeling exports, audit logs, run and store summaries, and hidden files
+// (such as in-progress spools) are skipped; files named directly are returned
+// as given. A directory may be a symlink, such as runs/latest.
+func Files(paths ...string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open dataset: %w", err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		var found []string
+		// The trailing separator makes WalkDir follow a symlinked directory
+		err = filepath.WalkDir(path+string(filepath.Separator), func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !IsDatasetName(d.Name()) {
+				return nil
+			}
+			found = append(found, p)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list datasets in %s: %w", path, err)
+		}
+		slices.Sort(found)
+		files = append(files, found...)
+	}
+	return files, nil
+}
+
+// IsDatasetName reports whether a file name looks like a dataset OpenDataset
+// reads, compressed or encrypted, rather than a sidecar file
+func IsDatasetName(name string) bool {
+	if strings.HasPrefix(name, ".") || slices.Contains(reportFiles, name) {
+		return false
+	}
+	name = strings.TrimSuffix(name, crypt.Extension)
+	name = strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ".zst")
+	for _, s := range sidecars {
+		if strings.HasSuffix(name, s) {
+			return false
+		}
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".jsonl", ".ndjson", ".arrow", ".feather", ".ipc", ".parquet":
+		return true
+	}
+	return false
+}
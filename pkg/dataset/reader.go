@@ -0,0 +1,522 @@
+package dataset
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grant/sn42/pkg/crypt"
+	"github.com/grant/sn42/pkg/denylist"
+	"github.com/klauspost/compress/zstd"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Record formats read by OpenDataset
+const (
+	FormatJSON    = "json"    // Dataset file written by the collectors, or a JSON array of records
+	FormatJSONL   = "jsonl"   // One record per line
+	FormatArrow   = "arrow"   // Arrow IPC (Feather v2) file written by export-arrow
+	FormatParquet = "parquet" // Parquet file with the export-arrow columns
+)
+
+// Filter selects the records an Iterator returns. The zero Filter keeps
+// everything. Lang, Since, Until and MinLikes are pushed down into the Arrow
+// and Parquet readers, which check them on the columns before building a
+// record and skip Parquet row groups whose statistics rule them out.
+type Filter struct {
+	Lang     string    // Keep only tweets in this language
+	Since    time.Time // Keep only tweets created at or after this time
+	Until    time.Time // Keep only tweets created before this time
+	MinLikes int64     // Keep only tweets with at least this many likes
+
+	// Match, if set, is called with every record that passed the other checks
+	Match func(types.Document) bool
+}
+
+// pushed reports whether the filter has conditions on columns
+func (f Filter) pushed() bool {
+	return f.Lang != "" || !f.Since.IsZero() || !f.Until.IsZero() || f.MinLikes > 0
+}
+
+// keepFields checks the column conditions; a zero created means unknown
+func (f Filter) keepFields(lang string, created time.Time, likes int64) bool {
+	if f.Lang != "" && lang != f.Lang {
+		return false
+	}
+	if (!f.Since.IsZero() || !f.Until.IsZero()) && created.IsZero() {
+		return false
+	}
+	if !f.Since.IsZero() && created.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !created.Before(f.Until) {
+		return false
+	}
+	return likes >= f.MinLikes
+}
+
+// keep checks every condition against a decoded record
+func (f Filter) keep(doc types.Document) bool {
+	if f.pushed() {
+		lang, _ := doc.Metadata["lang"].(string)
+		var created time.Time
+		if s, ok := doc.Metadata["created_at"].(string); ok {
+			created, _ = time.Parse(time.RFC3339, s)
+		}
+		likes, _ := MetadataInt(doc, "likes")
+		if !f.keepFields(lang, created, likes) {
+			return false
+		}
+	}
+	return f.Match == nil || f.Match(doc)
+}
+
+// keepAll reports whether doc passes every filter
+func keepAll(filters []Filter, doc types.Document) bool {
+	for _, f := range filters {
+		if !f.keep(doc) {
+			return false
+		}
+	}
+	return true
+}
+
+// MetadataInt returns a numeric metadata field as an integer, whichever number
+// type it was decoded as
+func MetadataInt(doc types.Document, field string) (int64, bool) {
+	switch v := doc.Metadata[field].(type) {
+	case float64:
+		return int64(v), true
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// MetadataID returns an ID metadata field such as conversation_id as a
+// string, whether it was stored as a string or a number. Missing and zero IDs
+// are "".
+func MetadataID(doc types.Document, field string) string {
+	switch v := doc.Metadata[field].(type) {
+	case string:
+		if v == "0" {
+			return ""
+		}
+		return v
+	case float64:
+		if v > 0 {
+			return strconv.FormatFloat(v, 'f', 0, 64)
+		}
+	case int64:
+		if v > 0 {
+			return strconv.FormatInt(v, 10)
+		}
+	case json.Number:
+		if v != "0" {
+			return v.String()
+		}
+	}
+	return ""
+}
+
+// source produces the records of one file, returning io.EOF at the end
+type source interface {
+	next() (types.Document, error)
+}
+
+// Iterator returns the records of a dataset file one at a time
+type Iterator struct {
+	src     source
+	format  string
+	header  *File // Set for collector dataset files
+	closers []io.Closer
+	deny    *denylist.Denylist
+	denied  int
+}
+
+// OpenDataset opens a dataset file for iteration. The format is taken from
+// the extension (.json, .jsonl/.ndjson, .arrow/.feather, .parquet), after
+// removing .gz or .zst, and otherwise sniffed from the contents. Gzip and
+// zstd compression and ENCRYPTION_KEY encryption are detected and undone
+// transparently. Only records passing every filter and not on the denylist
+// are returned.
+//
+// JSON and JSONL are streamed. Arrow and Parquet need random access, so
+// compressed or encrypted ones are read into memory first.
+func OpenDataset(path string, filters ...Filter) (*Iterator, error) {
+	deny, err := Denylist()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := openRaw(path)
+	if err != nil {
+		return nil, err
+	}
+	it := &Iterator{format: raw.format, closers: raw.closers, deny: deny}
+	fail := func(err error) (*Iterator, error) {
+		it.Close()
+		return nil, fmt.Errorf("failed to read dataset %s: %w", path, err)
+	}
+
+	switch it.format {
+	case FormatJSON:
+		src, err := newJSONSource(raw.r, filters)
+		if err != nil {
+			return fail(err)
+		}
+		it.src, it.header = src, src.header
+	case FormatJSONL:
+		it.src = newJSONLSource(raw.r, filters)
+	case FormatArrow, FormatParquet:
+		ra, err := raw.readerAt()
+		if err != nil {
+			return fail(err)
+		}
+		var src source
+		var closer io.Closer
+		if it.format == FormatArrow {
+			src, closer, err = newArrowSource(ra, filters)
+		} else {
+			src, closer, err = newParquetSource(ra, filters)
+		}
+		if err != nil {
+			return fail(err)
+		}
+		it.src = src
+		it.closers = append(it.closers, closer)
+	default:
+		return fail(fmt.Errorf("unrecognized format"))
+	}
+	return it, nil
+}
+
+// rawFile is an open dataset file with its encryption and compression undone
+type rawFile struct {
+	r       io.Reader // Decoded contents
+	file    *os.File
+	plain   bool // r still reads the file itself
+	format  string
+	closers []io.Closer
+}
+
+// openRaw opens a dataset file, undoing encryption and compression and
+// detecting the format of the contents
+func openRaw(path string) (*rawFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dataset: %w", err)
+	}
+	raw := &rawFile{r: f, file: f, plain: true, closers: []io.Closer{f}}
+	fail := func(err error) (*rawFile, error) {
+		raw.close()
+		return nil, fmt.Errorf("failed to read dataset %s: %w", path, err)
+	}
+
+	// Undo encryption and compression, innermost last
+	name := filepath.Base(path)
+	for {
+		br := bufio.NewReaderSize(raw.r, 1<<16)
+		raw.r = br
+		// A short peek just means a short file
+		head, _ := br.Peek(512)
+		switch {
+		case crypt.IsEncrypted(head):
+			key, err := crypt.KeyFromEnv()
+			if err != nil {
+				return fail(fmt.Errorf("file is encrypted: %w", err))
+			}
+			data, err := io.ReadAll(br)
+			if err != nil {
+				return fail(err)
+			}
+			if data, err = crypt.Decrypt(key, data); err != nil {
+				return fail(err)
+			}
+			raw.r = bytes.NewReader(data)
+			name = strings.TrimSuffix(name, crypt.Extension)
+		case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+			zr, err := gzip.NewReader(br)
+			if err != nil {
+				return fail(err)
+			}
+			raw.closers = append(raw.closers, zr)
+			raw.r = zr
+			name = strings.TrimSuffix(name, ".gz")
+		case bytes.HasPrefix(head, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+			zr, err := zstd.NewReader(br)
+			if err != nil {
+				return fail(err)
+			}
+			raw.closers = append(raw.closers, zr.IOReadCloser())
+			raw.r = zr
+			name = strings.TrimSuffix(name, ".zst")
+		default:
+			raw.format = detectFormat(name, head)
+			return raw, nil
+		}
+		raw.plain = false
+	}
+}
+
+// readerAt returns random access to the contents, as the columnar readers
+// need: the file itself, or the decoded contents read into memory
+func (f *rawFile) readerAt() (readerAtSeeker, error) {
+	if f.plain {
+		return f.file, nil
+	}
+	data, err := io.ReadAll(f.r)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+func (f *rawFile) close() error {
+	var errs []error
+	for i := len(f.closers) - 1; i >= 0; i-- {
+		errs = append(errs, f.closers[i].Close())
+	}
+	return errors.Join(errs...)
+}
+
+type readerAtSeeker interface {
+	io.ReaderAt
+	io.ReadSeeker
+}
+
+// detectFormat picks the format from the file name, falling back to the
+// first bytes of the contents
+func detectFormat(name string, head []byte) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return FormatJSON
+	case ".jsonl", ".ndjson":
+		return FormatJSONL
+	case ".arrow", ".feather", ".ipc":
+		return FormatArrow
+	case ".parquet":
+		return FormatParquet
+	}
+	switch trimmed := bytes.TrimLeft(head, " \t\r\n"); {
+	case bytes.HasPrefix(head, []byte("ARROW1")):
+		return FormatArrow
+	case bytes.HasPrefix(head, []byte("PAR1")):
+		return FormatParquet
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		return FormatJSON
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		// A dataset file is indented, while JSONL has a whole record on the first line
+		if line, _, _ := bytes.Cut(trimmed, []byte("\n")); bytes.HasSuffix(bytes.TrimSpace(line), []byte("}")) {
+			return FormatJSONL
+		}
+		return FormatJSON
+	}
+	return ""
+}
+
+// Format returns the format the file was read as
+func (it *Iterator) Format() string {
+	return it.format
+}
+
+// Header returns the header of a collector dataset file, nil for other
+// files. Fields stored after the tweets are only filled in once iteration
+// has finished.
+func (it *Iterator) Header() *File {
+	return it.header
+}
+
+// Next returns the next record, or io.EOF once there are no more
+func (it *Iterator) Next() (types.Document, error) {
+	for {
+		doc, err := it.src.next()
+		if err != nil || !it.deny.Denies(doc) {
+			return doc, err
+		}
+		it.denied++
+	}
+}
+
+// Denied returns the number of records left out so far because of the denylist
+func (it *Iterator) Denied() int {
+	return it.denied
+}
+
+// All returns the remaining records as a range-over-func sequence, ending
+// after the first error
+func (it *Iterator) All() iter.Seq2[types.Document, error] {
+	return func(yield func(types.Document, error) bool) {
+		for {
+			doc, err := it.Next()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if !yield(doc, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Close releases the file and decoders
+func (it *Iterator) Close() error {
+	var errs []error
+	for i := len(it.closers) - 1; i >= 0; i-- {
+		errs = append(errs, it.closers[i].Close())
+	}
+	it.closers = nil
+	return errors.Join(errs...)
+}
+
+// jsonSource streams the elements of the tweets array of a dataset file, or
+// of a top-level array of records
+type jsonSource struct {
+	dec     *json.Decoder
+	filters []Filter
+	header  *File // Nil for a bare array
+	done    bool
+}
+
+func newJSONSource(r io.Reader, filters []Filter) (*jsonSource, error) {
+	s := &jsonSource{dec: json.NewDecoder(r), filters: filters}
+	tok, err := s.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('['):
+		return s, nil
+	case json.Delim('{'):
+		s.header = &File{}
+		found, err := s.readHeader()
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			s.done = true
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("expected a JSON object or array, got %v", tok)
+}
+
+// readHeader decodes header fields up to the tweets array, or to the end of
+// the object, reporting whether the tweets array was reached
+func (s *jsonSource) readHeader() (bool, error) {
+	for s.dec.More() {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return false, err
+		}
+		switch tok {
+		case "tweets":
+			return true, expectDelim(s.dec, '[')
+		case "total_tweets":
+			err = s.dec.Decode(&s.header.TotalTweets)
+		case "trend":
+			err = s.dec.Decode(&s.header.Trend)
+		case "query":
+			err = s.dec.Decode(&s.header.Query)
+		case "saved_query":
+			err = s.dec.Decode(&s.header.SavedQuery)
+		case "collected_at":
+			err = s.dec.Decode(&s.header.CollectedAt)
+		default:
+			var skip json.RawMessage
+			err = s.dec.Decode(&skip)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return false, expectDelim(s.dec, '}')
+}
+
+func (s *jsonSource) next() (types.Document, error) {
+	for !s.done {
+		if !s.dec.More() {
+			// End of the array; a dataset file may have header fields after it
+			if err := expectDelim(s.dec, ']'); err != nil {
+				return types.Document{}, err
+			}
+			s.done = true
+			if s.header != nil {
+				if _, err := s.readHeader(); err != nil {
+					return types.Document{}, err
+				}
+			}
+			break
+		}
+		var doc types.Document
+		if err := s.dec.Decode(&doc); err != nil {
+			return types.Document{}, err
+		}
+		if keepAll(s.filters, doc) {
+			return doc, nil
+		}
+	}
+	return types.Document{}, io.EOF
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != want {
+		return fmt.Errorf("expected %q, got %v", want, tok)
+	}
+	return nil
+}
+
+// jsonlSource reads one record per line, skipping blank lines
+type jsonlSource struct {
+	scanner *bufio.Scanner
+	filters []Filter
+	line    int
+}
+
+func newJSONLSource(r io.Reader, filters []Filter) *jsonlSource {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 1<<16), 64<<20)
+	return &jsonlSource{scanner: scanner, filters: filters}
+}
+
+func (s *jsonlSource) next() (types.Document, error) {
+	for s.scanner.Scan() {
+		s.line++
+		line := bytes.TrimSpace(s.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var doc types.Document
+		if err := json.Unmarshal(line, &doc); err != nil {
+			return types.Document{}, fmt.Errorf("line %d: %w", s.line, err)
+		}
+		if keepAll(s.filters, doc) {
+			return doc, nil
+		}
+	}
+	if err := s.scanner.Err(); err != nil {
+		return types.Document{}, err
+	}
+	return types.Document{}, io.EOF
+}
@@ -0,0 +1,97 @@
+package dataset
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/grant/sn42/pkg/sample"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// SortKeys map a sort order to the key a tweet is ordered by: id (or
+// tweet_id) and created_at put the oldest tweets first, engagement the
+// highest first. Keys compare as strings, so they also work for external
+// sorts (see pkg/spill).
+var SortKeys = map[string]func(types.Document) string{
+	"id":         idKey,
+	"tweet_id":   idKey,
+	"created_at": createdKey,
+	"engagement": engagementKey,
+}
+
+// SortOrders lists the sort orders for usage messages
+const SortOrders = "id (or tweet_id), created_at or engagement"
+
+// SortKey returns the key function of a sort order
+func SortKey(by string) (func(types.Document) string, error) {
+	key, ok := SortKeys[by]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort order %q (expected %s)", by, SortOrders)
+	}
+	return key, nil
+}
+
+// Sort orders tweets in place by the sort order by. Ties keep their order,
+// so sorting the same tweets always gives the same result.
+func Sort(tweets []types.Document, by string) error {
+	key, err := SortKey(by)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, len(tweets))
+	idx := make([]int, len(tweets))
+	for i, t := range tweets {
+		keys[i], idx[i] = key(t), i
+	}
+	slices.SortStableFunc(idx, func(a, b int) int { return cmp.Compare(keys[a], keys[b]) })
+	sorted := make([]types.Document, len(tweets))
+	for i, j := range idx {
+		sorted[i] = tweets[j]
+	}
+	copy(tweets, sorted)
+	return nil
+}
+
+// SortFile re-sorts the dataset file at path in place, encrypting it with
+// key if non-nil
+func SortFile(path, by string, key []byte) error {
+	f, err := Load(path)
+	if err != nil {
+		return err
+	}
+	if err := Sort(f.Tweets, by); err != nil {
+		return err
+	}
+	if err := f.Save(path, key); err != nil {
+		return fmt.Errorf("failed to sort %s: %w", path, err)
+	}
+	return nil
+}
+
+// idKey orders numeric tweet IDs numerically, before any other IDs
+func idKey(doc types.Document) string {
+	if id, err := strconv.ParseUint(doc.Id, 10, 64); err == nil {
+		return fmt.Sprintf("%020d", id)
+	}
+	return "~" + doc.Id
+}
+
+// createdKey orders tweets by creation time, tweets without one last
+func createdKey(doc types.Document) string {
+	s, _ := doc.Metadata["created_at"].(string)
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return "~"
+	}
+	return t.UTC().Format("2006-01-02T15:04:05.000000000Z")
+}
+
+// engagementKey orders tweets by descending engagement
+func engagementKey(doc types.Document) string {
+	e := min(max(sample.Engagement(doc), 0), math.MaxInt64/2)
+	return fmt.Sprintf("%020d", math.MaxInt64-int64(e))
+}
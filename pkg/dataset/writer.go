@@ -0,0 +1,141 @@
+package dataset
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/grant/sn42/pkg/denylist"
+	"github.com/grant/sn42/pkg/sink"
+)
+
+// Writer streams tweets into a dataset file without holding them in memory.
+// Tweets are spooled to a temporary file as they arrive, and Close writes the
+// dataset, with the same layout as File.Save, from the header and the spool.
+// It is a sink.Sink, so the collector can flush to it with the other sinks.
+// Tweets on the denylist are dropped.
+type Writer struct {
+	path   string
+	header File
+	spool  *os.File
+	buf    *bufio.Writer
+	count  int
+	closed bool
+	deny   *denylist.Denylist
+}
+
+// NewWriter starts a dataset file at path described by header, whose Tweets
+// are ignored. Nothing is written to path until Close.
+func NewWriter(path string, header File) (*Writer, error) {
+	deny, err := Denylist()
+	if err != nil {
+		return nil, err
+	}
+	spool, err := os.CreateTemp(filepath.Dir(path), ".spool-*.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spool file: %w", err)
+	}
+	header.Tweets = nil
+	return &Writer{path: path, header: header, spool: spool, buf: bufio.NewWriter(spool), deny: deny}, nil
+}
+
+// Write appends the batch's tweets to the spool
+func (w *Writer) Write(ctx context.Context, batch sink.Batch) error {
+	for _, d := range batch.Docs {
+		if w.deny.Denies(d) {
+			continue
+		}
+		data, err := json.MarshalIndent(d, "    ", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal tweet: %w", err)
+		}
+		if w.count > 0 {
+			w.buf.WriteByte(',')
+		}
+		w.buf.WriteString("\n    ")
+		if _, err := w.buf.Write(data); err != nil {
+			return fmt.Errorf("failed to spool tweets: %w", err)
+		}
+		w.count++
+	}
+	return nil
+}
+
+// Header returns the header that Close writes, which may still be changed
+func (w *Writer) Header() *File {
+	return &w.header
+}
+
+// Count returns the number of tweets written so far
+func (w *Writer) Count() int {
+	return w.count
+}
+
+// Discard removes the spool without writing the dataset file
+func (w *Writer) Discard() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	w.spool.Close()
+	return os.Remove(w.spool.Name())
+}
+
+// Close writes the dataset file and removes the spool
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	defer os.Remove(w.spool.Name())
+	defer w.spool.Close()
+
+	if err := w.buf.Flush(); err != nil {
+		return fmt.Errorf("failed to spool tweets: %w", err)
+	}
+	if _, err := w.spool.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read spool file: %w", err)
+	}
+
+	w.header.TotalTweets = w.count
+	if w.header.CollectedAt == "" {
+		w.header.CollectedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	head, err := json.MarshalIndent(w.header, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal dataset: %w", err)
+	}
+	// The header ends with the empty tweets array, which the spool fills in
+	head = bytes.TrimSuffix(head, []byte("null\n}"))
+
+	out, err := os.Create(w.path)
+	if err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	bw := bufio.NewWriter(out)
+	bw.Write(head)
+	if w.count == 0 {
+		bw.WriteString("[]\n}")
+	} else {
+		bw.WriteByte('[')
+		if _, err := io.Copy(bw, w.spool); err != nil {
+			out.Close()
+			return fmt.Errorf("failed to write file: %w", err)
+		}
+		bw.WriteString("\n  ]\n}")
+	}
+	if err := bw.Flush(); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+// Package denylist reads the maintained list of tweets and users that must
+// never appear in a dataset again, such as takedowns and opt-outs. The
+// dataset reader and writer and the collection pipeline all exclude what it
+// lists, so a takedown reaches every later export without editing files.
+package denylist
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// DefaultPath is used when DENYLIST_FILE is not set, if the file exists
+const DefaultPath = "data/denylist.txt"
+
+// Denylist matches tweets by ID and authors by ID or handle. A nil Denylist
+// denies nothing.
+type Denylist struct {
+	Path    string
+	tweets  map[string]bool
+	users   map[string]bool // Author IDs
+	handles map[string]bool // Lowercase usernames
+}
+
+// FromEnv loads the denylist named by DENYLIST_FILE, or DefaultPath if that
+// is unset and the file exists. It returns nil when there is no denylist.
+func FromEnv() (*Denylist, error) {
+	path := os.Getenv("DENYLIST_FILE")
+	if path == "" {
+		if _, err := os.Stat(DefaultPath); errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		path = DefaultPath
+	}
+	return Load(path)
+}
+
+// Load reads a denylist file with one entry per line: a tweet ID, either bare
+// or as "tweet:<id>", "user:<id>" for an author ID, or "@handle". Anything
+// after a # is a comment.
+func Load(path string) (*Denylist, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open denylist: %w", err)
+	}
+	defer f.Close()
+
+	d := &Denylist{Path: path, tweets: map[string]bool{}, users: map[string]bool{}, handles: map[string]bool{}}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		entry, _, _ := strings.Cut(scanner.Text(), "#")
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		if handle, ok := strings.CutPrefix(entry, "@"); ok && handle != "" {
+			d.handles[strings.ToLower(handle)] = true
+			continue
+		}
+		kind, id, ok := strings.Cut(entry, ":")
+		if !ok {
+			kind, id = "tweet", entry
+		}
+		if id = strings.TrimSpace(id); id == "" || strings.Trim(id, "0123456789") != "" {
+			return nil, fmt.Errorf("%s:%d: invalid entry %q (expected a tweet ID, tweet:<id>, user:<id> or @handle)", path, n, scanner.Text())
+		}
+		switch strings.TrimSpace(kind) {
+		case "tweet":
+			d.tweets[id] = true
+		case "user":
+			d.users[id] = true
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown entry type %q (expected tweet or user)", path, n, kind)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read denylist: %w", err)
+	}
+	return d, nil
+}
+
+// Len returns the number of entries
+func (d *Denylist) Len() int {
+	if d == nil {
+		return 0
+	}
+	return len(d.tweets) + len(d.users) + len(d.handles)
+}
+
+// Denies reports whether doc is a listed tweet or by a listed author
+func (d *Denylist) Denies(doc types.Document) bool {
+	if d.Len() == 0 {
+		return false
+	}
+	if d.tweets[doc.Id] {
+		return true
+	}
+	for _, key := range []string{"tweet_id", "user_id", "author_id"} {
+		v, ok := doc.Metadata[key]
+		if !ok || v == nil {
+			continue
+		}
+		id := fmt.Sprint(v)
+		if f, ok := v.(float64); ok {
+			id = fmt.Sprintf("%.0f", f)
+		}
+		if (key == "tweet_id" && d.tweets[id]) || (key != "tweet_id" && d.users[id]) {
+			return true
+		}
+	}
+	if name, ok := doc.Metadata["username"].(string); ok {
+		return d.handles[strings.ToLower(strings.TrimPrefix(name, "@"))]
+	}
+	return false
+}
+
+// Filter returns the documents not denied, reusing the slice
+func (d *Denylist) Filter(docs []types.Document) []types.Document {
+	if d.Len() == 0 {
+		return docs
+	}
+	kept := docs[:0]
+	for _, doc := range docs {
+		if !d.Denies(doc) {
+			kept = append(kept, doc)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,142 @@
+// Package envprofile selects a named environment, such as dev, staging or
+// prod, from a profiles file: the API URL and token, the directory runs are
+// written to, and any other variables such as the sink URLs. A profile is
+// applied over the environment and .env, so switching between test and
+// production endpoints is one --profile flag rather than an edited .env.
+package envprofile
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/grant/sn42/pkg/rundir"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFile is read when PROFILES is not set
+const DefaultFile = "profiles.yaml"
+
+var nameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Profile is one named environment
+type Profile struct {
+	Name     string            `yaml:"-"`
+	APIURL   string            `yaml:"api_url"`   // Sets GOPHER_CLIENT_URL
+	Proxy    string            `yaml:"proxy"`     // Sets GOPHER_CLIENT_PROXY
+	TokenEnv string            `yaml:"token_env"` // Variable holding the token, copied to GOPHER_CLIENT_TOKEN
+	Token    string            `yaml:"token"`     // The token itself, for test endpoints; prefer token_env
+	DataDir  string            `yaml:"data_dir"`  // Directory runs are written to instead of runs/
+	Env      map[string]string `yaml:"env"`       // Other variables, e.g. ES_URL or DATASET_LICENSE
+}
+
+// Path returns the profiles file from PROFILES, or the default
+func Path() string {
+	if path := os.Getenv("PROFILES"); path != "" {
+		return path
+	}
+	return DefaultFile
+}
+
+// Load reads the profile called name from a profiles file, a YAML mapping
+// of profile names to profiles
+func Load(path, name string) (*Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profiles: %w", err)
+	}
+	var profiles map[string]*Profile
+	if err := yaml.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("failed to parse profiles %s: %w", path, err)
+	}
+	for n := range profiles {
+		if !nameRegex.MatchString(n) {
+			return nil, fmt.Errorf("profile name %q must be lowercase letters, digits, - or _", n)
+		}
+	}
+	p := profiles[name]
+	if p == nil {
+		names := slices.Sorted(maps.Keys(profiles))
+		return nil, fmt.Errorf("no profile named %q in %s (available: %s)", name, path, strings.Join(names, ", "))
+	}
+	p.Name = name
+	if p.Token != "" && p.TokenEnv != "" {
+		return nil, fmt.Errorf("profile %s: set token or token_env, not both", name)
+	}
+	return p, nil
+}
+
+// Apply sets the profile's variables and data directory. Variables already
+// set, including by .env, are overridden. A token_env that is not set is
+// an error, so a profile never runs with another environment's token.
+func (p *Profile) Apply() error {
+	vars := map[string]string{}
+	maps.Copy(vars, p.Env)
+	if p.APIURL != "" {
+		vars["GOPHER_CLIENT_URL"] = p.APIURL
+	}
+	if p.Proxy != "" {
+		vars["GOPHER_CLIENT_PROXY"] = p.Proxy
+	}
+	switch {
+	case p.TokenEnv != "":
+		token := os.Getenv(p.TokenEnv)
+		if token == "" {
+			return fmt.Errorf("profile %s: %s is not set", p.Name, p.TokenEnv)
+		}
+		vars["GOPHER_CLIENT_TOKEN"] = token
+	case p.Token != "":
+		vars["GOPHER_CLIENT_TOKEN"] = p.Token
+	}
+	for k, v := range vars {
+		if err := os.Setenv(k, v); err != nil {
+			return fmt.Errorf("profile %s: failed to set %s: %w", p.Name, k, err)
+		}
+	}
+	if p.DataDir != "" {
+		rundir.Root = p.DataDir
+	}
+	return nil
+}
+
+// DataDir returns the directory the profile called name writes runs to,
+// without applying it, for tools that only read what runs left behind. An
+// empty name, or a profile without data_dir, gives the current Root.
+func DataDir(name string) (string, error) {
+	if name == "" {
+		return rundir.Root, nil
+	}
+	p, err := Load(Path(), name)
+	if err != nil {
+		return "", err
+	}
+	return cmp.Or(p.DataDir, rundir.Root), nil
+}
+
+// RegisterFlag registers -profile on fs
+func RegisterFlag(fs *flag.FlagSet) *string {
+	return fs.String("profile", "", "Use this environment from the profiles file (PROFILES, default "+DefaultFile+"): its API URL, token, data directory and sinks")
+}
+
+// Use loads and applies the profile called name and says which one is in
+// use. An empty name does nothing.
+func Use(name string) error {
+	if name == "" {
+		return nil
+	}
+	p, err := Load(Path(), name)
+	if err != nil {
+		return err
+	}
+	if err := p.Apply(); err != nil {
+		return err
+	}
+	url := cmp.Or(os.Getenv("GOPHER_CLIENT_URL"), "the default API URL")
+	fmt.Fprintf(os.Stderr, "🔧 Profile %s: %s, runs in %s\n", p.Name, url, rundir.Root)
+	return nil
+}
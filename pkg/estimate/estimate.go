@@ -0,0 +1,165 @@
+// Package estimate predicts what a collection will cost before it runs: the
+// API requests it makes, how long it takes and how large its output is,
+// from the pagination rules and the averages of past runs in the audit log.
+package estimate
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/grant/sn42/pkg/audit"
+	"github.com/grant/sn42/pkg/collect"
+	"github.com/grant/sn42/pkg/crypt"
+	"github.com/grant/sn42/pkg/manifest"
+)
+
+// Assumed when the audit log has no usable history
+const (
+	defaultSecondsPerTweet = 0.1  // A 100-tweet page every 10s
+	defaultBytesPerRecord  = 2500 // An indented tweet with its metadata
+)
+
+// History holds the averages of past runs
+type History struct {
+	Runs            int     // Finished runs with at least one successful query
+	Tweets          int     // Tweets fetched by their successful queries
+	SecondsPerTweet float64 // Collection time per fetched tweet, 0 if unknown
+	Datasets        int     // Datasets whose manifest could be read
+	BytesPerRecord  float64 // Dataset size per saved record, 0 if unknown
+}
+
+// FromAudit averages the finished runs in the audit log at path. Queries
+// that failed are left out, and so are datasets whose manifest is gone. A
+// missing log gives an empty History.
+func FromAudit(path string) (History, error) {
+	var h History
+	runs, err := audit.Read(path)
+	if err != nil {
+		return h, err
+	}
+	var seconds float64
+	var bytes int64
+	var records int
+	for _, r := range runs {
+		if r.Summary == nil {
+			continue
+		}
+		counted := false
+		for _, q := range r.Summary.Queries {
+			if q.Error != "" || q.Fetched == 0 {
+				continue
+			}
+			h.Tweets += q.Fetched
+			seconds += q.Seconds
+			counted = true
+		}
+		if counted {
+			h.Runs++
+		}
+		for _, dest := range r.Destinations {
+			if !strings.HasSuffix(strings.TrimSuffix(dest, crypt.Extension), ".json") || strings.HasSuffix(dest, ".manifest.json") {
+				continue
+			}
+			m, err := manifest.Load(dest)
+			if err != nil || m.Records == 0 {
+				continue
+			}
+			h.Datasets++
+			bytes += m.SizeBytes
+			records += m.Records
+		}
+	}
+	if h.Tweets > 0 && seconds > 0 {
+		h.SecondsPerTweet = seconds / float64(h.Tweets)
+	}
+	if records > 0 {
+		h.BytesPerRecord = float64(bytes) / float64(records)
+	}
+	return h, nil
+}
+
+// Options describes how the run will collect
+type Options struct {
+	Preflight         bool    // One 1-result request per query before collecting
+	ProbeRequests     int     // Requests per query for -expand probes
+	RequestsPerMinute float64 // Rate limit of the token (0 = unknown)
+}
+
+// Query is one query to estimate and its target
+type Query struct {
+	Query  string
+	Amount int
+}
+
+// QueryEstimate is the prediction for one query
+type QueryEstimate struct {
+	Query
+	Requests int
+	Seconds  float64
+	Bytes    int64
+}
+
+// Estimate is the prediction for a whole run
+type Estimate struct {
+	Queries  []QueryEstimate
+	Requests int
+	Seconds  float64
+	Bytes    int64
+	History  History
+	Limited  bool // The rate limit, not collection speed, sets the duration
+}
+
+// Compute predicts a run of queries from the history. Each query is assumed
+// to reach its amount, so the figures are upper bounds for queries that run
+// out of results.
+func Compute(queries []Query, h History, opts Options) Estimate {
+	perTweet := h.SecondsPerTweet
+	if perTweet == 0 {
+		perTweet = defaultSecondsPerTweet
+	}
+	perRecord := h.BytesPerRecord
+	if perRecord == 0 {
+		perRecord = defaultBytesPerRecord
+	}
+	e := Estimate{History: h}
+	for _, q := range queries {
+		qe := QueryEstimate{Query: q}
+		qe.Requests = (q.Amount+collect.APIMaxResults-1)/collect.APIMaxResults + opts.ProbeRequests
+		if opts.Preflight {
+			qe.Requests++
+		}
+		qe.Seconds = float64(q.Amount) * perTweet
+		qe.Bytes = int64(math.Round(float64(q.Amount) * perRecord))
+		e.Queries = append(e.Queries, qe)
+		e.Requests += qe.Requests
+		e.Seconds += qe.Seconds
+		e.Bytes += qe.Bytes
+	}
+	if opts.RequestsPerMinute > 0 {
+		if limited := float64(e.Requests) / opts.RequestsPerMinute * 60; limited > e.Seconds {
+			e.Seconds, e.Limited = limited, true
+		}
+	}
+	return e
+}
+
+// Duration returns the predicted duration of the run
+func (e Estimate) Duration() time.Duration {
+	return time.Duration(e.Seconds * float64(time.Second)).Round(time.Second)
+}
+
+// FormatBytes renders a size in B, KB, MB or GB
+func FormatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 2; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMG"[exp])
+}
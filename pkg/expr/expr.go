@@ -0,0 +1,535 @@
+// Package expr parses and evaluates the record expressions used by
+// `dataset query` and the transform rules of the collection pipeline, e.g.
+// 'likes > 500 && lang == "en"'.
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Expr is a parsed expression, evaluated against one record at a time.
+//
+//	expr       = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | "(" expr ")" | comparison
+//	comparison = operand [ ("==" | "!=" | "<" | "<=" | ">" | ">=" | "=~") operand ]
+//	operand    = field | number | string | true | false | null
+//
+// A bare operand is true unless it is missing, null, false, 0 or "". Fields
+// are named as for Field.
+type Expr struct {
+	source string
+	root   node
+}
+
+// node is one operator or operand of an expression
+type node interface {
+	eval(doc types.Document) any
+}
+
+type (
+	orExpr  struct{ left, right node }
+	andExpr struct{ left, right node }
+	notExpr struct{ e node }
+	cmpExpr struct {
+		op          string
+		left, right node
+	}
+	matchExpr struct {
+		left node
+		re   *regexp.Regexp
+	}
+	fieldExpr   struct{ path string }
+	literalExpr struct{ v any }
+)
+
+// Eval returns the value of the expression for doc
+func (e *Expr) Eval(doc types.Document) any {
+	return e.root.eval(doc)
+}
+
+// Match reports whether the expression is true for doc
+func (e *Expr) Match(doc types.Document) bool {
+	return Truthy(e.root.eval(doc))
+}
+
+func (e *Expr) String() string {
+	return e.source
+}
+
+func (e orExpr) eval(doc types.Document) any {
+	return Truthy(e.left.eval(doc)) || Truthy(e.right.eval(doc))
+}
+
+func (e andExpr) eval(doc types.Document) any {
+	return Truthy(e.left.eval(doc)) && Truthy(e.right.eval(doc))
+}
+
+func (e notExpr) eval(doc types.Document) any {
+	return !Truthy(e.e.eval(doc))
+}
+
+func (e cmpExpr) eval(doc types.Document) any {
+	return compare(e.op, e.left.eval(doc), e.right.eval(doc))
+}
+
+func (e literalExpr) eval(types.Document) any {
+	return e.v
+}
+
+func (e matchExpr) eval(doc types.Document) any {
+	switch v := e.left.eval(doc).(type) {
+	case string:
+		return e.re.MatchString(v)
+	case []any:
+		// A list matches when any of its strings does
+		for _, elem := range v {
+			if s, ok := elem.(string); ok && e.re.MatchString(s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (e fieldExpr) eval(doc types.Document) any {
+	v, _ := Field(doc, e.path)
+	return v
+}
+
+// Field returns the value at a dotted path such as "content" or
+// "metadata.likes". A name that is not a record field is looked up in the
+// metadata, so "lang" is short for "metadata.lang".
+func Field(doc types.Document, path string) (any, bool) {
+	name, rest, nested := strings.Cut(path, ".")
+	var v any
+	switch name {
+	case "id":
+		v = doc.Id
+	case "source":
+		v = string(doc.Source)
+	case "content":
+		v = doc.Content
+	case "updated_at":
+		v = doc.UpdatedAt
+	case "metadata":
+		if !nested {
+			return doc.Metadata, doc.Metadata != nil
+		}
+		return lookup(doc.Metadata, rest)
+	default:
+		return lookup(doc.Metadata, path)
+	}
+	return v, !nested
+}
+
+// lookup follows a dotted path through nested JSON objects
+func lookup(m map[string]any, path string) (any, bool) {
+	var v any = m
+	for name := range strings.SplitSeq(path, ".") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = obj[name]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// Truthy reports whether a value counts as true on its own
+func Truthy(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	}
+	if n, ok := number(v); ok {
+		return n != 0
+	}
+	return true
+}
+
+// number converts the numeric types records decode to
+func number(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case json.Number:
+		n, err := v.Float64()
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// ParseTime accepts RFC 3339 timestamps and plain dates
+func ParseTime(s string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// compare applies a comparison operator. Numbers compare by value and
+// strings that are both timestamps by time; values of different types are
+// never equal or ordered, and a missing field equals only null.
+func compare(op string, a, b any) bool {
+	c, ok := order(a, b)
+	switch op {
+	case "==":
+		return ok && c == 0
+	case "!=":
+		return !ok || c != 0
+	case "<":
+		return ok && c < 0
+	case "<=":
+		return ok && c <= 0
+	case ">":
+		return ok && c > 0
+	case ">=":
+		return ok && c >= 0
+	}
+	return false
+}
+
+// order compares two values, reporting false if they are not comparable
+func order(a, b any) (int, bool) {
+	if a == nil || b == nil {
+		return 0, a == nil && b == nil
+	}
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	switch x := a.(type) {
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		tx, okx := ParseTime(x)
+		ty, oky := ParseTime(y)
+		if okx && oky {
+			return tx.Compare(ty), true
+		}
+		if okx != oky {
+			return 0, false
+		}
+		return strings.Compare(x, y), true
+	case bool:
+		y, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		if x == y {
+			return 0, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
+
+// Parse parses an expression
+func Parse(s string) (*Expr, error) {
+	toks, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+	}
+	return &Expr{source: s, root: root}, nil
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+)
+
+type token struct {
+	kind tokKind
+	text string // Operator or identifier; unquoted value for strings
+	pos  int
+}
+
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "<", ">", "!", "(", ")"}
+
+func tokenize(s string) ([]token, error) {
+	var toks []token
+	i := 0
+tokens:
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+			continue
+		case c == '"' || c == '\'':
+			// Find the closing quote, skipping escaped characters
+			j := i + 1
+			for j < len(s) && s[j] != c {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			raw := s[i+1 : j]
+			if c == '\'' {
+				raw = strings.ReplaceAll(strings.ReplaceAll(raw, `\'`, `'`), `"`, `\"`)
+			}
+			v, err := strconv.Unquote(`"` + raw + `"`)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at offset %d: %w", i, err)
+			}
+			toks = append(toks, token{tokString, v, i})
+			i = j + 1
+			continue
+		case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(s) && (s[j] == '.' || s[j] == 'e' || s[j] == 'E' || (s[j] >= '0' && s[j] <= '9') ||
+				((s[j] == '-' || s[j] == '+') && (s[j-1] == 'e' || s[j-1] == 'E'))) {
+				j++
+			}
+			toks = append(toks, token{tokNumber, s[i:j], i})
+			i = j
+			continue
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || s[j] == '.' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			toks = append(toks, token{tokIdent, s[i:j], i})
+			i = j
+			continue
+		}
+		for _, op := range operators {
+			if strings.HasPrefix(s[i:], op) {
+				toks = append(toks, token{tokOp, op, i})
+				i += len(op)
+				continue tokens
+			}
+		}
+		return nil, fmt.Errorf("unexpected %q at offset %d", c, i)
+	}
+	return append(toks, token{tokEOF, "end of expression", len(s)}), nil
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the given operator
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokOp && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	for err == nil && p.accept("||") {
+		var right node
+		if right, err = p.and(); err == nil {
+			left = orExpr{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.unary()
+	for err == nil && p.accept("&&") {
+		var right node
+		if right, err = p.unary(); err == nil {
+			left = andExpr{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) unary() (node, error) {
+	if p.accept("!") {
+		e, err := p.unary()
+		return notExpr{e}, err
+	}
+	if p.accept("(") {
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.peek(); !p.accept(")") {
+			return nil, fmt.Errorf("expected \")\" at offset %d, got %q", t.pos, t.text)
+		}
+		return e, nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (node, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != tokOp {
+		return left, nil
+	}
+	switch t.text {
+	case "=~":
+		p.next()
+		pattern := p.next()
+		if pattern.kind != tokString {
+			return nil, fmt.Errorf("expected a regular expression string at offset %d, got %q", pattern.pos, pattern.text)
+		}
+		re, err := regexp.Compile(pattern.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression at offset %d: %w", pattern.pos, err)
+		}
+		return matchExpr{left, re}, nil
+	case "==", "!=", "<", "<=", ">", ">=":
+		p.next()
+		right, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return cmpExpr{t.text, left, right}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) operand() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return literalExpr{t.text}, nil
+	case tokNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at offset %d", t.text, t.pos)
+		}
+		return literalExpr{n}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return literalExpr{true}, nil
+		case "false":
+			return literalExpr{false}, nil
+		case "null":
+			return literalExpr{nil}, nil
+		}
+		return fieldExpr{t.text}, nil
+	}
+	return nil, fmt.Errorf("expected a field or value at offset %d, got %q", t.pos, t.text)
+}
+
+// Filter returns a dataset.Filter matching the expression. The top-level &&
+// conditions on lang, created_at and likes become column conditions, which
+// columnar readers check before building records; the whole expression is
+// still evaluated on every record passing them.
+func (e *Expr) Filter() dataset.Filter {
+	var f dataset.Filter
+	var walk func(n node)
+	walk = func(n node) {
+		switch e := n.(type) {
+		case andExpr:
+			walk(e.left)
+			walk(e.right)
+		case cmpExpr:
+			name, ok := e.left.(fieldExpr)
+			lit, isLit := e.right.(literalExpr)
+			if !ok || !isLit {
+				return
+			}
+			path := strings.TrimPrefix(name.path, "metadata.")
+			switch path {
+			case "lang":
+				if s, ok := lit.v.(string); ok && e.op == "==" {
+					f.Lang = s
+				}
+			case "likes":
+				// Tweets with fewer likes than the bound cannot match
+				if n, ok := lit.v.(float64); ok && (e.op == ">=" || e.op == ">" || e.op == "==") && n > 0 {
+					f.MinLikes = max(f.MinLikes, int64(n))
+				}
+			case "created_at":
+				s, _ := lit.v.(string)
+				t, ok := ParseTime(s)
+				if !ok {
+					return
+				}
+				switch e.op {
+				case ">=", ">":
+					if t.After(f.Since) {
+						f.Since = t
+					}
+				case "<":
+					if f.Until.IsZero() || t.Before(f.Until) {
+						f.Until = t
+					}
+				case "<=":
+					if t = t.Add(time.Nanosecond); f.Until.IsZero() || t.Before(f.Until) {
+						f.Until = t
+					}
+				}
+			}
+		}
+	}
+	walk(e.root)
+	f.Match = e.Match
+	return f
+}
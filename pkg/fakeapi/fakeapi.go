@@ -0,0 +1,231 @@
+// Package fakeapi serves the subset of the gopher API the tools use (job
+// submission, job status and job results on /v1/search/live) from the
+// pkg/mock fixtures, with configurable latency and injected failures, so the
+// collectors can be exercised end to end over real HTTP.
+package fakeapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grant/sn42/pkg/mock"
+	"github.com/masa-finance/tee-worker/v2/api/args/twitter"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+const jobEndpoint = "/v1/search/live"
+
+// Config controls how the server behaves
+type Config struct {
+	Token       string        // If set, requests must send "Authorization: Bearer <Token>"
+	Latency     time.Duration // Added to every response
+	JobDuration time.Duration // How long a job stays "in progress" after submission
+	FailRate    float64       // Fraction of submissions answered with 503
+	JobFailRate float64       // Fraction of accepted jobs that end with status "error"
+	MaxResults  int           // Submissions asking for more results time out with 504 (0 = no limit)
+	RateLimit   int           // Submissions accepted per RateWindow before answering 429 (0 = no limit)
+	RateWindow  time.Duration // Fixed window RateLimit counts over, reported in the X-RateLimit-Reset header
+	Seed        uint64        // Seeds the failure injection, so runs are repeatable
+}
+
+// Stats counts the requests the server has handled
+type Stats struct {
+	Submitted int `json:"submitted"`
+	Rejected  int `json:"rejected"` // 4xx and 5xx answers to submissions
+	Limited   int `json:"limited"`  // Submissions answered with 429, also counted in Rejected
+	Failed    int `json:"failed"`   // Jobs that ended with status "error"
+	Polls     int `json:"polls"`
+	Results   int `json:"results"`
+}
+
+type job struct {
+	docs     []types.Document
+	readyAt  time.Time
+	failed   bool
+	reported bool // Counted in Stats.Failed
+}
+
+// Server is an http.Handler implementing the fake API. It also serves its
+// Stats as JSON on /fake/stats.
+type Server struct {
+	cfg  Config
+	data *mock.Client
+	mux  *http.ServeMux
+
+	mu    sync.Mutex
+	rng   *rand.Rand
+	jobs  map[string]*job
+	seq   int
+	stats Stats
+
+	windowStart time.Time // Start of the current rate limit window
+	windowUsed  int       // Submissions counted in it
+}
+
+// New creates a server serving the mock fixtures
+func New(cfg Config) (*Server, error) {
+	data, err := mock.New()
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		cfg:  cfg,
+		data: data,
+		mux:  http.NewServeMux(),
+		rng:  rand.New(rand.NewPCG(cfg.Seed, cfg.Seed)),
+		jobs: make(map[string]*job),
+	}
+	s.mux.HandleFunc("POST "+jobEndpoint, s.submit)
+	s.mux.HandleFunc("GET "+jobEndpoint+"/status/{id}", s.status)
+	s.mux.HandleFunc("GET "+jobEndpoint+"/result/{id}", s.result)
+	s.mux.HandleFunc("GET /fake/stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, s.Stats())
+	})
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.Latency > 0 {
+		time.Sleep(s.cfg.Latency)
+	}
+	if s.cfg.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.cfg.Token {
+		writeError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// Stats returns the request counts so far
+func (s *Server) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+func (s *Server) submit(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Type      types.JobType           `json:"type"`
+		Arguments twitter.SearchArguments `json:"arguments"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Submitted++
+	if s.limited(w) {
+		s.stats.Rejected++
+		s.stats.Limited++
+		writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+	switch {
+	case err != nil:
+		s.stats.Rejected++
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case body.Type != types.TwitterJob:
+		s.stats.Rejected++
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unsupported job type %q", body.Type))
+		return
+	case s.cfg.MaxResults > 0 && body.Arguments.MaxResults > s.cfg.MaxResults:
+		s.stats.Rejected++
+		http.Error(w, "upstream timeout", http.StatusGatewayTimeout)
+		return
+	case s.rng.Float64() < s.cfg.FailRate:
+		s.stats.Rejected++
+		http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	docs, err := s.data.SearchTwitterWithArgs(body.Arguments)
+	if err != nil {
+		s.stats.Rejected++
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.seq++
+	id := fmt.Sprintf("fake-%d", s.seq)
+	s.jobs[id] = &job{docs: docs, readyAt: time.Now().Add(s.cfg.JobDuration), failed: s.rng.Float64() < s.cfg.JobFailRate}
+	writeJSON(w, types.ResultResponse{UUID: id})
+}
+
+// limited counts a submission against the rate limit, sets the rate limit
+// headers, and reports whether the limit was already used up
+func (s *Server) limited(w http.ResponseWriter) bool {
+	if s.cfg.RateLimit <= 0 {
+		return false
+	}
+	now := time.Now()
+	if now.Sub(s.windowStart) >= s.cfg.RateWindow {
+		s.windowStart, s.windowUsed = now, 0
+	}
+	reset := s.windowStart.Add(s.cfg.RateWindow)
+	h := w.Header()
+	h.Set("X-RateLimit-Limit", strconv.Itoa(s.cfg.RateLimit))
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+	if s.windowUsed >= s.cfg.RateLimit {
+		h.Set("X-RateLimit-Remaining", "0")
+		h.Set("Retry-After", strconv.Itoa(int(math.Ceil(reset.Sub(now).Seconds()))))
+		return true
+	}
+	s.windowUsed++
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(s.cfg.RateLimit-s.windowUsed))
+	return false
+}
+
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Polls++
+	j, ok := s.jobs[r.PathValue("id")]
+	switch {
+	case !ok:
+		writeError(w, http.StatusNotFound, "job not found")
+	case time.Now().Before(j.readyAt):
+		writeJSON(w, types.IndexerJobResult{Status: types.JobStatusActive})
+	case j.failed:
+		if !j.reported {
+			j.reported = true
+			s.stats.Failed++
+		}
+		writeJSON(w, types.IndexerJobResult{Status: types.JobStatusError, Error: "injected job failure"})
+	default:
+		writeJSON(w, types.IndexerJobResult{Status: types.JobStatusDone})
+	}
+}
+
+func (s *Server) result(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Results++
+	j, ok := s.jobs[r.PathValue("id")]
+	if !ok || j.failed || time.Now().Before(j.readyAt) {
+		writeError(w, http.StatusNotFound, "no results for job")
+		return
+	}
+	delete(s.jobs, r.PathValue("id"))
+	docs := j.docs
+	if docs == nil {
+		docs = []types.Document{}
+	}
+	writeJSON(w, docs)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError answers with the API's JSON error body
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": strings.TrimSpace(msg)})
+}
@@ -0,0 +1,156 @@
+// Package graph builds user interaction graphs (mentions, retweets and
+// replies) from collected tweets and writes them as GraphML or GEXF.
+package graph
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/grant/sn42/pkg/stats"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Edge types
+const (
+	Mention = "mention"
+	Retweet = "retweet"
+	Reply   = "reply"
+)
+
+// EdgeTypes lists the supported edge types
+var EdgeTypes = []string{Mention, Retweet, Reply}
+
+var (
+	retweetRegex = regexp.MustCompile(`^RT @(\w{1,15}):`)
+	replyRegex   = regexp.MustCompile(`^@(\w{1,15})\b`)
+)
+
+// Node is a user. Tweets counts the tweets they authored in the dataset;
+// users who were only mentioned, retweeted or replied to have none.
+type Node struct {
+	ID     string
+	Tweets int
+}
+
+// Edge is a directed interaction from one user to another, weighted by the
+// number of tweets in which it occurred
+type Edge struct {
+	Source, Target string
+	Type           string
+	Weight         int
+}
+
+// Graph is a directed multigraph of users with one edge per source, target
+// and type
+type Graph struct {
+	types map[string]bool
+	nodes map[string]*Node
+	edges map[Edge]int // Keyed with a zero Weight
+}
+
+// New returns an empty graph recording the given edge types (all if none)
+func New(edgeTypes ...string) (*Graph, error) {
+	if len(edgeTypes) == 0 {
+		edgeTypes = EdgeTypes
+	}
+	g := &Graph{types: map[string]bool{}, nodes: map[string]*Node{}, edges: map[Edge]int{}}
+	for _, t := range edgeTypes {
+		switch t {
+		case Mention, Retweet, Reply:
+			g.types[t] = true
+		default:
+			return nil, fmt.Errorf("unknown edge type %q (supported: %v)", t, EdgeTypes)
+		}
+	}
+	return g, nil
+}
+
+// Add records the interactions of one tweet. A retweet ("RT @user: ...")
+// links the author to the retweeted user and a reply (is_reply, or text
+// starting with @user) to the first user it addresses; other users mentioned
+// in the text get mention edges. Tweets without an author and self-loops are
+// ignored.
+func (g *Graph) Add(doc types.Document) {
+	author, _ := doc.Metadata["username"].(string)
+	author = strings.ToLower(author)
+	if author == "" {
+		return
+	}
+	g.node(author).Tweets++
+
+	target, kind := "", ""
+	if m := retweetRegex.FindStringSubmatch(doc.Content); m != nil {
+		target, kind = strings.ToLower(m[1]), Retweet
+	} else if m := replyRegex.FindStringSubmatch(doc.Content); m != nil && isReply(doc) {
+		target, kind = strings.ToLower(m[1]), Reply
+	}
+	if target != "" {
+		g.link(author, target, kind)
+	}
+
+	seen := map[string]bool{target: true}
+	for _, user := range stats.Mentions(doc) {
+		if !seen[user] {
+			seen[user] = true
+			g.link(author, user, Mention)
+		}
+	}
+}
+
+// isReply reports whether a tweet is a reply, going by its is_reply metadata
+// when present
+func isReply(doc types.Document) bool {
+	if v, ok := doc.Metadata["is_reply"].(bool); ok {
+		return v
+	}
+	return true
+}
+
+func (g *Graph) node(id string) *Node {
+	n := g.nodes[id]
+	if n == nil {
+		n = &Node{ID: id}
+		g.nodes[id] = n
+	}
+	return n
+}
+
+func (g *Graph) link(source, target, kind string) {
+	if source == target || !g.types[kind] {
+		return
+	}
+	g.node(target)
+	g.edges[Edge{Source: source, Target: target, Type: kind}]++
+}
+
+// Nodes returns the users sorted by ID
+func (g *Graph) Nodes() []Node {
+	nodes := make([]Node, 0, len(g.nodes))
+	for _, n := range g.nodes {
+		nodes = append(nodes, *n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes
+}
+
+// Edges returns the interactions sorted by source, target and type
+func (g *Graph) Edges() []Edge {
+	edges := make([]Edge, 0, len(g.edges))
+	for e, weight := range g.edges {
+		e.Weight = weight
+		edges = append(edges, e)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		a, b := edges[i], edges[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		return a.Type < b.Type
+	})
+	return edges
+}
@@ -0,0 +1,181 @@
+// Package health serves Kubernetes-style liveness and readiness endpoints for
+// collection runs. Liveness is tied to progress: a run that has not completed
+// an API request within the stall timeout reports itself unhealthy, so the
+// orchestrator can restart a stuck collector.
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultStallTimeout is how long a run may go without progress before
+// /healthz starts failing
+const DefaultStallTimeout = 10 * time.Minute
+
+// Monitor tracks run progress and readiness. A nil Monitor ignores all calls.
+type Monitor struct {
+	mu           sync.Mutex
+	stallTimeout time.Duration
+	started      time.Time
+	lastProgress time.Time
+	progress     int
+	ready        bool
+	circuitOpen  bool
+}
+
+// Status is the JSON body returned by both endpoints
+type Status struct {
+	Status       string `json:"status"`
+	Ready        bool   `json:"ready"`
+	Progress     int    `json:"progress"` // Completed API requests
+	LastProgress string `json:"last_progress"`
+	Uptime       string `json:"uptime"`
+	Circuit      string `json:"circuit,omitempty"` // "open" while requests are paused by the circuit breaker
+}
+
+// NewMonitor creates a monitor whose liveness fails after stallTimeout
+// without progress
+func NewMonitor(stallTimeout time.Duration) *Monitor {
+	now := time.Now()
+	return &Monitor{stallTimeout: stallTimeout, started: now, lastProgress: now}
+}
+
+// Beat records progress, e.g. a completed API request
+func (m *Monitor) Beat() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastProgress = time.Now()
+	m.progress++
+}
+
+// SetReady marks the run ready (set-up finished, collecting) or not ready
+func (m *Monitor) SetReady(ready bool) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ready = ready
+	// Waiting on set-up is not a stall, so the clock starts when collection does
+	if ready {
+		m.lastProgress = time.Now()
+	}
+}
+
+// SetCircuitOpen marks requests as paused by the circuit breaker, or resumed.
+// A paused run is not ready, but neither is it stalled.
+func (m *Monitor) SetCircuitOpen(open bool) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.circuitOpen = open
+	m.lastProgress = time.Now()
+}
+
+func (m *Monitor) status() (Status, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	alive := m.circuitOpen || now.Sub(m.lastProgress) <= m.stallTimeout
+	s := Status{
+		Status:       "ok",
+		Ready:        m.ready && !m.circuitOpen,
+		Progress:     m.progress,
+		LastProgress: m.lastProgress.UTC().Format(time.RFC3339),
+		Uptime:       now.Sub(m.started).Round(time.Second).String(),
+	}
+	if m.circuitOpen {
+		s.Circuit = "open"
+	}
+	if !alive {
+		s.Status = fmt.Sprintf("stalled: no progress for %s", now.Sub(m.lastProgress).Round(time.Second))
+	}
+	return s, alive
+}
+
+// Handler serves /healthz (liveness) and /readyz (readiness). Both return 200
+// when the check passes and 503 otherwise, with a Status body.
+func (m *Monitor) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		s, alive := m.status()
+		writeStatus(w, s, alive)
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		s, alive := m.status()
+		switch {
+		case s.Circuit == "open":
+			s.Status = "paused: circuit open"
+		case !s.Ready && alive:
+			s.Status = "not ready"
+		}
+		writeStatus(w, s, alive && s.Ready)
+	})
+	return mux
+}
+
+func writeStatus(w http.ResponseWriter, s Status, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(s)
+}
+
+// Serve listens on addr and serves the monitor's endpoints in the background.
+// It returns once the listener is bound, so a bad address fails immediately.
+func Serve(addr string, m *Monitor) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	srv := &http.Server{Handler: m.Handler(), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("⚠️ Health server stopped: %v\n", err)
+		}
+	}()
+	return nil
+}
+
+// Flags holds the health endpoint command-line flags shared by the collectors
+type Flags struct {
+	Addr         string
+	StallTimeout time.Duration
+}
+
+// RegisterFlags registers -health-addr and -stall-timeout on fs
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	fs.StringVar(&f.Addr, "health-addr", "", "Serve /healthz and /readyz on this address, e.g. :8081 (disabled if empty)")
+	fs.DurationVar(&f.StallTimeout, "stall-timeout", DefaultStallTimeout, "Fail /healthz after this long without a completed API request")
+	return f
+}
+
+// Start serves the endpoints if -health-addr is set. It returns a nil Monitor
+// when they are disabled.
+func (f *Flags) Start() (*Monitor, error) {
+	if f.Addr == "" {
+		return nil, nil
+	}
+	if f.StallTimeout <= 0 {
+		return nil, fmt.Errorf("-stall-timeout must be positive, got %s", f.StallTimeout)
+	}
+	m := NewMonitor(f.StallTimeout)
+	if err := Serve(f.Addr, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
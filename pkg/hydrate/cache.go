@@ -0,0 +1,83 @@
+package hydrate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// DefaultCache is the profile cache shared by every dataset
+const DefaultCache = "data/users/profiles.json"
+
+// Lookup statuses
+const (
+	Found    = "found"
+	NotFound = "not_found" // Suspended, deleted or renamed
+	Failed   = "failed"    // The lookup kept failing; never cached
+)
+
+// Entry is the outcome of looking up one author
+type Entry struct {
+	Status    string          `json:"status"`
+	FetchedAt string          `json:"fetched_at"`
+	Profile   *types.Document `json:"profile,omitempty"`
+	Error     string          `json:"error,omitempty"` // Last error of a Failed lookup
+}
+
+// Fresh reports whether the entry was fetched less than maxAge before now
+func (e *Entry) Fresh(maxAge time.Duration, now time.Time) bool {
+	at, err := time.Parse(time.RFC3339, e.FetchedAt)
+	return err == nil && now.Sub(at) < maxAge
+}
+
+// Cache holds the profiles looked up so far, by author key
+type Cache struct {
+	path     string
+	Profiles map[string]*Entry `json:"profiles"`
+}
+
+// LoadCache reads the cache file at path. A missing file is an empty cache.
+func LoadCache(path string) (*Cache, error) {
+	c := &Cache{path: path, Profiles: map[string]*Entry{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profile cache: %w", err)
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse profile cache %s: %w", path, err)
+	}
+	if c.Profiles == nil {
+		c.Profiles = map[string]*Entry{}
+	}
+	return c, nil
+}
+
+// Save writes the cache file, replacing it in one step
+func (c *Cache) Save() error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("failed to create profile cache directory: %w", err)
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal profile cache: %w", err)
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write profile cache: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write profile cache: %w", err)
+	}
+	return nil
+}
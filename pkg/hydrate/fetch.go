@@ -0,0 +1,53 @@
+package hydrate
+
+import (
+	"context"
+	"time"
+
+	"github.com/grant/sn42/pkg/collect"
+	"github.com/grant/sn42/pkg/compliance"
+	"github.com/masa-finance/tee-worker/v2/api/args/twitter"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Fetcher looks up profiles, by username with the getprofile capability or
+// by user ID with getprofilebyid
+type Fetcher struct {
+	collect.Lookup
+}
+
+// Fetch looks up every author and returns the entries in the same order.
+// Stopping ctx leaves the remaining authors Failed.
+func (f *Fetcher) Fetch(ctx context.Context, authors []*Author) []*Entry {
+	entries := make([]*Entry, len(authors))
+	f.Each(ctx, len(authors), func(i int) {
+		entries[i] = f.lookup(ctx, authors[i])
+	})
+
+	for i, e := range entries {
+		if e == nil {
+			entries[i] = &Entry{Status: Failed, Error: ctx.Err().Error()}
+		}
+	}
+	return entries
+}
+
+// lookup fetches one profile
+func (f *Fetcher) lookup(ctx context.Context, a *Author) *Entry {
+	args := twitter.NewSearchArguments()
+	args.Type, args.Query = types.CapGetProfile, a.Username
+	if a.Username == "" {
+		args.Type, args.Query = types.CapGetProfileById, a.UserID
+	}
+	args.MaxResults = 1
+
+	results, err := f.Search(ctx, args, compliance.IsUnavailable)
+	now := time.Now().UTC().Format(time.RFC3339)
+	switch {
+	case err == nil && len(results) > 0:
+		return &Entry{Status: Found, FetchedAt: now, Profile: &results[0]}
+	case err == nil, compliance.IsUnavailable(err):
+		return &Entry{Status: NotFound, FetchedAt: now}
+	}
+	return &Entry{Status: Failed, FetchedAt: now, Error: err.Error()}
+}
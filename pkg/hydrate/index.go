@@ -0,0 +1,74 @@
+// Package hydrate hydrates the authors of collected tweets with their current
+// profiles, into a users table that joins back to the tweets. Profiles are
+// cached, so later runs only look up authors that are new or stale.
+package hydrate
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Author is one author of the tweets in a dataset
+type Author struct {
+	Key      string // Join key: the lowercase username, or id:<user ID> without one
+	Username string
+	UserID   string
+	Tweets   int // Tweets by the author in the dataset
+}
+
+// Index collects the distinct authors of tweets
+type Index struct {
+	authors map[string]*Author
+}
+
+// NewIndex returns an empty index
+func NewIndex() *Index {
+	return &Index{authors: map[string]*Author{}}
+}
+
+// Add counts a tweet towards its author. Tweets without a username or user
+// ID are skipped.
+func (x *Index) Add(doc types.Document) {
+	username, _ := doc.Metadata["username"].(string)
+	username = strings.TrimPrefix(strings.TrimSpace(username), "@")
+	userID := cmp.Or(dataset.MetadataID(doc, "user_id"), dataset.MetadataID(doc, "author_id"))
+	key := Key(username, userID)
+	if key == "" {
+		return
+	}
+	a := x.authors[key]
+	if a == nil {
+		a = &Author{Key: key, Username: username}
+		x.authors[key] = a
+	}
+	a.UserID = cmp.Or(a.UserID, userID)
+	a.Tweets++
+}
+
+// Authors returns the authors, most tweets first (ties by key)
+func (x *Index) Authors() []*Author {
+	list := make([]*Author, 0, len(x.authors))
+	for _, a := range x.authors {
+		list = append(list, a)
+	}
+	slices.SortFunc(list, func(a, b *Author) int {
+		return cmp.Or(cmp.Compare(b.Tweets, a.Tweets), cmp.Compare(a.Key, b.Key))
+	})
+	return list
+}
+
+// Key returns the join key of an author: the lowercase username, or
+// id:<userID> if the username is unknown
+func Key(username, userID string) string {
+	if username != "" {
+		return strings.ToLower(username)
+	}
+	if userID != "" {
+		return "id:" + userID
+	}
+	return ""
+}
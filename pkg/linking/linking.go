@@ -0,0 +1,200 @@
+// Package linking finds the links, hashtags and topics that records from
+// different platforms share, and gives each such entity a stable ID, so a
+// merged dataset can be cut into cross-platform topic datasets.
+package linking
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/grant/sn42/pkg/query"
+	"github.com/grant/sn42/pkg/stats"
+	"github.com/grant/sn42/pkg/unified"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Entity kinds
+const (
+	URL     = "url"
+	Hashtag = "hashtag"
+	Topic   = "topic" // A trend or matched keyword
+)
+
+// Entity is a link, hashtag or topic, with its value normalized so that the
+// same entity found on different platforms compares equal
+type Entity struct {
+	ID    string `json:"id"`
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// NewEntity returns the entity of a kind and normalized value. Its ID is a
+// hash of both, so it is the same in every run and every dataset.
+func NewEntity(kind, value string) Entity {
+	sum := sha256.Sum256([]byte(kind + ":" + value))
+	return Entity{ID: "ent_" + hex.EncodeToString(sum[:6]), Kind: kind, Value: value}
+}
+
+// Extract returns the distinct entities of a record: the links in its text or
+// urls metadata, its hashtags, and its trend and matched keywords. Records
+// that keep their text in metadata, such as Reddit posts, are read through
+// the unified schema.
+func Extract(doc types.Document) []Entity {
+	if doc.Content == "" {
+		doc.Content = unified.FromDocument(doc).Text
+	}
+	var entities []Entity
+	seen := map[Entity]bool{}
+	add := func(kind, value string) {
+		if value == "" {
+			return
+		}
+		e := NewEntity(kind, value)
+		if !seen[e] {
+			seen[e] = true
+			entities = append(entities, e)
+		}
+	}
+	for _, link := range stats.Links(doc) {
+		add(URL, NormalizeURL(link))
+	}
+	for _, tag := range query.Hashtags(doc) {
+		add(Hashtag, tag)
+	}
+	if trend, ok := doc.Metadata["trend"].(string); ok {
+		add(Topic, normalizeTopic(trend))
+	}
+	if keywords, ok := doc.Metadata["matched_keywords"].([]any); ok {
+		for _, k := range keywords {
+			if s, ok := k.(string); ok {
+				add(Topic, normalizeTopic(s))
+			}
+		}
+	}
+	return entities
+}
+
+// NormalizeURL reduces a link to its host (without www.) and path, without
+// the scheme, fragment, trailing slash or utm_ tracking parameters, so links
+// to the same page shared on different platforms match. Other query
+// parameters are kept, sorted.
+func NormalizeURL(link string) string {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	params := u.Query()
+	for k := range params {
+		if strings.HasPrefix(strings.ToLower(k), "utm_") {
+			params.Del(k)
+		}
+	}
+	s := host + strings.TrimSuffix(u.EscapedPath(), "/")
+	if q := params.Encode(); q != "" {
+		s += "?" + q
+	}
+	return s
+}
+
+// normalizeTopic lowercases a topic and drops a leading # or $
+func normalizeTopic(s string) string {
+	return strings.TrimLeft(strings.ToLower(strings.TrimSpace(s)), "#$")
+}
+
+// Index counts the sources each entity appears in over a first pass across a
+// merged dataset
+type Index struct {
+	sources map[Entity]map[types.Source]int
+}
+
+// NewIndex returns an empty Index
+func NewIndex() *Index {
+	return &Index{sources: map[Entity]map[types.Source]int{}}
+}
+
+// Add counts the entities of one record under its source
+func (x *Index) Add(doc types.Document) {
+	source := doc.Source
+	if source == types.UnknownSource {
+		source = types.TwitterSource
+	}
+	for _, e := range Extract(doc) {
+		if x.sources[e] == nil {
+			x.sources[e] = map[types.Source]int{}
+		}
+		x.sources[e][source]++
+	}
+}
+
+// Linked is an entity shared across sources, with its record count per source
+type Linked struct {
+	Entity
+	Sources map[types.Source]int `json:"sources"`
+	Records int                  `json:"records"`
+}
+
+// Linked returns the entities found in at least minSources sources, those in
+// the most records first (ties by ID)
+func (x *Index) Linked(minSources int) []Linked {
+	var linked []Linked
+	for e, sources := range x.sources {
+		if len(sources) < minSources {
+			continue
+		}
+		l := Linked{Entity: e, Sources: sources}
+		for _, n := range sources {
+			l.Records += n
+		}
+		linked = append(linked, l)
+	}
+	sort.Slice(linked, func(i, j int) bool {
+		if linked[i].Records != linked[j].Records {
+			return linked[i].Records > linked[j].Records
+		}
+		return linked[i].ID < linked[j].ID
+	})
+	return linked
+}
+
+// Tagger tags records with the IDs of the cross-source entities they contain
+type Tagger struct {
+	linked map[string]bool
+}
+
+// NewTagger returns a Tagger for the given linked entities
+func NewTagger(linked []Linked) *Tagger {
+	t := &Tagger{linked: map[string]bool{}}
+	for _, l := range linked {
+		t.linked[l.ID] = true
+	}
+	return t
+}
+
+// Tag sets the record's entity_ids metadata to the IDs of the linked
+// entities it contains, sorted, and reports whether there were any. Records
+// without any are left unchanged.
+func (t *Tagger) Tag(doc *types.Document) bool {
+	var ids []string
+	for _, e := range Extract(*doc) {
+		if t.linked[e.ID] {
+			ids = append(ids, e.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return false
+	}
+	sort.Strings(ids)
+	if doc.Metadata == nil {
+		doc.Metadata = map[string]any{}
+	}
+	tagged := make([]any, len(ids))
+	for i, id := range ids {
+		tagged[i] = id
+	}
+	doc.Metadata["entity_ids"] = tagged
+	return true
+}
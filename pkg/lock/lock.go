@@ -0,0 +1,172 @@
+// Package lock keeps concurrent runs from collecting the same query, or
+// writing the same file, at the same time. Locks are files under runs/.locks
+// recording who holds them; a lock left behind by a process that is no
+// longer running is taken over.
+package lock
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/grant/sn42/pkg/rundir"
+)
+
+// Dir returns the directory holding the lock files
+func Dir() string {
+	return filepath.Join(rundir.Root, ".locks")
+}
+
+// pollInterval is how often a waiting run checks whether a lock was released
+const pollInterval = 2 * time.Second
+
+// Holder describes the run holding a lock
+type Holder struct {
+	Tool     string `json:"tool"`
+	Resource string `json:"resource"` // The query or file locked
+	Run      string `json:"run,omitempty"`
+	PID      int    `json:"pid"`
+	Host     string `json:"host"`
+	Since    string `json:"since"`
+}
+
+// LockedError is returned when another run holds the lock
+type LockedError struct {
+	Holder Holder
+}
+
+func (e *LockedError) Error() string {
+	h := e.Holder
+	return fmt.Sprintf("%s is in use by %s (run %s, pid %d on %s) since %s; wait for it to finish or pass -lock-wait", h.Resource, h.Tool, h.Run, h.PID, h.Host, h.Since)
+}
+
+// Lock is a held lock
+type Lock struct {
+	path string
+}
+
+// Query locks a query for tool
+func Query(tool, run, query string, wait time.Duration) (*Lock, error) {
+	return acquire(key("query", query), Holder{Tool: tool, Resource: "query " + query, Run: run}, wait)
+}
+
+// File locks a file for tool
+func File(tool, run, path string, wait time.Duration) (*Lock, error) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return acquire(key("file", path), Holder{Tool: tool, Resource: path, Run: run}, wait)
+}
+
+// key names the lock file of a resource
+func key(kind, resource string) string {
+	sum := sha256.Sum256([]byte(resource))
+	return filepath.Join(Dir(), kind+"-"+hex.EncodeToString(sum[:8])+".lock")
+}
+
+// acquire creates the lock file at path, waiting up to wait for its holder
+// to release it
+func acquire(path string, h Holder, wait time.Duration) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	h.PID = os.Getpid()
+	h.Host, _ = os.Hostname()
+	h.Since = time.Now().UTC().Format(time.RFC3339)
+	data, err := json.Marshal(h)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal lock: %w", err)
+	}
+
+	deadline := time.Now().Add(wait)
+	waiting := false
+	for {
+		err := create(path, data)
+		if err == nil {
+			return &Lock{path: path}, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("failed to create lock: %w", err)
+		}
+		holder, ok := read(path)
+		if ok && stale(holder) {
+			fmt.Printf("🔓 Taking over the lock on %s left by pid %d, which is no longer running\n", h.Resource, holder.PID)
+			os.Remove(path)
+			continue
+		}
+		if !ok {
+			// Released in the meantime, or still being written
+			holder = Holder{Tool: "another run", Resource: h.Resource}
+		}
+		if time.Now().After(deadline) {
+			return nil, &LockedError{Holder: holder}
+		}
+		if !waiting {
+			fmt.Printf("⏳ %s is in use by %s (pid %d); waiting up to %s...\n", h.Resource, holder.Tool, holder.PID, time.Until(deadline).Round(time.Second))
+			waiting = true
+		}
+		time.Sleep(min(pollInterval, time.Until(deadline)))
+	}
+}
+
+// create writes the lock file, failing if it exists
+func create(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+func read(path string) (Holder, bool) {
+	var h Holder
+	data, err := os.ReadFile(path)
+	if err != nil || json.Unmarshal(data, &h) != nil {
+		return h, false
+	}
+	return h, true
+}
+
+// stale reports whether the holder was a process on this host that has exited
+func stale(h Holder) bool {
+	host, _ := os.Hostname()
+	if h.Host != host || h.PID <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(h.PID)
+	if err != nil {
+		return true
+	}
+	if runtime.GOOS == "windows" {
+		// FindProcess only succeeds for running processes there
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err != nil && !errors.Is(err, syscall.EPERM)
+}
+
+// Release removes the lock; later calls, and calls on a nil Lock, do nothing
+func (l *Lock) Release() error {
+	if l == nil || l.path == "" {
+		return nil
+	}
+	path := l.path
+	l.path = ""
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to release lock: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+// Package manifest writes the sidecar files that describe a dataset file: a
+// machine-readable manifest (<name>.manifest.json) and a human-readable dataset
+// card (<name>.card.md). Both carry the provenance configured for the
+// collector, so released datasets always state their license and origin.
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/grant/sn42/pkg/crypt"
+	"github.com/grant/sn42/pkg/stats"
+)
+
+// Provenance describes who collected a dataset and under which terms it may be used
+type Provenance struct {
+	License     string `json:"license,omitempty"`
+	Collector   string `json:"collector,omitempty"`
+	IntendedUse string `json:"intended_use,omitempty"`
+}
+
+// ProvenanceFromEnv reads provenance fields from DATASET_LICENSE,
+// DATASET_COLLECTOR and DATASET_INTENDED_USE
+func ProvenanceFromEnv() Provenance {
+	return Provenance{
+		License:     os.Getenv("DATASET_LICENSE"),
+		Collector:   os.Getenv("DATASET_COLLECTOR"),
+		IntendedUse: os.Getenv("DATASET_INTENDED_USE"),
+	}
+}
+
+// Manifest describes one dataset file
+type Manifest struct {
+	Dataset    string     `json:"dataset"` // Base name of the dataset file
+	Tool       string     `json:"tool"`    // Command that produced the file
+	Query      string     `json:"query"`
+	SavedQuery string     `json:"saved_query,omitempty"` // Name of the saved query that was run
+	Trend      string     `json:"trend,omitempty"`
+	Records    int        `json:"records"`
+	SizeBytes  int64      `json:"size_bytes"`
+	SHA256     string     `json:"sha256"`
+	Encrypted  bool       `json:"encrypted"`
+	CreatedAt  string     `json:"created_at"`
+	Pipeline   []string   `json:"pipeline,omitempty"` // Processing stages applied before writing
+	Seed       *uint64    `json:"seed,omitempty"`     // Random seed used for sampling, if any
+	Provenance Provenance `json:"provenance"`
+
+	// Tokens is filled in by `stats -tokens -update-manifest`
+	Tokens *stats.TokenStats `json:"tokens,omitempty"`
+}
+
+// Load reads the manifest of a dataset file
+func Load(dataPath string) (*Manifest, error) {
+	data, err := os.ReadFile(Path(dataPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// Write fills in the file-derived fields of m (name, size, checksum) from the
+// dataset at dataPath and writes the manifest and dataset card next to it.
+// It returns the path of the manifest file.
+func Write(dataPath string, m *Manifest) (string, error) {
+	f, err := os.Open(dataPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open dataset: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash dataset: %w", err)
+	}
+
+	m.Dataset = filepath.Base(dataPath)
+	m.SizeBytes = size
+	m.SHA256 = hex.EncodeToString(h.Sum(nil))
+	m.Encrypted = strings.HasSuffix(dataPath, crypt.Extension)
+	if m.CreatedAt == "" {
+		m.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	manifestPath := Path(dataPath)
+	tmp := manifestPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := os.Rename(tmp, manifestPath); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	if err := writeCard(CardPath(dataPath), m); err != nil {
+		return "", err
+	}
+	return manifestPath, nil
+}
+
+// Path returns the manifest path for a dataset file
+func Path(dataPath string) string {
+	return stem(dataPath) + ".manifest.json"
+}
+
+// CardPath returns the dataset card path for a dataset file
+func CardPath(dataPath string) string {
+	return stem(dataPath) + ".card.md"
+}
+
+// stem strips the .json and .enc extensions from a dataset path
+func stem(dataPath string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(dataPath, crypt.Extension), ".json")
+}
+
+var cardTemplate = template.Must(template.New("card").Parse(`# Dataset card: {{.Dataset}}
+
+{{if .Trend}}Tweets collected for the trending topic **{{.Trend}}**{{else}}Tweets collected{{end}} from the Gopher AI subnet API with ` + "`{{.Tool}}`" + `.
+
+## Provenance
+
+| Field | Value |
+|-------|-------|
+| License | {{or .Provenance.License "not specified"}} |
+| Collector | {{or .Provenance.Collector "not specified"}} |
+| Intended use | {{or .Provenance.IntendedUse "not specified"}} |
+
+## Collection
+
+| Field | Value |
+|-------|-------|
+| Query | ` + "`{{.Query}}`" + ` |
+{{- if .SavedQuery}}
+| Saved query | {{.SavedQuery}} |
+{{- end}}
+| Records | {{.Records}} |
+| Created at | {{.CreatedAt}} |
+| File size | {{.SizeBytes}} bytes |
+| SHA-256 | ` + "`{{.SHA256}}`" + ` |
+| Encrypted | {{.Encrypted}} |
+{{- if .Pipeline}}
+| Processing | {{range $i, $s := .Pipeline}}{{if $i}}, {{end}}` + "`{{$s}}`" + `{{end}} |
+{{- end}}
+{{- with .Seed}}
+| Random seed | {{.}} |
+{{- end}}
+{{- with .Tokens}}
+| Tokens ({{.Tokenizer}}) | {{.Total}} total, {{printf "%.1f" .Average}} average, {{.Max}} max |
+{{- end}}
+`))
+
+func writeCard(path string, m *Manifest) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create dataset card: %w", err)
+	}
+	if err := cardTemplate.Execute(f, m); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write dataset card: %w", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,195 @@
+// Package mock stands in for the gopher API with canned trends and tweets
+// from testdata, so the collectors can run end to end (pagination, the
+// processing pipeline, sinks) without a token or network access.
+package mock
+
+import (
+	"cmp"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/masa-finance/tee-worker/v2/api/args/twitter"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+var (
+	//go:embed testdata/tweets.json
+	tweetsJSON []byte
+	//go:embed testdata/trends.json
+	trendsJSON []byte
+)
+
+// Client serves searches from the fixture tweets. It satisfies
+// collect.Searcher.
+type Client struct {
+	tweets []types.Document // Newest first, like the API
+	trends []mockTrend
+}
+
+// mockTrend is a fixture trend, with the tweet volume the API may report
+type mockTrend struct {
+	Name        string `json:"name"`
+	TweetVolume int64  `json:"tweet_volume,omitempty"`
+}
+
+// New loads the fixtures
+func New() (*Client, error) {
+	c := &Client{}
+	if err := json.Unmarshal(tweetsJSON, &c.tweets); err != nil {
+		return nil, fmt.Errorf("failed to parse mock tweets: %w", err)
+	}
+	if err := json.Unmarshal(trendsJSON, &c.trends); err != nil {
+		return nil, fmt.Errorf("failed to parse mock trends: %w", err)
+	}
+	slices.SortFunc(c.tweets, func(a, b types.Document) int {
+		return cmp.Compare(tweetID(b), tweetID(a))
+	})
+	return c, nil
+}
+
+// Trends returns the fixture trends as the documents of a GetTrends job
+func (c *Client) Trends() []types.Document {
+	docs := make([]types.Document, len(c.trends))
+	for i, t := range c.trends {
+		docs[i] = types.Document{Id: t.Name, Content: t.Name, Source: types.TwitterSource}
+		if t.TweetVolume > 0 {
+			docs[i].Metadata = map[string]any{"tweet_volume": float64(t.TweetVolume)}
+		}
+	}
+	return docs
+}
+
+// tokenRe splits a query into quoted phrases and other tokens
+var tokenRe = regexp.MustCompile(`"[^"]*"|\S+`)
+
+// SearchTwitterWithArgs returns the fixture tweets matching args.Query,
+// newest first, up to args.MaxResults, or the trends, a tweet by ID, the
+// retweeters of a tweet or a profile for those job types. A tweet matches when its text
+// contains any of the query's words or quoted phrases; of the operators,
+// only max_id, since_id and min_faves are applied.
+func (c *Client) SearchTwitterWithArgs(args twitter.SearchArguments) ([]types.Document, error) {
+	if args.Type == types.CapGetTrends {
+		return c.Trends(), nil
+	}
+	if args.Type == types.CapGetById {
+		for _, t := range c.tweets {
+			if t.Id == args.Query {
+				t.Metadata = maps.Clone(t.Metadata)
+				return []types.Document{t}, nil
+			}
+		}
+		return nil, nil
+	}
+
+	if args.Type == types.CapGetRetweeters {
+		return c.retweeters(args.Query, args.MaxResults), nil
+	}
+	if args.Type == types.CapGetProfile || args.Type == types.CapGetProfileById {
+		return c.profile(args.Query), nil
+	}
+
+	var terms []string
+	var maxID, sinceID int64
+	minFaves := 0
+	for _, tok := range tokenRe.FindAllString(args.Query, -1) {
+		switch op, value, _ := strings.Cut(tok, ":"); {
+		case op == "max_id":
+			maxID, _ = strconv.ParseInt(value, 10, 64)
+		case op == "since_id":
+			sinceID, _ = strconv.ParseInt(value, 10, 64)
+		case op == "min_faves":
+			minFaves, _ = strconv.Atoi(value)
+		case strings.HasPrefix(tok, `"`):
+			terms = append(terms, strings.ToLower(strings.Trim(tok, `"`)))
+		case strings.Contains(tok, ":"), tok == "OR", tok == "AND", strings.HasPrefix(tok, "-"):
+			// Other operators and exclusions are ignored
+		default:
+			terms = append(terms, strings.ToLower(strings.Trim(tok, "()")))
+		}
+	}
+
+	limit := args.MaxResults
+	if limit <= 0 {
+		limit = 10
+	}
+	var results []types.Document
+	for _, t := range c.tweets {
+		if len(results) == limit {
+			break
+		}
+		if maxID > 0 && tweetID(t) > maxID {
+			continue
+		}
+		if sinceID > 0 && tweetID(t) <= sinceID {
+			continue
+		}
+		if likes, ok := t.Metadata["likes"].(float64); ok && int(likes) < minFaves {
+			continue
+		}
+		if matches(strings.ToLower(t.Content), terms) {
+			// Stages may edit metadata, which must not change the fixtures
+			t.Metadata = maps.Clone(t.Metadata)
+			results = append(results, t)
+		}
+	}
+	return results, nil
+}
+
+// retweeters makes up the retweeters of a tweet: the profiles of the other
+// fixture authors, up to limit
+func (c *Client) retweeters(id string, limit int) []types.Document {
+	var author string
+	for _, t := range c.tweets {
+		if t.Id == id {
+			author, _ = t.Metadata["username"].(string)
+		}
+	}
+	var profiles []types.Document
+	seen := map[string]bool{author: true}
+	for _, t := range c.tweets {
+		name, _ := t.Metadata["username"].(string)
+		if len(profiles) == cmp.Or(limit, 10) {
+			break
+		}
+		if name != "" && !seen[name] {
+			seen[name] = true
+			profiles = append(profiles, types.Document{Id: "user-" + name, Source: types.TwitterSource, Content: name, Metadata: map[string]any{"username": name}})
+		}
+	}
+	return profiles
+}
+
+// profile makes up the profile of a fixture author, looked up by username or
+// by the user-<username> ID the retweeters have; other users are not found
+func (c *Client) profile(user string) []types.Document {
+	for _, t := range c.tweets {
+		name, _ := t.Metadata["username"].(string)
+		if name != "" && (strings.EqualFold(name, user) || user == "user-"+name) {
+			return []types.Document{{Id: "user-" + name, Source: types.TwitterSource, Content: name, Metadata: map[string]any{"username": name}}}
+		}
+	}
+	return nil
+}
+
+func matches(text string, terms []string) bool {
+	if len(terms) == 0 {
+		return true
+	}
+	for _, term := range terms {
+		if term != "" && strings.Contains(text, term) {
+			return true
+		}
+	}
+	return false
+}
+
+func tweetID(t types.Document) int64 {
+	id, _ := strconv.ParseInt(t.Id, 10, 64)
+	return id
+}
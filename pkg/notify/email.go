@@ -0,0 +1,200 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/grant/sn42/pkg/report"
+)
+
+// EmailConfig configures the SMTP notifier
+type EmailConfig struct {
+	Host     string   // SMTP server host
+	Port     string   // SMTP port; 465 uses implicit TLS, others STARTTLS when offered
+	Username string   // Optional SMTP username
+	Password string   // Optional SMTP password
+	From     string   // Sender address
+	To       []string // Recipient addresses
+}
+
+// Email sends the run summary by email, with summary.json and summary.md attached
+type Email struct {
+	cfg EmailConfig
+}
+
+// NewEmailFromEnv creates an email notifier from NOTIFY_SMTP_* and
+// NOTIFY_EMAIL_* environment variables
+func NewEmailFromEnv() (*Email, error) {
+	var to []string
+	for _, addr := range strings.Split(os.Getenv("NOTIFY_EMAIL_TO"), ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+	return NewEmail(EmailConfig{
+		Host:     os.Getenv("NOTIFY_SMTP_HOST"),
+		Port:     os.Getenv("NOTIFY_SMTP_PORT"),
+		Username: os.Getenv("NOTIFY_SMTP_USERNAME"),
+		Password: os.Getenv("NOTIFY_SMTP_PASSWORD"),
+		From:     os.Getenv("NOTIFY_EMAIL_FROM"),
+		To:       to,
+	})
+}
+
+// NewEmail creates an email notifier
+func NewEmail(cfg EmailConfig) (*Email, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("SMTP host is required")
+	}
+	if cfg.Port == "" {
+		cfg.Port = "587"
+	}
+	if cfg.From == "" {
+		return nil, fmt.Errorf("sender address is required (set NOTIFY_EMAIL_FROM)")
+	}
+	if len(cfg.To) == 0 {
+		return nil, fmt.Errorf("at least one recipient is required (set NOTIFY_EMAIL_TO)")
+	}
+	return &Email{cfg: cfg}, nil
+}
+
+// Notify emails s to the configured recipients
+func (e *Email) Notify(ctx context.Context, s *report.Summary) error {
+	msg, err := e.message(s)
+	if err != nil {
+		return err
+	}
+	if err := e.send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// message builds a multipart email with the Markdown summary as its body and
+// the JSON and Markdown summaries as attachments
+func (e *Email) message(s *report.Summary) ([]byte, error) {
+	summaryJSON, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal summary: %w", err)
+	}
+	markdown := s.Markdown()
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	subject := fmt.Sprintf("%s run %s: %d tweets saved from %d queries", s.Tool, s.Status, s.Totals.Saved, len(s.Queries))
+	fmt.Fprintf(&buf, "From: %s\r\n", e.cfg.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(e.cfg.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+
+	parts := []struct {
+		contentType, filename string
+		data                  []byte
+	}{
+		{"text/plain; charset=utf-8", "", []byte(markdown)},
+		{"application/json", "summary.json", summaryJSON},
+		{"text/markdown; charset=utf-8", "summary.md", []byte(markdown)},
+	}
+	for _, p := range parts {
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Type", p.contentType)
+		h.Set("Content-Transfer-Encoding", "base64")
+		if p.filename != "" {
+			h.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", p.filename))
+		}
+		w, err := mw.CreatePart(h)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build email: %w", err)
+		}
+		if _, err := w.Write([]byte(wrapBase64(p.data))); err != nil {
+			return nil, fmt.Errorf("failed to build email: %w", err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to build email: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// wrapBase64 encodes data as base64 in 76-character lines, as MIME requires
+func wrapBase64(data []byte) string {
+	enc := base64.StdEncoding.EncodeToString(data)
+	var b strings.Builder
+	for len(enc) > 76 {
+		b.WriteString(enc[:76] + "\r\n")
+		enc = enc[76:]
+	}
+	b.WriteString(enc + "\r\n")
+	return b.String()
+}
+
+// send delivers msg over SMTP, using implicit TLS on port 465 and STARTTLS when
+// the server offers it otherwise
+func (e *Email) send(ctx context.Context, msg []byte) error {
+	addr := net.JoinHostPort(e.cfg.Host, e.cfg.Port)
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	var conn net.Conn
+	var err error
+	if e.cfg.Port == "465" {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: e.cfg.Host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(time.Minute))
+
+	c, err := smtp.NewClient(conn, e.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok && e.cfg.Port != "465" {
+		if err := c.StartTLS(&tls.Config{ServerName: e.cfg.Host}); err != nil {
+			return err
+		}
+	}
+	if e.cfg.Username != "" {
+		// PlainAuth refuses to send credentials over an unencrypted connection
+		// except to localhost
+		if err := c.Auth(smtp.PlainAuth("", e.cfg.Username, e.cfg.Password, e.cfg.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(e.cfg.From); err != nil {
+		return err
+	}
+	for _, to := range e.cfg.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,76 @@
+// Package notify sends the run summary to chat and other channels when a
+// collection run finishes or fails, so unattended jobs do not fail silently.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/grant/sn42/pkg/report"
+)
+
+// Notifier delivers a run summary to one channel
+type Notifier interface {
+	Notify(ctx context.Context, s *report.Summary) error
+}
+
+// Dispatcher sends summaries to every configured notifier. A nil Dispatcher
+// sends nothing.
+type Dispatcher struct {
+	notifiers    []Notifier
+	failuresOnly bool
+}
+
+// FromEnv builds the notifiers configured through environment variables.
+// Each notifier is enabled by its URL or host variable; NOTIFY_ON=failure limits
+// notifications to runs that did not finish cleanly. It returns nil (and no
+// error) when no notifier is configured.
+func FromEnv() (*Dispatcher, error) {
+	d := &Dispatcher{}
+	switch on := os.Getenv("NOTIFY_ON"); on {
+	case "", "always":
+	case "failure":
+		d.failuresOnly = true
+	default:
+		return nil, fmt.Errorf("invalid NOTIFY_ON %q (expected always or failure)", on)
+	}
+
+	if os.Getenv("NOTIFY_WEBHOOK_URL") != "" {
+		w, err := NewWebhookFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		d.notifiers = append(d.notifiers, w)
+	}
+
+	if os.Getenv("NOTIFY_SMTP_HOST") != "" {
+		e, err := NewEmailFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		d.notifiers = append(d.notifiers, e)
+	}
+
+	if len(d.notifiers) == 0 {
+		return nil, nil
+	}
+	return d, nil
+}
+
+// Send delivers s to every notifier and reports whether it was sent; successful
+// runs are skipped when only failures are reported. It tries all notifiers and
+// joins their errors.
+func (d *Dispatcher) Send(ctx context.Context, s *report.Summary) (bool, error) {
+	if d == nil || (d.failuresOnly && s.Status == report.StatusOK) {
+		return false, nil
+	}
+	var errs []error
+	for _, n := range d.notifiers {
+		if err := n.Notify(ctx, s); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return true, errors.Join(errs...)
+}
@@ -0,0 +1,168 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/grant/sn42/pkg/report"
+)
+
+// Webhook payload formats
+const (
+	FormatSlack   = "slack"   // {"text": ...}
+	FormatDiscord = "discord" // {"content": ...}
+	FormatJSON    = "json"    // The summary itself, for custom receivers
+)
+
+// discordMaxContent is the longest message Discord accepts
+const discordMaxContent = 2000
+
+// WebhookConfig configures the chat webhook notifier
+type WebhookConfig struct {
+	URL    string // Incoming webhook URL
+	Format string // Payload format; detected from URL if empty
+}
+
+// Webhook posts the run summary to a Slack or Discord incoming webhook, or as
+// JSON to any other endpoint
+type Webhook struct {
+	cfg        WebhookConfig
+	httpClient *http.Client
+}
+
+// NewWebhookFromEnv creates a webhook notifier from NOTIFY_WEBHOOK_URL and
+// NOTIFY_WEBHOOK_FORMAT
+func NewWebhookFromEnv() (*Webhook, error) {
+	return NewWebhook(WebhookConfig{
+		URL:    os.Getenv("NOTIFY_WEBHOOK_URL"),
+		Format: os.Getenv("NOTIFY_WEBHOOK_FORMAT"),
+	})
+}
+
+// NewWebhook creates a webhook notifier
+func NewWebhook(cfg WebhookConfig) (*Webhook, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("webhook URL is required")
+	}
+	if cfg.Format == "" {
+		cfg.Format = detectFormat(cfg.URL)
+	}
+	switch cfg.Format {
+	case FormatSlack, FormatDiscord, FormatJSON:
+	default:
+		return nil, fmt.Errorf("invalid NOTIFY_WEBHOOK_FORMAT %q (expected %s, %s or %s)", cfg.Format, FormatSlack, FormatDiscord, FormatJSON)
+	}
+	return &Webhook{cfg: cfg, httpClient: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+// detectFormat picks the payload format from well-known webhook hosts
+func detectFormat(url string) string {
+	switch {
+	case strings.Contains(url, "hooks.slack.com"):
+		return FormatSlack
+	case strings.Contains(url, "discord.com/api/webhooks"), strings.Contains(url, "discordapp.com/api/webhooks"):
+		return FormatDiscord
+	default:
+		return FormatJSON
+	}
+}
+
+// Notify posts s to the webhook
+func (w *Webhook) Notify(ctx context.Context, s *report.Summary) error {
+	text := Message(s, 0)
+	if w.cfg.Format == FormatDiscord {
+		text = Message(s, discordMaxContent)
+	}
+	return w.Post(ctx, text, s)
+}
+
+// Post posts text to a Slack or Discord webhook, or data as JSON to any
+// other endpoint
+func (w *Webhook) Post(ctx context.Context, text string, data any) error {
+	var payload any
+	switch w.cfg.Format {
+	case FormatSlack:
+		payload = map[string]string{"text": text}
+	case FormatDiscord:
+		if len(text) > discordMaxContent {
+			text = strings.ToValidUTF8(text[:discordMaxContent-len("…")], "") + "…"
+		}
+		payload = map[string]string{"content": text}
+	default:
+		payload = data
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// Message renders a short chat message for s: a status line followed by one
+// line per query. With limit > 0, queries that do not fit are summarized in a
+// final line so the message stays within limit characters.
+func Message(s *report.Summary, limit int) string {
+	icon := map[string]string{report.StatusOK: "✅", report.StatusPartial: "⚠️", report.StatusFailed: "❌"}[s.Status]
+	t := s.Totals
+	head := fmt.Sprintf("%s %s run %s: %d tweets saved from %d queries in %s (%d fetched, %d dropped, %d errors)",
+		icon, s.Tool, s.Status, t.Saved, len(s.Queries), seconds(s.Seconds), t.Fetched, t.Dropped, t.Errors)
+	lines := []string{head}
+	if s.Note != "" {
+		lines = append(lines, s.Note)
+	}
+	for _, q := range s.Queries {
+		name := q.Query
+		if q.Label != "" {
+			name = q.Label
+		}
+		line := fmt.Sprintf("• %s: %d/%d saved (%d fetched, %d dropped) in %s", name, q.Saved, q.Requested, q.Fetched, q.Dropped, seconds(q.Seconds))
+		if q.Error != "" {
+			line += " ❌ " + q.Error
+		}
+		lines = append(lines, line)
+	}
+	for _, f := range s.Files {
+		lines = append(lines, "• "+f)
+	}
+
+	msg := strings.Join(lines, "\n")
+	if limit <= 0 || len(msg) <= limit {
+		return msg
+	}
+	// Drop lines from the end until the message and the overflow note fit
+	for n := len(lines) - 1; n > 0; n-- {
+		more := fmt.Sprintf("\n… and %d more lines", len(lines)-n)
+		msg = strings.Join(lines[:n], "\n")
+		if len(msg)+len(more) <= limit {
+			return msg + more
+		}
+	}
+	return head[:min(len(head), limit)]
+}
+
+func seconds(s float64) string {
+	return (time.Duration(s * float64(time.Second))).Round(time.Second).String()
+}
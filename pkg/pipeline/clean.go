@@ -0,0 +1,154 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/masa-finance/tee-worker/v2/api/types"
+	"golang.org/x/text/unicode/runenames"
+)
+
+// Cleaning steps supported by the clean stage
+const (
+	StepStripURLs          = "strip-urls"
+	StepCollapseWhitespace = "collapse-whitespace"
+	StepRemoveControl      = "remove-control"
+	StepStripEmoji         = "strip-emoji"
+	StepEmojiAliases       = "emoji-aliases"
+)
+
+// CleanSteps lists the supported cleaning steps
+var CleanSteps = []string{StepStripURLs, StepCollapseWhitespace, StepRemoveControl, StepStripEmoji, StepEmojiAliases}
+
+var (
+	urlRegex        = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+	whitespaceRegex = regexp.MustCompile(`\s+`)
+)
+
+// Clean normalizes document text by applying cleaning steps in the configured order
+type Clean struct {
+	steps []string
+}
+
+// NewClean creates a clean stage. Steps are applied in the given order.
+func NewClean(steps []string) (*Clean, error) {
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("no cleaning steps given")
+	}
+	for _, step := range steps {
+		if !slices.Contains(CleanSteps, step) {
+			return nil, fmt.Errorf("unknown cleaning step %q (supported: %s)", step, strings.Join(CleanSteps, ", "))
+		}
+	}
+	if slices.Contains(steps, StepStripEmoji) && slices.Contains(steps, StepEmojiAliases) {
+		return nil, fmt.Errorf("%s and %s are mutually exclusive", StepStripEmoji, StepEmojiAliases)
+	}
+	return &Clean{steps: steps}, nil
+}
+
+// ParseClean creates a clean stage from a comma-separated step list,
+// e.g. "strip-urls,collapse-whitespace"
+func ParseClean(spec string) (*Clean, error) {
+	return NewClean(splitList(spec))
+}
+
+func (c *Clean) Name() string {
+	return "clean(" + strings.Join(c.steps, ",") + ")"
+}
+
+func (c *Clean) Process(docs []types.Document) ([]types.Document, error) {
+	for i := range docs {
+		docs[i].Content = c.CleanText(docs[i].Content)
+	}
+	return docs, nil
+}
+
+// CleanText applies the cleaning steps to a single string
+func (c *Clean) CleanText(text string) string {
+	for _, step := range c.steps {
+		switch step {
+		case StepStripURLs:
+			text = urlRegex.ReplaceAllString(text, "")
+		case StepCollapseWhitespace:
+			text = strings.TrimSpace(whitespaceRegex.ReplaceAllString(text, " "))
+		case StepRemoveControl:
+			text = strings.Map(func(r rune) rune {
+				if unicode.IsControl(r) && r != '\n' && r != '\t' {
+					return -1
+				}
+				return r
+			}, text)
+		case StepStripEmoji:
+			text = strings.Map(func(r rune) rune {
+				if isEmoji(r) || isEmojiComponent(r) {
+					return -1
+				}
+				return r
+			}, text)
+		case StepEmojiAliases:
+			text = emojiToAliases(text)
+		}
+	}
+	return text
+}
+
+// isEmoji reports whether r is in one of the Unicode blocks used for emoji pictographs
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF: // Mahjong ... Symbols and Pictographs Extended-A (incl. flags)
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // Miscellaneous Symbols, Dingbats
+		return true
+	case r >= 0x2300 && r <= 0x23FF: // Miscellaneous Technical (⌚, ⏰, ...)
+		return true
+	case r >= 0x2B00 && r <= 0x2BFF: // Miscellaneous Symbols and Arrows (⭐, ⬛, ...)
+		return true
+	}
+	return false
+}
+
+// isEmojiComponent reports whether r only modifies or joins emoji
+// (zero width joiner, variation selector 16, keycap, tag characters)
+func isEmojiComponent(r rune) bool {
+	return r == 0x200D || r == 0xFE0F || r == 0x20E3 || (r >= 0xE0020 && r <= 0xE007F)
+}
+
+// emojiToAliases replaces each emoji with a :short_name: alias derived from its
+// Unicode character name, e.g. 😀 -> :grinning_face:. Pairs of regional
+// indicators become flag aliases (🇺🇸 -> :flag_us:).
+func emojiToAliases(text string) string {
+	var b strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case isRegionalIndicator(r) && i+1 < len(runes) && isRegionalIndicator(runes[i+1]):
+			b.WriteString(":flag_")
+			b.WriteRune('a' + (r - 0x1F1E6))
+			b.WriteRune('a' + (runes[i+1] - 0x1F1E6))
+			b.WriteString(":")
+			i++
+		case isEmojiComponent(r):
+			// Joiners and selectors have no meaning once the emoji is written as text
+		case r >= 0x1F3FB && r <= 0x1F3FF:
+			// Skin tone modifiers are dropped alongside the emoji they modify
+		case isEmoji(r):
+			name := runenames.Name(r)
+			if name == "" {
+				b.WriteRune(r)
+				continue
+			}
+			b.WriteString(":" + strings.ReplaceAll(strings.ToLower(name), " ", "_") + ":")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
@@ -0,0 +1,85 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grant/sn42/pkg/spill"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Deduplication keys supported by the dedup stage
+const (
+	DedupByID   = "id"
+	DedupByText = "text"
+)
+
+// Dedup drops documents already seen earlier in the run. Keyed by text it
+// catches the identical posts that spam networks send from many accounts.
+type Dedup struct {
+	key  string
+	seen *spill.Set // Hashed IDs and texts seen so far
+}
+
+// NewDedup creates a dedup stage keyed on "id" or "text". Text dedup also drops
+// repeated tweet IDs. The seen IDs and texts are kept in seen, which spills
+// them to disk past its memory budget; a nil seen keeps them all in memory.
+func NewDedup(key string, seen *spill.Set) (*Dedup, error) {
+	if key != DedupByID && key != DedupByText {
+		return nil, fmt.Errorf("unknown dedup key %q (supported: %s, %s)", key, DedupByID, DedupByText)
+	}
+	if seen == nil {
+		seen = spill.NewSet("", 0)
+	}
+	return &Dedup{key: key, seen: seen}, nil
+}
+
+func (d *Dedup) Name() string {
+	return "dedup(" + d.key + ")"
+}
+
+func (d *Dedup) Process(docs []types.Document) ([]types.Document, error) {
+	kept := docs[:0]
+	for _, doc := range docs {
+		dup, err := d.Seen(doc)
+		if err != nil {
+			return nil, err
+		}
+		if !dup {
+			kept = append(kept, doc)
+		}
+	}
+	return kept, nil
+}
+
+// Seen records doc and reports whether it duplicates an earlier document
+func (d *Dedup) Seen(doc types.Document) (bool, error) {
+	// The prefixes keep an ID and a text with the same characters apart
+	if doc.Id != "" {
+		added, err := d.seen.Add(spill.KeyOf("id:" + doc.Id))
+		if err != nil || !added {
+			return !added, err
+		}
+	}
+	if d.key == DedupByText {
+		added, err := d.seen.Add(spill.KeyOf("text:" + DedupText(doc.Content)))
+		return !added, err
+	}
+	return false, nil
+}
+
+// Close removes the stage's spill files
+func (d *Dedup) Close() error {
+	return d.seen.Close()
+}
+
+// DedupText returns the form of text that is compared for duplicates: NFKC
+// normalized, case folded, with links removed (shortened links differ per post)
+// and whitespace collapsed.
+func DedupText(text string) string {
+	text = norm.NFKC.String(text)
+	text = strings.ToLower(text)
+	text = urlRegex.ReplaceAllString(text, "")
+	return strings.TrimSpace(whitespaceRegex.ReplaceAllString(text, " "))
+}
@@ -0,0 +1,24 @@
+package pipeline
+
+import (
+	"github.com/grant/sn42/pkg/denylist"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Denylist drops tweets on the denylist before they reach any sink
+type Denylist struct {
+	list *denylist.Denylist
+}
+
+// NewDenylist creates a stage dropping the tweets list denies
+func NewDenylist(list *denylist.Denylist) *Denylist {
+	return &Denylist{list: list}
+}
+
+func (d *Denylist) Name() string {
+	return "denylist"
+}
+
+func (d *Denylist) Process(docs []types.Document) ([]types.Document, error) {
+	return d.list.Filter(docs), nil
+}
@@ -0,0 +1,33 @@
+package pipeline
+
+import (
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// SkipExisting drops tweets already in the dataset being appended to, so
+// they neither count towards AMOUNT nor take up later stages
+type SkipExisting struct {
+	ids map[string]bool
+}
+
+// NewSkipExisting creates a stage dropping tweets whose ID is in ids. The IDs
+// of the tweets it passes are added, so repeats in later batches are dropped too.
+func NewSkipExisting(ids map[string]bool) *SkipExisting {
+	return &SkipExisting{ids: ids}
+}
+
+func (s *SkipExisting) Name() string {
+	return "skip-existing"
+}
+
+func (s *SkipExisting) Process(docs []types.Document) ([]types.Document, error) {
+	kept := docs[:0]
+	for _, doc := range docs {
+		if doc.Id != "" && s.ids[doc.Id] {
+			continue
+		}
+		s.ids[doc.Id] = true
+		kept = append(kept, doc)
+	}
+	return kept, nil
+}
@@ -0,0 +1,153 @@
+package pipeline
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/grant/sn42/pkg/denylist"
+	"github.com/grant/sn42/pkg/spill"
+)
+
+// Flags holds the command-line options that configure a pipeline, so every
+// collector exposes the same stages with the same flag names
+type Flags struct {
+	Timestamps string
+	Normalize  string
+	Clean      string
+	Transforms string
+	Plugins    string
+	Validate   bool
+	Dedup      string
+	Spill      *spill.Flags // Memory budget of the dedup state
+
+	Moderate   string
+	Threshold  float64
+	Quarantine string
+
+	ImageText          string
+	Transcribe         bool
+	TranscribeLanguage string
+
+	Fields string
+}
+
+// RegisterFlags defines the pipeline flags on fs
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	fs.StringVar(&f.Timestamps, "timestamps", "", "Rewrite created_at as RFC 3339 UTC, and \"drop\" or \"tag\" tweets where it cannot be parsed")
+	fs.StringVar(&f.Normalize, "normalize", "", "Comma-separated Unicode normalization options: "+strings.Join(NormalizeOptions, ", "))
+	fs.StringVar(&f.Clean, "clean", "", "Comma-separated text cleaning steps: "+strings.Join(CleanSteps, ", "))
+	fs.StringVar(&f.Transforms, "transforms", "", "YAML file of rules dropping tweets or setting metadata with query expressions")
+	fs.StringVar(&f.Plugins, "plugin", "", "Comma-separated WebAssembly modules each tweet is passed through (see README)")
+	fs.BoolVar(&f.Validate, "validate", true, "Move records without an ID or text to the --quarantine file instead of writing them")
+	fs.StringVar(&f.Dedup, "dedup", "", "Drop duplicate tweets by \"id\" or by normalized \"text\"")
+	f.Spill = spill.RegisterFlags(fs)
+	fs.StringVar(&f.Moderate, "moderate", "", "Score tweets with the moderation endpoint (MODERATION_URL) and \"drop\" or \"tag\" toxic ones")
+	fs.Float64Var(&f.Threshold, "toxicity-threshold", 0.8, "Moderation score (0-1) at or above which a tweet is dropped or tagged")
+	fs.StringVar(&f.Quarantine, "quarantine", "data/quarantine.jsonl", "File that tweets dropped by --moderate and records failing --validate are appended to")
+	fs.StringVar(&f.ImageText, "image-text", "", "Comma-separated tasks run on the photos of tweets with the image text endpoint (IMAGE_TEXT_URL): "+strings.Join(ImageTextTasks, ", "))
+	fs.BoolVar(&f.Transcribe, "transcribe", false, "Transcribe the videos of tweets with the transcription endpoint (TRANSCRIPTION_URL)")
+	fs.StringVar(&f.TranscribeLanguage, "transcribe-language", "", "Language of the videos for --transcribe, e.g. \"en\" (default: detected by the endpoint)")
+	fs.StringVar(&f.Fields, "fields", "", "Comma-separated fields and metadata keys to write, e.g. \"content,created_at,lang\", or to leave out, e.g. \"-user,-raw_html\"")
+	return f
+}
+
+// Build creates the pipeline selected by the flags. The denylist configured
+// by DENYLIST_FILE always applies and runs first, then timestamp
+// normalization. Unicode normalization follows so that cleaning steps see
+// text in a single Unicode form. Transform rules and then plugins see the
+// cleaned text, and validation then checks the records as they will be
+// written; all run before dedup, so the tweets they drop take no dedup
+// state. Dedup compares
+// the text that will be written, and moderation runs after it so duplicates
+// are not scored. Image text extraction and transcription follow, so only
+// the tweets that are kept have their media sent. Field projection comes
+// last, once every stage has seen the whole tweet.
+func (f *Flags) Build() (Pipeline, error) {
+	var p Pipeline
+	deny, err := denylist.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if deny != nil {
+		p = append(p, NewDenylist(deny))
+	}
+	if f.Timestamps != "" {
+		stage, err := NewTimestamps(f.Timestamps)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --timestamps: %w", err)
+		}
+		p = append(p, stage)
+	}
+	if f.Normalize != "" {
+		stage, err := ParseNormalize(f.Normalize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --normalize: %w", err)
+		}
+		p = append(p, stage)
+	}
+	if f.Clean != "" {
+		stage, err := ParseClean(f.Clean)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --clean: %w", err)
+		}
+		p = append(p, stage)
+	}
+	if f.Transforms != "" {
+		stage, err := LoadTransforms(f.Transforms)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --transforms: %w", err)
+		}
+		p = append(p, stage)
+	}
+	for _, path := range splitList(f.Plugins) {
+		stage, err := NewPlugin(path)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("invalid --plugin: %w", err)
+		}
+		p = append(p, stage)
+	}
+	if f.Validate {
+		p = append(p, NewValidate(f.Quarantine))
+	}
+	if f.Dedup != "" {
+		stage, err := NewDedup(f.Dedup, spill.NewSet(f.Spill.Dir, f.Spill.Budget()))
+		if err != nil {
+			return nil, fmt.Errorf("invalid --dedup: %w", err)
+		}
+		p = append(p, stage)
+	}
+	if f.Moderate != "" {
+		stage, err := NewModerateFromEnv(f.Moderate, f.Threshold, f.Quarantine)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --moderate: %w", err)
+		}
+		p = append(p, stage)
+	}
+	if f.ImageText != "" {
+		stage, err := NewImageTextFromEnv(f.ImageText)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --image-text: %w", err)
+		}
+		p = append(p, stage)
+	}
+	if f.Transcribe {
+		stage, err := NewTranscribeFromEnv(f.TranscribeLanguage)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --transcribe: %w", err)
+		}
+		p = append(p, stage)
+	} else if f.TranscribeLanguage != "" {
+		return nil, fmt.Errorf("--transcribe-language needs --transcribe")
+	}
+	if f.Fields != "" {
+		stage, err := ParseProject(f.Fields)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --fields: %w", err)
+		}
+		p = append(p, stage)
+	}
+	return p, nil
+}
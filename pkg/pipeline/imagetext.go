@@ -0,0 +1,136 @@
+package pipeline
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Tasks the image-text stage can run on each photo
+const (
+	ImageOCR     = "ocr"
+	ImageCaption = "caption"
+)
+
+// ImageTextTasks lists the supported tasks, for flag help and errors
+var ImageTextTasks = []string{ImageOCR, ImageCaption}
+
+// imageTextBatchSize is the number of images sent per request
+const imageTextBatchSize = 16
+
+// ImageTextConfig configures the image-text endpoint
+type ImageTextConfig struct {
+	URL    string   // Endpoint accepting {"task": ..., "images": [urls]} requests
+	APIKey string   // Optional bearer token
+	Tasks  []string // ImageOCR and/or ImageCaption
+}
+
+// ImageText extracts text from the photos of tweets with an OCR or
+// captioning endpoint, so image tweets carry their text into text datasets.
+// Photos are passed by URL, as listed in the photos metadata; tweets without
+// photos are left as they are. A failed request does not drop tweets: they
+// are kept without the text, with the error in image_text_error.
+type ImageText struct {
+	cfg      ImageTextConfig
+	endpoint *textEndpoint
+}
+
+type imageTextRequest struct {
+	Task   string   `json:"task"`
+	Images []string `json:"images"`
+}
+
+// NewImageTextFromEnv creates an image-text stage for the tasks, given as a
+// comma-separated list, and the endpoint in IMAGE_TEXT_URL and
+// IMAGE_TEXT_API_KEY
+func NewImageTextFromEnv(tasks string) (*ImageText, error) {
+	return NewImageText(ImageTextConfig{
+		URL:    os.Getenv("IMAGE_TEXT_URL"),
+		APIKey: os.Getenv("IMAGE_TEXT_API_KEY"),
+		Tasks:  splitList(tasks),
+	})
+}
+
+// NewImageText creates an image-text stage
+func NewImageText(cfg ImageTextConfig) (*ImageText, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("image text endpoint is required (set IMAGE_TEXT_URL)")
+	}
+	if len(cfg.Tasks) == 0 {
+		return nil, fmt.Errorf("no image text tasks given (supported: %s)", strings.Join(ImageTextTasks, ", "))
+	}
+	for _, task := range cfg.Tasks {
+		if !slices.Contains(ImageTextTasks, task) {
+			return nil, fmt.Errorf("unknown image text task %q (supported: %s)", task, strings.Join(ImageTextTasks, ", "))
+		}
+	}
+	return &ImageText{
+		cfg:      cfg,
+		endpoint: &textEndpoint{name: "image text", url: cfg.URL, apiKey: cfg.APIKey, httpClient: &http.Client{Timeout: 120 * time.Second}},
+	}, nil
+}
+
+func (s *ImageText) Name() string {
+	return fmt.Sprintf("image_text(%s)", strings.Join(s.cfg.Tasks, ","))
+}
+
+// Process adds image_ocr and/or image_captions to the tweets with photos,
+// one text per photo in the order of photos
+func (s *ImageText) Process(docs []types.Document) ([]types.Document, error) {
+	// Photos of the whole batch are sent together, and the texts handed back
+	// to their tweets by position
+	type photo struct{ doc, index int }
+	var urls []string
+	var owners []photo
+	for i, doc := range docs {
+		for j, url := range PhotoURLs(doc) {
+			urls = append(urls, url)
+			owners = append(owners, photo{i, j})
+		}
+	}
+	if len(urls) == 0 {
+		return docs, nil
+	}
+
+	for _, task := range s.cfg.Tasks {
+		key := "image_ocr"
+		if task == ImageCaption {
+			key = "image_captions"
+		}
+		for start := 0; start < len(urls); start += imageTextBatchSize {
+			end := min(start+imageTextBatchSize, len(urls))
+			texts, err := s.endpoint.extract(imageTextRequest{Task: task, Images: urls[start:end]}, end-start)
+			for k, owner := range owners[start:end] {
+				doc := &docs[owner.doc]
+				if doc.Metadata == nil {
+					doc.Metadata = make(map[string]any)
+				}
+				if err != nil {
+					doc.Metadata["image_text_error"] = err.Error()
+					continue
+				}
+				list, _ := doc.Metadata[key].([]string)
+				if list == nil {
+					list = make([]string, len(PhotoURLs(*doc)))
+				}
+				list[owner.index] = texts[k]
+				doc.Metadata[key] = list
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "⚠️ Image %s failed for %d photos, keeping their tweets without it: %v\n", task, end-start, err)
+			}
+		}
+	}
+	return docs, nil
+}
+
+// PhotoURLs returns the URLs in a tweet's photos metadata, as the API lists
+// them: [{"id": ..., "url": ...}, ...]
+func PhotoURLs(doc types.Document) []string {
+	return mediaURLs(doc.Metadata, "photos")
+}
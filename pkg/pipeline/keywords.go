@@ -0,0 +1,35 @@
+package pipeline
+
+import (
+	"github.com/grant/sn42/pkg/query"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// MatchedKeywords records which of the keywords of an OR query each tweet
+// matched, in the matched_keywords metadata field
+type MatchedKeywords struct {
+	keywords []string
+}
+
+// NewMatchedKeywords creates a stage attributing tweets to keywords
+func NewMatchedKeywords(keywords []string) *MatchedKeywords {
+	return &MatchedKeywords{keywords: keywords}
+}
+
+func (m *MatchedKeywords) Name() string {
+	return "match-keywords"
+}
+
+func (m *MatchedKeywords) Process(docs []types.Document) ([]types.Document, error) {
+	for i := range docs {
+		if docs[i].Metadata == nil {
+			docs[i].Metadata = map[string]any{}
+		}
+		matched := query.MatchKeywords(docs[i], m.keywords)
+		if matched == nil {
+			matched = []string{}
+		}
+		docs[i].Metadata["matched_keywords"] = matched
+	}
+	return docs, nil
+}
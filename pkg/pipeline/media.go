@@ -0,0 +1,84 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// textEndpoint is a service that turns media, given by URL, into text: one
+// JSON request for a batch of URLs, answered with {"results": [{"text": ...}]}
+// in the same order
+type textEndpoint struct {
+	name       string // For errors, e.g. "image text"
+	url        string
+	apiKey     string // Optional bearer token
+	httpClient *http.Client
+}
+
+// extract sends req, which lists n media URLs, and returns one text per URL
+func (e *textEndpoint) extract(req any, n int) ([]string, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s request: %w", e.name, err)
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s request: %w", e.name, err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := e.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("%s request failed: %w", e.name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s endpoint returned status %d: %s", e.name, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var out struct {
+		Results []struct {
+			Text string `json:"text"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode %s response: %w", e.name, err)
+	}
+	if len(out.Results) != n {
+		return nil, fmt.Errorf("%s endpoint returned %d results for %d inputs", e.name, len(out.Results), n)
+	}
+	texts := make([]string, n)
+	for i, r := range out.Results {
+		texts[i] = strings.TrimSpace(r.Text)
+	}
+	return texts, nil
+}
+
+// mediaURLs returns the url of each object in a list metadata field such as
+// photos or videos ([{"id": ..., "url": ...}, ...]), falling back to the
+// other keys given for objects without one
+func mediaURLs(metadata map[string]any, field string, fallbacks ...string) []string {
+	items, _ := metadata[field].([]any)
+	var urls []string
+	for _, item := range items {
+		m, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		for _, key := range append([]string{"url"}, fallbacks...) {
+			if url, _ := m[key].(string); url != "" {
+				urls = append(urls, url)
+				break
+			}
+		}
+	}
+	return urls
+}
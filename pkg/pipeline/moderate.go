@@ -0,0 +1,206 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Actions the moderate stage takes on tweets at or above the threshold
+const (
+	ModerateDrop = "drop"
+	ModerateTag  = "tag"
+)
+
+// moderationBatchSize is the number of texts sent per moderation request
+const moderationBatchSize = 32
+
+// ModerationConfig configures the moderation endpoint and how its scores are used
+type ModerationConfig struct {
+	URL        string  // Moderation endpoint accepting OpenAI-style {"input": [...]} requests
+	APIKey     string  // Optional bearer token
+	Model      string  // Optional model name sent with each request
+	Action     string  // "drop" or "tag"
+	Threshold  float64 // Tweets scoring at or above this are dropped or tagged
+	Quarantine string  // File that dropped tweets are appended to (drop only)
+}
+
+// Moderate scores tweets with a moderation endpoint and drops or tags those above
+// a toxicity threshold. A tweet's score is its highest category score.
+type Moderate struct {
+	cfg        ModerationConfig
+	httpClient *http.Client
+	quarantine *quarantineFile
+}
+
+type moderationRequest struct {
+	Model string   `json:"model,omitempty"`
+	Input []string `json:"input"`
+}
+
+type moderationResponse struct {
+	Results []moderationResult `json:"results"`
+}
+
+type moderationResult struct {
+	Flagged        bool               `json:"flagged"`
+	CategoryScores map[string]float64 `json:"category_scores"`
+}
+
+// NewModerateFromEnv creates a moderate stage for the endpoint in MODERATION_URL,
+// MODERATION_API_KEY and MODERATION_MODEL
+func NewModerateFromEnv(action string, threshold float64, quarantine string) (*Moderate, error) {
+	return NewModerate(ModerationConfig{
+		URL:        os.Getenv("MODERATION_URL"),
+		APIKey:     os.Getenv("MODERATION_API_KEY"),
+		Model:      os.Getenv("MODERATION_MODEL"),
+		Action:     action,
+		Threshold:  threshold,
+		Quarantine: quarantine,
+	})
+}
+
+// NewModerate creates a moderate stage. In drop mode the quarantine file is
+// opened for appending so removed tweets can be audited later.
+func NewModerate(cfg ModerationConfig) (*Moderate, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("moderation endpoint is required (set MODERATION_URL)")
+	}
+	if cfg.Action != ModerateDrop && cfg.Action != ModerateTag {
+		return nil, fmt.Errorf("unknown moderation action %q (supported: %s, %s)", cfg.Action, ModerateDrop, ModerateTag)
+	}
+	if cfg.Threshold <= 0 || cfg.Threshold > 1 {
+		return nil, fmt.Errorf("toxicity threshold must be in (0, 1], got %g", cfg.Threshold)
+	}
+
+	m := &Moderate{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+	if cfg.Action == ModerateDrop && cfg.Quarantine != "" {
+		m.quarantine = &quarantineFile{path: cfg.Quarantine}
+		if err := m.quarantine.open(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *Moderate) Name() string {
+	return fmt.Sprintf("moderate(%s>=%g)", m.cfg.Action, m.cfg.Threshold)
+}
+
+func (m *Moderate) Process(docs []types.Document) ([]types.Document, error) {
+	kept := docs[:0]
+	for start := 0; start < len(docs); start += moderationBatchSize {
+		chunk := docs[start:min(start+moderationBatchSize, len(docs))]
+		results, err := m.score(chunk)
+		if err != nil {
+			return nil, err
+		}
+		for i, doc := range chunk {
+			score, categories := m.evaluate(results[i])
+			if score < m.cfg.Threshold {
+				kept = append(kept, doc)
+				continue
+			}
+			if m.cfg.Action == ModerateTag {
+				if doc.Metadata == nil {
+					doc.Metadata = make(map[string]any)
+				}
+				doc.Metadata["toxicity_score"] = score
+				doc.Metadata["toxicity_categories"] = categories
+				kept = append(kept, doc)
+				continue
+			}
+			if err := m.quarantineDoc(doc, score, categories, results[i].CategoryScores); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return kept, nil
+}
+
+// Close closes the quarantine file
+func (m *Moderate) Close() error {
+	return m.quarantine.Close()
+}
+
+// score sends the texts of docs to the moderation endpoint and returns one
+// result per document
+func (m *Moderate) score(docs []types.Document) ([]moderationResult, error) {
+	req := moderationRequest{Model: m.cfg.Model, Input: make([]string, len(docs))}
+	for i, doc := range docs {
+		req.Input[i] = doc.Content
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal moderation request: %w", err)
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, m.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create moderation request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if m.cfg.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+m.cfg.APIKey)
+	}
+
+	resp, err := m.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("moderation request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("moderation endpoint returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var out moderationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode moderation response: %w", err)
+	}
+	if len(out.Results) != len(docs) {
+		return nil, fmt.Errorf("moderation endpoint returned %d results for %d inputs", len(out.Results), len(docs))
+	}
+	return out.Results, nil
+}
+
+// evaluate returns the highest category score and the categories at or above
+// the threshold, sorted by name
+func (m *Moderate) evaluate(r moderationResult) (float64, []string) {
+	var score float64
+	categories := []string{}
+	for name, s := range r.CategoryScores {
+		score = max(score, s)
+		if s >= m.cfg.Threshold {
+			categories = append(categories, name)
+		}
+	}
+	sort.Strings(categories)
+	return score, categories
+}
+
+// quarantineDoc appends a dropped document to the quarantine file
+func (m *Moderate) quarantineDoc(doc types.Document, score float64, categories []string, scores map[string]float64) error {
+	if m.quarantine == nil {
+		return nil
+	}
+	return m.quarantine.write(QuarantineRecord{
+		RemovedAt:  time.Now().UTC().Format(time.RFC3339),
+		Stage:      "moderate",
+		Score:      score,
+		Categories: categories,
+		Scores:     scores,
+		Document:   doc,
+	})
+}
@@ -0,0 +1,120 @@
+package pipeline
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/masa-finance/tee-worker/v2/api/types"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalization options supported by the normalize stage
+const (
+	NormNFC             = "nfc"
+	NormNFKC            = "nfkc"
+	NormStripZeroWidth  = "strip-zero-width"
+	NormFoldConfusables = "fold-confusables"
+)
+
+// NormalizeOptions lists the supported normalization options
+var NormalizeOptions = []string{NormNFC, NormNFKC, NormStripZeroWidth, NormFoldConfusables}
+
+// Normalize brings document text into a single Unicode form so that visually
+// identical tweets compare equal, e.g. for duplicate-text detection
+type Normalize struct {
+	options []string
+}
+
+// NewNormalize creates a normalize stage. Invisible characters are stripped and
+// confusables folded before the normalization form is applied, whatever the order given.
+func NewNormalize(options []string) (*Normalize, error) {
+	if len(options) == 0 {
+		return nil, fmt.Errorf("no normalization options given")
+	}
+	for _, opt := range options {
+		if !slices.Contains(NormalizeOptions, opt) {
+			return nil, fmt.Errorf("unknown normalization option %q (supported: %s)", opt, strings.Join(NormalizeOptions, ", "))
+		}
+	}
+	if slices.Contains(options, NormNFC) && slices.Contains(options, NormNFKC) {
+		return nil, fmt.Errorf("%s and %s are mutually exclusive", NormNFC, NormNFKC)
+	}
+	return &Normalize{options: options}, nil
+}
+
+// ParseNormalize creates a normalize stage from a comma-separated option list,
+// e.g. "nfkc,strip-zero-width"
+func ParseNormalize(spec string) (*Normalize, error) {
+	return NewNormalize(splitList(spec))
+}
+
+func (n *Normalize) Name() string {
+	return "normalize(" + strings.Join(n.options, ",") + ")"
+}
+
+func (n *Normalize) Process(docs []types.Document) ([]types.Document, error) {
+	for i := range docs {
+		docs[i].Content = n.NormalizeText(docs[i].Content)
+	}
+	return docs, nil
+}
+
+// NormalizeText applies the normalization options to a single string
+func (n *Normalize) NormalizeText(text string) string {
+	if slices.Contains(n.options, NormStripZeroWidth) {
+		text = strings.Map(func(r rune) rune {
+			if isZeroWidth(r) {
+				return -1
+			}
+			return r
+		}, text)
+	}
+	if slices.Contains(n.options, NormFoldConfusables) {
+		text = strings.Map(func(r rune) rune {
+			if latin, ok := confusables[r]; ok {
+				return latin
+			}
+			return r
+		}, text)
+	}
+	switch {
+	case slices.Contains(n.options, NormNFKC):
+		text = norm.NFKC.String(text)
+	case slices.Contains(n.options, NormNFC):
+		text = norm.NFC.String(text)
+	}
+	return text
+}
+
+// isZeroWidth reports whether r is an invisible formatting character commonly used
+// to make copies of the same text look distinct (zero width spaces and joiners,
+// word joiner, byte order mark, soft hyphen and bidirectional controls)
+func isZeroWidth(r rune) bool {
+	switch {
+	case r >= 0x200B && r <= 0x200F, // zero width space, ZWNJ, ZWJ, LRM, RLM
+		r >= 0x202A && r <= 0x202E, // bidi embeddings and overrides
+		r >= 0x2060 && r <= 0x2064, // word joiner, invisible operators
+		r >= 0x2066 && r <= 0x2069, // bidi isolates
+		r == 0xFEFF, r == 0x00AD, r == 0x180E, r == 0x034F:
+		return true
+	}
+	return false
+}
+
+// confusables maps Cyrillic and Greek letters that render identically to a Latin
+// letter onto that letter. It covers the homoglyphs seen in spam, not the full
+// Unicode confusables table.
+var confusables = map[rune]rune{
+	// Cyrillic lowercase
+	'а': 'a', 'с': 'c', 'е': 'e', 'һ': 'h', 'і': 'i', 'ј': 'j', 'о': 'o', 'р': 'p',
+	'ѕ': 's', 'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w', 'х': 'x', 'у': 'y',
+	// Cyrillic uppercase
+	'А': 'A', 'В': 'B', 'С': 'C', 'Е': 'E', 'Н': 'H', 'І': 'I', 'Ј': 'J', 'К': 'K',
+	'М': 'M', 'О': 'O', 'Р': 'P', 'Ѕ': 'S', 'Т': 'T', 'Х': 'X', 'Ү': 'Y',
+	// Greek lowercase
+	'ο': 'o', 'ν': 'v', 'α': 'a', 'ι': 'i', 'κ': 'k', 'ρ': 'p', 'υ': 'u',
+	// Greek uppercase
+	'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K', 'Μ': 'M',
+	'Ν': 'N', 'Ο': 'O', 'Ρ': 'P', 'Τ': 'T', 'Υ': 'Y', 'Χ': 'X',
+}
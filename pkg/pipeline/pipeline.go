@@ -0,0 +1,84 @@
+// Package pipeline applies record processing stages to each fetched batch
+// before it is written to the output file and sinks.
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Stage transforms a batch of documents. Stages may modify documents in place
+// and may drop documents by omitting them from the returned slice.
+type Stage interface {
+	// Name identifies the stage and its settings, e.g. "clean(strip-urls)".
+	// It is recorded in manifests so outputs document how they were processed.
+	Name() string
+	Process(docs []types.Document) ([]types.Document, error)
+}
+
+// Pipeline runs stages in order. A nil Pipeline passes batches through unchanged.
+type Pipeline []Stage
+
+// Process runs the batch through every stage
+func (p Pipeline) Process(docs []types.Document) ([]types.Document, error) {
+	return p.Observe(docs, nil)
+}
+
+// Observer is told, after each stage, how many documents went in and came
+// out and how long the stage took
+type Observer func(stage string, in, out int, took time.Duration)
+
+// Observe runs the batch through every stage like Process, reporting each
+// stage to observe if it is not nil
+func (p Pipeline) Observe(docs []types.Document, observe Observer) ([]types.Document, error) {
+	var err error
+	for _, stage := range p {
+		in, start := len(docs), time.Now()
+		if docs, err = stage.Process(docs); err != nil {
+			return nil, fmt.Errorf("stage %s: %w", stage.Name(), err)
+		}
+		if observe != nil {
+			observe(stage.Name(), in, len(docs), time.Since(start))
+		}
+	}
+	return docs, nil
+}
+
+// Names returns the names of the stages, in order
+func (p Pipeline) Names() []string {
+	if len(p) == 0 {
+		return nil
+	}
+	names := make([]string, len(p))
+	for i, stage := range p {
+		names[i] = stage.Name()
+	}
+	return names
+}
+
+// Close releases resources held by stages, such as open files
+func (p Pipeline) Close() error {
+	var errs []error
+	for _, stage := range p {
+		if c, ok := stage.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// splitList splits a comma-separated option list, dropping empty entries
+func splitList(spec string) []string {
+	var items []string
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
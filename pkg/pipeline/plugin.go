@@ -0,0 +1,131 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/masa-finance/tee-worker/v2/api/types"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// Plugin runs every tweet through a WebAssembly module, so teams can ship
+// their own filters and enrichers without forking the collectors. The module
+// is sandboxed: it has no filesystem or network access, and its output goes
+// to stderr.
+//
+// A plugin exports its memory and two functions:
+//
+//	alloc(size u32) u32         returns a buffer of size bytes for the input
+//	process(ptr u32, len u32) u64
+//
+// process receives a tweet as JSON in the buffer and returns the address of
+// the tweet to keep, as JSON, in the upper 32 bits and its length in the
+// lower 32, or 0 to drop the tweet. Both buffers must stay valid until the
+// next call. WASI modules built as reactors (e.g. GOOS=wasip1 with
+// -buildmode=c-shared) have their _initialize function run first.
+type Plugin struct {
+	path    string
+	mu      sync.Mutex // Module instances are not safe for concurrent use
+	runtime wazero.Runtime
+	mod     api.Module
+	alloc   api.Function
+	process api.Function
+}
+
+// NewPlugin compiles and instantiates the module at path
+func NewPlugin(path string) (*Plugin, error) {
+	wasm, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin: %w", err)
+	}
+	ctx := context.Background()
+	runtime := wazero.NewRuntime(ctx)
+	fail := func(err error) (*Plugin, error) {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		return fail(err)
+	}
+	compiled, err := runtime.CompileModule(ctx, wasm)
+	if err != nil {
+		return fail(err)
+	}
+	config := wazero.NewModuleConfig().
+		WithStartFunctions("_initialize").
+		WithStdout(os.Stderr).
+		WithStderr(os.Stderr)
+	mod, err := runtime.InstantiateModule(ctx, compiled, config)
+	if err != nil {
+		return fail(err)
+	}
+
+	p := &Plugin{path: path, runtime: runtime, mod: mod, alloc: mod.ExportedFunction("alloc"), process: mod.ExportedFunction("process")}
+	if p.alloc == nil || p.process == nil || mod.Memory() == nil {
+		return fail(fmt.Errorf("module must export memory, alloc and process"))
+	}
+	return p, nil
+}
+
+func (p *Plugin) Name() string {
+	return "plugin(" + filepath.Base(p.path) + ")"
+}
+
+func (p *Plugin) Process(docs []types.Document) ([]types.Document, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	kept := docs[:0]
+	for _, doc := range docs {
+		out, keep, err := p.call(doc)
+		if err != nil {
+			return nil, fmt.Errorf("tweet %s: %w", doc.Id, err)
+		}
+		if keep {
+			kept = append(kept, out)
+		}
+	}
+	return kept, nil
+}
+
+// call passes one tweet to the module and decodes the tweet it returns
+func (p *Plugin) call(doc types.Document) (types.Document, bool, error) {
+	ctx := context.Background()
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return doc, false, fmt.Errorf("failed to marshal tweet: %w", err)
+	}
+	res, err := p.alloc.Call(ctx, uint64(len(data)))
+	if err != nil {
+		return doc, false, fmt.Errorf("alloc failed: %w", err)
+	}
+	ptr := uint32(res[0])
+	if !p.mod.Memory().Write(ptr, data) {
+		return doc, false, fmt.Errorf("alloc returned an invalid buffer")
+	}
+	if res, err = p.process.Call(ctx, uint64(ptr), uint64(len(data))); err != nil {
+		return doc, false, fmt.Errorf("process failed: %w", err)
+	}
+	if res[0] == 0 {
+		return doc, false, nil
+	}
+	out, ok := p.mod.Memory().Read(uint32(res[0]>>32), uint32(res[0]))
+	if !ok {
+		return doc, false, fmt.Errorf("process returned an invalid buffer")
+	}
+	var result types.Document
+	if err := json.Unmarshal(out, &result); err != nil {
+		return doc, false, fmt.Errorf("process returned invalid JSON: %w", err)
+	}
+	return result, true, nil
+}
+
+// Close releases the module
+func (p *Plugin) Close() error {
+	return p.runtime.Close(context.Background())
+}
@@ -0,0 +1,164 @@
+package pipeline
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// documentFields are the top-level fields of a document; other names are
+// metadata keys
+var documentFields = []string{"id", "source", "content", "metadata", "embedding", "score", "updated_at"}
+
+// Project limits the fields written for each tweet. It either keeps only the
+// listed fields or, when every entry starts with "-", drops the listed ones.
+// Fields are top-level document fields or metadata keys, as "metadata.<key>"
+// or just "<key>", with dots for nested objects (e.g. "user.profile_image").
+// The id is always kept, as the dataset tools match records by it.
+type Project struct {
+	spec    string
+	exclude bool
+	top     map[string]bool // Top-level fields listed
+	paths   [][]string      // Metadata paths listed
+}
+
+// ParseProject creates a projection stage from a comma-separated field list,
+// e.g. "content,created_at,lang,likes" or "-raw_html,-user"
+func ParseProject(spec string) (*Project, error) {
+	fields := splitList(spec)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no fields given")
+	}
+	p := &Project{spec: strings.Join(fields, ","), top: map[string]bool{}}
+	for i, f := range fields {
+		name, negated := strings.CutPrefix(f, "-")
+		if i == 0 {
+			p.exclude = negated
+		} else if negated != p.exclude {
+			return nil, fmt.Errorf("fields to keep and fields to drop cannot be mixed (%q)", f)
+		}
+		if name == "id" && p.exclude {
+			return nil, fmt.Errorf("the id cannot be dropped")
+		}
+		key, inMetadata := strings.CutPrefix(name, "metadata.")
+		if !inMetadata && slices.Contains(documentFields, name) {
+			p.top[name] = true
+			continue
+		}
+		path := strings.Split(key, ".")
+		for _, part := range path {
+			if part == "" {
+				return nil, fmt.Errorf("invalid field %q", f)
+			}
+		}
+		p.paths = append(p.paths, path)
+	}
+	return p, nil
+}
+
+func (p *Project) Name() string {
+	return "fields(" + p.spec + ")"
+}
+
+func (p *Project) Process(docs []types.Document) ([]types.Document, error) {
+	for i := range docs {
+		if p.exclude {
+			p.drop(&docs[i])
+		} else {
+			p.keep(&docs[i])
+		}
+	}
+	return docs, nil
+}
+
+// keep clears every field not listed
+func (p *Project) keep(doc *types.Document) {
+	if !p.top["source"] {
+		doc.Source = ""
+	}
+	if !p.top["content"] {
+		doc.Content = ""
+	}
+	if !p.top["embedding"] {
+		doc.Embedding = nil
+	}
+	if !p.top["score"] {
+		doc.Score = 0
+	}
+	if !p.top["updated_at"] {
+		doc.UpdatedAt = time.Time{}
+	}
+	if p.top["metadata"] || doc.Metadata == nil {
+		return
+	}
+	kept := map[string]any{}
+	for _, path := range p.paths {
+		if v, ok := lookupPath(doc.Metadata, path); ok {
+			setPath(kept, path, v)
+		}
+	}
+	doc.Metadata = kept
+}
+
+// drop clears the fields listed
+func (p *Project) drop(doc *types.Document) {
+	for name := range p.top {
+		switch name {
+		case "source":
+			doc.Source = ""
+		case "content":
+			doc.Content = ""
+		case "metadata":
+			doc.Metadata = nil
+		case "embedding":
+			doc.Embedding = nil
+		case "score":
+			doc.Score = 0
+		case "updated_at":
+			doc.UpdatedAt = time.Time{}
+		}
+	}
+	for _, path := range p.paths {
+		deletePath(doc.Metadata, path)
+	}
+}
+
+func lookupPath(m map[string]any, path []string) (any, bool) {
+	var v any = m
+	for _, name := range path {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = obj[name]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func setPath(m map[string]any, path []string, v any) {
+	for _, name := range path[:len(path)-1] {
+		next, ok := m[name].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			m[name] = next
+		}
+		m = next
+	}
+	m[path[len(path)-1]] = v
+}
+
+func deletePath(m map[string]any, path []string) {
+	for _, name := range path[:len(path)-1] {
+		next, ok := m[name].(map[string]any)
+		if !ok {
+			return
+		}
+		m = next
+	}
+	delete(m, path[len(path)-1])
+}
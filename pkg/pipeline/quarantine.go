@@ -0,0 +1,68 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// QuarantineRecord is one line of the quarantine file. Tweets dropped by
+// moderation carry their scores; records failing validation carry the reason.
+type QuarantineRecord struct {
+	RemovedAt  string             `json:"removed_at"`
+	Stage      string             `json:"stage,omitempty"` // "moderate" or "validate"
+	Reason     string             `json:"reason,omitempty"`
+	Score      float64            `json:"score,omitempty"`
+	Categories []string           `json:"categories,omitempty"`
+	Scores     map[string]float64 `json:"scores,omitempty"`
+	Document   types.Document     `json:"document"`
+}
+
+// quarantineFile appends records to the quarantine file at path, opening it
+// on first use
+type quarantineFile struct {
+	path string
+	f    *os.File
+}
+
+// open creates the quarantine file and its directory if needed
+func (q *quarantineFile) open() error {
+	if q.f != nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(q.path), 0755); err != nil {
+		return fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+	f, err := os.OpenFile(q.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open quarantine file: %w", err)
+	}
+	q.f = f
+	return nil
+}
+
+// write appends rec as one line
+func (q *quarantineFile) write(rec QuarantineRecord) error {
+	if err := q.open(); err != nil {
+		return err
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal quarantine record: %w", err)
+	}
+	if _, err := q.f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write quarantine record: %w", err)
+	}
+	return nil
+}
+
+// Close closes the file, if it was opened
+func (q *quarantineFile) Close() error {
+	if q == nil || q.f == nil {
+		return nil
+	}
+	return q.f.Close()
+}
@@ -0,0 +1,116 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Actions the timestamps stage takes on tweets whose created_at cannot be parsed
+const (
+	TimestampsDrop = "drop"
+	TimestampsTag  = "tag"
+)
+
+// timestampLayouts are the created_at formats seen from the API and in older datasets
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	time.RubyDate, // Twitter v1.1: "Wed Oct 10 20:19:24 +0000 2018"
+	time.RFC1123Z,
+	time.RFC1123,
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02T15:04:05", // No zone: taken as UTC
+	"2006-01-02 15:04:05",
+	time.DateOnly,
+}
+
+// Timestamps rewrites created_at as an RFC 3339 UTC timestamp, whatever format
+// it arrived in, and drops or tags tweets where it is missing or unparseable
+type Timestamps struct {
+	action string
+}
+
+// NewTimestamps creates a timestamps stage that will "drop" or "tag" tweets
+// without a valid created_at
+func NewTimestamps(action string) (*Timestamps, error) {
+	if action != TimestampsDrop && action != TimestampsTag {
+		return nil, fmt.Errorf("unknown timestamps action %q (supported: %s, %s)", action, TimestampsDrop, TimestampsTag)
+	}
+	return &Timestamps{action: action}, nil
+}
+
+func (t *Timestamps) Name() string {
+	return "timestamps(" + t.action + ")"
+}
+
+func (t *Timestamps) Process(docs []types.Document) ([]types.Document, error) {
+	kept := docs[:0]
+	for _, doc := range docs {
+		created, ok := ParseTimestamp(doc.Metadata["created_at"])
+		switch {
+		case ok:
+			doc.Metadata["created_at"] = created.UTC().Format(time.RFC3339)
+			delete(doc.Metadata, "timestamp_invalid")
+		case t.action == TimestampsDrop:
+			continue
+		default:
+			// The original value is kept aside so created_at is always valid
+			if doc.Metadata == nil {
+				doc.Metadata = make(map[string]any)
+			}
+			if raw, ok := doc.Metadata["created_at"]; ok {
+				doc.Metadata["created_at_raw"] = raw
+				delete(doc.Metadata, "created_at")
+			}
+			doc.Metadata["timestamp_invalid"] = true
+		}
+		kept = append(kept, doc)
+	}
+	return kept, nil
+}
+
+// ParseTimestamp parses a created_at value: a string in one of the known
+// layouts or a Unix time in seconds or milliseconds, as a number or a string
+func ParseTimestamp(v any) (time.Time, bool) {
+	switch v := v.(type) {
+	case string:
+		s := strings.TrimSpace(v)
+		for _, layout := range timestampLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, true
+			}
+		}
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return unixTime(n)
+		}
+	case float64:
+		return unixTime(int64(v))
+	case int64:
+		return unixTime(v)
+	case int:
+		return unixTime(int64(v))
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return unixTime(n)
+		}
+	}
+	return time.Time{}, false
+}
+
+// unixTime takes values past the year 2286 in seconds as milliseconds, and
+// rejects values before Twitter existed
+func unixTime(n int64) (time.Time, bool) {
+	if n > 1e10 {
+		n /= 1000
+	}
+	// 2006-03-21, the first tweet
+	if n < 1142899200 {
+		return time.Time{}, false
+	}
+	return time.Unix(n, 0).UTC(), true
+}
@@ -0,0 +1,36 @@
+package pipeline
+
+import (
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Topic stamps every record with the query it was collected for, and the
+// trend the query was built from if any, in the query and trend metadata
+// fields. The dataset file records them too, but only the records survive
+// merging datasets or streaming them to a sink.
+type Topic struct {
+	trend, query string
+}
+
+// NewTopic creates a stage stamping records with query and, unless it is
+// empty, trend
+func NewTopic(trend, query string) *Topic {
+	return &Topic{trend: trend, query: query}
+}
+
+func (t *Topic) Name() string {
+	return "topic"
+}
+
+func (t *Topic) Process(docs []types.Document) ([]types.Document, error) {
+	for i := range docs {
+		if docs[i].Metadata == nil {
+			docs[i].Metadata = map[string]any{}
+		}
+		docs[i].Metadata["query"] = t.query
+		if t.trend != "" {
+			docs[i].Metadata["trend"] = t.trend
+		}
+	}
+	return docs, nil
+}
@@ -0,0 +1,125 @@
+package pipeline
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// transcribeBatchSize is the number of videos sent per request; each can take
+// the endpoint a while
+const transcribeBatchSize = 4
+
+// TranscribeConfig configures the transcription endpoint
+type TranscribeConfig struct {
+	URL      string // Endpoint accepting {"media": [urls], "language": ...} requests
+	APIKey   string // Optional bearer token
+	Language string // Optional ISO 639-1 hint, e.g. "en"; empty to detect
+}
+
+// Transcribe sends the videos of records to a speech-to-text endpoint and
+// stores the transcripts, for speech-text datasets from social media video.
+// Videos are passed by URL: the videos metadata of tweets, and the page URL
+// of TikTok records that have no transcription_text yet. Records without
+// video are left as they are. A failed request does not drop records: they
+// are kept without transcripts, with the error in transcript_error.
+type Transcribe struct {
+	cfg      TranscribeConfig
+	endpoint *textEndpoint
+}
+
+type transcribeRequest struct {
+	Media    []string `json:"media"`
+	Language string   `json:"language,omitempty"`
+}
+
+// NewTranscribeFromEnv creates a transcribe stage for the endpoint in
+// TRANSCRIPTION_URL and TRANSCRIPTION_API_KEY
+func NewTranscribeFromEnv(language string) (*Transcribe, error) {
+	return NewTranscribe(TranscribeConfig{
+		URL:      os.Getenv("TRANSCRIPTION_URL"),
+		APIKey:   os.Getenv("TRANSCRIPTION_API_KEY"),
+		Language: language,
+	})
+}
+
+// NewTranscribe creates a transcribe stage
+func NewTranscribe(cfg TranscribeConfig) (*Transcribe, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("transcription endpoint is required (set TRANSCRIPTION_URL)")
+	}
+	return &Transcribe{
+		cfg:      cfg,
+		endpoint: &textEndpoint{name: "transcription", url: cfg.URL, apiKey: cfg.APIKey, httpClient: &http.Client{Timeout: 10 * time.Minute}},
+	}, nil
+}
+
+func (s *Transcribe) Name() string {
+	if s.cfg.Language != "" {
+		return fmt.Sprintf("transcribe(lang=%s)", s.cfg.Language)
+	}
+	return "transcribe"
+}
+
+// Process adds transcripts to the tweets with videos, one per video in the
+// order of videos, and transcription_text to TikTok records
+func (s *Transcribe) Process(docs []types.Document) ([]types.Document, error) {
+	type video struct{ doc, index int }
+	var urls []string
+	var owners []video
+	for i, doc := range docs {
+		for j, url := range VideoURLs(doc) {
+			urls = append(urls, url)
+			owners = append(owners, video{i, j})
+		}
+	}
+
+	for start := 0; start < len(urls); start += transcribeBatchSize {
+		end := min(start+transcribeBatchSize, len(urls))
+		texts, err := s.endpoint.extract(transcribeRequest{Media: urls[start:end], Language: s.cfg.Language}, end-start)
+		for k, owner := range owners[start:end] {
+			doc := &docs[owner.doc]
+			if doc.Metadata == nil {
+				doc.Metadata = make(map[string]any)
+			}
+			switch {
+			case err != nil:
+				doc.Metadata["transcript_error"] = err.Error()
+			case doc.Source == types.TiktokSource:
+				doc.Metadata["transcription_text"] = texts[k]
+			default:
+				list, _ := doc.Metadata["transcripts"].([]string)
+				if list == nil {
+					list = make([]string, len(VideoURLs(*doc)))
+				}
+				list[owner.index] = texts[k]
+				doc.Metadata["transcripts"] = list
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️ Transcription failed for %d videos, keeping their records without it: %v\n", end-start, err)
+		}
+	}
+	return docs, nil
+}
+
+// VideoURLs returns the videos of a record to transcribe: the URLs in a
+// tweet's videos metadata (the HLS playlist for videos without a file URL),
+// or the page URL of a TikTok record that has no transcription yet
+func VideoURLs(doc types.Document) []string {
+	if doc.Source != types.TiktokSource {
+		return mediaURLs(doc.Metadata, "videos", "hls_url")
+	}
+	if text, _ := doc.Metadata["transcription_text"].(string); text != "" {
+		return nil
+	}
+	for _, key := range []string{"url", "original_url"} {
+		if url, _ := doc.Metadata[key].(string); url != "" {
+			return []string{url}
+		}
+	}
+	return nil
+}
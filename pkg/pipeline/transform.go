@@ -0,0 +1,169 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/grant/sn42/pkg/expr"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+	"gopkg.in/yaml.v3"
+)
+
+// TransformRule is one entry of a transforms file. A rule either filters
+// tweets, with Drop or Keep, or edits their metadata, with Set and Unset
+// applied to the tweets matching If (all tweets if empty).
+type TransformRule struct {
+	Name  string         `yaml:"name"`
+	Drop  string         `yaml:"drop"` // Drop tweets matching this expression
+	Keep  string         `yaml:"keep"` // Drop tweets not matching this expression
+	If    string         `yaml:"if"`
+	Set   map[string]any `yaml:"set"`   // Metadata keys to set, dotted for nested objects
+	Unset []string       `yaml:"unset"` // Metadata keys to remove
+}
+
+// Transform applies the rules of a transforms file to every tweet in order,
+// so simple filters and enrichments need no code changes. Expressions use
+// the `dataset query` syntax.
+type Transform struct {
+	path  string
+	rules []transformRule
+}
+
+type transformRule struct {
+	drop, keep, cond *expr.Expr
+	set              map[string]any
+	unset            [][]string
+}
+
+// LoadTransforms reads a transforms file, a YAML list of rules
+func LoadTransforms(path string) (*Transform, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transforms: %w", err)
+	}
+	var rules []TransformRule
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse transforms %s: %w", path, err)
+	}
+	return NewTransform(path, rules)
+}
+
+// NewTransform compiles transform rules; path names them in the manifest
+func NewTransform(path string, rules []TransformRule) (*Transform, error) {
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("%s has no rules", path)
+	}
+	t := &Transform{path: path}
+	for i, r := range rules {
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		compiled, err := r.compile()
+		if err != nil {
+			return nil, fmt.Errorf("transform rule %s: %w", name, err)
+		}
+		t.rules = append(t.rules, compiled)
+	}
+	return t, nil
+}
+
+func (r TransformRule) compile() (transformRule, error) {
+	var c transformRule
+	filters := r.Drop != "" || r.Keep != ""
+	edits := len(r.Set) > 0 || len(r.Unset) > 0
+	switch {
+	case r.Drop != "" && r.Keep != "":
+		return c, fmt.Errorf("drop and keep cannot be used together")
+	case filters && (edits || r.If != ""):
+		return c, fmt.Errorf("drop and keep cannot be combined with if, set or unset; use a separate rule")
+	case !filters && !edits:
+		return c, fmt.Errorf("no drop, keep, set or unset given")
+	}
+
+	var err error
+	parse := func(s string) *expr.Expr {
+		if s == "" || err != nil {
+			return nil
+		}
+		var e *expr.Expr
+		if e, err = expr.Parse(s); err != nil {
+			err = fmt.Errorf("invalid expression %q: %w", s, err)
+		}
+		return e
+	}
+	c.drop, c.keep, c.cond = parse(r.Drop), parse(r.Keep), parse(r.If)
+	if err != nil {
+		return c, err
+	}
+
+	c.set = map[string]any{}
+	for key, v := range r.Set {
+		if err := checkMetadataKey(key); err != nil {
+			return c, err
+		}
+		c.set[strings.TrimPrefix(key, "metadata.")] = v
+	}
+	for _, key := range r.Unset {
+		if err := checkMetadataKey(key); err != nil {
+			return c, err
+		}
+		c.unset = append(c.unset, strings.Split(strings.TrimPrefix(key, "metadata."), "."))
+	}
+	return c, nil
+}
+
+// checkMetadataKey rejects keys naming document fields, which rules cannot edit
+func checkMetadataKey(key string) error {
+	if slices.Contains(documentFields, key) {
+		return fmt.Errorf("%q is a document field; set and unset only edit metadata keys", key)
+	}
+	if strings.Contains(key, "..") || strings.HasPrefix(key, ".") || strings.HasSuffix(key, ".") || key == "" {
+		return fmt.Errorf("invalid metadata key %q", key)
+	}
+	return nil
+}
+
+func (t *Transform) Name() string {
+	return "transform(" + filepath.Base(t.path) + ")"
+}
+
+func (t *Transform) Process(docs []types.Document) ([]types.Document, error) {
+	kept := docs[:0]
+	for _, doc := range docs {
+		if t.apply(&doc) {
+			kept = append(kept, doc)
+		}
+	}
+	return kept, nil
+}
+
+// apply runs the rules on doc, reporting false once one of them drops it
+func (t *Transform) apply(doc *types.Document) bool {
+	for _, r := range t.rules {
+		switch {
+		case r.drop != nil:
+			if r.drop.Match(*doc) {
+				return false
+			}
+		case r.keep != nil:
+			if !r.keep.Match(*doc) {
+				return false
+			}
+		case r.cond == nil || r.cond.Match(*doc):
+			if doc.Metadata == nil {
+				doc.Metadata = make(map[string]any)
+			}
+			for key, v := range r.set {
+				setPath(doc.Metadata, strings.Split(key, "."), v)
+			}
+			for _, path := range r.unset {
+				deletePath(doc.Metadata, path)
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package pipeline
+
+import (
+	"slices"
+
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// TrendDedup keeps each tweet once across the trends of a run. A tweet
+// already kept for an earlier trend is dropped, and the trend being
+// collected is recorded as one more that it matched.
+type TrendDedup struct {
+	trend   string
+	matched map[string][]string // Tweet ID → trends it matched, the one it was kept for first
+	order   []string            // Tweet IDs in the order they were kept
+}
+
+// TrendMatch lists the trends one tweet matched, the one it was kept for first
+type TrendMatch struct {
+	ID     string   `json:"id"`
+	Trends []string `json:"trends"`
+}
+
+// NewTrendDedup creates a stage deduplicating tweets across trends
+func NewTrendDedup() *TrendDedup {
+	return &TrendDedup{matched: map[string][]string{}}
+}
+
+func (d *TrendDedup) Name() string {
+	return "dedup-trends"
+}
+
+// SetTrend sets the trend the following batches are collected for
+func (d *TrendDedup) SetTrend(trend string) {
+	d.trend = trend
+}
+
+func (d *TrendDedup) Process(docs []types.Document) ([]types.Document, error) {
+	kept := docs[:0]
+	for _, doc := range docs {
+		if doc.Id == "" {
+			kept = append(kept, doc)
+			continue
+		}
+		trends, seen := d.matched[doc.Id]
+		if !seen {
+			d.order = append(d.order, doc.Id)
+			kept = append(kept, doc)
+		}
+		if !slices.Contains(trends, d.trend) {
+			d.matched[doc.Id] = append(trends, d.trend)
+		}
+	}
+	return kept, nil
+}
+
+// Trends returns the trends the tweet with id matched, the one it was kept
+// for first
+func (d *TrendDedup) Trends(id string) []string {
+	return d.matched[id]
+}
+
+// Matches returns the trends of every tweet kept, in the order they were kept
+func (d *TrendDedup) Matches() []TrendMatch {
+	matches := make([]TrendMatch, len(d.order))
+	for i, id := range d.order {
+		matches[i] = TrendMatch{ID: id, Trends: d.matched[id]}
+	}
+	return matches
+}
+
+// Shared returns how many tweets matched more than one trend
+func (d *TrendDedup) Shared() int {
+	n := 0
+	for _, trends := range d.matched {
+		if len(trends) > 1 {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,91 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Validate keeps malformed records, those without an ID or without text, out
+// of the outputs. They are appended to the quarantine file with the reason
+// instead, so they can still be inspected.
+type Validate struct {
+	quarantine *quarantineFile // nil to drop invalid records without a trace
+}
+
+// NewValidate creates a validate stage quarantining invalid records to the
+// file at quarantine, which is created on the first one; empty to only drop
+// them
+func NewValidate(quarantine string) *Validate {
+	v := &Validate{}
+	if quarantine != "" {
+		v.quarantine = &quarantineFile{path: quarantine}
+	}
+	return v
+}
+
+func (v *Validate) Name() string {
+	return "validate"
+}
+
+func (v *Validate) Process(docs []types.Document) ([]types.Document, error) {
+	kept := docs[:0]
+	reasons := map[string]int{}
+	for _, doc := range docs {
+		reason := Invalid(doc)
+		if reason == "" {
+			kept = append(kept, doc)
+			continue
+		}
+		for _, problem := range strings.Split(reason, ", ") {
+			reasons[problem]++
+		}
+		if v.quarantine == nil {
+			continue
+		}
+		err := v.quarantine.write(QuarantineRecord{
+			RemovedAt: time.Now().UTC().Format(time.RFC3339),
+			Stage:     v.Name(),
+			Reason:    reason,
+			Document:  doc,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if dropped := len(docs) - len(kept); dropped > 0 {
+		where := "dropped"
+		if v.quarantine != nil {
+			where = "quarantined to " + v.quarantine.path
+		}
+		var counts []string
+		for problem, n := range reasons {
+			counts = append(counts, fmt.Sprintf("%d %s", n, problem))
+		}
+		sort.Strings(counts)
+		fmt.Fprintf(os.Stderr, "⚠️ %d invalid records %s (%s)\n", dropped, where, strings.Join(counts, ", "))
+	}
+	return kept, nil
+}
+
+// Close closes the quarantine file
+func (v *Validate) Close() error {
+	return v.quarantine.Close()
+}
+
+// Invalid returns why doc is not fit to be written, e.g. "missing id", or ""
+// if it is valid
+func Invalid(doc types.Document) string {
+	var problems []string
+	if strings.TrimSpace(doc.Id) == "" {
+		problems = append(problems, "missing id")
+	}
+	if strings.TrimSpace(doc.Content) == "" {
+		problems = append(problems, "empty text")
+	}
+	return strings.Join(problems, ", ")
+}
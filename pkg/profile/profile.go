@@ -0,0 +1,130 @@
+// Package profile adds Go profiling to the collectors: live pprof endpoints
+// and CPU and heap profiles written for a whole run.
+package profile
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"time"
+)
+
+// Flags holds the profiling command-line flags shared by the collectors
+type Flags struct {
+	Addr       string
+	CPUProfile string
+	MemProfile string
+}
+
+// RegisterFlags registers -pprof-addr, -cpuprofile and -memprofile on fs
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	fs.StringVar(&f.Addr, "pprof-addr", "", "Serve pprof endpoints (/debug/pprof/) on this address, e.g. localhost:6060 (disabled if empty)")
+	fs.StringVar(&f.CPUProfile, "cpuprofile", "", "Write a CPU profile of the run to this file")
+	fs.StringVar(&f.MemProfile, "memprofile", "", "Write a heap profile to this file when the run ends")
+	return f
+}
+
+// Profiler is a running profiling session. A nil Profiler does nothing.
+type Profiler struct {
+	cpu     *os.File
+	memPath string
+	stopped bool
+}
+
+// Start serves the pprof endpoints and starts the CPU profile as configured.
+// It returns nil when no profiling is requested.
+func (f *Flags) Start() (*Profiler, error) {
+	if f.Addr == "" && f.CPUProfile == "" && f.MemProfile == "" {
+		return nil, nil
+	}
+	if f.Addr != "" {
+		if err := serve(f.Addr); err != nil {
+			return nil, err
+		}
+		fmt.Printf("pprof endpoints on http://%s/debug/pprof/\n", f.Addr)
+	}
+
+	p := &Profiler{memPath: f.MemProfile}
+	if f.CPUProfile != "" {
+		cpu, err := os.Create(f.CPUProfile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create CPU profile: %w", err)
+		}
+		if err := runtimepprof.StartCPUProfile(cpu); err != nil {
+			cpu.Close()
+			return nil, fmt.Errorf("failed to start CPU profile: %w", err)
+		}
+		p.cpu = cpu
+	}
+	return p, nil
+}
+
+// Stop finishes the CPU profile and writes the heap profile. It is safe to
+// call more than once; only the first call has an effect.
+func (p *Profiler) Stop() error {
+	if p == nil || p.stopped {
+		return nil
+	}
+	p.stopped = true
+
+	var errs []error
+	if p.cpu != nil {
+		runtimepprof.StopCPUProfile()
+		if err := p.cpu.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to write CPU profile: %w", err))
+		} else {
+			fmt.Printf("CPU profile written to %s\n", p.cpu.Name())
+		}
+	}
+	if p.memPath != "" {
+		if err := writeHeap(p.memPath); err != nil {
+			errs = append(errs, err)
+		} else {
+			fmt.Printf("Heap profile written to %s\n", p.memPath)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func writeHeap(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create heap profile: %w", err)
+	}
+	// Collect garbage first so the profile shows live memory
+	runtime.GC()
+	if err := runtimepprof.WriteHeapProfile(f); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write heap profile: %w", err)
+	}
+	return f.Close()
+}
+
+// serve listens on addr and serves the standard pprof handlers in the background
+func serve(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("⚠️ pprof server stopped: %v\n", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,135 @@
+package query
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+var hashtagRegex = regexp.MustCompile(`#(\w+)`)
+
+// Hashtags returns the lowercased hashtags of a tweet, from its metadata when
+// present and otherwise parsed from the text
+func Hashtags(doc types.Document) []string {
+	var tags []string
+	if list, ok := doc.Metadata["hashtags"].([]any); ok {
+		for _, tag := range list {
+			if s, ok := tag.(string); ok && s != "" {
+				tags = append(tags, strings.ToLower(strings.TrimPrefix(s, "#")))
+			}
+		}
+		return tags
+	}
+	for _, m := range hashtagRegex.FindAllStringSubmatch(doc.Content, -1) {
+		tags = append(tags, strings.ToLower(m[1]))
+	}
+	return tags
+}
+
+// CoOccurringHashtags returns up to n hashtags that appear most often in docs,
+// most frequent first (ties by name). Each tweet counts once per hashtag,
+// hashtags seen in fewer than minCount tweets are ignored, and hashtags already
+// part of query are left out.
+func CoOccurringHashtags(docs []types.Document, query string, n, minCount int) []string {
+	exclude := map[string]bool{}
+	for _, term := range strings.Fields(strings.ToLower(query)) {
+		exclude[strings.Trim(term, `"()#`)] = true
+	}
+
+	counts := map[string]int{}
+	for _, doc := range docs {
+		seen := map[string]bool{}
+		for _, tag := range Hashtags(doc) {
+			if !seen[tag] && !exclude[tag] {
+				counts[tag]++
+				seen[tag] = true
+			}
+		}
+	}
+
+	var tags []string
+	for tag, count := range counts {
+		if count >= minCount {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if counts[tags[i]] != counts[tags[j]] {
+			return counts[tags[i]] > counts[tags[j]]
+		}
+		return tags[i] < tags[j]
+	})
+	return tags[:min(n, len(tags))]
+}
+
+// WithHashtags widens query so it also matches tweets carrying any of the
+// hashtags. Operators such as min_faves:100 or -filter:retweets keep applying
+// to the whole query: `"bitcoin" min_faves:100` with #btc becomes
+// `("bitcoin" OR #btc) min_faves:100`.
+func WithHashtags(query string, hashtags []string) string {
+	if len(hashtags) == 0 {
+		return query
+	}
+	terms, operators := splitOperators(query)
+	clauses := []string{}
+	if terms != "" {
+		clauses = append(clauses, terms)
+	}
+	for _, tag := range hashtags {
+		clauses = append(clauses, "#"+tag)
+	}
+	expanded := "(" + strings.Join(clauses, " OR ") + ")"
+	if operators != "" {
+		expanded += " " + operators
+	}
+	return expanded
+}
+
+// splitOperators separates search terms from operators (tokens like
+// name:value or starting with -), keeping quoted phrases intact
+func splitOperators(query string) (terms, operators string) {
+	var termTokens, operatorTokens []string
+	for _, token := range tokenize(query) {
+		if isOperator(token) {
+			operatorTokens = append(operatorTokens, token)
+		} else {
+			termTokens = append(termTokens, token)
+		}
+	}
+	return strings.Join(termTokens, " "), strings.Join(operatorTokens, " ")
+}
+
+// isOperator reports whether a query token is a search operator rather than a term
+func isOperator(token string) bool {
+	if strings.HasPrefix(token, `"`) || strings.HasPrefix(token, "(") {
+		return false
+	}
+	return strings.HasPrefix(token, "-") || strings.Contains(token, ":")
+}
+
+// tokenize splits a query on whitespace outside of double quotes
+func tokenize(query string) []string {
+	var tokens []string
+	var current strings.Builder
+	inQuotes := false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			current.WriteRune(r)
+		case (r == ' ' || r == '\t') && !inQuotes:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
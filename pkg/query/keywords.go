@@ -0,0 +1,130 @@
+package query
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/grant/sn42/pkg/twitterquery"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// KeywordBatch is one OR query covering several keywords
+type KeywordBatch struct {
+	Keywords []string
+	Query    string
+}
+
+// LoadKeywords reads keywords from a file, one per line. Blank lines, lines
+// starting with # followed by a space, and repeated keywords are skipped;
+// hashtags such as #btc are kept.
+func LoadKeywords(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open keywords file: %w", err)
+	}
+	defer f.Close()
+
+	var keywords []string
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line == "#" || strings.HasPrefix(line, "# ") {
+			continue
+		}
+		if key := strings.ToLower(line); !seen[key] {
+			seen[key] = true
+			keywords = append(keywords, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read keywords file: %w", err)
+	}
+	if len(keywords) == 0 {
+		return nil, fmt.Errorf("keywords file %s is empty", path)
+	}
+	return keywords, nil
+}
+
+// PackKeywords packs keywords into as few OR queries as fit within the query
+// length limit, each combined with the given operators (e.g. "min_faves:100").
+// Keywords are placed longest first into the first query with room (first-fit
+// decreasing), which comes close to the minimum number of queries.
+func PackKeywords(keywords []string, operators string) ([]KeywordBatch, error) {
+	sorted := slices.Clone(keywords)
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	var batches []KeywordBatch
+	for _, kw := range sorted {
+		placed := false
+		for i := range batches {
+			q, err := twitterquery.Raw(operators).AnyOf(append(slices.Clone(batches[i].Keywords), kw)...).Build()
+			if err == nil {
+				batches[i].Keywords = append(batches[i].Keywords, kw)
+				batches[i].Query = q
+				placed = true
+				break
+			}
+		}
+		if placed {
+			continue
+		}
+		q, err := twitterquery.Raw(operators).AnyOf(kw).Build()
+		if err != nil {
+			return nil, fmt.Errorf("keyword %q: %w", kw, err)
+		}
+		batches = append(batches, KeywordBatch{Keywords: []string{kw}, Query: q})
+	}
+	return batches, nil
+}
+
+// MatchKeywords returns the keywords a tweet matches: hashtag keywords match the
+// tweet's hashtags, other keywords match whole words of its text case-insensitively
+func MatchKeywords(doc types.Document, keywords []string) []string {
+	text := strings.ToLower(doc.Content)
+	hashtags := Hashtags(doc)
+
+	var matched []string
+	for _, kw := range keywords {
+		lower := strings.ToLower(kw)
+		if tag, ok := strings.CutPrefix(lower, "#"); ok {
+			if slices.Contains(hashtags, tag) {
+				matched = append(matched, kw)
+			}
+			continue
+		}
+		if containsWord(text, lower) {
+			matched = append(matched, kw)
+		}
+	}
+	return matched
+}
+
+// containsWord reports whether word occurs in text on word boundaries, so
+// "eth" does not match "ethereum"
+func containsWord(text, word string) bool {
+	for start := 0; ; {
+		i := strings.Index(text[start:], word)
+		if i < 0 {
+			return false
+		}
+		i += start
+		end := i + len(word)
+		before, _ := utf8.DecodeLastRuneInString(text[:i])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			return true
+		}
+		start = i + 1
+	}
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_')
+}
@@ -0,0 +1,38 @@
+package query
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LoadQueries reads search queries from a file, one per line, each run as
+// it is. Blank lines, lines starting with # followed by a space, and repeated
+// queries are skipped; a query starting with a hashtag is kept.
+func LoadQueries(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open queries file: %w", err)
+	}
+	defer f.Close()
+
+	var queries []string
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line == "#" || strings.HasPrefix(line, "# ") || seen[line] {
+			continue
+		}
+		seen[line] = true
+		queries = append(queries, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read queries file: %w", err)
+	}
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("queries file %s is empty", path)
+	}
+	return queries, nil
+}
@@ -0,0 +1,108 @@
+// Package query builds search queries for the collectors.
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var placeholderRegex = regexp.MustCompile(`\{(\w+)\}`)
+
+// Matrix is a query template with a table of values for each of its
+// placeholders. It expands to one query per combination of values, e.g.
+//
+//	{"template": "{keyword} min_faves:{min_faves}",
+//	 "variables": {"keyword": ["bitcoin", "ethereum"], "min_faves": [100, 1000]}}
+type Matrix struct {
+	Template  string              `json:"template"`
+	Variables map[string][]string `json:"-"`
+}
+
+// UnmarshalJSON accepts strings and numbers as variable values
+func (m *Matrix) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Template  string           `json:"template"`
+		Variables map[string][]any `json:"variables"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	m.Template = raw.Template
+	m.Variables = make(map[string][]string, len(raw.Variables))
+	for name, values := range raw.Variables {
+		for _, v := range values {
+			switch v := v.(type) {
+			case string:
+				m.Variables[name] = append(m.Variables[name], v)
+			case float64:
+				m.Variables[name] = append(m.Variables[name], strconv.FormatFloat(v, 'f', -1, 64))
+			default:
+				return fmt.Errorf("variable %q: values must be strings or numbers, got %v", name, v)
+			}
+		}
+	}
+	return nil
+}
+
+// LoadMatrix reads a query matrix from a JSON file
+func LoadMatrix(path string) (*Matrix, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read query matrix: %w", err)
+	}
+	var m Matrix
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse query matrix %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// Expand returns every query produced by substituting the variables into the
+// template. Placeholders vary in the order they appear in the template, the
+// last one fastest. Every placeholder needs at least one value and every
+// variable must be used.
+func (m *Matrix) Expand() ([]string, error) {
+	if strings.TrimSpace(m.Template) == "" {
+		return nil, fmt.Errorf("query matrix has no template")
+	}
+
+	var names []string
+	for _, match := range placeholderRegex.FindAllStringSubmatch(m.Template, -1) {
+		if !slices.Contains(names, match[1]) {
+			names = append(names, match[1])
+		}
+	}
+	for _, name := range names {
+		if len(m.Variables[name]) == 0 {
+			return nil, fmt.Errorf("template placeholder {%s} has no values", name)
+		}
+	}
+	var unused []string
+	for name := range m.Variables {
+		if !slices.Contains(names, name) {
+			unused = append(unused, name)
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		return nil, fmt.Errorf("variables not used in template: %s", strings.Join(unused, ", "))
+	}
+
+	queries := []string{m.Template}
+	for _, name := range names {
+		var next []string
+		for _, q := range queries {
+			for _, v := range m.Variables[name] {
+				next = append(next, strings.ReplaceAll(q, "{"+name+"}", v))
+			}
+		}
+		queries = next
+	}
+	return queries, nil
+}
@@ -0,0 +1,118 @@
+package query
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grant/sn42/pkg/twitterquery"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultSavedQueriesFile is read when SAVED_QUERIES is not set
+const DefaultSavedQueriesFile = "queries.yaml"
+
+var savedNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Saved is a named query from the saved queries file
+type Saved struct {
+	Name    string  `yaml:"name"`
+	Query   string  `yaml:"query"`
+	Amount  int     `yaml:"amount"` // Default number of tweets to collect, 0 to use AMOUNT
+	Filters Filters `yaml:"filters"`
+}
+
+// Filters are search operators added to a saved query
+type Filters struct {
+	Lang            string `yaml:"lang"`
+	MinFaves        *int   `yaml:"min_faves"`
+	ExcludeRetweets bool   `yaml:"exclude_retweets"`
+	From            string `yaml:"from"`
+	Since           string `yaml:"since"` // YYYY-MM-DD
+	Until           string `yaml:"until"` // YYYY-MM-DD
+}
+
+// Build returns the saved query with its filters applied
+func (s *Saved) Build() (string, error) {
+	b := twitterquery.Raw(s.Query)
+	f := s.Filters
+	if f.From != "" {
+		b.From(f.From)
+	}
+	if f.Lang != "" {
+		b.Lang(f.Lang)
+	}
+	if f.MinFaves != nil {
+		b.MinFaves(*f.MinFaves)
+	}
+	if f.Since != "" {
+		t, err := time.Parse(time.DateOnly, f.Since)
+		if err != nil {
+			return "", fmt.Errorf("saved query %s: invalid since date %q (expected YYYY-MM-DD)", s.Name, f.Since)
+		}
+		b.Since(t)
+	}
+	if f.Until != "" {
+		t, err := time.Parse(time.DateOnly, f.Until)
+		if err != nil {
+			return "", fmt.Errorf("saved query %s: invalid until date %q (expected YYYY-MM-DD)", s.Name, f.Until)
+		}
+		b.Until(t)
+	}
+	if f.ExcludeRetweets {
+		b.ExcludeRetweets()
+	}
+	q, err := b.Build()
+	if err != nil {
+		return "", fmt.Errorf("saved query %s: %w", s.Name, err)
+	}
+	return q, nil
+}
+
+// SavedQueriesPath returns the saved queries file from SAVED_QUERIES, or the default
+func SavedQueriesPath() string {
+	if path := os.Getenv("SAVED_QUERIES"); path != "" {
+		return path
+	}
+	return DefaultSavedQueriesFile
+}
+
+// LoadSaved reads a saved queries file, a YAML list of queries
+func LoadSaved(path string) ([]Saved, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read saved queries: %w", err)
+	}
+	var saved []Saved
+	if err := yaml.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("failed to parse saved queries %s: %w", path, err)
+	}
+
+	names := map[string]bool{}
+	for _, s := range saved {
+		if !savedNameRegex.MatchString(s.Name) {
+			return nil, fmt.Errorf("saved query name %q must be lowercase letters, digits, - or _", s.Name)
+		}
+		if names[s.Name] {
+			return nil, fmt.Errorf("saved query %s is defined more than once", s.Name)
+		}
+		names[s.Name] = true
+	}
+	return saved, nil
+}
+
+// FindSaved returns the saved query called name
+func FindSaved(saved []Saved, name string) (*Saved, error) {
+	names := make([]string, len(saved))
+	for i := range saved {
+		if saved[i].Name == name {
+			return &saved[i], nil
+		}
+		names[i] = saved[i].Name
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("no saved query named %q (available: %s)", name, strings.Join(names, ", "))
+}
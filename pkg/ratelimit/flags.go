@@ -0,0 +1,41 @@
+package ratelimit
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gopher-lab/gopher-client/client"
+)
+
+// Flags holds the rate limit command-line flags shared by the collectors
+type Flags struct {
+	Wait    bool
+	MaxWait time.Duration
+}
+
+// RegisterFlags registers -rate-limit-wait and -max-rate-limit-wait on fs
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	fs.BoolVar(&f.Wait, "rate-limit-wait", true, "When the API reports its rate limit is used up, hold requests until it resets instead of failing them into the retry backoff")
+	fs.DurationVar(&f.MaxWait, "max-rate-limit-wait", 5*time.Minute, "Fail instead of waiting for a rate limit that resets further away than this")
+	return f
+}
+
+// Attach routes c's requests through a Pacer, unless -rate-limit-wait is
+// off, in which case it returns nil
+func (f *Flags) Attach(c *client.Client) (*Pacer, error) {
+	if !f.Wait {
+		return nil, nil
+	}
+	if f.MaxWait <= 0 {
+		return nil, fmt.Errorf("-max-rate-limit-wait must be positive, got %s", f.MaxWait)
+	}
+	if c.HTTPClient == nil {
+		c.HTTPClient = &http.Client{}
+	}
+	p := NewPacer(c.HTTPClient.Transport, f.MaxWait)
+	c.HTTPClient.Transport = p
+	return p, nil
+}
@@ -0,0 +1,202 @@
+// Package ratelimit paces the gopher client by the rate limits the API
+// reports. It reads the Retry-After and X-RateLimit-* (or IETF RateLimit-*)
+// headers of every response, and the retry_after or reset fields of a 429
+// error body; when the quota is used up, the next request waits exactly
+// until it resets instead of failing into the collectors' fixed backoff.
+package ratelimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxAttempts bounds how many times one request is resent after a 429
+const maxAttempts = 5
+
+// Pacer is an http.RoundTripper that holds requests back while the API's
+// rate limit is exhausted and resends a request rejected with 429 once the
+// limit has reset
+type Pacer struct {
+	base    http.RoundTripper
+	maxWait time.Duration
+
+	mu     sync.Mutex
+	until  time.Time // No request goes out before this
+	waits  int
+	waited time.Duration
+}
+
+// NewPacer paces the requests sent through base (http.DefaultTransport if
+// nil). A reset further away than maxWait is not waited for: the request
+// fails with the API's 429 as it would without pacing.
+func NewPacer(base http.RoundTripper, maxWait time.Duration) *Pacer {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Pacer{base: base, maxWait: maxWait}
+}
+
+func (p *Pacer) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		if err := p.wait(req); err != nil {
+			return nil, err
+		}
+		resp, err := p.base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		reset, limited := p.observe(resp)
+		if !limited || attempt == maxAttempts || req.Body != nil && req.GetBody == nil {
+			return resp, nil
+		}
+		wait := reset.Sub(time.Now())
+		if reset.IsZero() || wait > p.maxWait {
+			return resp, nil
+		}
+		// Resend once the limit resets, with a fresh copy of the body
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// wait sleeps until the limit resets, if it is exhausted
+func (p *Pacer) wait(req *http.Request) error {
+	p.mu.Lock()
+	wait := p.until.Sub(time.Now())
+	if wait > 0 {
+		p.waits++
+		p.waited += wait
+	}
+	p.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	fmt.Printf("⏳ Rate limit reached; waiting %s until it resets\n", wait.Round(time.Second))
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// observe records the rate limit reported by resp. It returns when the limit
+// resets, if known, and whether resp was a 429.
+func (p *Pacer) observe(resp *http.Response) (time.Time, bool) {
+	limited := resp.StatusCode == http.StatusTooManyRequests
+	now := time.Now()
+	reset, exhausted := Reset(resp.Header, now)
+	if limited && reset.IsZero() {
+		reset = p.bodyReset(resp, now)
+	}
+	if !limited && !exhausted || reset.IsZero() || reset.Sub(now) > p.maxWait {
+		return reset, limited
+	}
+	p.mu.Lock()
+	if reset.After(p.until) {
+		p.until = reset
+	}
+	p.mu.Unlock()
+	return reset, limited
+}
+
+// bodyReset reads the reset time from a JSON error body such as
+// {"error": "rate limited", "retry_after": 30}, leaving the body readable
+func (p *Pacer) bodyReset(resp *http.Response, now time.Time) time.Time {
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return time.Time{}
+	}
+	var body struct {
+		RetryAfter json.Number `json:"retry_after"`
+		Reset      json.Number `json:"reset"`
+		ResetAt    string      `json:"reset_at"`
+	}
+	if json.Unmarshal(data, &body) != nil {
+		return time.Time{}
+	}
+	if s, err := body.RetryAfter.Float64(); err == nil {
+		return now.Add(time.Duration(s * float64(time.Second)))
+	}
+	if t := resetValue(body.Reset.String(), now); !t.IsZero() {
+		return t
+	}
+	if t, err := time.Parse(time.RFC3339, body.ResetAt); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+// Reset reads the rate limit headers of a response received at now. It
+// returns when the limit resets, or the zero time if the headers do not
+// say, and whether the limit is exhausted: a Retry-After header, or no
+// requests remaining.
+func Reset(h http.Header, now time.Time) (time.Time, bool) {
+	if v := h.Get("Retry-After"); v != "" {
+		if s, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			return now.Add(time.Duration(s) * time.Second), true
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return t, true
+		}
+	}
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		remaining := h.Get(prefix + "Remaining")
+		if remaining == "" {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(remaining))
+		return resetValue(h.Get(prefix+"Reset"), now), err == nil && n <= 0
+	}
+	return time.Time{}, false
+}
+
+// resetValue parses a reset header or field, which is either a Unix time
+// or, as in the IETF RateLimit headers, a number of seconds from now
+func resetValue(v string, now time.Time) time.Time {
+	s, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || s < 0 {
+		return time.Time{}
+	}
+	// A billion seconds is over 30 years: anything larger is a timestamp
+	if s > 1e9 {
+		return time.Unix(int64(s), 0)
+	}
+	return now.Add(time.Duration(s * float64(time.Second)))
+}
+
+// Waits returns how many requests were held back and for how long in total.
+// A nil Pacer has none.
+func (p *Pacer) Waits() (int, time.Duration) {
+	if p == nil {
+		return 0, 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.waits, p.waited
+}
+
+// Stats describes the waits so far
+func (p *Pacer) Stats() string {
+	n, d := p.Waits()
+	return fmt.Sprintf("held back %d requests for %s in total", n, d.Round(time.Second))
+}
@@ -0,0 +1,229 @@
+// Package report builds the end-of-run summary written by the collectors: one
+// row per query with what was requested, fetched, dropped and saved, written
+// as summary.json and as a Markdown table for posting to PRs or chat.
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Query is the outcome of collecting one query or trend
+type Query struct {
+	Query     string   `json:"query"`
+	Label     string   `json:"label,omitempty"` // Saved query name, keyword batch or trend
+	Requested int      `json:"requested"`
+	Fetched   int      `json:"fetched"` // Tweets returned by the API
+	Dropped   int      `json:"dropped"` // Removed by dedup, moderation and other stages
+	Saved     int      `json:"saved"`   // Records written to the output file
+	Error     string   `json:"error,omitempty"`
+	Seconds   float64  `json:"duration_seconds"`
+	Files     []string `json:"files,omitempty"`
+}
+
+// Summary describes one collection run
+type Summary struct {
+	Tool       string   `json:"tool"`
+	StartedAt  string   `json:"started_at"`
+	FinishedAt string   `json:"finished_at,omitempty"`
+	Seconds    float64  `json:"duration_seconds"`
+	Status     string   `json:"status"`         // One of the Status constants
+	Note       string   `json:"note,omitempty"` // e.g. why the run stopped early
+	Queries    []Query  `json:"queries"`
+	Files      []string `json:"files,omitempty"` // Outputs not tied to one query, e.g. a combined dataset
+	Totals     Totals   `json:"totals"`
+	Stages     []Stage  `json:"stages,omitempty"` // Where the run's time went, across all queries
+
+	started time.Time
+}
+
+// Run statuses
+const (
+	StatusOK      = "ok"
+	StatusPartial = "partial" // Some queries failed
+	StatusFailed  = "failed"  // Every query failed, or the run was aborted
+)
+
+// Totals sums the per-query counts
+type Totals struct {
+	Requested int `json:"requested"`
+	Fetched   int `json:"fetched"`
+	Dropped   int `json:"dropped"`
+	Saved     int `json:"saved"`
+	Errors    int `json:"errors"`
+}
+
+// Stage is the time a run spent in one step of collection, summed over all
+// its queries: the API requests, a pipeline stage, or writing the output
+type Stage struct {
+	Name    string  `json:"name"`  // "fetch", the pipeline stage's name, "sink" or "save"
+	Phase   string  `json:"phase"` // fetch, process or write
+	Calls   int     `json:"calls"` // Batches handled
+	In      int     `json:"records_in"`
+	Out     int     `json:"records_out"`
+	Seconds float64 `json:"duration_seconds"`
+}
+
+// StageShares describes the stages in one line with each one's share of the
+// time spent in all of them, e.g. "fetch 41.2s (96%), dedup(id) 812µs (0%)"
+func StageShares(stages []Stage) string {
+	var total float64
+	for _, st := range stages {
+		total += st.Seconds
+	}
+	parts := make([]string, len(stages))
+	for i, st := range stages {
+		parts[i] = fmt.Sprintf("%s %s (%s)", st.Name, formatStageSeconds(st.Seconds), share(st.Seconds, total))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func share(part, total float64) string {
+	if total <= 0 {
+		return "0%"
+	}
+	return fmt.Sprintf("%.0f%%", 100*part/total)
+}
+
+// formatStageSeconds keeps microseconds, as most stages take far less than
+// the 100ms run durations are rounded to
+func formatStageSeconds(s float64) string {
+	d := time.Duration(s * float64(time.Second))
+	if d >= time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(time.Microsecond).String()
+}
+
+// New starts the summary of a run of tool
+func New(tool string) *Summary {
+	now := time.Now()
+	return &Summary{Tool: tool, StartedAt: now.UTC().Format(time.RFC3339), Queries: []Query{}, started: now}
+}
+
+// Add records the outcome of one query
+func (s *Summary) Add(q Query) {
+	s.Queries = append(s.Queries, q)
+}
+
+// Finish stamps the end time and fills in the totals and status
+func (s *Summary) Finish() {
+	now := time.Now()
+	s.FinishedAt = now.UTC().Format(time.RFC3339)
+	s.Seconds = seconds(now.Sub(s.started))
+
+	s.Totals = Totals{}
+	for _, q := range s.Queries {
+		s.Totals.Requested += q.Requested
+		s.Totals.Fetched += q.Fetched
+		s.Totals.Dropped += q.Dropped
+		s.Totals.Saved += q.Saved
+		if q.Error != "" {
+			s.Totals.Errors++
+		}
+	}
+	switch {
+	case s.Totals.Errors == 0:
+		s.Status = StatusOK
+	case s.Totals.Errors < len(s.Queries):
+		s.Status = StatusPartial
+	default:
+		s.Status = StatusFailed
+	}
+}
+
+// Abort finishes the summary of a run that stopped on err
+func (s *Summary) Abort(err error) {
+	s.Finish()
+	s.Status = StatusFailed
+	s.Note = strings.TrimSpace(s.Note + " Aborted: " + err.Error())
+}
+
+// Since returns the time elapsed since start in seconds, as stored in Query
+func Since(start time.Time) float64 {
+	return seconds(time.Since(start))
+}
+
+func seconds(d time.Duration) float64 {
+	return d.Round(100 * time.Millisecond).Seconds()
+}
+
+// Markdown renders the summary as a heading, a per-query table and totals
+func (s *Summary) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s run summary\n\n", s.Tool)
+	fmt.Fprintf(&b, "Status: **%s** · started %s · %s\n", s.Status, s.StartedAt, formatSeconds(s.Seconds))
+	if s.Note != "" {
+		fmt.Fprintf(&b, "\n%s\n", s.Note)
+	}
+	b.WriteString("\n| Query | Requested | Fetched | Dropped | Saved | Duration | Files | Error |\n")
+	b.WriteString("|-------|-----------|---------|---------|-------|----------|-------|-------|\n")
+	for _, q := range s.Queries {
+		name := "`" + cell(q.Query) + "`"
+		if q.Label != "" {
+			name = cell(q.Label) + " (" + name + ")"
+		}
+		files := make([]string, len(q.Files))
+		for i, f := range q.Files {
+			files[i] = "`" + cell(filepath.Base(f)) + "`"
+		}
+		fmt.Fprintf(&b, "| %s | %d | %d | %d | %d | %s | %s | %s |\n",
+			name, q.Requested, q.Fetched, q.Dropped, q.Saved, formatSeconds(q.Seconds), strings.Join(files, "<br>"), cell(q.Error))
+	}
+	t := s.Totals
+	fmt.Fprintf(&b, "| **Total** | %d | %d | %d | %d | %s | | %d errors |\n", t.Requested, t.Fetched, t.Dropped, t.Saved, formatSeconds(s.Seconds), t.Errors)
+	if len(s.Stages) > 0 {
+		var total float64
+		for _, st := range s.Stages {
+			total += st.Seconds
+		}
+		b.WriteString("\nTime by stage:\n\n")
+		b.WriteString("| Stage | Phase | Batches | Records in | Records out | Time | Share |\n")
+		b.WriteString("|-------|-------|---------|------------|-------------|------|-------|\n")
+		for _, st := range s.Stages {
+			fmt.Fprintf(&b, "| `%s` | %s | %d | %d | %d | %s | %s |\n",
+				cell(st.Name), st.Phase, st.Calls, st.In, st.Out, formatStageSeconds(st.Seconds), share(st.Seconds, total))
+		}
+	}
+	if len(s.Files) > 0 {
+		b.WriteString("\nCombined outputs:\n")
+		for _, f := range s.Files {
+			fmt.Fprintf(&b, "- `%s`\n", f)
+		}
+	}
+	return b.String()
+}
+
+// cell escapes text for a Markdown table cell
+func cell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+func formatSeconds(s float64) string {
+	return (time.Duration(s * float64(time.Second))).Round(100 * time.Millisecond).String()
+}
+
+// Write writes the summary as JSON to path and as Markdown next to it (same
+// name with a .md extension). It returns the Markdown path.
+func (s *Summary) Write(path string) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create summary directory: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal summary: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write summary: %w", err)
+	}
+	mdPath := strings.TrimSuffix(path, filepath.Ext(path)) + ".md"
+	if err := os.WriteFile(mdPath, []byte(s.Markdown()), 0644); err != nil {
+		return "", fmt.Errorf("failed to write summary: %w", err)
+	}
+	return mdPath, nil
+}
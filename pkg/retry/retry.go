@@ -0,0 +1,219 @@
+// Package retry keeps a disk-persisted queue of batches that failed after all
+// retries, so they can be collected again later without redoing the parts of
+// the run that succeeded.
+package retry
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/grant/sn42/pkg/collect"
+	"github.com/grant/sn42/pkg/crypt"
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/manifest"
+	"github.com/grant/sn42/pkg/report"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// QueueFile is the name of the queue the collectors write in their run directory
+const QueueFile = "retry_queue.jsonl"
+
+// Entry is one failed batch: the query, the page it failed on and how many
+// tweets were still missing, plus the dataset file they belong to
+type Entry struct {
+	Tool      string `json:"tool"`
+	Query     string `json:"query"`
+	Trend     string `json:"trend,omitempty"`
+	MaxID     int64  `json:"max_id,omitempty"` // Page to resume from, 0 for the first page
+	Remaining int    `json:"remaining"`
+	File      string `json:"file"`
+	Error     string `json:"error"`
+	FailedAt  string `json:"failed_at"`
+}
+
+// NewEntry describes the batch that failed with be, for the dataset file at path
+func NewEntry(tool, path string, be *collect.BatchError) Entry {
+	return Entry{
+		Tool:      tool,
+		Query:     be.Query,
+		MaxID:     be.MaxID,
+		Remaining: be.Remaining,
+		File:      path,
+		Error:     be.Err.Error(),
+		FailedAt:  time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// Append adds e to the queue file at path, creating it if needed
+func Append(path string, e Entry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create retry queue directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open retry queue: %w", err)
+	}
+	if err := json.NewEncoder(f).Encode(e); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write retry queue: %w", err)
+	}
+	return f.Close()
+}
+
+// Load reads every entry of the queue file at path
+func Load(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open retry queue: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid retry entry: %w", path, line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read retry queue: %w", err)
+	}
+	return entries, nil
+}
+
+// save rewrites the queue file with entries, removing it when none are left
+func save(path string, entries []Entry) error {
+	if len(entries) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove retry queue: %w", err)
+		}
+		return nil
+	}
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("failed to encode retry entry: %w", err)
+		}
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write retry queue: %w", err)
+	}
+	return nil
+}
+
+// Replay collects every entry of the queue at path again with c and merges
+// the tweets into the entry's dataset file, updating its manifest. Entries
+// that fail again stay queued with their new resume point, and the queue file
+// is removed once empty. It returns one result per entry for the run summary.
+func Replay(ctx context.Context, c *collect.Collector, path string) ([]report.Query, error) {
+	entries, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Replaying %d failed batches from %s\n", len(entries), path)
+
+	var results []report.Query
+	var pending []Entry
+	for i, e := range entries {
+		fmt.Printf("\n=== Retry %d/%d: %s (%d tweets into %s) ===\n", i+1, len(entries), e.Query, e.Remaining, e.File)
+		if err := c.Budget.Check(); err != nil {
+			fmt.Printf("⚠️ %v; keeping the remaining %d batches queued\n", err, len(entries)-i)
+			pending = append(pending, entries[i:]...)
+			break
+		}
+
+		start := time.Now()
+		result := report.Query{Query: e.Query, Label: "retry", Requested: e.Remaining}
+		counts := collect.Stats{}
+		collector := *c
+		collector.Stats = &counts
+		// The tweets are merged into the file here, so they must be returned
+		collector.FlushEvery, collector.MaxBufferBytes = 0, 0
+		tweets, err := collector.Resume(ctx, e.Query, e.MaxID, e.Remaining)
+		result.Fetched, result.Dropped = counts.Fetched, counts.Dropped
+
+		// A batch that fails again is queued from where it stopped this time
+		var requeue *Entry
+		var be *collect.BatchError
+		if errors.As(err, &be) {
+			fmt.Printf("❌ Failed again: %v\n", err)
+			result.Error = err.Error()
+			next := NewEntry(e.Tool, e.File, be)
+			next.Trend = e.Trend
+			requeue = &next
+		} else if err != nil && !errors.Is(err, collect.ErrBudgetExhausted) {
+			result.Error = err.Error()
+		}
+
+		if len(tweets) > 0 {
+			added, err := merge(e, tweets)
+			if err != nil {
+				// Nothing was saved, so the whole batch is queued again
+				fmt.Printf("❌ %v\n", err)
+				result.Error = err.Error()
+				requeue = &e
+			} else {
+				result.Saved = added
+				result.Files = []string{e.File, manifest.Path(e.File), manifest.CardPath(e.File)}
+				fmt.Printf("✅ Added %d tweets to %s\n", added, e.File)
+			}
+		}
+		if requeue != nil {
+			pending = append(pending, *requeue)
+		}
+		result.Seconds = report.Since(start)
+		results = append(results, result)
+	}
+
+	if err := save(path, pending); err != nil {
+		return results, err
+	}
+	if len(pending) > 0 {
+		fmt.Printf("\n🔁 %d batches still queued in %s\n", len(pending), path)
+	} else {
+		fmt.Printf("\n✅ Retry queue %s is empty\n", path)
+	}
+	return results, nil
+}
+
+// merge appends tweets not already in the entry's dataset file, creating it if
+// needed, and rewrites its manifest. It returns the number of tweets added.
+func merge(e Entry, tweets []types.Document) (int, error) {
+	path := e.File
+	var key []byte
+	var err error
+	if strings.HasSuffix(path, crypt.Extension) {
+		if key, err = crypt.KeyFromEnv(); err != nil {
+			return 0, fmt.Errorf("%s is encrypted: %w", path, err)
+		}
+	}
+	// max_id is inclusive, so the first resumed tweet may already be saved
+	added, total, err := dataset.Append(path, dataset.File{Query: e.Query, Trend: e.Trend}, tweets, key)
+	if err != nil {
+		return 0, err
+	}
+
+	m, err := manifest.Load(path)
+	if err != nil {
+		m = &manifest.Manifest{Tool: e.Tool, Query: e.Query, Trend: e.Trend, Provenance: manifest.ProvenanceFromEnv()}
+	}
+	m.Records = total
+	m.Pipeline = append(m.Pipeline, fmt.Sprintf("retry(max_id=%d,added=%d)", e.MaxID, added))
+	if _, err := manifest.Write(path, m); err != nil {
+		return 0, err
+	}
+	return added, nil
+}
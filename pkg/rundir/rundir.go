@@ -0,0 +1,177 @@
+// Package rundir gives each collection run a unique ID and a directory under
+// runs/ holding everything the run writes: datasets, manifests, the summary,
+// the retry queue, quarantined tweets and a copy of the console output.
+package rundir
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Root is the directory runs are created in. A profile's data_dir changes it
+// before the first run is created.
+var Root = "runs"
+
+// Latest is the symlink in Root pointing at the most recent run
+const Latest = "latest"
+
+// LogFile is the name of the console output copy in a run directory
+const LogFile = "run.log"
+
+// Run is the directory of one run
+type Run struct {
+	ID   string
+	Path string
+
+	log     *os.File
+	stdout  *os.File // The terminal, while output is copied to the log
+	stderr  *os.File
+	copying sync.WaitGroup
+}
+
+// New creates the directory of a new run, points the latest symlink at it
+// and starts copying stdout and stderr into its log
+func New() (*Run, error) {
+	id, err := newID(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	r := &Run{ID: id, Path: filepath.Join(Root, id)}
+	if err := os.MkdirAll(r.Path, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create run directory: %w", err)
+	}
+	if err := r.link(); err != nil {
+		// The run itself does not need the symlink, e.g. where symlinks are unsupported
+		fmt.Fprintf(os.Stderr, "⚠️ %v\n", err)
+	}
+	if err := r.startLog(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// newID is the run's start time in UTC plus a random suffix, so runs sort
+// by time and two runs started in the same second do not collide
+func newID(start time.Time) (string, error) {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate run ID: %w", err)
+	}
+	return start.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix), nil
+}
+
+// link atomically replaces the latest symlink with one pointing at the run
+func (r *Run) link() error {
+	tmp := filepath.Join(Root, "."+Latest+"-"+r.ID)
+	if err := os.Symlink(r.ID, tmp); err != nil {
+		return fmt.Errorf("failed to link %s: %w", filepath.Join(Root, Latest), err)
+	}
+	if err := os.Rename(tmp, filepath.Join(Root, Latest)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to link %s: %w", filepath.Join(Root, Latest), err)
+	}
+	return nil
+}
+
+// startLog redirects stdout and stderr through pipes that copy into the log
+// as well as the terminal. The log package writes to both directly, so
+// log.Fatal messages are not lost when it exits.
+func (r *Run) startLog() error {
+	f, err := os.Create(r.File(LogFile))
+	if err != nil {
+		return fmt.Errorf("failed to create run log: %w", err)
+	}
+	r.log, r.stdout, r.stderr = f, os.Stdout, os.Stderr
+	tee := func(terminal *os.File) (*os.File, error) {
+		pr, pw, err := os.Pipe()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create run log: %w", err)
+		}
+		r.copying.Add(1)
+		go func() {
+			defer r.copying.Done()
+			io.Copy(io.MultiWriter(terminal, f), pr)
+			pr.Close()
+		}()
+		return pw, nil
+	}
+	if os.Stdout, err = tee(r.stdout); err != nil {
+		return err
+	}
+	if os.Stderr, err = tee(r.stderr); err != nil {
+		return err
+	}
+	log.SetOutput(io.MultiWriter(r.stderr, f))
+	return nil
+}
+
+// File returns the path of the named file in the run directory
+func (r *Run) File(name string) string {
+	return filepath.Join(r.Path, name)
+}
+
+// Default points the path flag name at the named file in the run directory,
+// unless it was given on the command line; an explicit empty value still
+// disables what the flag controls
+func (r *Run) Default(name string, p *string, file string) {
+	setDefault(name, p, r.File(file))
+}
+
+// DefaultRoot points the path flag name at the named file in Root, for files
+// shared by all runs, unless it was given on the command line
+func DefaultRoot(name string, p *string, file string) {
+	setDefault(name, p, filepath.Join(Root, file))
+}
+
+func setDefault(name string, p *string, path string) {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	if !set {
+		*p = path
+	}
+}
+
+// Close restores stdout and stderr and finishes the log
+func (r *Run) Close() error {
+	if r.log == nil {
+		return nil
+	}
+	r.restore()
+	log.SetOutput(r.stderr)
+	err := r.log.Close()
+	r.log = nil
+	return err
+}
+
+// Fatalf is log.Fatalf for the run: the message also ends its log
+func (r *Run) Fatalf(format string, v ...any) {
+	if r.log != nil {
+		r.restore()
+	}
+	log.Printf(format, v...)
+	r.Close()
+	os.Exit(1)
+}
+
+// restore points stdout and stderr back at the terminal once the pipes
+// have been drained into the log
+func (r *Run) restore() {
+	if os.Stdout == r.stdout {
+		return
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = r.stdout, r.stderr
+	stdout.Close()
+	stderr.Close()
+	r.copying.Wait()
+}
@@ -0,0 +1,298 @@
+// Package sample selects random and stratified subsets of collected documents.
+package sample
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Attributes that documents can be stratified by
+const (
+	ByLang    = "lang"
+	ByHashtag = "hashtag"
+	ByAuthor  = "author"
+	ByDay     = "day"
+)
+
+// Attributes lists the supported stratification attributes
+var Attributes = []string{ByLang, ByHashtag, ByAuthor, ByDay}
+
+// Unknown is the stratum of documents that lack the attribute
+const Unknown = "(none)"
+
+var hashtagRegex = regexp.MustCompile(`#(\w+)`)
+
+// KeyFunc returns the stratum a document belongs to
+type KeyFunc func(doc types.Document) string
+
+// Key returns the KeyFunc for a stratification attribute
+func Key(attribute string) (KeyFunc, error) {
+	switch attribute {
+	case ByLang:
+		return func(doc types.Document) string { return metadataString(doc, "lang") }, nil
+	case ByAuthor:
+		return func(doc types.Document) string { return metadataString(doc, "username") }, nil
+	case ByDay:
+		return func(doc types.Document) string {
+			t, err := time.Parse(time.RFC3339, metadataString(doc, "created_at"))
+			if err != nil {
+				return Unknown
+			}
+			return t.UTC().Format(time.DateOnly)
+		}, nil
+	case ByHashtag:
+		return firstHashtag, nil
+	}
+	return nil, fmt.Errorf("unknown stratification attribute %q (supported: %s)", attribute, strings.Join(Attributes, ", "))
+}
+
+// metadataString returns a string metadata field, or Unknown if it is missing
+func metadataString(doc types.Document, field string) string {
+	if s, ok := doc.Metadata[field].(string); ok && s != "" {
+		return s
+	}
+	return Unknown
+}
+
+// firstHashtag returns the first hashtag of a tweet, lowercased. Tweets with
+// several hashtags are assigned to the first so each belongs to one stratum.
+func firstHashtag(doc types.Document) string {
+	if tags, ok := doc.Metadata["hashtags"].([]any); ok {
+		for _, tag := range tags {
+			if s, ok := tag.(string); ok && s != "" {
+				return strings.ToLower(strings.TrimPrefix(s, "#"))
+			}
+		}
+	}
+	if m := hashtagRegex.FindStringSubmatch(doc.Content); m != nil {
+		return strings.ToLower(m[1])
+	}
+	return Unknown
+}
+
+// Random returns n documents chosen uniformly at random without replacement,
+// in their original order. If n >= len(docs) all documents are returned.
+func Random(docs []types.Document, n int, rng *rand.Rand) []types.Document {
+	indices := make([]int, len(docs))
+	for i := range indices {
+		indices[i] = i
+	}
+	return byIndex(docs, pick(indices, n, rng))
+}
+
+// pick chooses n of the indices at random and returns them sorted.
+// It reorders indices in place.
+func pick(indices []int, n int, rng *rand.Rand) []int {
+	n = min(n, len(indices))
+	// Partial Fisher-Yates shuffle: the first n entries become the sample
+	for i := 0; i < n; i++ {
+		j := i + rng.IntN(len(indices)-i)
+		indices[i], indices[j] = indices[j], indices[i]
+	}
+	chosen := slices.Clone(indices[:n])
+	sort.Ints(chosen)
+	return chosen
+}
+
+// byIndex returns the documents at the given indices
+func byIndex(docs []types.Document, indices []int) []types.Document {
+	out := make([]types.Document, len(indices))
+	for i, idx := range indices {
+		out[i] = docs[idx]
+	}
+	return out
+}
+
+// Stratum reports how one stratum was sampled
+type Stratum struct {
+	Name      string `json:"name"`
+	Available int    `json:"available"`
+	Target    int    `json:"target"`
+	Selected  int    `json:"selected"`
+}
+
+// Stratified samples n documents so that each stratum is represented in
+// proportion to its share of docs. If proportions is non-nil it sets the target
+// share of each listed stratum instead (weights are normalized to sum to 1) and
+// unlisted strata are left out. A stratum with fewer documents than its target
+// contributes all of them, so the result can be smaller than n; the returned
+// strata show where that happened. Documents are returned in their original order.
+func Stratified(docs []types.Document, n int, key KeyFunc, proportions map[string]float64, rng *rand.Rand) ([]types.Document, []Stratum) {
+	groups := map[string][]int{}
+	for i, doc := range docs {
+		k := key(doc)
+		groups[k] = append(groups[k], i)
+	}
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	for name := range proportions {
+		if _, ok := groups[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	weights := make([]float64, len(names))
+	for i, name := range names {
+		if proportions != nil {
+			weights[i] = proportions[name]
+		} else {
+			weights[i] = float64(len(groups[name]))
+		}
+	}
+	targets := allocate(n, weights)
+
+	strata := make([]Stratum, len(names))
+	var chosen []int
+	for i, name := range names {
+		indices := groups[name]
+		selected := min(targets[i], len(indices))
+		chosen = append(chosen, pick(indices, selected, rng)...)
+		strata[i] = Stratum{Name: name, Available: len(indices), Target: targets[i], Selected: selected}
+	}
+	sort.Ints(chosen)
+	return byIndex(docs, chosen), strata
+}
+
+// allocate splits n into integer shares proportional to weights using the
+// largest remainder method, so the shares always sum to n
+func allocate(n int, weights []float64) []int {
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+	shares := make([]int, len(weights))
+	if total <= 0 {
+		return shares
+	}
+
+	type remainder struct {
+		index int
+		frac  float64
+	}
+	remainders := make([]remainder, len(weights))
+	assigned := 0
+	for i, w := range weights {
+		exact := float64(n) * w / total
+		shares[i] = int(exact)
+		assigned += shares[i]
+		remainders[i] = remainder{i, exact - float64(shares[i])}
+	}
+	sort.SliceStable(remainders, func(a, b int) bool { return remainders[a].frac > remainders[b].frac })
+	for i := 0; i < n-assigned; i++ {
+		shares[remainders[i%len(remainders)].index]++
+	}
+	return shares
+}
+
+// ParseProportions parses target proportions such as "en=0.5,es=0.3,pt=0.2"
+func ParseProportions(spec string) (map[string]float64, error) {
+	proportions := map[string]float64{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid proportion %q (expected name=weight)", item)
+		}
+		var w float64
+		if _, err := fmt.Sscanf(value, "%g", &w); err != nil || w < 0 {
+			return nil, fmt.Errorf("invalid weight in %q", item)
+		}
+		proportions[strings.TrimSpace(name)] = w
+	}
+	if len(proportions) == 0 {
+		return nil, fmt.Errorf("no proportions given")
+	}
+	return proportions, nil
+}
+
+// Engagement returns the sum of a tweet's likes, retweets and replies
+func Engagement(doc types.Document) float64 {
+	var total float64
+	for _, field := range []string{"likes", "retweets", "replies"} {
+		switch v := doc.Metadata[field].(type) {
+		case float64:
+			total += v
+		case int:
+			total += float64(v)
+		case int64:
+			total += float64(v)
+		}
+	}
+	return total
+}
+
+// Top returns the n documents with the highest engagement, most engaged first.
+// Ties are broken by document ID so the result does not depend on input order.
+func Top(docs []types.Document, n int) []types.Document {
+	sorted := slices.Clone(docs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ei, ej := Engagement(sorted[i]), Engagement(sorted[j])
+		if ei != ej {
+			return ei > ej
+		}
+		return sorted[i].Id < sorted[j].Id
+	})
+	return sorted[:min(n, len(sorted))]
+}
+
+// Reservoir keeps a fixed-size uniform random sample of a stream of documents
+// using Algorithm R, holding at most size documents in memory however long the
+// stream is
+type Reservoir struct {
+	size  int
+	seen  int
+	items []reservoirItem
+	rng   *rand.Rand
+}
+
+type reservoirItem struct {
+	pos int // Position in the stream, used to restore stream order
+	doc types.Document
+}
+
+// NewReservoir creates a reservoir holding up to size documents
+func NewReservoir(size int, rng *rand.Rand) *Reservoir {
+	return &Reservoir{size: size, items: make([]reservoirItem, 0, size), rng: rng}
+}
+
+// Add offers documents from the stream to the reservoir
+func (r *Reservoir) Add(docs ...types.Document) {
+	for _, doc := range docs {
+		if len(r.items) < r.size {
+			r.items = append(r.items, reservoirItem{r.seen, doc})
+		} else if j := r.rng.IntN(r.seen + 1); j < r.size {
+			r.items[j] = reservoirItem{r.seen, doc}
+		}
+		r.seen++
+	}
+}
+
+// Seen returns the number of documents offered so far
+func (r *Reservoir) Seen() int {
+	return r.seen
+}
+
+// Docs returns the current sample in stream order
+func (r *Reservoir) Docs() []types.Document {
+	items := slices.Clone(r.items)
+	sort.Slice(items, func(i, j int) bool { return items[i].pos < items[j].pos })
+	docs := make([]types.Document, len(items))
+	for i, item := range items {
+		docs[i] = item.doc
+	}
+	return docs
+}
@@ -0,0 +1,55 @@
+package sample
+
+import (
+	"flag"
+	"math/rand/v2"
+	"strconv"
+)
+
+// Seed is a -seed command-line flag. When no seed is given a random one is
+// chosen, so every run can still be reproduced from the seed it reports.
+type Seed struct {
+	value uint64
+	set   bool
+}
+
+// RegisterSeedFlag defines the -seed flag on fs
+func RegisterSeedFlag(fs *flag.FlagSet) *Seed {
+	s := &Seed{}
+	fs.Var(s, "seed", "Random seed; the same seed and inputs give byte-identical output (default: random, recorded in the manifest)")
+	return s
+}
+
+func (s *Seed) String() string {
+	if s == nil || !s.set {
+		return ""
+	}
+	return strconv.FormatUint(s.value, 10)
+}
+
+func (s *Seed) Set(v string) error {
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return err
+	}
+	s.value, s.set = n, true
+	return nil
+}
+
+// Value returns the seed, choosing a random one on first use if none was given
+func (s *Seed) Value() uint64 {
+	if !s.set {
+		s.value, s.set = rand.Uint64(), true
+	}
+	return s.value
+}
+
+// Rand returns a random source seeded with the seed
+func (s *Seed) Rand() *rand.Rand {
+	return NewRand(s.Value())
+}
+
+// NewRand returns a deterministic random source for seed
+func NewRand(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, seed))
+}
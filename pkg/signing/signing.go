@@ -0,0 +1,198 @@
+// Package signing creates and verifies detached ed25519 signatures for dataset
+// files, so consumers of published datasets can verify provenance and detect
+// tampering.
+//
+// Keys are stored as PEM-encoded PKCS#8 (private) and PKIX (public) files, the
+// same format produced by `openssl genpkey -algorithm ed25519`.
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Extension is appended to a file's name to form its signature file name
+const Extension = ".sig"
+
+// signatureVersion prefixes the signed message for domain separation
+const signatureVersion = "sn42-signature-v1"
+
+// Signature is the content of a detached signature file
+type Signature struct {
+	Version   string `json:"version"`
+	File      string `json:"file"` // Base name of the signed file
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"`
+	SignedAt  string `json:"signed_at"`
+	KeyID     string `json:"key_id"`    // SHA-256 fingerprint of the public key
+	Signature []byte `json:"signature"` // ed25519 signature over the signed message
+}
+
+// GenerateKey creates a new key pair and writes it to privPath and privPath + ".pub"
+func GenerateKey(privPath string) error {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return fmt.Errorf("failed to encode private key: %w", err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return fmt.Errorf("failed to encode public key: %w", err)
+	}
+
+	if err := os.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0600); err != nil {
+		return fmt.Errorf("failed to write private key: %w", err)
+	}
+	if err := os.WriteFile(privPath+".pub", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0644); err != nil {
+		return fmt.Errorf("failed to write public key: %w", err)
+	}
+	return nil
+}
+
+// LoadPrivateKey reads a PEM-encoded ed25519 private key
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", path, err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ed25519 private key", path)
+	}
+	return priv, nil
+}
+
+// LoadPublicKey reads a PEM-encoded ed25519 public key
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	der, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %w", path, err)
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ed25519 public key", path)
+	}
+	return pub, nil
+}
+
+// KeyID returns the hex SHA-256 fingerprint of a public key
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:])
+}
+
+// SignFile signs the file at path and writes the signature to path + Extension
+func SignFile(priv ed25519.PrivateKey, path string) (*Signature, error) {
+	sum, size, err := hashFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := &Signature{
+		Version:  signatureVersion,
+		File:     filepath.Base(path),
+		Size:     size,
+		SHA256:   sum,
+		SignedAt: time.Now().UTC().Format(time.RFC3339),
+		KeyID:    KeyID(priv.Public().(ed25519.PublicKey)),
+	}
+	sig.Signature = ed25519.Sign(priv, sig.message())
+
+	data, err := json.MarshalIndent(sig, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal signature: %w", err)
+	}
+	if err := os.WriteFile(path+Extension, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write signature: %w", err)
+	}
+	return sig, nil
+}
+
+// VerifyFile checks the file at path against its detached signature at
+// path + Extension. It returns the parsed signature on success.
+func VerifyFile(pub ed25519.PublicKey, path string) (*Signature, error) {
+	data, err := os.ReadFile(path + Extension)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signature: %w", err)
+	}
+	var sig Signature
+	if err := json.Unmarshal(data, &sig); err != nil {
+		return nil, fmt.Errorf("failed to parse signature: %w", err)
+	}
+	if sig.Version != signatureVersion {
+		return nil, fmt.Errorf("unsupported signature version %q", sig.Version)
+	}
+	if sig.KeyID != KeyID(pub) {
+		return nil, fmt.Errorf("signed by a different key (key ID %s)", sig.KeyID)
+	}
+	if !ed25519.Verify(pub, sig.message(), sig.Signature) {
+		return nil, fmt.Errorf("invalid signature")
+	}
+
+	// The signature is authentic; now check the file still matches it
+	sum, size, err := hashFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Base(path) != sig.File {
+		return nil, fmt.Errorf("signature is for %s, not %s", sig.File, filepath.Base(path))
+	}
+	if size != sig.Size || sum != sig.SHA256 {
+		return nil, fmt.Errorf("file has been modified since it was signed")
+	}
+	return &sig, nil
+}
+
+// message is the byte string covered by the ed25519 signature
+func (s *Signature) message() []byte {
+	return fmt.Appendf(nil, "%s\n%s\n%d\n%s\n%s\n", s.Version, s.File, s.Size, s.SHA256, s.SignedAt)
+}
+
+func hashFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+func readPEM(path, blockType string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("%s does not contain a PEM %s block", path, blockType)
+	}
+	return block.Bytes, nil
+}
@@ -0,0 +1,125 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Buffered decouples fetching from a slow sink: batches are handed to a
+// background writer through a bounded queue, so fetching continues while the
+// sink catches up, and Write blocks once the queue is full instead of
+// buffering without limit.
+type Buffered struct {
+	next  Sink
+	queue chan Batch
+	done  chan struct{}
+
+	mu    sync.Mutex
+	err   error // First error of the background writer
+	stats QueueStats
+}
+
+// QueueStats describes how full a Buffered queue got during a run
+type QueueStats struct {
+	Capacity      int
+	Batches       int           // Batches written
+	MaxDepth      int           // Most batches waiting at once
+	BlockedWrites int           // Writes that waited for room in the queue
+	Blocked       time.Duration // Total time fetching waited on the sink
+}
+
+func (s QueueStats) String() string {
+	return fmt.Sprintf("%d batches, max depth %d/%d, fetching blocked %d times for %s",
+		s.Batches, s.MaxDepth, s.Capacity, s.BlockedWrites, s.Blocked.Round(time.Millisecond))
+}
+
+// NewBuffered wraps next with a queue of up to depth batches
+func NewBuffered(next Sink, depth int) *Buffered {
+	b := &Buffered{
+		next:  next,
+		queue: make(chan Batch, depth),
+		done:  make(chan struct{}),
+		stats: QueueStats{Capacity: depth},
+	}
+	go b.run()
+	return b
+}
+
+func (b *Buffered) run() {
+	defer close(b.done)
+	for batch := range b.queue {
+		if b.failed() != nil {
+			continue // Drain the queue so Write and Close never block on a dead writer
+		}
+		if err := b.next.Write(context.Background(), batch); err != nil {
+			b.mu.Lock()
+			b.err = err
+			b.mu.Unlock()
+		}
+	}
+}
+
+func (b *Buffered) failed() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+// Write queues batch for the background writer, blocking while the queue is
+// full. It returns the writer's error once a previous batch has failed.
+func (b *Buffered) Write(ctx context.Context, batch Batch) error {
+	if err := b.failed(); err != nil {
+		return err
+	}
+	// The caller keeps using its documents while this batch waits, so the
+	// queue gets its own copies of their metadata
+	docs := make([]types.Document, len(batch.Docs))
+	for i, doc := range batch.Docs {
+		doc.Metadata = maps.Clone(doc.Metadata)
+		docs[i] = doc
+	}
+	batch.Docs = docs
+
+	blocked := false
+	start := time.Now()
+	select {
+	case b.queue <- batch:
+	default:
+		blocked = true
+		select {
+		case b.queue <- batch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stats.Batches++
+	b.stats.MaxDepth = max(b.stats.MaxDepth, len(b.queue))
+	if blocked {
+		b.stats.BlockedWrites++
+		b.stats.Blocked += time.Since(start)
+	}
+	return nil
+}
+
+// Stats returns the queue metrics so far
+func (b *Buffered) Stats() QueueStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
+
+// Close waits for the queued batches to be written and closes the wrapped sink
+func (b *Buffered) Close() error {
+	close(b.queue)
+	<-b.done
+	return errors.Join(b.failed(), b.next.Close())
+}
@@ -0,0 +1,192 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ElasticsearchConfig configures the Elasticsearch/OpenSearch sink
+type ElasticsearchConfig struct {
+	URL         string // Cluster base URL, e.g. http://localhost:9200
+	Index       string // Target index name
+	MappingFile string // Optional path to a JSON file with the index settings/mappings
+	Username    string // Optional basic auth username
+	Password    string // Optional basic auth password
+	APIKey      string // Optional API key (takes precedence over basic auth)
+}
+
+// Elasticsearch bulk-indexes documents into an Elasticsearch or OpenSearch index.
+// Both engines share the _bulk and index creation APIs used here.
+type Elasticsearch struct {
+	cfg        ElasticsearchConfig
+	httpClient *http.Client
+}
+
+// NewElasticsearchFromEnv creates an Elasticsearch sink from ES_* environment variables
+func NewElasticsearchFromEnv() (*Elasticsearch, error) {
+	return NewElasticsearch(ElasticsearchConfig{
+		URL:         os.Getenv("ES_URL"),
+		Index:       os.Getenv("ES_INDEX"),
+		MappingFile: os.Getenv("ES_MAPPING"),
+		Username:    os.Getenv("ES_USERNAME"),
+		Password:    os.Getenv("ES_PASSWORD"),
+		APIKey:      os.Getenv("ES_API_KEY"),
+	})
+}
+
+// NewElasticsearch creates the sink and ensures the target index exists,
+// creating it with the provided mapping if it does not
+func NewElasticsearch(cfg ElasticsearchConfig) (*Elasticsearch, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("elasticsearch URL is required")
+	}
+	if cfg.Index == "" {
+		return nil, fmt.Errorf("elasticsearch index is required (set ES_INDEX)")
+	}
+	cfg.URL = strings.TrimRight(cfg.URL, "/")
+
+	es := &Elasticsearch{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+	if err := es.ensureIndex(context.Background()); err != nil {
+		return nil, err
+	}
+	return es, nil
+}
+
+// ensureIndex creates the index with the configured mapping unless it already exists
+func (es *Elasticsearch) ensureIndex(ctx context.Context) error {
+	resp, err := es.do(ctx, http.MethodHead, "/"+es.cfg.Index, nil, "")
+	if err != nil {
+		return fmt.Errorf("failed to check index %s: %w", es.cfg.Index, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("unexpected status %d checking index %s", resp.StatusCode, es.cfg.Index)
+	}
+
+	var body []byte
+	if es.cfg.MappingFile != "" {
+		body, err = os.ReadFile(es.cfg.MappingFile)
+		if err != nil {
+			return fmt.Errorf("failed to read mapping file: %w", err)
+		}
+		if !json.Valid(body) {
+			return fmt.Errorf("mapping file %s is not valid JSON", es.cfg.MappingFile)
+		}
+	}
+
+	resp, err = es.do(ctx, http.MethodPut, "/"+es.cfg.Index, body, "application/json")
+	if err != nil {
+		return fmt.Errorf("failed to create index %s: %w", es.cfg.Index, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to create index %s: status %d: %s", es.cfg.Index, resp.StatusCode, respBody)
+	}
+	return nil
+}
+
+// Write sends the batch to the _bulk API, using the document ID as the index ID
+// so re-running a collection updates records instead of duplicating them
+func (es *Elasticsearch) Write(ctx context.Context, batch Batch) error {
+	if len(batch.Docs) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, doc := range batch.Docs {
+		action := map[string]map[string]string{"index": {"_index": es.cfg.Index}}
+		if doc.Id != "" {
+			action["index"]["_id"] = doc.Id
+		}
+		if err := enc.Encode(action); err != nil {
+			return fmt.Errorf("failed to encode bulk action: %w", err)
+		}
+		if err := enc.Encode(doc); err != nil {
+			return fmt.Errorf("failed to encode document %s: %w", doc.Id, err)
+		}
+	}
+
+	resp, err := es.do(ctx, http.MethodPost, "/_bulk", buf.Bytes(), "application/x-ndjson")
+	if err != nil {
+		return fmt.Errorf("bulk request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read bulk response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("bulk request returned status %d: %s", resp.StatusCode, respBody)
+	}
+
+	// The bulk API returns 200 even when individual items fail
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID     string          `json:"_id"`
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return fmt.Errorf("failed to parse bulk response: %w", err)
+	}
+	if !result.Errors {
+		return nil
+	}
+
+	failed := 0
+	var firstErr string
+	for _, item := range result.Items {
+		for _, r := range item {
+			if len(r.Error) > 0 {
+				failed++
+				if firstErr == "" {
+					firstErr = fmt.Sprintf("document %s: %s", r.ID, r.Error)
+				}
+			}
+		}
+	}
+	return fmt.Errorf("%d of %d documents failed to index (first error: %s)", failed, len(batch.Docs), firstErr)
+}
+
+// Close is a no-op; every Write is sent synchronously
+func (es *Elasticsearch) Close() error {
+	return nil
+}
+
+func (es *Elasticsearch) do(ctx context.Context, method, path string, body []byte, contentType string) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, es.cfg.URL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if es.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+es.cfg.APIKey)
+	} else if es.cfg.Username != "" {
+		req.SetBasicAuth(es.cfg.Username, es.cfg.Password)
+	}
+	return es.httpClient.Do(req)
+}
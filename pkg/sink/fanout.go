@@ -0,0 +1,149 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// maxOptionalFailures is how many batches in a row an optional sink may fail
+// before a FanOut stops writing to it
+const maxOptionalFailures = 5
+
+// Named is a sink with the name it is reported under
+type Named struct {
+	Name     string
+	Sink     Sink
+	Optional bool // Its failures are reported without failing the write
+}
+
+// FanOut writes every batch to several sinks at once, each independently of
+// the others: a sink that fails or is slow does not keep the batch from the
+// rest. The write fails when a required sink failed. An optional sink's
+// failure is only counted, and after maxOptionalFailures batches in a row
+// the sink is given up on for the rest of the run.
+type FanOut struct {
+	sinks []*fanOutSink
+}
+
+type fanOutSink struct {
+	Named
+	stats  SinkStats
+	inARow int
+}
+
+// SinkStats describes how one sink of a FanOut fared
+type SinkStats struct {
+	Name      string
+	Optional  bool
+	Batches   int    // Batches written
+	Failed    int    // Batches that failed
+	Skipped   int    // Batches not written once it was given up on
+	GaveUp    bool   // Batches stopped being written to it after repeated failures
+	LastError string // Error of the last failed batch
+}
+
+func (s SinkStats) String() string {
+	name := s.Name
+	if s.Optional {
+		name += " (optional)"
+	}
+	if s.Failed == 0 {
+		return fmt.Sprintf("%s: %d batches", name, s.Batches)
+	}
+	line := fmt.Sprintf("%s: %d batches, %d failed", name, s.Batches, s.Failed)
+	if s.GaveUp {
+		line += fmt.Sprintf(", %d skipped after %d failed in a row", s.Skipped, maxOptionalFailures)
+	}
+	return line + " (" + s.LastError + ")"
+}
+
+// NewFanOut creates a fan-out to sinks
+func NewFanOut(sinks ...Named) *FanOut {
+	f := &FanOut{}
+	for _, s := range sinks {
+		f.sinks = append(f.sinks, &fanOutSink{Named: s, stats: SinkStats{Name: s.Name, Optional: s.Optional}})
+	}
+	return f
+}
+
+// Write writes batch to every sink concurrently and waits for all of them.
+// It returns the errors of the required sinks that failed.
+func (f *FanOut) Write(ctx context.Context, batch Batch) error {
+	errs := make([]error, len(f.sinks))
+	written := make([]bool, len(f.sinks))
+	var wg sync.WaitGroup
+	for i, s := range f.sinks {
+		if s.stats.GaveUp {
+			s.stats.Skipped++
+			continue
+		}
+		written[i] = true
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.Sink.Write(ctx, batch)
+		}()
+	}
+	wg.Wait()
+
+	var failed []error
+	for i, s := range f.sinks {
+		if !written[i] {
+			continue
+		}
+		if errs[i] == nil {
+			s.stats.Batches++
+			s.inARow = 0
+			continue
+		}
+		s.stats.Failed++
+		s.stats.LastError = errs[i].Error()
+		if !s.Optional {
+			failed = append(failed, fmt.Errorf("%s: %w", s.Name, errs[i]))
+			continue
+		}
+		s.inARow++
+		if s.inARow >= maxOptionalFailures {
+			s.stats.GaveUp = true
+			fmt.Printf("⚠️ Optional sink %s failed %d batches in a row, no longer writing to it: %v\n", s.Name, s.inARow, errs[i])
+		} else {
+			fmt.Printf("⚠️ Optional sink %s failed, carrying on without it for this batch: %v\n", s.Name, errs[i])
+		}
+	}
+	return errors.Join(failed...)
+}
+
+// Close closes every sink
+func (f *FanOut) Close() error {
+	var errs []error
+	for _, s := range f.sinks {
+		if err := s.Sink.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Stats returns how every sink fared so far, in the order they were given
+func (f *FanOut) Stats() []SinkStats {
+	stats := make([]SinkStats, len(f.sinks))
+	for i, s := range f.sinks {
+		stats[i] = s.stats
+	}
+	return stats
+}
+
+// Failures describes the optional sinks that missed batches, failed or
+// skipped, or returns "" if none did
+func (f *FanOut) Failures() string {
+	var failed []string
+	for _, s := range f.Stats() {
+		if s.Optional && s.Failed > 0 {
+			failed = append(failed, fmt.Sprintf("%s (%d of %d batches)", s.Name, s.Failed+s.Skipped, s.Batches+s.Failed+s.Skipped))
+		}
+	}
+	return strings.Join(failed, ", ")
+}
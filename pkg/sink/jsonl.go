@@ -0,0 +1,58 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// JSONL appends every document as one line of JSON to a local file, for an
+// archive of everything collected across runs next to the per-run datasets
+type JSONL struct {
+	path string
+	f    *os.File
+	w    *bufio.Writer
+}
+
+// NewJSONLFromEnv creates a JSON Lines sink appending to JSONL_PATH
+func NewJSONLFromEnv() (*JSONL, error) {
+	return NewJSONL(os.Getenv("JSONL_PATH"))
+}
+
+// NewJSONL opens path for appending, creating it and its directory if needed
+func NewJSONL(path string) (*JSONL, error) {
+	if path == "" {
+		return nil, fmt.Errorf("jsonl path is required")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create jsonl directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open jsonl file: %w", err)
+	}
+	return &JSONL{path: path, f: f, w: bufio.NewWriter(f)}, nil
+}
+
+// Write appends the batch and flushes it to the file, so a crashed run loses
+// at most the batch being written
+func (j *JSONL) Write(ctx context.Context, batch Batch) error {
+	enc := json.NewEncoder(j.w)
+	for _, doc := range batch.Docs {
+		if err := enc.Encode(doc); err != nil {
+			return fmt.Errorf("failed to write document %s to %s: %w", doc.Id, j.path, err)
+		}
+	}
+	if err := j.w.Flush(); err != nil {
+		return fmt.Errorf("failed to write to %s: %w", j.path, err)
+	}
+	return nil
+}
+
+func (j *JSONL) Close() error {
+	return errors.Join(j.w.Flush(), j.f.Close())
+}
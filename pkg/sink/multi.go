@@ -0,0 +1,43 @@
+package sink
+
+import (
+	"context"
+	"errors"
+)
+
+// Join returns a sink writing every batch to each of sinks in order, skipping
+// nil ones. Closing it closes them all.
+func Join(sinks ...Sink) Sink {
+	var m multi
+	for _, s := range sinks {
+		if s != nil {
+			m = append(m, s)
+		}
+	}
+	if len(m) == 1 {
+		return m[0]
+	}
+	return m
+}
+
+// multi writes every batch to several sinks in order
+type multi []Sink
+
+func (m multi) Write(ctx context.Context, batch Batch) error {
+	for _, s := range m {
+		if err := s.Write(ctx, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multi) Close() error {
+	var errs []error
+	for _, s := range m {
+		if err := s.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,117 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const defaultNATSSubject = "sn42.tweets"
+
+// NATSConfig configures the NATS JetStream sink
+type NATSConfig struct {
+	URL       string // Server URL(s), comma separated, e.g. nats://localhost:4222
+	Subject   string // Subject documents are published to
+	Stream    string // Optional stream to create for the subject if it does not exist
+	CredsFile string // Optional user credentials file
+}
+
+// NATS publishes every document to a JetStream subject. The tweet ID is used as
+// the JetStream message ID, so the server drops duplicates within the stream's
+// duplicate window (e.g. when overlapping collections publish the same tweet).
+type NATS struct {
+	cfg NATSConfig
+	nc  *nats.Conn
+	js  jetstream.JetStream
+}
+
+// NewNATSFromEnv creates a NATS JetStream sink from NATS_* environment variables
+func NewNATSFromEnv() (*NATS, error) {
+	return NewNATS(NATSConfig{
+		URL:       os.Getenv("NATS_URL"),
+		Subject:   os.Getenv("NATS_SUBJECT"),
+		Stream:    os.Getenv("NATS_STREAM"),
+		CredsFile: os.Getenv("NATS_CREDS"),
+	})
+}
+
+// NewNATS connects to the server and, if a stream name is configured, ensures
+// a stream capturing the subject exists
+func NewNATS(cfg NATSConfig) (*NATS, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("nats URL is required")
+	}
+	if cfg.Subject == "" {
+		cfg.Subject = defaultNATSSubject
+	}
+
+	var opts []nats.Option
+	if cfg.CredsFile != "" {
+		opts = append(opts, nats.UserCredentials(cfg.CredsFile))
+	}
+	nc, err := nats.Connect(cfg.URL, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to nats: %w", err)
+	}
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("failed to create jetstream context: %w", err)
+	}
+
+	if cfg.Stream != "" {
+		_, err := js.CreateStream(context.Background(), jetstream.StreamConfig{
+			Name:     cfg.Stream,
+			Subjects: []string{cfg.Subject},
+		})
+		if err != nil && !errors.Is(err, jetstream.ErrStreamNameAlreadyInUse) {
+			nc.Close()
+			return nil, fmt.Errorf("failed to create stream %s: %w", cfg.Stream, err)
+		}
+	}
+
+	return &NATS{cfg: cfg, nc: nc, js: js}, nil
+}
+
+// Write publishes the batch asynchronously and waits for every acknowledgement
+func (n *NATS) Write(ctx context.Context, batch Batch) error {
+	futures := make([]jetstream.PubAckFuture, 0, len(batch.Docs))
+	for _, doc := range batch.Docs {
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("failed to marshal document %s: %w", doc.Id, err)
+		}
+
+		var opts []jetstream.PublishOpt
+		if doc.Id != "" {
+			opts = append(opts, jetstream.WithMsgID(doc.Id))
+		}
+		f, err := n.js.PublishAsync(n.cfg.Subject, data, opts...)
+		if err != nil {
+			return fmt.Errorf("failed to publish document %s: %w", doc.Id, err)
+		}
+		futures = append(futures, f)
+	}
+
+	for _, f := range futures {
+		select {
+		case <-f.Ok():
+		case err := <-f.Err():
+			return fmt.Errorf("publish to %s was not acknowledged: %w", n.cfg.Subject, err)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Close drains the connection so in-flight messages are delivered
+func (n *NATS) Close() error {
+	return n.nc.Drain()
+}
@@ -0,0 +1,139 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+)
+
+// PubSubConfig configures the Google Cloud Pub/Sub sink
+type PubSubConfig struct {
+	ProjectID string // GCP project ID
+	Topic     string // Topic ID or full topic name (projects/<p>/topics/<t>)
+	Ordering  bool   // Publish with the query as ordering key
+
+	// Batching settings; zero values keep the client library defaults
+	CountThreshold int           // Publish a batch when it has this many messages
+	ByteThreshold  int           // Publish a batch when it reaches this many bytes
+	DelayThreshold time.Duration // Publish a non-empty batch after this delay
+}
+
+// PubSub publishes every document as a message on a Pub/Sub topic.
+// Credentials are resolved through Application Default Credentials, and
+// PUBSUB_EMULATOR_HOST is honoured for local testing.
+type PubSub struct {
+	cfg       PubSubConfig
+	client    *pubsub.Client
+	publisher *pubsub.Publisher
+}
+
+// NewPubSubFromEnv creates a Pub/Sub sink from PUBSUB_* environment variables
+func NewPubSubFromEnv() (*PubSub, error) {
+	cfg := PubSubConfig{
+		ProjectID: os.Getenv("PUBSUB_PROJECT"),
+		Topic:     os.Getenv("PUBSUB_TOPIC"),
+	}
+	if cfg.ProjectID == "" {
+		cfg.ProjectID = os.Getenv("GOOGLE_CLOUD_PROJECT")
+	}
+
+	var err error
+	if v := os.Getenv("PUBSUB_ORDERING"); v != "" {
+		if cfg.Ordering, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid PUBSUB_ORDERING: %s (must be true or false)", v)
+		}
+	}
+	if v := os.Getenv("PUBSUB_BATCH_COUNT"); v != "" {
+		if cfg.CountThreshold, err = strconv.Atoi(v); err != nil || cfg.CountThreshold < 0 {
+			return nil, fmt.Errorf("invalid PUBSUB_BATCH_COUNT: %s (must be a non-negative number)", v)
+		}
+	}
+	if v := os.Getenv("PUBSUB_BATCH_BYTES"); v != "" {
+		if cfg.ByteThreshold, err = strconv.Atoi(v); err != nil || cfg.ByteThreshold < 0 {
+			return nil, fmt.Errorf("invalid PUBSUB_BATCH_BYTES: %s (must be a non-negative number)", v)
+		}
+	}
+	if v := os.Getenv("PUBSUB_BATCH_DELAY"); v != "" {
+		if cfg.DelayThreshold, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("invalid PUBSUB_BATCH_DELAY: %s (must be a duration like 100ms)", v)
+		}
+	}
+
+	return NewPubSub(cfg)
+}
+
+// NewPubSub creates the Pub/Sub client and a publisher for the topic
+func NewPubSub(cfg PubSubConfig) (*PubSub, error) {
+	if cfg.ProjectID == "" {
+		return nil, fmt.Errorf("pubsub project ID is required (set PUBSUB_PROJECT)")
+	}
+	if cfg.Topic == "" {
+		return nil, fmt.Errorf("pubsub topic is required")
+	}
+
+	client, err := pubsub.NewClient(context.Background(), cfg.ProjectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pubsub client: %w", err)
+	}
+
+	publisher := client.Publisher(cfg.Topic)
+	publisher.EnableMessageOrdering = cfg.Ordering
+	if cfg.CountThreshold > 0 {
+		publisher.PublishSettings.CountThreshold = cfg.CountThreshold
+	}
+	if cfg.ByteThreshold > 0 {
+		publisher.PublishSettings.ByteThreshold = cfg.ByteThreshold
+	}
+	if cfg.DelayThreshold > 0 {
+		publisher.PublishSettings.DelayThreshold = cfg.DelayThreshold
+	}
+
+	return &PubSub{cfg: cfg, client: client, publisher: publisher}, nil
+}
+
+// Write publishes one message per document and waits until the whole batch
+// has been accepted by the server
+func (p *PubSub) Write(ctx context.Context, batch Batch) error {
+	orderingKey := ""
+	if p.cfg.Ordering {
+		orderingKey = batch.Query
+	}
+
+	results := make([]*pubsub.PublishResult, 0, len(batch.Docs))
+	for _, doc := range batch.Docs {
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("failed to marshal document %s: %w", doc.Id, err)
+		}
+		results = append(results, p.publisher.Publish(ctx, &pubsub.Message{
+			Data:        data,
+			OrderingKey: orderingKey,
+			Attributes: map[string]string{
+				"id":    doc.Id,
+				"query": batch.Query,
+			},
+		}))
+	}
+
+	for _, r := range results {
+		if _, err := r.Get(ctx); err != nil {
+			if orderingKey != "" {
+				// A failed publish pauses the ordering key; resume so the next batch can be sent
+				p.publisher.ResumePublish(orderingKey)
+			}
+			return fmt.Errorf("failed to publish to %s: %w", p.cfg.Topic, err)
+		}
+	}
+	return nil
+}
+
+// Close flushes outstanding messages and closes the client
+func (p *PubSub) Close() error {
+	p.publisher.Stop()
+	return p.client.Close()
+}
@@ -0,0 +1,102 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const defaultRedisStream = "sn42:tweets"
+
+// RedisConfig configures the Redis Stream sink
+type RedisConfig struct {
+	URL    string // redis:// or rediss:// connection URL
+	Stream string // Stream key to XADD entries to
+	MaxLen int64  // Approximate maximum stream length (0 = unbounded)
+}
+
+// Redis appends every document as an entry on a Redis Stream, for lightweight
+// fan-out to downstream workers via consumer groups
+type Redis struct {
+	cfg    RedisConfig
+	client *redis.Client
+}
+
+// NewRedisFromEnv creates a Redis Stream sink from REDIS_* environment variables
+func NewRedisFromEnv() (*Redis, error) {
+	cfg := RedisConfig{
+		URL:    os.Getenv("REDIS_URL"),
+		Stream: os.Getenv("REDIS_STREAM"),
+	}
+	if maxLenStr := os.Getenv("REDIS_MAXLEN"); maxLenStr != "" {
+		maxLen, err := strconv.ParseInt(maxLenStr, 10, 64)
+		if err != nil || maxLen < 0 {
+			return nil, fmt.Errorf("invalid REDIS_MAXLEN: %s (must be a non-negative number)", maxLenStr)
+		}
+		cfg.MaxLen = maxLen
+	}
+	return NewRedis(cfg)
+}
+
+// NewRedis connects to Redis and verifies the connection
+func NewRedis(cfg RedisConfig) (*Redis, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("redis URL is required")
+	}
+	if cfg.Stream == "" {
+		cfg.Stream = defaultRedisStream
+	}
+
+	opts, err := redis.ParseURL(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	return &Redis{cfg: cfg, client: client}, nil
+}
+
+// Write XADDs one stream entry per document in a single pipeline round trip.
+// Each entry carries the document ID, the query it was collected for and its
+// JSON encoding.
+func (r *Redis) Write(ctx context.Context, batch Batch) error {
+	if len(batch.Docs) == 0 {
+		return nil
+	}
+
+	pipe := r.client.Pipeline()
+	for _, doc := range batch.Docs {
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("failed to marshal document %s: %w", doc.Id, err)
+		}
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: r.cfg.Stream,
+			MaxLen: r.cfg.MaxLen,
+			Approx: r.cfg.MaxLen > 0,
+			Values: map[string]any{
+				"id":       doc.Id,
+				"query":    batch.Query,
+				"document": data,
+			},
+		})
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to XADD batch to %s: %w", r.cfg.Stream, err)
+	}
+	return nil
+}
+
+// Close closes the Redis connection pool
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
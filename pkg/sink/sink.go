@@ -0,0 +1,99 @@
+// Package sink provides destinations that collected documents are streamed to
+// batch by batch, in addition to the JSON file written at the end of a run.
+package sink
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Batch is one page of documents returned by the API for a query
+type Batch struct {
+	Query string // Base query the documents were collected for (without max_id)
+	Docs  []types.Document
+}
+
+// Sink receives batches of collected documents as they are fetched
+type Sink interface {
+	// Write delivers one batch of documents to the sink
+	Write(ctx context.Context, batch Batch) error
+	// Close flushes any pending data and releases resources
+	Close() error
+}
+
+// envSinks maps the variable enabling each sink to the sink's name
+var envSinks = []struct{ env, name string }{
+	{"ES_URL", "elasticsearch"},
+	{"REDIS_URL", "redis"},
+	{"NATS_URL", "nats"},
+	{"PUBSUB_TOPIC", "pubsub"},
+	{"SQS_QUEUE_URL", "sqs"},
+	{"JSONL_PATH", "jsonl"},
+}
+
+// Configured returns the names of the sinks FromEnv would build, without
+// connecting to them or revealing their URLs
+func Configured() []string {
+	var names []string
+	for _, s := range envSinks {
+		if os.Getenv(s.env) != "" {
+			names = append(names, s.name)
+		}
+	}
+	return names
+}
+
+// FromEnv builds the sinks configured through environment variables.
+// Each sink is enabled by its URL variable; when several are configured every
+// batch is written to all of them through a FanOut, and the sinks named in
+// SINKS_OPTIONAL (comma-separated) are optional: their failures do not stop
+// the collection. It returns a nil Sink (and no error) when no sink is
+// configured.
+func FromEnv() (Sink, error) {
+	builders := map[string]func() (Sink, error){
+		"elasticsearch": func() (Sink, error) { return NewElasticsearchFromEnv() },
+		"redis":         func() (Sink, error) { return NewRedisFromEnv() },
+		"nats":          func() (Sink, error) { return NewNATSFromEnv() },
+		"pubsub":        func() (Sink, error) { return NewPubSubFromEnv() },
+		"sqs":           func() (Sink, error) { return NewSQSFromEnv() },
+		"jsonl":         func() (Sink, error) { return NewJSONLFromEnv() },
+	}
+	configured := Configured()
+	optional := map[string]bool{}
+	for _, name := range strings.Split(os.Getenv("SINKS_OPTIONAL"), ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if !slices.Contains(configured, name) {
+			return nil, fmt.Errorf("SINKS_OPTIONAL names %q, which is not a configured sink (configured: %s)", name, cmp.Or(strings.Join(configured, ", "), "none"))
+		}
+		optional[name] = true
+	}
+
+	var sinks []Named
+	for _, name := range configured {
+		s, err := builders[name]()
+		if err != nil {
+			for _, built := range sinks {
+				built.Sink.Close()
+			}
+			return nil, err
+		}
+		sinks = append(sinks, Named{Name: name, Sink: s, Optional: optional[name]})
+	}
+
+	switch {
+	case len(sinks) == 0:
+		return nil, nil
+	case len(sinks) == 1 && !sinks[0].Optional:
+		return sinks[0].Sink, nil
+	default:
+		return NewFanOut(sinks...), nil
+	}
+}
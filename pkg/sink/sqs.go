@@ -0,0 +1,200 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const (
+	sqsMaxBatchEntries = 10         // SendMessageBatch entry limit
+	sqsMaxBatchBytes   = 256 * 1024 // SendMessageBatch total payload limit
+)
+
+// SQSConfig configures the AWS SQS sink
+type SQSConfig struct {
+	QueueURL string // Queue URL; FIFO queues (.fifo) get group and dedup IDs set automatically
+	S3Bucket string // When set, batches are uploaded to S3 and only a pointer is enqueued
+	S3Prefix string // Key prefix for uploaded batch objects
+}
+
+// S3Pointer is the message body enqueued for a batch uploaded to S3
+type S3Pointer struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	Query  string `json:"query"`
+	Count  int    `json:"count"`
+}
+
+// SQS enqueues collected documents on an SQS queue, either one message per
+// document or, when an S3 bucket is configured, one pointer message per batch
+// uploaded to S3 (for consumers that prefer fewer, larger work items).
+// Credentials and region come from the standard AWS configuration chain.
+type SQS struct {
+	cfg  SQSConfig
+	fifo bool
+	sqs  *sqs.Client
+	s3   *s3.Client
+}
+
+// NewSQSFromEnv creates an SQS sink from SQS_* environment variables
+func NewSQSFromEnv() (*SQS, error) {
+	return NewSQS(SQSConfig{
+		QueueURL: os.Getenv("SQS_QUEUE_URL"),
+		S3Bucket: os.Getenv("SQS_S3_BUCKET"),
+		S3Prefix: os.Getenv("SQS_S3_PREFIX"),
+	})
+}
+
+// NewSQS loads the AWS configuration and creates the service clients
+func NewSQS(cfg SQSConfig) (*SQS, error) {
+	if cfg.QueueURL == "" {
+		return nil, fmt.Errorf("sqs queue URL is required")
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	s := &SQS{
+		cfg:  cfg,
+		fifo: strings.HasSuffix(cfg.QueueURL, ".fifo"),
+		sqs:  sqs.NewFromConfig(awsCfg),
+	}
+	if cfg.S3Bucket != "" {
+		s.s3 = s3.NewFromConfig(awsCfg)
+	}
+	return s, nil
+}
+
+// Write enqueues the batch, as documents or as an S3 pointer depending on configuration
+func (s *SQS) Write(ctx context.Context, batch Batch) error {
+	if len(batch.Docs) == 0 {
+		return nil
+	}
+	if s.s3 != nil {
+		return s.writePointer(ctx, batch)
+	}
+	return s.writeDocuments(ctx, batch)
+}
+
+// writeDocuments sends one message per document, grouped into SendMessageBatch
+// calls that respect the per-request entry and size limits
+func (s *SQS) writeDocuments(ctx context.Context, batch Batch) error {
+	var entries []sqstypes.SendMessageBatchRequestEntry
+	size := 0
+
+	flush := func() error {
+		if len(entries) == 0 {
+			return nil
+		}
+		out, err := s.sqs.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{
+			QueueUrl: aws.String(s.cfg.QueueURL),
+			Entries:  entries,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to send message batch: %w", err)
+		}
+		if len(out.Failed) > 0 {
+			f := out.Failed[0]
+			return fmt.Errorf("%d of %d messages failed (first error: %s: %s)",
+				len(out.Failed), len(entries), aws.ToString(f.Code), aws.ToString(f.Message))
+		}
+		entries = entries[:0]
+		size = 0
+		return nil
+	}
+
+	for i, doc := range batch.Docs {
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("failed to marshal document %s: %w", doc.Id, err)
+		}
+		if len(data) > sqsMaxBatchBytes {
+			return fmt.Errorf("document %s is %d bytes, larger than the SQS message limit (use SQS_S3_BUCKET)", doc.Id, len(data))
+		}
+		if len(entries) == sqsMaxBatchEntries || size+len(data) > sqsMaxBatchBytes {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+
+		entry := sqstypes.SendMessageBatchRequestEntry{
+			Id:          aws.String(strconv.Itoa(i)),
+			MessageBody: aws.String(string(data)),
+			MessageAttributes: map[string]sqstypes.MessageAttributeValue{
+				"query": {DataType: aws.String("String"), StringValue: aws.String(batch.Query)},
+			},
+		}
+		if s.fifo {
+			entry.MessageGroupId = aws.String(batch.Query)
+			if doc.Id != "" {
+				entry.MessageDeduplicationId = aws.String(doc.Id)
+			}
+		}
+		entries = append(entries, entry)
+		size += len(data)
+	}
+	return flush()
+}
+
+// writePointer uploads the batch as a JSON array to S3 and enqueues a pointer to it
+func (s *SQS) writePointer(ctx context.Context, batch Batch) error {
+	data, err := json.Marshal(batch.Docs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch: %w", err)
+	}
+
+	key := path.Join(s.cfg.S3Prefix, fmt.Sprintf("%s_%s.json",
+		time.Now().UTC().Format("20060102T150405.000000000Z"), batch.Docs[0].Id))
+	_, err = s.s3.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.cfg.S3Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload batch to s3://%s/%s: %w", s.cfg.S3Bucket, key, err)
+	}
+
+	body, err := json.Marshal(S3Pointer{
+		Bucket: s.cfg.S3Bucket,
+		Key:    key,
+		Query:  batch.Query,
+		Count:  len(batch.Docs),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch pointer: %w", err)
+	}
+
+	input := &sqs.SendMessageInput{
+		QueueUrl:    aws.String(s.cfg.QueueURL),
+		MessageBody: aws.String(string(body)),
+	}
+	if s.fifo {
+		input.MessageGroupId = aws.String(batch.Query)
+		input.MessageDeduplicationId = aws.String(key)
+	}
+	if _, err := s.sqs.SendMessage(ctx, input); err != nil {
+		return fmt.Errorf("failed to enqueue batch pointer: %w", err)
+	}
+	return nil
+}
+
+// Close is a no-op; every Write is sent synchronously
+func (s *SQS) Close() error {
+	return nil
+}
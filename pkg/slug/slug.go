@@ -0,0 +1,132 @@
+// Package slug turns trends into names that are safe for files and
+// directories, whatever script the trend is written in.
+package slug
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Styles of slug
+const (
+	// ASCII keeps a-z, 0-9 and underscores, with accents dropped from Latin
+	// letters, and appends a hash of the text when letters of other scripts
+	// had to be left out, so 東京 and Москва get names of their own
+	ASCII = "ascii"
+	// Unicode keeps the letters and digits of every script, lowercased
+	Unicode = "unicode"
+)
+
+// Styles lists the supported styles, for flag help and errors
+var Styles = []string{ASCII, Unicode}
+
+// hashLen is the number of hex digits of the hash ASCII slugs get
+const hashLen = 8
+
+// New returns the slug function of style. A slug is lowercase, with spaces
+// as underscores and punctuation, emoji and repeated or outer underscores
+// dropped; it is empty when the text has nothing to keep, e.g. only emoji.
+func New(style string) (func(string) string, error) {
+	switch style {
+	case ASCII:
+		return ascii, nil
+	case Unicode:
+		return unicodeSlug, nil
+	}
+	return nil, fmt.Errorf("unknown slug style %q (supported: %s)", style, strings.Join(Styles, ", "))
+}
+
+func ascii(s string) string {
+	var b strings.Builder
+	lost := false
+	// Decomposing separates accents from their letters, and full-width
+	// letters and digits become plain ones
+	for _, r := range norm.NFKD.String(strings.ToLower(s)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('_')
+		case unicode.Is(unicode.Mn, r):
+			// Accents of Latin letters are dropped
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			lost = true
+		}
+	}
+	slug := tidy(b.String())
+	if !lost {
+		return slug
+	}
+	hash := hashOf(s)
+	if slug == "" {
+		return hash
+	}
+	return slug + "_" + hash
+}
+
+// hashOf is the short hash of s. The same trend always hashes the same,
+// whatever its case.
+func hashOf(s string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(norm.NFKC.String(s)))))
+	return hex.EncodeToString(sum[:])[:hashLen]
+}
+
+func unicodeSlug(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKC.String(strings.ToLower(s)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('_')
+		}
+	}
+	return tidy(b.String())
+}
+
+// tidy collapses repeated underscores and trims them from the ends
+func tidy(slug string) string {
+	for strings.Contains(slug, "__") {
+		slug = strings.ReplaceAll(slug, "__", "_")
+	}
+	return strings.Trim(slug, "_")
+}
+
+// Unique hands out slugs that are unique within a run. Slugs drop case, '#'
+// and accents, so #Bitcoin and Bitcoin share one; the first text to claim a
+// slug keeps it and later ones get the hash of their text appended, so the
+// name a text gets does not depend on how many others clashed before it.
+type Unique struct {
+	slug  func(string) string
+	taken map[string]string // Slug handed out -> text it was handed to
+}
+
+// NewUnique makes unique slugs with slug, as returned by New
+func NewUnique(slug func(string) string) *Unique {
+	return &Unique{slug: slug, taken: map[string]string{}}
+}
+
+// Slug returns a slug for s that no other text got from u, and the text that
+// already held its plain slug ("" if none). It returns "" when s has nothing
+// to keep. The same text given twice gets numbered slugs.
+func (u *Unique) Slug(s string) (slug, clash string) {
+	slug = u.slug(s)
+	if slug == "" {
+		return "", ""
+	}
+	clash = u.taken[slug]
+	if clash != "" {
+		base := slug + "_" + hashOf(s)
+		slug = base
+		for n := 2; u.taken[slug] != ""; n++ {
+			slug = fmt.Sprintf("%s_%d", base, n)
+		}
+	}
+	u.taken[slug] = s
+	return slug, clash
+}
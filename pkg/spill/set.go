@@ -0,0 +1,236 @@
+package spill
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+)
+
+// Key is a set member: the first 16 bytes of a SHA-256 hash, which keeps
+// collisions negligible at billions of members
+type Key [16]byte
+
+// KeyOf hashes s into a Key
+func KeyOf(s string) Key {
+	sum := sha256.Sum256([]byte(s))
+	return Key(sum[:16])
+}
+
+const (
+	// keyCost approximates the memory held per key in the in-memory map
+	keyCost = 48
+	// blockKeys is how many keys of a run share one index entry; a lookup
+	// reads one block of a run from disk
+	blockKeys = 256
+	// maxRuns is how many runs a Set keeps before compacting them into one,
+	// bounding the blocks read per lookup
+	maxRuns = 4
+)
+
+// Set is a set of keys held in memory up to a budget. Past the budget the
+// keys are sorted and spilled to a run file, and a lookup checks memory plus
+// one block of each run. A Set is not safe for concurrent use.
+type Set struct {
+	maxKeys int // Keys held in memory before spilling, 0 for no limit
+	mem     map[Key]struct{}
+	runs    []*run
+	dir     tempDir
+	len     int
+}
+
+// run is a file of sorted keys with the first key of every block in memory
+type run struct {
+	f     *os.File
+	index []Key
+	keys  int
+}
+
+// NewSet creates a set spilling to dir (the system temp directory if empty)
+// once it holds budget bytes of keys. A budget of 0 keeps every key in memory.
+func NewSet(dir string, budget int64) *Set {
+	s := &Set{mem: make(map[Key]struct{}), dir: tempDir{parent: dir}}
+	if budget > 0 {
+		s.maxKeys = max(int(budget/keyCost), blockKeys)
+	}
+	return s
+}
+
+// Add inserts k and reports whether it was not in the set yet
+func (s *Set) Add(k Key) (bool, error) {
+	if _, ok := s.mem[k]; ok {
+		return false, nil
+	}
+	for _, r := range s.runs {
+		found, err := r.contains(k)
+		if err != nil {
+			return false, err
+		}
+		if found {
+			return false, nil
+		}
+	}
+	s.mem[k] = struct{}{}
+	s.len++
+	if s.maxKeys > 0 && len(s.mem) >= s.maxKeys {
+		if err := s.spill(); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// Len returns the number of keys in the set
+func (s *Set) Len() int {
+	return s.len
+}
+
+// Spilled reports how many keys are held on disk
+func (s *Set) Spilled() int {
+	n := 0
+	for _, r := range s.runs {
+		n += r.keys
+	}
+	return n
+}
+
+// spill writes the in-memory keys to a new run, compacting the runs when
+// there are too many
+func (s *Set) spill() error {
+	keys := make([]Key, 0, len(s.mem))
+	for k := range s.mem {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b Key) int { return bytes.Compare(a[:], b[:]) })
+	r, err := s.writeRun(func(w *bufio.Writer) (int, error) {
+		for _, k := range keys {
+			if _, err := w.Write(k[:]); err != nil {
+				return 0, err
+			}
+		}
+		return len(keys), nil
+	})
+	if err != nil {
+		return err
+	}
+	s.runs = append(s.runs, r)
+	clear(s.mem)
+	if len(s.runs) > maxRuns {
+		return s.compact()
+	}
+	return nil
+}
+
+// compact merges every run into one
+func (s *Set) compact() error {
+	readers := make([]*bufio.Reader, len(s.runs))
+	heads := make([]*Key, len(s.runs))
+	next := func(i int) error {
+		var k Key
+		if _, err := io.ReadFull(readers[i], k[:]); err == io.EOF {
+			heads[i] = nil
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read spill file: %w", err)
+		}
+		heads[i] = &k
+		return nil
+	}
+	for i, r := range s.runs {
+		readers[i] = bufio.NewReaderSize(io.NewSectionReader(r.f, 0, int64(r.keys)*int64(len(Key{}))), 1<<16)
+		if err := next(i); err != nil {
+			return err
+		}
+	}
+
+	merged, err := s.writeRun(func(w *bufio.Writer) (int, error) {
+		n := 0
+		for {
+			// Runs are few, so the smallest head is found by a linear scan
+			low := -1
+			for i, h := range heads {
+				if h != nil && (low < 0 || bytes.Compare(h[:], heads[low][:]) < 0) {
+					low = i
+				}
+			}
+			if low < 0 {
+				return n, nil
+			}
+			if _, err := w.Write(heads[low][:]); err != nil {
+				return 0, err
+			}
+			n++
+			if err := next(low); err != nil {
+				return 0, err
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	for _, r := range s.runs {
+		r.f.Close()
+		os.Remove(r.f.Name())
+	}
+	s.runs = []*run{merged}
+	return nil
+}
+
+// writeRun writes a run of sorted keys with fill and indexes it
+func (s *Set) writeRun(fill func(w *bufio.Writer) (int, error)) (*run, error) {
+	f, err := s.dir.create()
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriterSize(f, 1<<16)
+	n, err := fill(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write spill file: %w", err)
+	}
+
+	// Index the first key of every block
+	r := &run{f: f, keys: n}
+	for i := 0; i < n; i += blockKeys {
+		var k Key
+		if _, err := f.ReadAt(k[:], int64(i)*int64(len(k))); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to index spill file: %w", err)
+		}
+		r.index = append(r.index, k)
+	}
+	return r, nil
+}
+
+// contains looks k up in the one block of the run that may hold it
+func (r *run) contains(k Key) (bool, error) {
+	block := sort.Search(len(r.index), func(i int) bool { return bytes.Compare(r.index[i][:], k[:]) > 0 }) - 1
+	if block < 0 {
+		return false, nil
+	}
+	first := block * blockKeys
+	count := min(blockKeys, r.keys-first)
+	buf := make([]byte, count*len(k))
+	if _, err := r.f.ReadAt(buf, int64(first)*int64(len(k))); err != nil {
+		return false, fmt.Errorf("failed to read spill file: %w", err)
+	}
+	i := sort.Search(count, func(i int) bool { return bytes.Compare(buf[i*len(k):(i+1)*len(k)], k[:]) >= 0 })
+	return i < count && bytes.Equal(buf[i*len(k):(i+1)*len(k)], k[:]), nil
+}
+
+// Close removes the set's spill files
+func (s *Set) Close() error {
+	for _, r := range s.runs {
+		r.f.Close()
+	}
+	s.runs = nil
+	clear(s.mem)
+	return s.dir.remove()
+}
@@ -0,0 +1,208 @@
+package spill
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// itemCost approximates the memory held per record beyond its key and data
+const itemCost = 64
+
+// Sorter sorts records by key with an external merge sort: records are held
+// in memory up to a budget, then sorted and spilled to a run file, and Each
+// merges the runs. Records with equal keys keep the order they were added in.
+type Sorter struct {
+	budget int64
+	items  []item
+	size   int64
+	runs   []string
+	dir    tempDir
+	len    int
+}
+
+type item struct {
+	key  string
+	data []byte
+}
+
+// NewSorter creates a sorter spilling to dir (the system temp directory if
+// empty) once it holds budget bytes of records. A budget of 0 sorts in memory.
+func NewSorter(dir string, budget int64) *Sorter {
+	return &Sorter{budget: budget, dir: tempDir{parent: dir}}
+}
+
+// Add adds a record; the sorter keeps data, so it must not be modified after
+func (s *Sorter) Add(key string, data []byte) error {
+	s.items = append(s.items, item{key, data})
+	s.size += int64(len(key) + len(data) + itemCost)
+	s.len++
+	if s.budget > 0 && s.size >= s.budget {
+		return s.spill()
+	}
+	return nil
+}
+
+// Len returns the number of records added
+func (s *Sorter) Len() int {
+	return s.len
+}
+
+// Runs returns the number of run files spilled so far
+func (s *Sorter) Runs() int {
+	return len(s.runs)
+}
+
+func (s *Sorter) sortItems() {
+	slices.SortStableFunc(s.items, func(a, b item) int { return strings.Compare(a.key, b.key) })
+}
+
+// spill writes the in-memory records to a new run file in key order
+func (s *Sorter) spill() error {
+	s.sortItems()
+	f, err := s.dir.create()
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriterSize(f, 1<<16)
+	var buf []byte
+	for _, it := range s.items {
+		buf = binary.AppendUvarint(buf[:0], uint64(len(it.key)))
+		buf = append(buf, it.key...)
+		buf = binary.AppendUvarint(buf, uint64(len(it.data)))
+		if _, err = w.Write(buf); err != nil {
+			break
+		}
+		if _, err = w.Write(it.data); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write spill file: %w", err)
+	}
+	s.runs = append(s.runs, f.Name())
+	s.items, s.size = nil, 0
+	return nil
+}
+
+// Each calls fn with the data of every record in key order, stopping at the
+// first error
+func (s *Sorter) Each(fn func(data []byte) error) error {
+	if len(s.runs) == 0 {
+		s.sortItems()
+		for _, it := range s.items {
+			if err := fn(it.data); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Spill the rest too, so every record is merged from a run in add order
+	if len(s.items) > 0 {
+		if err := s.spill(); err != nil {
+			return err
+		}
+	}
+	h := make(mergeHeap, 0, len(s.runs))
+	for i, path := range s.runs {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open spill file: %w", err)
+		}
+		defer f.Close()
+		r := &runReader{r: bufio.NewReaderSize(f, 1<<16), order: i}
+		if ok, err := r.next(); err != nil {
+			return err
+		} else if ok {
+			h = append(h, r)
+		}
+	}
+	heap.Init(&h)
+	for len(h) > 0 {
+		r := h[0]
+		if err := fn(r.data); err != nil {
+			return err
+		}
+		ok, err := r.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+	}
+	return nil
+}
+
+// Close removes the sorter's spill files
+func (s *Sorter) Close() error {
+	s.items, s.runs = nil, nil
+	return s.dir.remove()
+}
+
+// runReader reads the records of one run file in order
+type runReader struct {
+	r     *bufio.Reader
+	order int // Position of the run, breaking ties between equal keys
+	key   string
+	data  []byte
+}
+
+// next reads the following record, reporting false at the end of the run
+func (r *runReader) next() (bool, error) {
+	n, err := binary.ReadUvarint(r.r)
+	if errors.Is(err, io.EOF) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read spill file: %w", err)
+	}
+	key := make([]byte, n)
+	if _, err := io.ReadFull(r.r, key); err != nil {
+		return false, fmt.Errorf("failed to read spill file: %w", err)
+	}
+	if n, err = binary.ReadUvarint(r.r); err != nil {
+		return false, fmt.Errorf("failed to read spill file: %w", err)
+	}
+	// A fresh buffer per record, as fn may keep the data
+	r.data = make([]byte, n)
+	if _, err := io.ReadFull(r.r, r.data); err != nil {
+		return false, fmt.Errorf("failed to read spill file: %w", err)
+	}
+	r.key = string(key)
+	return true, nil
+}
+
+// mergeHeap orders run readers by their current key, then by run order
+type mergeHeap []*runReader
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	if h[i].key != h[j].key {
+		return h[i].key < h[j].key
+	}
+	return h[i].order < h[j].order
+}
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x any)   { *h = append(*h, x.(*runReader)) }
+func (h *mergeHeap) Pop() any {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
@@ -0,0 +1,68 @@
+// Package spill provides dedup sets and sorters that keep their state in
+// memory up to a budget and spill the rest to temporary files, so dedup, sort
+// and merge work on datasets much larger than the machine's memory.
+package spill
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Flags holds the memory budget command-line flags shared by the tools
+type Flags struct {
+	MemoryMB int
+	Dir      string
+}
+
+// RegisterFlags registers -memory-budget-mb and -spill-dir on fs
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	fs.IntVar(&f.MemoryMB, "memory-budget-mb", 0, "Spill dedup and sort state to temporary files once it takes this many MB (0 = keep it all in memory)")
+	fs.StringVar(&f.Dir, "spill-dir", "", "Directory for spill files (default: the system temp directory)")
+	return f
+}
+
+// Budget returns the memory budget in bytes, 0 for unlimited
+func (f *Flags) Budget() int64 {
+	return int64(f.MemoryMB) << 20
+}
+
+// tempDir creates the directory holding spill files on first use, so nothing
+// is created on disk unless state actually spills
+type tempDir struct {
+	parent string
+	path   string
+	files  int
+}
+
+// create opens a new spill file
+func (t *tempDir) create() (*os.File, error) {
+	if t.path == "" {
+		path, err := os.MkdirTemp(t.parent, "sn42-spill-*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create spill directory: %w", err)
+		}
+		t.path = path
+	}
+	t.files++
+	f, err := os.Create(filepath.Join(t.path, fmt.Sprintf("%06d.run", t.files)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spill file: %w", err)
+	}
+	return f, nil
+}
+
+// remove deletes the directory and every spill file in it
+func (t *tempDir) remove() error {
+	if t.path == "" {
+		return nil
+	}
+	err := os.RemoveAll(t.path)
+	t.path = ""
+	if err != nil {
+		return fmt.Errorf("failed to remove spill files: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package stats
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/grant/sn42/pkg/query"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+var (
+	mentionRegex = regexp.MustCompile(`(?:^|[^\w@])@(\w{1,15})`)
+	urlRegex     = regexp.MustCompile(`https?://[^\s<>"]+`)
+)
+
+// EntityCount is how many documents carry one entity, and their share of all documents
+type EntityCount struct {
+	Value string  `json:"value"`
+	Count int     `json:"count"`
+	Share float64 `json:"share"`
+}
+
+// Entities lists the most frequent entities of each kind in a set of documents
+type Entities struct {
+	Records  int           `json:"records"`
+	Hashtags []EntityCount `json:"hashtags"`
+	Mentions []EntityCount `json:"mentions"`
+	Authors  []EntityCount `json:"authors"`
+	Domains  []EntityCount `json:"domains"`
+}
+
+// EntityAccumulator counts the hashtags, mentioned users, authors and link
+// domains of documents added one at a time. Each document counts once per
+// entity, however often it repeats it.
+type EntityAccumulator struct {
+	records                              int
+	hashtags, mentions, authors, domains map[string]int
+}
+
+// NewEntityAccumulator returns an empty EntityAccumulator
+func NewEntityAccumulator() *EntityAccumulator {
+	return &EntityAccumulator{hashtags: map[string]int{}, mentions: map[string]int{}, authors: map[string]int{}, domains: map[string]int{}}
+}
+
+// Add counts the entities of one document
+func (a *EntityAccumulator) Add(doc types.Document) {
+	a.records++
+	countOnce(a.hashtags, query.Hashtags(doc))
+	countOnce(a.mentions, Mentions(doc))
+	countOnce(a.domains, Domains(doc))
+	if username, ok := doc.Metadata["username"].(string); ok && username != "" {
+		a.authors[strings.ToLower(username)]++
+	}
+}
+
+// Top returns the n most frequent entities of each kind (all if n is 0),
+// most frequent first with ties by value
+func (a *EntityAccumulator) Top(n int) *Entities {
+	return &Entities{
+		Records:  a.records,
+		Hashtags: a.top(a.hashtags, n),
+		Mentions: a.top(a.mentions, n),
+		Authors:  a.top(a.authors, n),
+		Domains:  a.top(a.domains, n),
+	}
+}
+
+func (a *EntityAccumulator) top(counts map[string]int, n int) []EntityCount {
+	entities := make([]EntityCount, 0, len(counts))
+	for value, count := range counts {
+		e := EntityCount{Value: value, Count: count}
+		if a.records > 0 {
+			e.Share = float64(count) / float64(a.records)
+		}
+		entities = append(entities, e)
+	}
+	sort.Slice(entities, func(i, j int) bool {
+		if entities[i].Count != entities[j].Count {
+			return entities[i].Count > entities[j].Count
+		}
+		return entities[i].Value < entities[j].Value
+	})
+	if n > 0 && len(entities) > n {
+		entities = entities[:n]
+	}
+	return entities
+}
+
+// countOnce increments the count of each distinct value
+func countOnce(counts map[string]int, values []string) {
+	seen := map[string]bool{}
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			counts[v]++
+		}
+	}
+}
+
+// Mentions returns the lowercased usernames mentioned in a tweet's text, without the @
+func Mentions(doc types.Document) []string {
+	var users []string
+	for _, m := range mentionRegex.FindAllStringSubmatch(doc.Content, -1) {
+		users = append(users, strings.ToLower(m[1]))
+	}
+	return users
+}
+
+// Links returns the links in a tweet, from its urls metadata when present and
+// otherwise parsed from the text
+func Links(doc types.Document) []string {
+	var links []string
+	switch list := doc.Metadata["urls"].(type) {
+	case []any:
+		for _, u := range list {
+			if s, ok := u.(string); ok {
+				links = append(links, s)
+			}
+		}
+	case []string:
+		links = list
+	}
+	if links == nil {
+		links = urlRegex.FindAllString(doc.Content, -1)
+	}
+	return links
+}
+
+// Domains returns the hosts of the links in a tweet. A leading www. is dropped.
+func Domains(doc types.Document) []string {
+	var domains []string
+	for _, link := range Links(doc) {
+		u, err := url.Parse(link)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		domains = append(domains, strings.TrimPrefix(strings.ToLower(u.Hostname()), "www."))
+	}
+	return domains
+}
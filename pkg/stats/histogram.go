@@ -0,0 +1,108 @@
+package stats
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Intervals lists the supported histogram bucket sizes
+var Intervals = []string{"hour", "day"}
+
+// Bucket is the number of documents created in one interval
+type Bucket struct {
+	Start string `json:"start"`
+	Count int    `json:"count"`
+}
+
+// Histogram counts documents per hour or day of their creation time. Buckets
+// run from the first to the last document without holes, so intervals in
+// which nothing was collected show up as empty buckets.
+type Histogram struct {
+	Interval     string   `json:"interval"`
+	Buckets      []Bucket `json:"buckets"`
+	EmptyBuckets int      `json:"empty_buckets"`
+	Undated      int      `json:"undated,omitempty"`
+}
+
+// ComputeHistogram buckets docs by their created_at time, per "hour" or "day" (UTC)
+func ComputeHistogram(docs []types.Document, interval string) (*Histogram, error) {
+	var step time.Duration
+	switch interval {
+	case "hour":
+		step = time.Hour
+	case "day":
+		step = 24 * time.Hour
+	default:
+		return nil, fmt.Errorf("unknown histogram interval %q (supported: %v)", interval, Intervals)
+	}
+
+	h := &Histogram{Interval: interval, Buckets: []Bucket{}}
+	counts := map[time.Time]int{}
+	var first, last time.Time
+	for _, doc := range docs {
+		createdAt, _ := doc.Metadata["created_at"].(string)
+		t, err := time.Parse(time.RFC3339, createdAt)
+		if err != nil {
+			h.Undated++
+			continue
+		}
+		start := t.UTC().Truncate(step)
+		counts[start]++
+		if first.IsZero() || start.Before(first) {
+			first = start
+		}
+		if start.After(last) {
+			last = start
+		}
+	}
+	if first.IsZero() {
+		return h, nil
+	}
+
+	for t := first; !t.After(last); t = t.Add(step) {
+		n := counts[t]
+		if n == 0 {
+			h.EmptyBuckets++
+		}
+		h.Buckets = append(h.Buckets, Bucket{Start: t.Format(time.RFC3339), Count: n})
+	}
+	return h, nil
+}
+
+// Chart renders the histogram as one bar per bucket, scaled so the largest
+// bucket is width characters wide. Empty buckets are marked so coverage gaps
+// stand out.
+func (h *Histogram) Chart(width int) string {
+	peak := 0
+	for _, b := range h.Buckets {
+		peak = max(peak, b.Count)
+	}
+
+	var sb strings.Builder
+	for _, b := range h.Buckets {
+		label := b.Start
+		if t, err := time.Parse(time.RFC3339, b.Start); err == nil {
+			if h.Interval == "day" {
+				label = t.Format("2006-01-02")
+			} else {
+				label = t.Format("2006-01-02 15:00")
+			}
+		}
+		bar := 0
+		if peak > 0 {
+			bar = b.Count * width / peak
+		}
+		if b.Count > 0 && bar == 0 {
+			bar = 1
+		}
+		marker := ""
+		if b.Count == 0 {
+			marker = " <- gap"
+		}
+		fmt.Fprintf(&sb, "%-16s | %s %d%s\n", label, strings.Repeat("#", bar), b.Count, marker)
+	}
+	return sb.String()
+}
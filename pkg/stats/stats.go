@@ -0,0 +1,105 @@
+// Package stats computes summary statistics over collected documents.
+package stats
+
+import (
+	"maps"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Stats summarizes a set of documents
+type Stats struct {
+	Records       int            `json:"records"`
+	UniqueAuthors int            `json:"unique_authors"`
+	TotalChars    int            `json:"total_chars"`
+	AvgChars      float64        `json:"avg_chars"`
+	Languages     map[string]int `json:"languages"`
+	Earliest      string         `json:"earliest,omitempty"`
+	Latest        string         `json:"latest,omitempty"`
+	Tokens        *TokenStats    `json:"tokens,omitempty"`
+	Histogram     *Histogram     `json:"histogram,omitempty"`
+}
+
+// Compute calculates basic statistics over docs
+func Compute(docs []types.Document) *Stats {
+	a := NewAccumulator()
+	for _, doc := range docs {
+		a.Add(doc)
+	}
+	return a.Stats()
+}
+
+// Accumulator computes the statistics of documents added one at a time, for
+// datasets streamed rather than held in memory
+type Accumulator struct {
+	s                Stats
+	authors          map[string]bool
+	earliest, latest time.Time
+}
+
+// NewAccumulator returns an empty Accumulator
+func NewAccumulator() *Accumulator {
+	return &Accumulator{s: Stats{Languages: map[string]int{}}, authors: map[string]bool{}}
+}
+
+// Add counts one document
+func (a *Accumulator) Add(doc types.Document) {
+	a.s.Records++
+	a.s.TotalChars += utf8.RuneCountInString(doc.Content)
+
+	if username, ok := doc.Metadata["username"].(string); ok && username != "" {
+		a.authors[username] = true
+	}
+	if lang, ok := doc.Metadata["lang"].(string); ok && lang != "" {
+		a.s.Languages[lang]++
+	}
+	if createdAt, ok := doc.Metadata["created_at"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, createdAt); err == nil {
+			if a.earliest.IsZero() || t.Before(a.earliest) {
+				a.earliest = t
+			}
+			if t.After(a.latest) {
+				a.latest = t
+			}
+		}
+	}
+}
+
+// Stats returns the statistics of the documents added so far
+func (a *Accumulator) Stats() *Stats {
+	s := a.s
+	s.Languages = maps.Clone(a.s.Languages)
+	s.UniqueAuthors = len(a.authors)
+	if s.Records > 0 {
+		s.AvgChars = float64(s.TotalChars) / float64(s.Records)
+	}
+	if !a.earliest.IsZero() {
+		s.Earliest = a.earliest.UTC().Format(time.RFC3339)
+		s.Latest = a.latest.UTC().Format(time.RFC3339)
+	}
+	return &s
+}
+
+// LanguageCount is one entry of a language breakdown
+type LanguageCount struct {
+	Lang  string
+	Count int
+}
+
+// TopLanguages returns the languages sorted by descending count (ties by name)
+func (s *Stats) TopLanguages() []LanguageCount {
+	langs := make([]LanguageCount, 0, len(s.Languages))
+	for lang, n := range s.Languages {
+		langs = append(langs, LanguageCount{lang, n})
+	}
+	sort.Slice(langs, func(i, j int) bool {
+		if langs[i].Count != langs[j].Count {
+			return langs[i].Count > langs[j].Count
+		}
+		return langs[i].Lang < langs[j].Lang
+	})
+	return langs
+}
@@ -0,0 +1,50 @@
+package stats
+
+import (
+	"fmt"
+
+	"github.com/masa-finance/tee-worker/v2/api/types"
+	"github.com/pkoukk/tiktoken-go"
+	tiktokenloader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// DefaultTokenizer is the tiktoken encoding used when none is specified
+const DefaultTokenizer = "cl100k_base"
+
+// Tokenizers lists the supported tiktoken encodings
+var Tokenizers = []string{"o200k_base", "cl100k_base", "p50k_base", "r50k_base"}
+
+func init() {
+	// Use the BPE ranks embedded in the binary instead of downloading them at runtime
+	tiktoken.SetBpeLoader(tiktokenloader.NewOfflineLoader())
+}
+
+// TokenStats reports token counts of the document text under a tokenizer
+type TokenStats struct {
+	Tokenizer string  `json:"tokenizer"`
+	Total     int     `json:"total"`
+	Average   float64 `json:"average"`
+	Max       int     `json:"max"`
+}
+
+// CountTokens tokenizes the content of every document with the named tiktoken encoding
+func CountTokens(docs []types.Document, tokenizer string) (*TokenStats, error) {
+	enc, err := tiktoken.GetEncoding(tokenizer)
+	if err != nil {
+		return nil, fmt.Errorf("unknown tokenizer %q (supported: %v): %w", tokenizer, Tokenizers, err)
+	}
+
+	ts := &TokenStats{Tokenizer: tokenizer}
+	for _, doc := range docs {
+		// Special tokens in tweet text are counted as ordinary text
+		n := len(enc.EncodeOrdinary(doc.Content))
+		ts.Total += n
+		if n > ts.Max {
+			ts.Max = n
+		}
+	}
+	if len(docs) > 0 {
+		ts.Average = float64(ts.Total) / float64(len(docs))
+	}
+	return ts, nil
+}
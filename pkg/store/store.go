@@ -0,0 +1,317 @@
+// Package store keeps one canonical, deduplicated dataset in a directory
+// that the collectors ingest into run after run, instead of leaving a new
+// file behind per run:
+//
+//	<store>/
+//	├── store.json                    records and files per partition
+//	├── ids.idx                       ID of every tweet in the store
+//	└── partitions/
+//	    └── 2026-02-04/
+//	        ├── <run>-<n>.json        one file per ingest and partition
+//	        └── <run>-<n>.manifest.json
+//
+// Tweets are partitioned by the UTC day they were created. Every partition
+// file is an ordinary dataset with a manifest, so the dataset tools read the
+// store (or one partition) as a directory.
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/grant/sn42/pkg/crypt"
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/grant/sn42/pkg/manifest"
+	"github.com/grant/sn42/pkg/pipeline"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+const (
+	metaFile      = "store.json"
+	indexFile     = "ids.idx"
+	partitionsDir = "partitions"
+
+	// UnknownPartition holds tweets without a valid created_at
+	UnknownPartition = "unknown"
+)
+
+// Meta is the content of store.json
+type Meta struct {
+	CreatedAt  string               `json:"created_at"`
+	UpdatedAt  string               `json:"updated_at"`
+	Records    int                  `json:"records"`
+	Ingests    int                  `json:"ingests"`
+	Partitions map[string]Partition `json:"partitions"`
+}
+
+// Partition counts the records and files of one partition
+type Partition struct {
+	Records int `json:"records"`
+	Files   int `json:"files"`
+}
+
+// Source describes where ingested tweets come from, for their manifests
+type Source struct {
+	Tool       string
+	Run        string
+	Query      string
+	Trend      string
+	SavedQuery string
+	Pipeline   []string
+	Provenance manifest.Provenance
+}
+
+// Result describes one ingest
+type Result struct {
+	Added      int
+	Duplicates int
+	Files      []string // Partition files written, with their manifests and cards
+}
+
+// Store is an open store. Callers hold a lock on its directory (see
+// pkg/lock) while ingesting, so concurrent runs do not interleave updates.
+type Store struct {
+	dir  string
+	key  []byte // Encrypts the partition files if non-nil
+	meta Meta
+	ids  map[string]bool
+}
+
+// Open opens the store in dir, creating it if needed. The ID index is
+// rebuilt from the partitions if it is missing.
+func Open(dir string, key []byte) (*Store, error) {
+	s := &Store{dir: dir, key: key, ids: make(map[string]bool)}
+	data, err := os.ReadFile(filepath.Join(dir, metaFile))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		now := time.Now().UTC().Format(time.RFC3339)
+		s.meta = Meta{CreatedAt: now, UpdatedAt: now, Partitions: map[string]Partition{}}
+		if err := os.MkdirAll(filepath.Join(dir, partitionsDir), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create store: %w", err)
+		}
+		if err := s.saveMeta(); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, fmt.Errorf("failed to read store: %w", err)
+	default:
+		if err := json.Unmarshal(data, &s.meta); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", filepath.Join(dir, metaFile), err)
+		}
+		if s.meta.Partitions == nil {
+			s.meta.Partitions = map[string]Partition{}
+		}
+	}
+
+	if err := s.loadIndex(); errors.Is(err, fs.ErrNotExist) {
+		err = s.rebuildIndex()
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) loadIndex() error {
+	f, err := os.Open(filepath.Join(s.dir, indexFile))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if id := strings.TrimSpace(scanner.Text()); id != "" {
+			s.ids[id] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read store index: %w", err)
+	}
+	return nil
+}
+
+// rebuildIndex reads the IDs of every partition file and writes the index
+func (s *Store) rebuildIndex() error {
+	files, err := dataset.Files(filepath.Join(s.dir, partitionsDir))
+	if err != nil {
+		return err
+	}
+	if len(files) > 0 {
+		fmt.Printf("Rebuilding the ID index of %s from %d files...\n", s.dir, len(files))
+	}
+	for _, file := range files {
+		ids, err := dataset.IDs(file)
+		if err != nil {
+			return err
+		}
+		maps.Copy(s.ids, ids)
+	}
+	return s.appendIndex(slices.Collect(maps.Keys(s.ids)))
+}
+
+// appendIndex adds ids to the index file, creating it if needed
+func (s *Store) appendIndex(ids []string) error {
+	f, err := os.OpenFile(filepath.Join(s.dir, indexFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open store index: %w", err)
+	}
+	// A failed append is cut off again, so the index never lists tweets
+	// whose files were not kept
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to open store index: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	for _, id := range ids {
+		w.WriteString(id + "\n")
+	}
+	if err := w.Flush(); err != nil {
+		f.Truncate(info.Size())
+		f.Close()
+		return fmt.Errorf("failed to write store index: %w", err)
+	}
+	return f.Close()
+}
+
+func (s *Store) saveMeta() error {
+	data, err := json.MarshalIndent(s.meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal store: %w", err)
+	}
+	path := filepath.Join(s.dir, metaFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	return nil
+}
+
+// Dir returns the store directory
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Meta returns the store's record counts
+func (s *Store) Meta() Meta {
+	m := s.meta
+	m.Partitions = maps.Clone(s.meta.Partitions)
+	return m
+}
+
+// IDs returns a copy of the IDs of the tweets in the store
+func (s *Store) IDs() map[string]bool {
+	return maps.Clone(s.ids)
+}
+
+// Ingest adds the tweets not yet in the store, writing one file per
+// partition they fall in, and updates the index and store.json. If a
+// partition cannot be written or the index not updated, the files already
+// written are removed again, so retrying the ingest does not store any tweet
+// twice.
+func (s *Store) Ingest(tweets []types.Document, src Source) (Result, error) {
+	var res Result
+	parts := make(map[string][]types.Document)
+	seen := make(map[string]bool)
+	for _, t := range tweets {
+		if t.Id != "" && (s.ids[t.Id] || seen[t.Id]) {
+			res.Duplicates++
+			continue
+		}
+		if t.Id != "" {
+			seen[t.Id] = true
+		}
+		name := PartitionOf(t)
+		parts[name] = append(parts[name], t)
+	}
+	if len(parts) == 0 {
+		return res, nil
+	}
+
+	meta := s.Meta()
+	undo := func(err error) (Result, error) {
+		for _, path := range res.Files {
+			os.Remove(path)
+		}
+		s.meta = meta
+		return Result{Duplicates: res.Duplicates}, err
+	}
+
+	s.meta.Ingests++
+	var added []string
+	for _, name := range slices.Sorted(maps.Keys(parts)) {
+		docs := parts[name]
+		path := filepath.Join(s.dir, partitionsDir, name, fmt.Sprintf("%s-%d.json", src.Run, s.meta.Ingests))
+		if s.key != nil {
+			path += crypt.Extension
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return undo(fmt.Errorf("failed to create partition: %w", err))
+		}
+		f := &dataset.File{Query: src.Query, Trend: src.Trend, SavedQuery: src.SavedQuery, Tweets: docs}
+		res.Files = append(res.Files, path, manifest.Path(path), manifest.CardPath(path))
+		if err := f.Save(path, s.key); err != nil {
+			return undo(err)
+		}
+		_, err := manifest.Write(path, &manifest.Manifest{
+			Tool:       src.Tool,
+			Query:      src.Query,
+			SavedQuery: src.SavedQuery,
+			Trend:      src.Trend,
+			Records:    len(f.Tweets),
+			Pipeline:   append(slices.Clone(src.Pipeline), fmt.Sprintf("store(partition=%s)", name)),
+			Provenance: src.Provenance,
+		})
+		if err != nil {
+			return undo(err)
+		}
+
+		// Save drops denylisted tweets, which are then not in the store
+		// either. Tweets without an ID are stored but cannot be indexed.
+		for _, t := range f.Tweets {
+			if t.Id != "" {
+				added = append(added, t.Id)
+			}
+		}
+		p := s.meta.Partitions[name]
+		p.Records += len(f.Tweets)
+		p.Files++
+		s.meta.Partitions[name] = p
+		s.meta.Records += len(f.Tweets)
+	}
+
+	if err := s.appendIndex(added); err != nil {
+		return undo(err)
+	}
+	for _, id := range added {
+		s.ids[id] = true
+	}
+	res.Added = len(added)
+	s.meta.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	return res, s.saveMeta()
+}
+
+// PartitionOf returns the partition of a tweet: the UTC day it was created
+func PartitionOf(t types.Document) string {
+	created, ok := pipeline.ParseTimestamp(t.Metadata["created_at"])
+	if !ok {
+		return UnknownPartition
+	}
+	return created.UTC().Format(time.DateOnly)
+}
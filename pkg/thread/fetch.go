@@ -0,0 +1,67 @@
+package thread
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grant/sn42/pkg/collect"
+	"github.com/grant/sn42/pkg/compliance"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Fetcher looks up tweets missing from conversations with the getbyid
+// capability
+type Fetcher struct {
+	collect.Lookup
+}
+
+// Fill looks up the tweets the builder is missing and adds those found, for
+// up to rounds rounds, since a fetched parent can itself be a reply to a
+// tweet not yet seen. It returns the number of tweets added and of lookups
+// that found nothing or kept failing.
+func (f *Fetcher) Fill(ctx context.Context, b *Builder, rounds int) (added, failed int) {
+	tried := map[string]bool{}
+	for range rounds {
+		var ids []string
+		for _, id := range b.Missing() {
+			if !tried[id] {
+				tried[id] = true
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 || ctx.Err() != nil {
+			break
+		}
+		fmt.Printf("🔎 Fetching %d missing tweets...\n", len(ids))
+		for _, doc := range f.fetch(ctx, ids) {
+			if doc == nil {
+				failed++
+				continue
+			}
+			b.AddFetched(*doc)
+			added++
+		}
+	}
+	return added, failed
+}
+
+// fetch looks up every ID, returning nil for those not found
+func (f *Fetcher) fetch(ctx context.Context, ids []string) []*types.Document {
+	docs := make([]*types.Document, len(ids))
+	f.Each(ctx, len(ids), func(i int) {
+		docs[i] = f.lookup(ctx, ids[i])
+	})
+	return docs
+}
+
+// lookup fetches one tweet
+func (f *Fetcher) lookup(ctx context.Context, id string) *types.Document {
+	results, err := f.Search(ctx, compliance.ByID(id), compliance.IsUnavailable)
+	switch {
+	case err == nil && len(results) > 0:
+		return &results[0]
+	case err != nil && !compliance.IsUnavailable(err) && ctx.Err() == nil:
+		fmt.Printf("⚠️ Lookup of tweet %s failed: %v\n", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+// Package thread groups collected tweets into the conversations they belong
+// to, for exporting reply threads as ordered dialogue turns.
+package thread
+
+import (
+	"sort"
+	"time"
+
+	"github.com/grant/sn42/pkg/dataset"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// parentFields are the metadata fields that may hold the ID of the tweet a
+// reply answers, in order of preference
+var parentFields = []string{"in_reply_to_status_id", "in_reply_to_tweet_id"}
+
+// Turn is one tweet of a conversation
+type Turn struct {
+	TweetID   string `json:"tweet_id"`
+	Username  string `json:"username,omitempty"`
+	Text      string `json:"text"`
+	CreatedAt string `json:"created_at,omitempty"`
+	ReplyTo   string `json:"reply_to,omitempty"`
+	Fetched   bool   `json:"fetched,omitempty"` // Looked up to fill a gap rather than collected
+
+	at time.Time
+}
+
+// Conversation is the tweets sharing a conversation ID, oldest first
+type Conversation struct {
+	ConversationID string `json:"conversation_id"`
+	Participants   int    `json:"participants"`
+	Complete       bool   `json:"complete"` // Every turn's parent is in the conversation
+	Turns          []Turn `json:"turns"`
+}
+
+// Builder collects tweets and groups them into conversations
+type Builder struct {
+	turns map[string]*Turn
+	convo map[string]string // Tweet ID to conversation ID, where known
+}
+
+// NewBuilder returns an empty Builder
+func NewBuilder() *Builder {
+	return &Builder{turns: map[string]*Turn{}, convo: map[string]string{}}
+}
+
+// Add records a collected tweet. A tweet seen twice keeps its first record.
+func (b *Builder) Add(doc types.Document) {
+	b.add(doc, false)
+}
+
+// AddFetched records a tweet looked up to fill a gap in a conversation
+func (b *Builder) AddFetched(doc types.Document) {
+	b.add(doc, true)
+}
+
+func (b *Builder) add(doc types.Document, fetched bool) {
+	id := dataset.MetadataID(doc, "tweet_id")
+	if id == "" {
+		id = doc.Id
+	}
+	if id == "" || b.turns[id] != nil {
+		return
+	}
+	t := &Turn{TweetID: id, Text: doc.Content, Fetched: fetched}
+	t.Username, _ = doc.Metadata["username"].(string)
+	if s, ok := doc.Metadata["created_at"].(string); ok {
+		if at, err := time.Parse(time.RFC3339, s); err == nil {
+			t.at = at
+			t.CreatedAt = at.UTC().Format(time.RFC3339)
+		}
+	}
+	for _, field := range parentFields {
+		if t.ReplyTo = dataset.MetadataID(doc, field); t.ReplyTo != "" {
+			break
+		}
+	}
+	b.turns[id] = t
+	if c := dataset.MetadataID(doc, "conversation_id"); c != "" {
+		b.convo[id] = c
+	}
+}
+
+// Missing returns the IDs of tweets that replies answer and conversations
+// start from but that were not added, sorted
+func (b *Builder) Missing() []string {
+	missing := map[string]bool{}
+	for id, t := range b.turns {
+		if t.ReplyTo != "" && b.turns[t.ReplyTo] == nil {
+			missing[t.ReplyTo] = true
+		}
+		if c := b.convo[id]; c != "" && b.turns[c] == nil {
+			missing[c] = true
+		}
+	}
+	ids := make([]string, 0, len(missing))
+	for id := range missing {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// conversationID returns the conversation of a tweet: its conversation_id,
+// else that of the nearest ancestor with one, else the oldest ancestor
+func (b *Builder) conversationID(t *Turn) string {
+	seen := map[string]bool{}
+	for {
+		if c := b.convo[t.TweetID]; c != "" {
+			return c
+		}
+		parent := b.turns[t.ReplyTo]
+		if t.ReplyTo == "" || seen[t.ReplyTo] {
+			return t.TweetID
+		}
+		if parent == nil {
+			return t.ReplyTo
+		}
+		seen[t.TweetID] = true
+		t = parent
+	}
+}
+
+// Conversations returns the conversations with at least minTurns tweets,
+// ordered by conversation ID, each with its turns oldest first (ties and
+// tweets without a time by ID)
+func (b *Builder) Conversations(minTurns int) []Conversation {
+	groups := map[string][]Turn{}
+	for _, t := range b.turns {
+		c := b.conversationID(t)
+		groups[c] = append(groups[c], *t)
+	}
+
+	var convos []Conversation
+	for id, turns := range groups {
+		if len(turns) < max(minTurns, 1) {
+			continue
+		}
+		sort.Slice(turns, func(i, j int) bool {
+			ti, tj := turns[i].at, turns[j].at
+			if !ti.Equal(tj) && !ti.IsZero() && !tj.IsZero() {
+				return ti.Before(tj)
+			}
+			return idLess(turns[i].TweetID, turns[j].TweetID)
+		})
+		c := Conversation{ConversationID: id, Complete: true, Turns: turns}
+		users := map[string]bool{}
+		inConvo := map[string]bool{}
+		for _, t := range turns {
+			if t.Username != "" {
+				users[t.Username] = true
+			}
+			inConvo[t.TweetID] = true
+		}
+		for _, t := range turns {
+			if t.ReplyTo != "" && !inConvo[t.ReplyTo] {
+				c.Complete = false
+			}
+		}
+		if !inConvo[id] {
+			c.Complete = false
+		}
+		c.Participants = len(users)
+		convos = append(convos, c)
+	}
+	sort.Slice(convos, func(i, j int) bool { return idLess(convos[i].ConversationID, convos[j].ConversationID) })
+	return convos
+}
+
+// idLess orders numeric tweet IDs numerically, which is also their time order
+func idLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
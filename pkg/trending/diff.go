@@ -0,0 +1,92 @@
+package trending
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Change is one trend that differs between two snapshots. A rank of 0 means
+// the trend is not in that snapshot.
+type Change struct {
+	Name    string `json:"name"`
+	OldRank int    `json:"old_rank,omitempty"`
+	NewRank int    `json:"new_rank,omitempty"`
+}
+
+// Moved returns how many places the trend rose (positive) or fell
+func (c Change) Moved() int {
+	return c.OldRank - c.NewRank
+}
+
+// Diff is how the trends changed from one snapshot to the next
+type Diff struct {
+	From      string   `json:"from"` // captured_at of the older snapshot
+	To        string   `json:"to"`
+	Region    string   `json:"region"`
+	New       []Change `json:"new"`       // In To only, by new rank
+	Dropped   []Change `json:"dropped"`   // In From only, by old rank
+	Moved     []Change `json:"moved"`     // In both at different ranks, by new rank
+	Unchanged int      `json:"unchanged"` // In both at the same rank
+}
+
+// Compare diffs two snapshots. Trends are matched ignoring case, as the
+// same hashtag can trend as #Bitcoin and #bitcoin; the newer spelling wins.
+func Compare(from, to *Snapshot) Diff {
+	d := Diff{From: from.CapturedAt, To: to.CapturedAt, Region: to.Region, New: []Change{}, Dropped: []Change{}, Moved: []Change{}}
+	oldRanks := map[string]Trend{}
+	for _, t := range from.Trends {
+		if _, ok := oldRanks[key(t.Name)]; !ok {
+			oldRanks[key(t.Name)] = t
+		}
+	}
+	seen := map[string]bool{}
+	for _, t := range to.Trends {
+		k := key(t.Name)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		old, ok := oldRanks[k]
+		switch {
+		case !ok:
+			d.New = append(d.New, Change{Name: t.Name, NewRank: t.Rank})
+		case old.Rank != t.Rank:
+			d.Moved = append(d.Moved, Change{Name: t.Name, OldRank: old.Rank, NewRank: t.Rank})
+		default:
+			d.Unchanged++
+		}
+	}
+	for _, t := range from.Trends {
+		k := key(t.Name)
+		if !seen[k] {
+			seen[k] = true
+			d.Dropped = append(d.Dropped, Change{Name: t.Name, OldRank: t.Rank})
+		}
+	}
+	return d
+}
+
+func key(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// List returns the snapshot files of region in dir, oldest first
+func List(dir, region string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trend snapshots: %w", err)
+	}
+	prefix := regionSlug(region) + "_"
+	var paths []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), prefix) && strings.HasSuffix(e.Name(), ".json") {
+			paths = append(paths, filepath.Join(dir, e.Name()))
+		}
+	}
+	// The capture time in the name sorts them
+	sort.Strings(paths)
+	return paths, nil
+}
@@ -0,0 +1,73 @@
+package trending
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Filter rejects trends not worth a query: pure emoji, single characters and
+// trends in scripts the dataset is not collecting
+type Filter struct {
+	// MinLength is the fewest letters and digits a trend must have, so a
+	// trend of only emoji or punctuation is rejected whenever it is set
+	MinLength int
+
+	scripts     []*unicode.RangeTable // Scripts letters may be written in, any if empty
+	scriptNames []string
+}
+
+// NewFilter creates a filter for trends of at least minLength letters and
+// digits written in scripts, a comma-separated list of Unicode script names
+// such as "Latin,Cyrillic" (any script if empty)
+func NewFilter(minLength int, scripts string) (*Filter, error) {
+	if minLength < 0 {
+		return nil, fmt.Errorf("minimum trend length must not be negative, got %d", minLength)
+	}
+	f := &Filter{MinLength: minLength}
+	for _, name := range strings.Split(scripts, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		table, canonical := script(name)
+		if table == nil {
+			return nil, fmt.Errorf("unknown script %q (e.g. Latin, Cyrillic, Arabic, Han, Hiragana)", name)
+		}
+		f.scripts = append(f.scripts, table)
+		f.scriptNames = append(f.scriptNames, canonical)
+	}
+	return f, nil
+}
+
+// script looks up a Unicode script by name, ignoring case
+func script(name string) (*unicode.RangeTable, string) {
+	for _, known := range slices.Sorted(maps.Keys(unicode.Scripts)) {
+		if strings.EqualFold(known, name) {
+			return unicode.Scripts[known], known
+		}
+	}
+	return nil, ""
+}
+
+// Reject returns why trend is not worth collecting, or "" if it is
+func (f *Filter) Reject(trend string) string {
+	length := 0
+	for _, r := range trend {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			continue
+		}
+		length++
+		if unicode.IsLetter(r) && len(f.scripts) > 0 && !unicode.In(r, f.scripts...) {
+			return fmt.Sprintf("not written in %s", strings.Join(f.scriptNames, " or "))
+		}
+	}
+	switch {
+	case f.MinLength > 0 && length == 0:
+		return "no letters or digits"
+	case length < f.MinLength:
+		return fmt.Sprintf("fewer than %d letters and digits", f.MinLength)
+	}
+	return ""
+}
@@ -0,0 +1,64 @@
+package trending
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadList reads the trends to collect from a file: a snapshot saved by
+// fetch-trends (.json), in rank order, or a text file with one trend per
+// line, where blank lines and lines starting with # followed by a space are
+// skipped. A trend repeated with another case is kept once. The trends of a
+// snapshot keep their volumes.
+func LoadList(path string) ([]Trend, error) {
+	var trends []Trend
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		s, err := LoadSnapshot(path)
+		if err != nil {
+			return nil, err
+		}
+		trends = s.Trends
+	} else {
+		var names []string
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open trends file: %w", err)
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && line != "#" && !strings.HasPrefix(line, "# ") {
+				names = append(names, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read trends file: %w", err)
+		}
+		trends = Ranked(names)
+	}
+	trends = Unique(trends)
+	if len(trends) == 0 {
+		return nil, fmt.Errorf("trends file %s is empty", path)
+	}
+	return trends, nil
+}
+
+// Unique returns trends without blank ones and those repeated with another
+// case, in their first order and ranked again
+func Unique(trends []Trend) []Trend {
+	var unique []Trend
+	seen := map[string]bool{}
+	for _, t := range trends {
+		t.Name = strings.TrimSpace(t.Name)
+		if k := key(t.Name); t.Name != "" && !seen[k] {
+			seen[k] = true
+			t.Rank = len(unique) + 1
+			unique = append(unique, t)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,150 @@
+package trending
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultDir is where fetch-trends keeps its snapshots
+const DefaultDir = "data/trends"
+
+// DefaultRegion labels snapshots of the trends the API serves by default.
+// The API takes no region, so this is the location of its accounts.
+const DefaultRegion = "default"
+
+// Trend is one trend in a snapshot
+type Trend struct {
+	Rank   int    `json:"rank"` // 1-based position in the API's list
+	Name   string `json:"name"`
+	Volume int64  `json:"volume,omitempty"` // Tweets about the trend, if the API reports it
+}
+
+// Snapshot is the trends list as fetched at one moment, in the API's order
+type Snapshot struct {
+	CapturedAt string  `json:"captured_at"`
+	Region     string  `json:"region"`
+	RunID      string  `json:"run_id,omitempty"` // Run that captured it
+	Trends     []Trend `json:"trends"`
+}
+
+// NewSnapshot keeps trends, as ranked by the API
+func NewSnapshot(trends []Trend, region, runID string, capturedAt time.Time) *Snapshot {
+	return &Snapshot{
+		CapturedAt: capturedAt.UTC().Format(time.RFC3339),
+		Region:     region,
+		RunID:      runID,
+		Trends:     trends,
+	}
+}
+
+// Ranked ranks names in the order given, without volumes
+func Ranked(names []string) []Trend {
+	trends := make([]Trend, len(names))
+	for i, name := range names {
+		trends[i] = Trend{Rank: i + 1, Name: name}
+	}
+	return trends
+}
+
+// Names returns the trends in rank order
+func (s *Snapshot) Names() []string {
+	return Names(s.Trends)
+}
+
+// Names returns the names of trends, in their order
+func Names(trends []Trend) []string {
+	names := make([]string, len(trends))
+	for i, t := range trends {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// Save writes the snapshot to dir as <region>_<captured_at>.json, so the
+// snapshots of a region sort by time, and returns its path
+func (s *Snapshot) Save(dir string) (string, error) {
+	at, err := time.Parse(time.RFC3339, s.CapturedAt)
+	if err != nil {
+		return "", fmt.Errorf("invalid snapshot time %q: %w", s.CapturedAt, err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create trends directory: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal trends snapshot: %w", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s_%s.json", regionSlug(s.Region), at.Format("20060102T150405Z")))
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to write trends snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to write trends snapshot: %w", err)
+	}
+	return path, nil
+}
+
+// LoadSnapshot reads a snapshot file
+func LoadSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trends snapshot: %w", err)
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse trends snapshot %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Latest returns the most recent snapshot of region in dir and its path. It
+// returns an error wrapping fs.ErrNotExist if there is none.
+func Latest(dir, region string) (*Snapshot, string, error) {
+	paths, err := List(dir, region)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(paths) == 0 {
+		return nil, "", fmt.Errorf("no trend snapshots of region %s in %s: %w", region, dir, fs.ErrNotExist)
+	}
+	path := paths[len(paths)-1]
+	s, err := LoadSnapshot(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return s, path, nil
+}
+
+// Age returns how long before now the snapshot was captured
+func (s *Snapshot) Age(now time.Time) (time.Duration, error) {
+	at, err := time.Parse(time.RFC3339, s.CapturedAt)
+	if err != nil {
+		return 0, fmt.Errorf("invalid snapshot time %q: %w", s.CapturedAt, err)
+	}
+	return now.Sub(at), nil
+}
+
+// regionSlug makes a region safe for a file name
+func regionSlug(region string) string {
+	slug := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '-'
+	}, strings.TrimSpace(region))
+	if slug == "" {
+		return DefaultRegion
+	}
+	return slug
+}
@@ -0,0 +1,118 @@
+// Package trending fetches the current Twitter trends from the gopher API.
+package trending
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gopher-lab/gopher-client/client"
+	"github.com/masa-finance/tee-worker/v2/api/args/twitter"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Fetch fetches trending topics using the gopher client.
+// It submits a GetTrends job via SearchTwitterWithArgsAsync with Type=CapGetTrends,
+// waits for completion, then extracts trend strings from the returned documents.
+func Fetch(c *client.Client) ([]string, error) {
+	trends, err := (&Fetcher{Client: c}).Fetch()
+	if err != nil {
+		return nil, err
+	}
+	return Names(trends), nil
+}
+
+// Fetcher fetches the trends like Fetch, retrying what fails. A job that
+// timed out is waited for again, as it may still finish; after any other
+// failure a new job is submitted, since the API keeps reporting the error of
+// a job that failed.
+type Fetcher struct {
+	Client *client.Client
+
+	// Retries is how many times a failed attempt is retried, waiting
+	// RetryDelay (default 5s) before the first retry and doubling it after
+	// each one
+	Retries    int
+	RetryDelay time.Duration
+}
+
+// Fetch returns the current trends, ranked in the API's order, or the error
+// of the last attempt once the retries are used up
+func (f *Fetcher) Fetch() ([]Trend, error) {
+	delay := cmp.Or(f.RetryDelay, 5*time.Second)
+	var jobID string
+	for attempt := 1; ; attempt++ {
+		trends, err := f.attempt(&jobID)
+		if err == nil {
+			return trends, nil
+		}
+		if attempt > f.Retries {
+			return nil, err
+		}
+		fmt.Printf("⚠️ Fetching trends failed, retrying in %s (%d/%d): %v\n", delay, attempt, f.Retries, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// attempt waits for the job *jobID, submitting a new one first if it is
+// empty, and clears it when the job has to be submitted again
+func (f *Fetcher) attempt(jobID *string) ([]Trend, error) {
+	if *jobID == "" {
+		args := twitter.NewSearchArguments()
+		args.Type = types.CapGetTrends
+
+		resp, err := f.Client.SearchTwitterWithArgsAsync(args)
+		if err != nil {
+			return nil, fmt.Errorf("failed to submit get trends job: %w", err)
+		}
+		if resp.Error != "" {
+			return nil, fmt.Errorf("get trends job error: %s", resp.Error)
+		}
+		if resp.UUID == "" {
+			return nil, fmt.Errorf("get trends job returned no job ID")
+		}
+		*jobID = resp.UUID
+		fmt.Printf("Get trends job submitted, waiting for completion (job ID: %s)...\n", resp.UUID)
+	}
+
+	docs, err := f.Client.WaitForJobCompletion(*jobID)
+	if err != nil {
+		// gopher-client gives up waiting after its timeout with this error
+		if !strings.Contains(err.Error(), "timed out after") {
+			*jobID = ""
+		}
+		return nil, fmt.Errorf("failed to wait for trends job: %w", err)
+	}
+
+	if len(docs) == 0 {
+		*jobID = ""
+		return nil, fmt.Errorf("no trends returned")
+	}
+
+	trends := FromDocs(docs)
+	if len(trends) == 0 {
+		*jobID = ""
+		return nil, fmt.Errorf("no trends returned")
+	}
+	return trends, nil
+}
+
+// FromDocs ranks the trends in the documents of a GetTrends job in their
+// order, with the tweet volume of those that report one
+func FromDocs(docs []types.Document) []Trend {
+	var trends []Trend
+	for _, d := range docs {
+		// tee-indexer getDocsFromTrends uses Id and Content as the trend string
+		s := d.Id
+		if s == "" {
+			s = d.Content
+		}
+		s = strings.TrimSpace(s)
+		if s != "" {
+			trends = append(trends, Trend{Rank: len(trends) + 1, Name: s, Volume: volume(d.Metadata)})
+		}
+	}
+	return trends
+}
@@ -0,0 +1,43 @@
+package trending
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// volumeKeys are the metadata fields a trend's tweet volume may be reported
+// in, the first one set winning
+var volumeKeys = []string{"tweet_volume", "volume", "tweet_count"}
+
+// volume returns the tweet volume in a trend document's metadata, or 0 if it
+// has none
+func volume(meta map[string]any) int64 {
+	for _, key := range volumeKeys {
+		switch v := meta[key].(type) {
+		case float64:
+			return int64(v)
+		case int:
+			return int64(v)
+		case int64:
+			return v
+		case string:
+			if n, err := strconv.ParseInt(strings.ReplaceAll(strings.TrimSpace(v), ",", ""), 10, 64); err == nil {
+				return n
+			}
+		}
+	}
+	return 0
+}
+
+// ByVolume returns the trends with the highest tweet volume first. Trends
+// without a volume follow those with one, and trends with the same volume
+// keep their order, so a list without volumes is returned as it is.
+func ByVolume(trends []Trend) []Trend {
+	sorted := slices.Clone(trends)
+	slices.SortStableFunc(sorted, func(a, b Trend) int {
+		return cmp.Compare(b.Volume, a.Volume)
+	})
+	return sorted
+}
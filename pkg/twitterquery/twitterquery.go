@@ -0,0 +1,249 @@
+// Package twitterquery builds Twitter advanced search query strings.
+//
+//	q, err := twitterquery.New().Exact("bitcoin").MinFaves(100).Lang("en").Build()
+//	// "bitcoin" min_faves:100 lang:en
+package twitterquery
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxLength is the longest query Twitter search accepts
+const MaxLength = 512
+
+var (
+	usernameRegex = regexp.MustCompile(`^[A-Za-z0-9_]{1,15}$`)
+	langRegex     = regexp.MustCompile(`^[a-z]{2,3}$`)
+)
+
+// Builder assembles a query from terms and operators. Methods record the first
+// invalid value, which Build reports.
+type Builder struct {
+	raw             string
+	terms           []string
+	anyOf           [][]string
+	from            string
+	quotedID        int64
+	lang            string
+	minFaves        int
+	excludeRetweets bool
+	since, until    time.Time
+	maxID           int64
+	sinceID         int64
+	err             error
+}
+
+// New returns an empty builder
+func New() *Builder {
+	return &Builder{minFaves: -1}
+}
+
+// Raw starts from an existing query string, e.g. one supplied by the user,
+// which is kept verbatim ahead of the builder's own terms and operators
+func Raw(query string) *Builder {
+	b := New()
+	b.raw = strings.TrimSpace(query)
+	return b
+}
+
+// Keywords adds terms that must all appear. Keywords containing spaces are
+// quoted so they match as phrases.
+func (b *Builder) Keywords(words ...string) *Builder {
+	for _, w := range words {
+		w = strings.TrimSpace(w)
+		if w == "" {
+			continue
+		}
+		if strings.ContainsAny(w, " \t") {
+			b.Exact(w)
+			continue
+		}
+		b.terms = append(b.terms, w)
+	}
+	return b
+}
+
+// Exact adds phrases that must appear exactly; each is quoted
+func (b *Builder) Exact(phrases ...string) *Builder {
+	for _, p := range phrases {
+		if strings.Contains(p, `"`) {
+			b.fail(fmt.Errorf("phrase %q must not contain double quotes", p))
+			continue
+		}
+		if p = strings.TrimSpace(p); p != "" {
+			b.terms = append(b.terms, `"`+p+`"`)
+		}
+	}
+	return b
+}
+
+// AnyOf adds a group of alternatives of which at least one must appear,
+// rendered as (a OR b OR "c d"). Multi-word alternatives are quoted.
+func (b *Builder) AnyOf(words ...string) *Builder {
+	var group []string
+	for _, w := range words {
+		w = strings.TrimSpace(w)
+		switch {
+		case w == "":
+			continue
+		case strings.Contains(w, `"`):
+			b.fail(fmt.Errorf("keyword %q must not contain double quotes", w))
+		case strings.ContainsAny(w, " \t"):
+			group = append(group, `"`+w+`"`)
+		default:
+			group = append(group, w)
+		}
+	}
+	if len(group) > 0 {
+		b.anyOf = append(b.anyOf, group)
+	}
+	return b
+}
+
+// From restricts results to tweets posted by username (with or without @)
+func (b *Builder) From(username string) *Builder {
+	username = strings.TrimPrefix(username, "@")
+	if !usernameRegex.MatchString(username) {
+		b.fail(fmt.Errorf("invalid username %q", username))
+	}
+	b.from = username
+	return b
+}
+
+// QuotedTweet restricts results to tweets quoting the tweet with the given ID
+func (b *Builder) QuotedTweet(id int64) *Builder {
+	if id <= 0 {
+		b.fail(fmt.Errorf("quoted tweet ID must be positive, got %d", id))
+	}
+	b.quotedID = id
+	return b
+}
+
+// Lang restricts results to a language given as an ISO 639-1 code, e.g. "en"
+func (b *Builder) Lang(code string) *Builder {
+	if !langRegex.MatchString(code) {
+		b.fail(fmt.Errorf("invalid language code %q", code))
+	}
+	b.lang = code
+	return b
+}
+
+// MinFaves restricts results to tweets with at least n likes
+func (b *Builder) MinFaves(n int) *Builder {
+	if n < 0 {
+		b.fail(fmt.Errorf("min_faves must not be negative, got %d", n))
+	}
+	b.minFaves = n
+	return b
+}
+
+// ExcludeRetweets drops retweets from the results
+func (b *Builder) ExcludeRetweets() *Builder {
+	b.excludeRetweets = true
+	return b
+}
+
+// Since restricts results to tweets posted on or after the day of t (UTC)
+func (b *Builder) Since(t time.Time) *Builder {
+	b.since = t
+	return b
+}
+
+// Until restricts results to tweets posted before the day of t (UTC)
+func (b *Builder) Until(t time.Time) *Builder {
+	b.until = t
+	return b
+}
+
+// MaxID restricts results to tweets with an ID of at most id, for paging
+// backwards through results
+func (b *Builder) MaxID(id int64) *Builder {
+	if id <= 0 {
+		b.fail(fmt.Errorf("max_id must be positive, got %d", id))
+	}
+	b.maxID = id
+	return b
+}
+
+// SinceID restricts results to tweets with an ID greater than id, for
+// fetching only what was posted after the newest tweet already collected
+func (b *Builder) SinceID(id int64) *Builder {
+	if id <= 0 {
+		b.fail(fmt.Errorf("since_id must be positive, got %d", id))
+	}
+	b.sinceID = id
+	return b
+}
+
+// Build returns the query string, or the first validation error
+func (b *Builder) Build() (string, error) {
+	if b.err != nil {
+		return "", b.err
+	}
+	if !b.since.IsZero() && !b.until.IsZero() && !b.since.Before(b.until) {
+		return "", fmt.Errorf("since (%s) must be before until (%s)", day(b.since), day(b.until))
+	}
+
+	var parts []string
+	if b.raw != "" {
+		parts = append(parts, b.raw)
+	}
+	parts = append(parts, b.terms...)
+	for _, group := range b.anyOf {
+		if len(group) == 1 {
+			parts = append(parts, group[0])
+		} else {
+			parts = append(parts, "("+strings.Join(group, " OR ")+")")
+		}
+	}
+	if b.from != "" {
+		parts = append(parts, "from:"+b.from)
+	}
+	if b.quotedID > 0 {
+		parts = append(parts, "quoted_tweet_id:"+strconv.FormatInt(b.quotedID, 10))
+	}
+	if len(parts) == 0 {
+		return "", fmt.Errorf("query needs at least one keyword, author or quoted tweet")
+	}
+	if b.lang != "" {
+		parts = append(parts, "lang:"+b.lang)
+	}
+	if b.minFaves >= 0 {
+		parts = append(parts, "min_faves:"+strconv.Itoa(b.minFaves))
+	}
+	if !b.since.IsZero() {
+		parts = append(parts, "since:"+day(b.since))
+	}
+	if !b.until.IsZero() {
+		parts = append(parts, "until:"+day(b.until))
+	}
+	if b.excludeRetweets {
+		parts = append(parts, "-filter:retweets")
+	}
+	if b.maxID > 0 {
+		parts = append(parts, "max_id:"+strconv.FormatInt(b.maxID, 10))
+	}
+	if b.sinceID > 0 {
+		parts = append(parts, "since_id:"+strconv.FormatInt(b.sinceID, 10))
+	}
+
+	query := strings.Join(parts, " ")
+	if len(query) > MaxLength {
+		return "", fmt.Errorf("query is %d characters, longer than the %d allowed", len(query), MaxLength)
+	}
+	return query, nil
+}
+
+func (b *Builder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+func day(t time.Time) string {
+	return t.UTC().Format(time.DateOnly)
+}
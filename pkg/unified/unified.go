@@ -0,0 +1,231 @@
+// Package unified maps documents from every source the gopher API serves
+// (Twitter, Reddit, TikTok, web pages) onto one record schema, so datasets
+// mixing platforms can be merged and filtered the same way.
+package unified
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grant/sn42/pkg/expr"
+	"github.com/grant/sn42/pkg/pipeline"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// Record is a document in the unified schema. Fields a source does not have
+// are left empty; metrics a source does not report are nil rather than 0.
+type Record struct {
+	Source    string         `json:"source"`
+	ID        string         `json:"id"`
+	Text      string         `json:"text"`
+	Author    Author         `json:"author"`
+	Timestamp string         `json:"timestamp,omitempty"` // RFC 3339, UTC
+	Lang      string         `json:"lang,omitempty"`
+	URL       string         `json:"url,omitempty"`
+	Metrics   Metrics        `json:"metrics"`
+	Extras    map[string]any `json:"extras,omitempty"` // Source-specific metadata not mapped above
+}
+
+// Author identifies who posted a record
+type Author struct {
+	ID       string `json:"id,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+// Metrics are the engagement counts of a record: likes are likes, upvotes or
+// diggs, shares are retweets or shares, replies are replies or comments
+type Metrics struct {
+	Likes   *int64 `json:"likes,omitempty"`
+	Shares  *int64 `json:"shares,omitempty"`
+	Replies *int64 `json:"replies,omitempty"`
+	Views   *int64 `json:"views,omitempty"`
+}
+
+// Mapping names the metadata fields that hold each unified field for one
+// source, as dotted paths. The first path present wins.
+type Mapping struct {
+	AuthorID  []string
+	Author    []string
+	Timestamp []string
+	Lang      []string
+	URL       []string
+	Likes     []string
+	Shares    []string
+	Replies   []string
+	Views     []string
+	Text      []string // Used when the document content is empty
+}
+
+// Mappings are the field mappings of the known sources
+var Mappings = map[types.Source]Mapping{
+	types.TwitterSource: {
+		AuthorID:  []string{"user_id", "author_id"},
+		Author:    []string{"username"},
+		Timestamp: []string{"created_at"},
+		Lang:      []string{"lang"},
+		URL:       []string{"permanent_url", "url"},
+		Likes:     []string{"likes", "public_metrics.like_count"},
+		Shares:    []string{"retweets", "public_metrics.retweet_count"},
+		Replies:   []string{"replies", "public_metrics.reply_count"},
+		Views:     []string{"views", "public_metrics.impression_count"},
+	},
+	types.RedditSource: {
+		Author:    []string{"username"},
+		Timestamp: []string{"createdAt", "created_at"},
+		URL:       []string{"url"},
+		Likes:     []string{"upVotes"},
+		Replies:   []string{"numberOfComments", "numberOfreplies"},
+		Text:      []string{"body", "title"},
+	},
+	types.TiktokSource: {
+		Author:    []string{"author"},
+		Timestamp: []string{"createTime"},
+		Lang:      []string{"detected_language"},
+		URL:       []string{"url", "original_url"},
+		Likes:     []string{"stats.diggCount"},
+		Shares:    []string{"stats.shareCount"},
+		Replies:   []string{"stats.commentCount"},
+		Views:     []string{"stats.playCount"},
+		Text:      []string{"desc", "transcription_text"},
+	},
+	types.WebSource: {
+		Author:    []string{"metadata.author"},
+		Timestamp: []string{"crawl.loadedTime"},
+		Lang:      []string{"metadata.languageCode"},
+		URL:       []string{"url", "metadata.canonicalUrl"},
+		Text:      []string{"text", "markdown"},
+	},
+}
+
+// FromDocument maps a document onto the unified schema with the mapping of
+// its source. Documents from an unknown source, or without one, are mapped
+// as tweets, which is what every collector here writes. Top-level metadata
+// fields used by the mapping are left out of Extras.
+func FromDocument(doc types.Document) Record {
+	source := doc.Source
+	if source == types.UnknownSource {
+		source = types.TwitterSource
+	}
+	m, ok := Mappings[source]
+	if !ok {
+		m = Mappings[types.TwitterSource]
+	}
+
+	used := map[string]bool{}
+	str := func(paths []string) string {
+		for _, path := range paths {
+			if v, ok := expr.Field(doc, "metadata."+path); ok && v != nil {
+				used[strings.SplitN(path, ".", 2)[0]] = true
+				if s, ok := v.(string); ok {
+					return s
+				}
+				return fmt.Sprint(v)
+			}
+		}
+		return ""
+	}
+	num := func(paths []string) *int64 {
+		for _, path := range paths {
+			v, ok := expr.Field(doc, "metadata."+path)
+			if !ok {
+				continue
+			}
+			if n, ok := toInt(v); ok {
+				used[strings.SplitN(path, ".", 2)[0]] = true
+				return &n
+			}
+		}
+		return nil
+	}
+
+	r := Record{
+		Source: string(source),
+		ID:     doc.Id,
+		Text:   doc.Content,
+		Author: Author{ID: str(m.AuthorID), Username: str(m.Author)},
+		Lang:   str(m.Lang),
+		URL:    str(m.URL),
+		Metrics: Metrics{
+			Likes:   num(m.Likes),
+			Shares:  num(m.Shares),
+			Replies: num(m.Replies),
+			Views:   num(m.Views),
+		},
+	}
+	if r.Text == "" {
+		r.Text = str(m.Text)
+	}
+	if r.URL == "" && source == types.TwitterSource && r.Author.Username != "" && r.ID != "" {
+		r.URL = "https://x.com/" + r.Author.Username + "/status/" + r.ID
+	}
+	for _, path := range m.Timestamp {
+		if v, ok := expr.Field(doc, "metadata."+path); ok {
+			if t, ok := pipeline.ParseTimestamp(v); ok {
+				r.Timestamp = t.UTC().Format(time.RFC3339)
+				used[strings.SplitN(path, ".", 2)[0]] = true
+				break
+			}
+		}
+	}
+
+	for k, v := range doc.Metadata {
+		if !used[k] {
+			if r.Extras == nil {
+				r.Extras = map[string]any{}
+			}
+			r.Extras[k] = v
+		}
+	}
+	return r
+}
+
+// Document returns the record as a document whose metadata holds the unified
+// fields under flat names (author, author_id, timestamp, lang, url, likes,
+// shares, replies, views) next to the extras, so records from every source
+// can be filtered with the same expressions
+func (r Record) Document() types.Document {
+	meta := maps.Clone(r.Extras)
+	if meta == nil {
+		meta = map[string]any{}
+	}
+	set := func(k, v string) {
+		if v != "" {
+			meta[k] = v
+		}
+	}
+	set("author", r.Author.Username)
+	set("author_id", r.Author.ID)
+	set("timestamp", r.Timestamp)
+	set("lang", r.Lang)
+	set("url", r.URL)
+	for k, v := range map[string]*int64{"likes": r.Metrics.Likes, "shares": r.Metrics.Shares, "replies": r.Metrics.Replies, "views": r.Metrics.Views} {
+		if v != nil {
+			meta[k] = float64(*v)
+		}
+	}
+	return types.Document{Id: r.ID, Source: types.Source(r.Source), Content: r.Text, Metadata: meta}
+}
+
+// toInt converts a decoded metric to an integer, whichever number type it
+// was decoded as
+func toInt(v any) (int64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return int64(v), true
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,87 @@
+package vcr
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+
+	"github.com/gopher-lab/gopher-client/client"
+)
+
+// Flags holds the -record and -replay command-line flags shared by the collectors
+type Flags struct {
+	Record string
+	Replay string
+}
+
+// RegisterFlags registers -record and -replay on fs
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	fs.StringVar(&f.Record, "record", "", "Record every API request and response to this cassette (JSON Lines, without the host or token)")
+	fs.StringVar(&f.Replay, "replay", "", "Answer API requests from this cassette instead of the network (no token needed)")
+	return f
+}
+
+// Replaying reports whether -replay is set
+func (f *Flags) Replaying() bool {
+	return f.Replay != ""
+}
+
+// Cassette is the recorder or replayer attached to a client
+type Cassette struct {
+	path     string
+	recorder *Recorder
+	replayer *Replayer
+}
+
+// Attach routes c's requests through the cassette named by the flags. It
+// returns nil when neither flag is set.
+func (f *Flags) Attach(c *client.Client) (*Cassette, error) {
+	if f.Record != "" && f.Replay != "" {
+		return nil, fmt.Errorf("-record and -replay cannot be combined")
+	}
+	if f.Record == "" && f.Replay == "" {
+		return nil, nil
+	}
+	if c.HTTPClient == nil {
+		c.HTTPClient = &http.Client{}
+	}
+	cas := &Cassette{}
+	if f.Record != "" {
+		rec, err := NewRecorder(f.Record, c.HTTPClient.Transport)
+		if err != nil {
+			return nil, err
+		}
+		cas.path, cas.recorder = f.Record, rec
+		c.HTTPClient.Transport = rec
+		fmt.Printf("📼 Recording API requests to %s\n", f.Record)
+		return cas, nil
+	}
+	rep, err := Load(f.Replay)
+	if err != nil {
+		return nil, err
+	}
+	cas.path, cas.replayer = f.Replay, rep
+	c.HTTPClient.Transport = rep
+	fmt.Printf("📼 Replaying %d API interactions from %s\n", len(rep.interactions), f.Replay)
+	return cas, nil
+}
+
+// Close finishes recording, or warns about interactions that were never
+// replayed. A nil Cassette does nothing.
+func (c *Cassette) Close() error {
+	if c == nil {
+		return nil
+	}
+	if c.recorder != nil {
+		if err := c.recorder.Close(); err != nil {
+			return err
+		}
+		fmt.Printf("📼 Recorded %d API interactions to %s\n", c.recorder.Recorded(), c.path)
+		return nil
+	}
+	if n := c.replayer.Unplayed(); n > 0 {
+		fmt.Printf("⚠️ %d interactions in %s were not replayed: the run did not make the requests it recorded\n", n, c.path)
+	}
+	return nil
+}
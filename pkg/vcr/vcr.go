@@ -0,0 +1,237 @@
+// Package vcr records the HTTP interactions of the gopher client into a
+// cassette and replays them later, so a run (or an integration test) can be
+// repeated against exactly the responses the API gave, without a token or
+// network access. Cassettes are JSON Lines, one interaction per line, and
+// are sanitized as they are recorded: only the method, path, request body,
+// status, content type and response body are kept, so the host, the
+// Authorization header and any cookies never reach disk.
+package vcr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Interaction is one recorded request and its response
+type Interaction struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"` // URL path and query, without the host
+	Body        string `json:"body,omitempty"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Response    string `json:"response,omitempty"`
+	Error       string `json:"error,omitempty"` // Transport error instead of a response
+}
+
+func (i Interaction) key() string {
+	return i.Method + " " + i.Path + "\n" + i.Body
+}
+
+// Recorder is an http.RoundTripper appending every interaction to a cassette
+type Recorder struct {
+	base http.RoundTripper
+
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+	n   int
+}
+
+// NewRecorder records the requests sent through base (http.DefaultTransport
+// if nil) to a new cassette at path, replacing any existing one
+func NewRecorder(path string, base http.RoundTripper) (*Recorder, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create cassette directory: %w", err)
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cassette: %w", err)
+	}
+	return &Recorder{base: base, f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	in, err := requestInteraction(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.base.RoundTrip(req)
+	if err != nil {
+		in.Error = err.Error()
+		r.write(in)
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response for cassette: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	in.Status = resp.StatusCode
+	in.ContentType = resp.Header.Get("Content-Type")
+	in.Response = string(body)
+	r.write(in)
+	return resp, nil
+}
+
+// write appends an interaction. A failed write is reported on Close, so
+// recording never fails the request itself.
+func (r *Recorder) write(in Interaction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.enc == nil {
+		return
+	}
+	if err := r.enc.Encode(in); err != nil {
+		fmt.Printf("⚠️ Failed to record %s %s: %v\n", in.Method, in.Path, err)
+		return
+	}
+	r.n++
+}
+
+// Close closes the cassette
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f, r.enc = nil, nil
+	if err != nil {
+		return fmt.Errorf("failed to close cassette: %w", err)
+	}
+	return nil
+}
+
+// Recorded returns the number of interactions recorded
+func (r *Recorder) Recorded() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.n
+}
+
+// Replayer is an http.RoundTripper answering requests from a cassette.
+// Each request gets the first unplayed interaction with the same method,
+// path and body; once those are used up, the last one is repeated, since
+// the client polls job status an unpredictable number of times.
+type Replayer struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	played       []bool
+	last         map[string]int
+}
+
+// ErrNoInteraction is returned for a request the cassette does not contain
+var ErrNoInteraction = errors.New("no recorded interaction")
+
+// Load reads the cassette at path
+func Load(path string) (*Replayer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	p := &Replayer{last: make(map[string]int)}
+	for n, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var in Interaction
+		if err := json.Unmarshal([]byte(line), &in); err != nil {
+			return nil, fmt.Errorf("failed to parse %s line %d: %w", path, n+1, err)
+		}
+		p.interactions = append(p.interactions, in)
+	}
+	p.played = make([]bool, len(p.interactions))
+	return p, nil
+}
+
+func (p *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	want, err := requestInteraction(req)
+	if err != nil {
+		return nil, err
+	}
+	key := want.key()
+
+	p.mu.Lock()
+	found := -1
+	for i, in := range p.interactions {
+		if !p.played[i] && in.key() == key {
+			found = i
+			break
+		}
+	}
+	if found >= 0 {
+		p.played[found] = true
+		p.last[key] = found
+	} else if i, ok := p.last[key]; ok {
+		found = i
+	}
+	p.mu.Unlock()
+
+	if found < 0 {
+		return nil, fmt.Errorf("%w for %s %s", ErrNoInteraction, want.Method, want.Path)
+	}
+	in := p.interactions[found]
+	if in.Error != "" {
+		return nil, errors.New(in.Error)
+	}
+	header := make(http.Header)
+	if in.ContentType != "" {
+		header.Set("Content-Type", in.ContentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+		StatusCode:    in.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(in.Response)),
+		ContentLength: int64(len(in.Response)),
+		Request:       req,
+	}, nil
+}
+
+// Unplayed returns the number of interactions no request has asked for,
+// which means the run took a different path than the recorded one
+func (p *Replayer) Unplayed() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, played := range p.played {
+		if !played {
+			n++
+		}
+	}
+	return n
+}
+
+// requestInteraction captures the sanitized request, restoring its body for
+// sending
+func requestInteraction(req *http.Request) (Interaction, error) {
+	in := Interaction{Method: req.Method, Path: req.URL.RequestURI()}
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return in, fmt.Errorf("failed to read request for cassette: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		in.Body = string(body)
+	}
+	return in, nil
+}
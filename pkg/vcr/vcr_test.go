@@ -0,0 +1,116 @@
+package vcr_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gopher-lab/gopher-client/client"
+	"github.com/grant/sn42/pkg/collect"
+	"github.com/grant/sn42/pkg/vcr"
+	"github.com/masa-finance/tee-worker/v2/api/args/twitter"
+	"github.com/masa-finance/tee-worker/v2/api/types"
+)
+
+// testdata/bitcoin_pages.jsonl was recorded from fetch-tweets with QUERY=bitcoin
+// AMOUNT=150 -preflight=false -adaptive-batch=false -retries 3 against
+// fake-gopher -fail-rate 0.3 -seed 5. It holds three pages: 100 tweets, 11
+// tweets from the last ID of the first page, and the last tweet alone, whose
+// submission was answered with 503 twice before succeeding.
+const (
+	cassette = "testdata/bitcoin_pages.jsonl"
+	query    = "bitcoin"
+	target   = 150
+
+	lastOfPage1 = "1889999999957382373"
+	lastOfPage2 = "1889999999952970048"
+)
+
+// searches records the queries sent through a Searcher
+type searches struct {
+	collect.Searcher
+	queries []string
+}
+
+func (s *searches) SearchTwitterWithArgs(args twitter.SearchArguments) ([]types.Document, error) {
+	s.queries = append(s.queries, args.Query)
+	return s.Searcher.SearchTwitterWithArgs(args)
+}
+
+// replay returns a collector answered from the cassette, and the replayer
+func replay(t *testing.T, retries int) (*collect.Collector, *searches, *vcr.Replayer) {
+	t.Helper()
+	replayer, err := vcr.Load(cassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := client.NewClient("http://replay", "")
+	c.HTTPClient.Transport = replayer
+	s := &searches{Searcher: c}
+	return &collect.Collector{Client: s, Retries: retries, RetryDelay: time.Millisecond}, s, replayer
+}
+
+func TestReplayPaginatesWithMaxID(t *testing.T) {
+	c, s, replayer := replay(t, 2)
+	tweets, err := c.Collect(context.Background(), query, target)
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if len(tweets) != 112 {
+		t.Errorf("expected 112 tweets over three pages, got %d", len(tweets))
+	}
+
+	// The third page is submitted three times: twice answered with 503 and
+	// retried, then accepted
+	want := []string{
+		query,
+		query + " max_id:" + lastOfPage1,
+		query + " max_id:" + lastOfPage2,
+		query + " max_id:" + lastOfPage2,
+		query + " max_id:" + lastOfPage2,
+	}
+	if !slices.Equal(s.queries, want) {
+		t.Errorf("unexpected queries:\n got %q\nwant %q", s.queries, want)
+	}
+	if n := replayer.Unplayed(); n != 0 {
+		t.Errorf("expected every interaction to be replayed, %d were not", n)
+	}
+}
+
+func TestReplayReportsBatchErrorAfterRetries(t *testing.T) {
+	c, s, replayer := replay(t, 1)
+	tweets, err := c.Collect(context.Background(), query, target)
+
+	var be *collect.BatchError
+	if !errors.As(err, &be) {
+		t.Fatalf("expected a BatchError, got %v", err)
+	}
+	if be.Query != query {
+		t.Errorf("expected the failed batch of %q, got %q", query, be.Query)
+	}
+	// The batch resumes from the page that failed, with what was still missing
+	if got := strconv.FormatInt(be.MaxID, 10); got != lastOfPage2 {
+		t.Errorf("expected the failed batch to resume from max_id %s, got %s", lastOfPage2, got)
+	}
+	if len(tweets) != 111 || be.Remaining != target-111 {
+		t.Errorf("expected the 111 tweets of the first two pages and %d remaining, got %d and %d", target-111, len(tweets), be.Remaining)
+	}
+	if len(s.queries) != 4 {
+		t.Errorf("expected the failing page to be tried twice, got %d requests: %q", len(s.queries), s.queries)
+	}
+	// Only the accepted third page is left: its submission, status and result
+	if n := replayer.Unplayed(); n != 3 {
+		t.Errorf("expected the last accepted submission and its job to be unplayed, got %d", n)
+	}
+}
+
+func TestReplayUnknownRequest(t *testing.T) {
+	c, _, _ := replay(t, 0)
+	_, err := c.Collect(context.Background(), "ethereum", target)
+	if !errors.Is(err, vcr.ErrNoInteraction) {
+		t.Fatalf("expected ErrNoInteraction for a query the cassette does not hold, got %v", err)
+	}
+}